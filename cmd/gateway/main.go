@@ -2,22 +2,24 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/dublyo/mcp-gateway/internal/gateway"
+	"github.com/dublyo/mcp-gateway/internal/logging"
 	"github.com/dublyo/mcp-gateway/internal/server"
 )
 
 func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("Starting Dublyo MCP Gateway...")
+	logging.Setup()
+	slog.Info("Starting Dublyo MCP Gateway...")
 
 	// Validate required env
 	if os.Getenv("GATEWAY_TOKEN") == "" {
-		log.Fatal("GATEWAY_TOKEN environment variable is required")
+		slog.Error("GATEWAY_TOKEN environment variable is required")
+		os.Exit(1)
 	}
 
 	// Create gateway
@@ -38,12 +40,13 @@ func main() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
-		log.Println("Shutting down gateway...")
+		slog.Info("Shutting down gateway...")
 		cancel()
 		os.Exit(0)
 	}()
 
 	if err := srv.Start(); err != nil {
-		log.Fatalf("Server error: %v", err)
+		slog.Error("Server error", "err", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+)
+
+// authCache is a bounded LRU of recently verified API keys. Entries are keyed
+// by a seeded hash of the presented key (the raw key is never stored) and
+// remember which connection and stored key hash they matched, so a changed
+// key hash naturally misses.
+type authCache struct {
+	mu      sync.Mutex
+	seed    maphash.Seed
+	max     int
+	ll      *list.List
+	entries map[uint64]*list.Element
+}
+
+type authCacheEntry struct {
+	key     uint64
+	connID  string
+	keyHash string // the stored APIKeyHash/PrevKeyHash this key matched
+}
+
+func newAuthCache(max int) *authCache {
+	return &authCache{
+		seed:    maphash.MakeSeed(),
+		max:     max,
+		ll:      list.New(),
+		entries: make(map[uint64]*list.Element),
+	}
+}
+
+func (c *authCache) hashKey(apiKey string) uint64 {
+	return maphash.String(c.seed, apiKey)
+}
+
+// get returns the stored key hash the presented key previously matched for connID
+func (c *authCache) get(connID, apiKey string) (string, bool) {
+	k := c.hashKey(apiKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[k]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*authCacheEntry)
+	if e.connID != connID {
+		return "", false
+	}
+	c.ll.MoveToFront(el)
+	return e.keyHash, true
+}
+
+func (c *authCache) put(connID, apiKey, keyHash string) {
+	if c.max <= 0 {
+		return
+	}
+	k := c.hashKey(apiKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[k]; ok {
+		e := el.Value.(*authCacheEntry)
+		e.connID = connID
+		e.keyHash = keyHash
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.entries[k] = c.ll.PushFront(&authCacheEntry{key: k, connID: connID, keyHash: keyHash})
+	for c.ll.Len() > c.max {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*authCacheEntry).key)
+	}
+}
+
+// invalidate drops all entries for a connection
+func (c *authCache) invalidate(connID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*authCacheEntry); e.connID == connID {
+			c.ll.Remove(el)
+			delete(c.entries, e.key)
+		}
+		el = next
+	}
+}
+
+// purge drops every entry, e.g. when the pepper changes
+func (c *authCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.entries = make(map[uint64]*list.Element)
+}
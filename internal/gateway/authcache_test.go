@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+// benchGateway serves one connection accepting apiKey, with an auth cache
+// of cacheSize entries
+func benchGateway(b *testing.B, cacheSize int, apiKey string) (*Gateway, *Connection) {
+	b.Helper()
+	b.Setenv("AUTH_CACHE_SIZE", strconv.Itoa(cacheSize))
+	sum := sha256.Sum256([]byte("pepper" + apiKey))
+	g := New()
+	g.ApplyConfig(GatewayConfig{Pepper: "pepper", Connections: []ConnectionConfig{
+		{ID: "conn-1", Slug: "one", Domain: "one.example.com", Profile: "time", Enabled: true, APIKeyHash: hex.EncodeToString(sum[:])},
+	}})
+	return g, g.GetConnection("one.example.com")
+}
+
+func BenchmarkVerifyAPIKey(b *testing.B) {
+	const apiKey = "mcp_0123456789abcdef0123456789abcdef"
+	benchmarks := []struct {
+		name      string
+		cacheSize int
+		key       string
+		want      bool
+	}{
+		{"cached", 1024, apiKey, true},
+		{"uncached", 0, apiKey, true},
+		{"wrong key", 1024, "mcp_wrong", false},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			g, conn := benchGateway(b, bm.cacheSize, apiKey)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if g.VerifyAPIKey(conn, bm.key) != bm.want {
+					b.Fatal("unexpected result")
+				}
+			}
+		})
+	}
+
+	b.Run("cached parallel", func(b *testing.B) {
+		g, conn := benchGateway(b, 1024, apiKey)
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if !g.VerifyAPIKey(conn, apiKey) {
+					b.Fatal("key rejected")
+				}
+			}
+		})
+	})
+}
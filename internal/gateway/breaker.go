@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker short-circuits tool calls for a connection whose backend
+// keeps failing, so callers get a fast error instead of paying the full timeout.
+// It implements mcp.ToolGuard.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int           // consecutive failures before opening
+	window    time.Duration // failures older than this don't count
+	cooldown  time.Duration // how long to stay open before probing
+
+	state    string
+	failures int
+	lastFail time.Time
+	openedAt time.Time
+	probing  bool // a half-open probe is in flight
+}
+
+func NewCircuitBreaker(threshold int, window, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow returns an error if the breaker is open. After the cool-down it lets
+// a single probe call through (half-open).
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		remaining := b.cooldown - time.Since(b.openedAt)
+		if remaining > 0 {
+			return fmt.Errorf("backend unavailable (circuit open, retry in %s)", remaining.Round(time.Second))
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return fmt.Errorf("backend unavailable (circuit half-open, probe in progress)")
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Record reports the outcome of a call that was allowed through
+func (b *CircuitBreaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+		b.openedAt = now
+		b.probing = false
+		return
+	}
+
+	if !b.lastFail.IsZero() && now.Sub(b.lastFail) > b.window {
+		b.failures = 0
+	}
+	b.failures++
+	b.lastFail = now
+
+	if b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = now
+	}
+}
+
+// State returns the current breaker state
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
@@ -4,28 +4,36 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
-	"log"
+	"maps"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/dublyo/mcp-gateway/internal/logging"
 	"github.com/dublyo/mcp-gateway/internal/mcp"
 	"github.com/dublyo/mcp-gateway/internal/profiles"
 )
 
 // ConnectionConfig is a single MCP connection received from the API
 type ConnectionConfig struct {
-	ID             string            `json:"id"`
-	Slug           string            `json:"slug"`
-	Domain         string            `json:"domain"`
-	Profile        string            `json:"profile"`
-	APIKeyHash     string            `json:"apiKeyHash"`
-	PrevKeyHash    string            `json:"prevKeyHash,omitempty"`
-	PrevKeyExpiry  string            `json:"prevKeyExpiry,omitempty"`
-	Enabled        bool              `json:"enabled"`
-	EnvVars        map[string]string `json:"envVars"`
-	RateLimit      int               `json:"rateLimit"`
-	MaxConcurrency int               `json:"maxConcurrency"`
-	CreatedAt      string            `json:"createdAt"`
+	ID                 string            `json:"id"`
+	Slug               string            `json:"slug"`
+	Domain             string            `json:"domain"`
+	Profile            string            `json:"profile"` // profile ID, or comma-separated IDs served together
+	APIKeyHash         string            `json:"apiKeyHash"`
+	PrevKeyHash        string            `json:"prevKeyHash,omitempty"`
+	PrevKeyExpiry      string            `json:"prevKeyExpiry,omitempty"`
+	Enabled            bool              `json:"enabled"`
+	EnvVars            map[string]string `json:"envVars"`
+	RateLimit          int               `json:"rateLimit"`
+	MaxConcurrency     int               `json:"maxConcurrency"`
+	MaxToolConcurrency int               `json:"maxToolConcurrency"` // in-flight tool calls across all sessions
+	CreatedAt          string            `json:"createdAt"`
 }
 
 // GatewayConfig is received from the Dublyo API sync endpoint
@@ -41,11 +49,23 @@ type GatewayConfig struct {
 type Connection struct {
 	Config  ConnectionConfig
 	Handler *mcp.Handler
+	Breaker *CircuitBreaker // nil unless the profile has a backend
+	Limiter *ToolLimiter
+
+	// EnvProblems lists env vars the profile needs but the config lacks
+	EnvProblems []string
+
+	// clientNets are the networks ALLOWED_CLIENT_CIDRS lets reach the
+	// connection; nil allows any client
+	clientNets []*net.IPNet
 
 	// Rate limiting
-	mu          sync.Mutex
-	requests    []time.Time
-	sessions    int32
+	mu       sync.Mutex
+	requests []time.Time
+	sessions int32
+
+	// PrevKeyHash we've already logged a grace-window use for
+	prevKeyLogged string
 }
 
 // Gateway manages all connections and their state
@@ -56,26 +76,118 @@ type Gateway struct {
 	version     int64
 	gatewayID   string
 	serverID    string
+	ready       bool // set once the first config has been applied
+
+	// configured are the last applied config's connections, as the API sent
+	// them, for TraefikConfig
+	configured []ConnectionConfig
 
 	// Metrics
-	metricsMu sync.Mutex
-	metrics   map[string]*Metrics
+	metricsMu     sync.Mutex
+	metrics       map[string]*Metrics
+	latencyWindow int // number of latency samples kept per connection for P95
+
+	authCache *authCache // recently verified API keys
+
+	// Circuit breaker settings applied to new connections
+	breakerThreshold int
+	breakerWindow    time.Duration
+	breakerCooldown  time.Duration
+
+	// onToolsChanged is called with the IDs of connections whose tool list
+	// changed in a config reload
+	onToolsChanged func(connIDs []string)
+
+	// token is the current gateway token: GATEWAY_TOKEN until the API
+	// rotates it through the poller
+	tokenMu sync.RWMutex
+	token   string
 }
 
 type Metrics struct {
-	RequestCount  int64
-	ErrorCount    int64
-	AuthFailures  int64
-	Latencies     []float64 // rolling window for P95
-	ActiveSessions int
+	RequestCount   int64
+	ErrorCount     int64
+	AuthFailures   int64
+	PrevKeyUses    int64     // requests authenticated with the previous (rotated-out) key
+	Latencies      []float64 // rolling window for P95, kept across reports
+	ActiveSessions int       // gauge, maintained by Increment/DecrementSessions
 	LastRequestAt  time.Time
+	Tools          map[string]*ToolMetrics // per-tool breakdown of tools/call requests
+}
+
+// ToolMetrics counts calls to one tool of a connection
+type ToolMetrics struct {
+	CallCount  int64
+	ErrorCount int64
+	Latencies  []float64 // rolling window for P95, kept across reports
 }
 
 func New() *Gateway {
+	latencyWindow := 1000
+	if s := os.Getenv("METRICS_LATENCY_WINDOW"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			latencyWindow = n
+		}
+	}
+
+	breakerThreshold := 5
+	if s := os.Getenv("BREAKER_THRESHOLD"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			breakerThreshold = n
+		}
+	}
+	authCacheSize := 1024
+	if s := os.Getenv("AUTH_CACHE_SIZE"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			authCacheSize = n
+		}
+	}
+
+	breakerWindow := durationEnv("BREAKER_WINDOW", time.Minute)
+	breakerCooldown := durationEnv("BREAKER_COOLDOWN", 30*time.Second)
+
 	return &Gateway{
-		connections: make(map[string]*Connection),
-		metrics:     make(map[string]*Metrics),
+		connections:      make(map[string]*Connection),
+		metrics:          make(map[string]*Metrics),
+		latencyWindow:    latencyWindow,
+		authCache:        newAuthCache(authCacheSize),
+		breakerThreshold: breakerThreshold,
+		breakerWindow:    breakerWindow,
+		breakerCooldown:  breakerCooldown,
+		token:            os.Getenv("GATEWAY_TOKEN"),
+	}
+}
+
+// durationEnv parses a duration env var, returning fallback if unset or invalid
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	if s := os.Getenv(key); s != "" {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			return d
+		}
 	}
+	return fallback
+}
+
+// OnToolsChanged registers fn to be called, on its own goroutine, with the
+// IDs of live connections whose tools changed when a config was applied
+func (g *Gateway) OnToolsChanged(fn func(connIDs []string)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onToolsChanged = fn
+}
+
+// GatewayToken returns the current gateway token
+func (g *Gateway) GatewayToken() string {
+	g.tokenMu.RLock()
+	defer g.tokenMu.RUnlock()
+	return g.token
+}
+
+// SetGatewayToken replaces the gateway token, as the API rotates it
+func (g *Gateway) SetGatewayToken(token string) {
+	g.tokenMu.Lock()
+	defer g.tokenMu.Unlock()
+	g.token = token
 }
 
 // ApplyConfig applies a new config from the API
@@ -83,6 +195,11 @@ func (g *Gateway) ApplyConfig(cfg GatewayConfig) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	var toolsChanged []string
+
+	if g.pepper != cfg.Pepper {
+		g.authCache.purge()
+	}
 	g.pepper = cfg.Pepper
 	g.version = cfg.Version
 	g.gatewayID = cfg.GatewayID
@@ -95,26 +212,66 @@ func (g *Gateway) ApplyConfig(cfg GatewayConfig) {
 			continue
 		}
 
+		// A comma-separated list serves several profiles' tools together
+		profile, err := profiles.Resolve(cc.Profile)
+		if err != nil {
+			logging.Component("gateway").Error("invalid profile, skipping connection",
+				"connection", cc.ID, "slug", cc.Slug, "profile", cc.Profile, "err", err)
+			continue
+		}
+
 		// Reuse existing connection if it exists and profile matches
 		existing := g.connections[cc.Domain]
 		if existing != nil && existing.Config.Profile == cc.Profile {
+			if existing.Config.APIKeyHash != cc.APIKeyHash || existing.Config.PrevKeyHash != cc.PrevKeyHash {
+				g.authCache.invalidate(cc.ID)
+			}
+			// Tools generated from env (e.g. an OpenAPI spec URL) may have changed
+			if _, dynamic := profile.(profiles.DynamicToolsProvider); dynamic && !maps.Equal(existing.Config.EnvVars, cc.EnvVars) {
+				toolsChanged = append(toolsChanged, cc.ID)
+			}
 			existing.Config = cc
-			existing.Handler.UpdateEnvVars(cc.EnvVars)
+			existing.Handler.UpdateEnvVars(g.handlerEnv(cc))
+			existing.Handler.SetToolRecorder(toolRecorder{g: g, connID: cc.ID})
+			if existing.Limiter.Limit() != toolConcurrencyLimit(cc.MaxToolConcurrency) {
+				// In-flight calls release the limiter they acquired, so swapping is safe
+				existing.Limiter = NewToolLimiter(cc.MaxToolConcurrency)
+				existing.Handler.SetToolLimiter(existing.Limiter)
+			}
 			newConns[cc.Domain] = existing
 		} else {
 			// Create new handler
-			profile, ok := profiles.Get(cc.Profile)
-			if !ok {
-				log.Printf("Unknown profile %s for connection %s, skipping", cc.Profile, cc.Slug)
-				continue
+			if existing != nil {
+				// Same connection, new profile: its sessions see a different tool set
+				toolsChanged = append(toolsChanged, cc.ID)
 			}
-			handler := mcp.NewHandler(profile, cc.EnvVars)
+			handler := mcp.NewHandler(profile, g.handlerEnv(cc))
+			// Only a backend that can be down is worth guarding
+			var breaker *CircuitBreaker
+			if profiles.HasBackend(profile) {
+				breaker = NewCircuitBreaker(g.breakerThreshold, g.breakerWindow, g.breakerCooldown)
+				handler.SetToolGuard(breaker)
+			}
+			limiter := NewToolLimiter(cc.MaxToolConcurrency)
+			handler.SetToolLimiter(limiter)
+			handler.SetToolRecorder(toolRecorder{g: g, connID: cc.ID})
 			newConns[cc.Domain] = &Connection{
 				Config:  cc,
 				Handler: handler,
+				Breaker: breaker,
+				Limiter: limiter,
 			}
 		}
 
+		// Flag missing/invalid env up front instead of on the first tool call
+		conn := newConns[cc.Domain]
+		conn.EnvProblems = profiles.ValidateEnv(profile, cc.EnvVars)
+		if len(conn.EnvProblems) > 0 {
+			logging.Component("gateway").Warn("connection is misconfigured",
+				"connection", cc.ID, "slug", cc.Slug, "profile", cc.Profile, "problems", strings.Join(conn.EnvProblems, "; "))
+		}
+		conn.clientNets = clientNets(cc)
+
 		// Ensure metrics entry exists
 		g.metricsMu.Lock()
 		if _, ok := g.metrics[cc.ID]; !ok {
@@ -124,7 +281,49 @@ func (g *Gateway) ApplyConfig(cfg GatewayConfig) {
 	}
 
 	g.connections = newConns
-	log.Printf("Config applied: version=%d, connections=%d", cfg.Version, len(newConns))
+	g.configured = cfg.Connections
+	g.ready = true
+	logging.Component("gateway").Info("config applied", "version", cfg.Version, "connections", len(newConns))
+
+	if len(toolsChanged) > 0 && g.onToolsChanged != nil {
+		go g.onToolsChanged(toolsChanged)
+	}
+}
+
+// handlerEnv returns the env a connection's tools run with: its configured
+// env plus the connection identity, which the diagnostics profile reports,
+// and its effective limits, which initialize reports
+func (g *Gateway) handlerEnv(cc ConnectionConfig) map[string]string {
+	env := make(map[string]string, len(cc.EnvVars)+9)
+	for k, v := range cc.EnvVars {
+		env[k] = v
+	}
+	env[profiles.EnvConnectionID] = cc.ID
+	env[profiles.EnvConnectionSlug] = cc.Slug
+	env[profiles.EnvConnectionDomain] = cc.Domain
+	env[profiles.EnvConnectionProfile] = cc.Profile
+	env[profiles.EnvServerID] = g.serverID
+	env[profiles.EnvGatewayID] = g.gatewayID
+	env[profiles.EnvRateLimit] = strconv.Itoa(rateLimit(cc.RateLimit))
+	env[profiles.EnvMaxSessions] = strconv.Itoa(sessionLimit(cc.MaxConcurrency))
+	env[profiles.EnvMaxToolConcurrency] = strconv.Itoa(toolConcurrencyLimit(cc.MaxToolConcurrency))
+	return env
+}
+
+// rateLimit applies the default to a configured RateLimit (requests per minute)
+func rateLimit(limit int) int {
+	if limit <= 0 {
+		return 60
+	}
+	return limit
+}
+
+// sessionLimit applies the default to a configured MaxConcurrency (open sessions)
+func sessionLimit(limit int) int {
+	if limit <= 0 {
+		return 10
+	}
+	return limit
 }
 
 // GetConnection returns the connection for the given domain
@@ -141,8 +340,27 @@ func (g *Gateway) Version() int64 {
 	return g.version
 }
 
+// Readiness reports whether a config has been applied yet, along with
+// the current config version and number of live connections
+func (g *Gateway) Readiness() (ready bool, version int64, connections int) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.ready, g.version, len(g.connections)
+}
+
 // VerifyAPIKey checks if the given API key is valid for the connection
 func (g *Gateway) VerifyAPIKey(conn *Connection, apiKey string) bool {
+	// Fast path: key was verified recently and the stored hash it matched is still current
+	if matched, ok := g.authCache.get(conn.Config.ID, apiKey); ok {
+		if matched == conn.Config.APIKeyHash {
+			return true
+		}
+		if matched == conn.Config.PrevKeyHash && prevKeyActive(conn) {
+			g.recordPrevKeyUse(conn)
+			return true
+		}
+	}
+
 	g.mu.RLock()
 	pepper := g.pepper
 	g.mu.RUnlock()
@@ -153,28 +371,80 @@ func (g *Gateway) VerifyAPIKey(conn *Connection, apiKey string) bool {
 
 	// Check primary key
 	if subtle.ConstantTimeCompare([]byte(computed), []byte(conn.Config.APIKeyHash)) == 1 {
+		g.authCache.put(conn.Config.ID, apiKey, conn.Config.APIKeyHash)
 		return true
 	}
 
 	// Check previous key during rotation grace period
-	if conn.Config.PrevKeyHash != "" && conn.Config.PrevKeyExpiry != "" {
-		expiry, err := time.Parse(time.RFC3339, conn.Config.PrevKeyExpiry)
-		if err == nil && time.Now().Before(expiry) {
-			if subtle.ConstantTimeCompare([]byte(computed), []byte(conn.Config.PrevKeyHash)) == 1 {
-				return true
-			}
+	if prevKeyActive(conn) {
+		if subtle.ConstantTimeCompare([]byte(computed), []byte(conn.Config.PrevKeyHash)) == 1 {
+			g.authCache.put(conn.Config.ID, apiKey, conn.Config.PrevKeyHash)
+			g.recordPrevKeyUse(conn)
+			return true
 		}
 	}
 
 	return false
 }
 
+// recordPrevKeyUse counts a request authenticated with the previous key and
+// logs the first such use after each rotation
+func (g *Gateway) recordPrevKeyUse(conn *Connection) {
+	conn.mu.Lock()
+	first := conn.prevKeyLogged != conn.Config.PrevKeyHash
+	conn.prevKeyLogged = conn.Config.PrevKeyHash
+	conn.mu.Unlock()
+	if first {
+		logging.Component("auth").Info("connection authenticated with previous API key",
+			"connection", conn.Config.ID, "slug", conn.Config.Slug, "grace_until", conn.Config.PrevKeyExpiry)
+	}
+
+	g.metricsMu.Lock()
+	g.metricsFor(conn.Config.ID).PrevKeyUses++
+	g.metricsMu.Unlock()
+}
+
+// prevKeyActive reports whether the previous key is still within its rotation grace period
+func prevKeyActive(conn *Connection) bool {
+	if conn.Config.PrevKeyHash == "" || conn.Config.PrevKeyExpiry == "" {
+		return false
+	}
+	expiry, err := time.Parse(time.RFC3339, conn.Config.PrevKeyExpiry)
+	return err == nil && time.Now().Before(expiry)
+}
+
+// clientNets parses a connection's ALLOWED_CLIENT_CIDRS. An invalid list
+// denies every client rather than silently allowing them all.
+func clientNets(cc ConnectionConfig) []*net.IPNet {
+	list := cc.EnvVars["ALLOWED_CLIENT_CIDRS"]
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	nets, err := profiles.ParseCIDRList(list)
+	if err != nil || len(nets) == 0 {
+		logging.Component("gateway").Warn("invalid ALLOWED_CLIENT_CIDRS, denying all clients",
+			"connection", cc.ID, "slug", cc.Slug, "err", err)
+		return []*net.IPNet{}
+	}
+	return nets
+}
+
+// ClientAllowed reports whether a client at ip may use the connection
+func (g *Gateway) ClientAllowed(conn *Connection, ip net.IP) bool {
+	if conn.clientNets == nil {
+		return true
+	}
+	for _, n := range conn.clientNets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // CheckRateLimit returns true if the request is within rate limits
 func (g *Gateway) CheckRateLimit(conn *Connection) bool {
-	limit := conn.Config.RateLimit
-	if limit <= 0 {
-		limit = 60
-	}
+	limit := rateLimit(conn.Config.RateLimit)
 
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
@@ -201,10 +471,7 @@ func (g *Gateway) CheckRateLimit(conn *Connection) bool {
 
 // CheckConcurrency returns true if under the concurrency limit
 func (g *Gateway) CheckConcurrency(conn *Connection) bool {
-	limit := conn.Config.MaxConcurrency
-	if limit <= 0 {
-		limit = 10
-	}
+	limit := sessionLimit(conn.Config.MaxConcurrency)
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
 	return int(conn.sessions) < limit
@@ -215,6 +482,10 @@ func (g *Gateway) IncrementSessions(conn *Connection) {
 	conn.mu.Lock()
 	conn.sessions++
 	conn.mu.Unlock()
+
+	g.metricsMu.Lock()
+	g.metricsFor(conn.Config.ID).ActiveSessions++
+	g.metricsMu.Unlock()
 }
 
 // DecrementSessions decrements the active session count
@@ -224,43 +495,92 @@ func (g *Gateway) DecrementSessions(conn *Connection) {
 		conn.sessions--
 	}
 	conn.mu.Unlock()
-}
 
-// RecordRequest records a request metric
-func (g *Gateway) RecordRequest(connID string, latencyMs float64, isError bool) {
 	g.metricsMu.Lock()
-	defer g.metricsMu.Unlock()
+	if m := g.metricsFor(conn.Config.ID); m.ActiveSessions > 0 {
+		m.ActiveSessions--
+	}
+	g.metricsMu.Unlock()
+}
 
+// metricsFor returns the metrics entry for a connection, creating it if needed.
+// Caller must hold metricsMu.
+func (g *Gateway) metricsFor(connID string) *Metrics {
 	m, ok := g.metrics[connID]
 	if !ok {
 		m = &Metrics{}
 		g.metrics[connID] = m
 	}
+	return m
+}
+
+// RecordRequest records a request metric
+func (g *Gateway) RecordRequest(connID string, latencyMs float64, isError bool) {
+	g.metricsMu.Lock()
+	defer g.metricsMu.Unlock()
 
+	m := g.metricsFor(connID)
 	m.RequestCount++
 	m.LastRequestAt = time.Now()
 	if isError {
 		m.ErrorCount++
 	}
 
-	// Rolling latency window (keep last 100)
+	// Rolling latency window (keep last latencyWindow samples)
 	m.Latencies = append(m.Latencies, latencyMs)
-	if len(m.Latencies) > 100 {
-		m.Latencies = m.Latencies[len(m.Latencies)-100:]
+	if len(m.Latencies) > g.latencyWindow {
+		m.Latencies = m.Latencies[len(m.Latencies)-g.latencyWindow:]
 	}
 }
 
-// RecordAuthFailure records an auth failure
-func (g *Gateway) RecordAuthFailure(connID string) {
+// maxTrackedTools caps the distinct tool names kept per connection
+const maxTrackedTools = 200
+
+// RecordToolCall records a tools/call request in the connection's per-tool metrics
+func (g *Gateway) RecordToolCall(connID, tool string, latencyMs float64, isError bool) {
 	g.metricsMu.Lock()
 	defer g.metricsMu.Unlock()
 
-	m, ok := g.metrics[connID]
+	m := g.metricsFor(connID)
+	if m.Tools == nil {
+		m.Tools = make(map[string]*ToolMetrics)
+	}
+	t, ok := m.Tools[tool]
+	if !ok && len(m.Tools) >= maxTrackedTools {
+		// Tool names come from clients, so don't let them grow the map forever
+		tool = "(other)"
+		t, ok = m.Tools[tool]
+	}
 	if !ok {
-		m = &Metrics{}
-		g.metrics[connID] = m
+		t = &ToolMetrics{}
+		m.Tools[tool] = t
+	}
+	t.CallCount++
+	if isError {
+		t.ErrorCount++
 	}
-	m.AuthFailures++
+	t.Latencies = append(t.Latencies, latencyMs)
+	if len(t.Latencies) > g.latencyWindow {
+		t.Latencies = t.Latencies[len(t.Latencies)-g.latencyWindow:]
+	}
+}
+
+// toolRecorder feeds a connection's tool calls into the gateway metrics
+type toolRecorder struct {
+	g      *Gateway
+	connID string
+}
+
+func (r toolRecorder) RecordTool(tool string, latencyMs float64, isError bool) {
+	r.g.RecordToolCall(r.connID, tool, latencyMs, isError)
+}
+
+// RecordAuthFailure records an auth failure
+func (g *Gateway) RecordAuthFailure(connID string) {
+	g.metricsMu.Lock()
+	defer g.metricsMu.Unlock()
+
+	g.metricsFor(connID).AuthFailures++
 }
 
 // MetricsReport is what we send to the API
@@ -269,75 +589,120 @@ type MetricsReport struct {
 	RequestCount   int64   `json:"requestCount"`
 	ErrorCount     int64   `json:"errorCount"`
 	AuthFailures   int64   `json:"authFailures"`
+	PrevKeyUses    int64   `json:"prevKeyUses"`
 	P95LatencyMs   float64 `json:"p95LatencyMs"`
 	ActiveSessions int     `json:"activeSessions"`
+	BreakerState   string  `json:"breakerState,omitempty"`
 	LastRequestAt  string  `json:"lastRequestAt,omitempty"`
+
+	Tools map[string]ToolMetricsReport `json:"tools,omitempty"`
 }
 
-// CollectAndResetMetrics returns current metrics and resets delta counters
-func (g *Gateway) CollectAndResetMetrics() []MetricsReport {
-	g.metricsMu.Lock()
-	defer g.metricsMu.Unlock()
+// ToolMetricsReport is the per-tool part of a MetricsReport
+type ToolMetricsReport struct {
+	CallCount    int64   `json:"callCount"`
+	ErrorCount   int64   `json:"errorCount"`
+	P95LatencyMs float64 `json:"p95LatencyMs"`
+}
 
+// CollectMetrics returns the metrics accumulated since the last report the
+// API acknowledged. Counters keep running until AckMetrics subtracts what was
+// reported, so a failed report's counts roll into the next one.
+func (g *Gateway) CollectMetrics() []MetricsReport {
+	// Snapshot breaker states once rather than scanning per metric
 	g.mu.RLock()
-	defer g.mu.RUnlock()
+	breakerStates := make(map[string]string, len(g.connections))
+	for _, conn := range g.connections {
+		if conn.Breaker != nil {
+			breakerStates[conn.Config.ID] = conn.Breaker.State()
+		}
+	}
+	g.mu.RUnlock()
+
+	g.metricsMu.Lock()
+	defer g.metricsMu.Unlock()
 
 	var reports []MetricsReport
 	for connID, m := range g.metrics {
-		if m.RequestCount == 0 && m.ErrorCount == 0 && m.AuthFailures == 0 {
+		if m.RequestCount == 0 && m.ErrorCount == 0 && m.AuthFailures == 0 && m.PrevKeyUses == 0 && m.ActiveSessions == 0 &&
+			breakerStates[connID] != BreakerOpen {
 			continue
 		}
 
-		// Calculate P95
-		p95 := float64(0)
-		if len(m.Latencies) > 0 {
-			sorted := make([]float64, len(m.Latencies))
-			copy(sorted, m.Latencies)
-			// Simple sort for P95
-			for i := range sorted {
-				for j := i + 1; j < len(sorted); j++ {
-					if sorted[i] > sorted[j] {
-						sorted[i], sorted[j] = sorted[j], sorted[i]
-					}
-				}
-			}
-			idx := int(float64(len(sorted)) * 0.95)
-			if idx >= len(sorted) {
-				idx = len(sorted) - 1
-			}
-			p95 = sorted[idx]
-		}
-
-		// Get active session count from connection
-		activeSessions := 0
-		for _, conn := range g.connections {
-			if conn.Config.ID == connID {
-				conn.mu.Lock()
-				activeSessions = int(conn.sessions)
-				conn.mu.Unlock()
-				break
-			}
-		}
+		p95 := percentile(m.Latencies, 95)
 
 		report := MetricsReport{
 			ConnectionID:   connID,
 			RequestCount:   m.RequestCount,
 			ErrorCount:     m.ErrorCount,
 			AuthFailures:   m.AuthFailures,
+			PrevKeyUses:    m.PrevKeyUses,
 			P95LatencyMs:   p95,
-			ActiveSessions: activeSessions,
+			ActiveSessions: m.ActiveSessions,
+			BreakerState:   breakerStates[connID],
 		}
 		if !m.LastRequestAt.IsZero() {
 			report.LastRequestAt = m.LastRequestAt.Format(time.RFC3339)
 		}
+		for name, t := range m.Tools {
+			if t.CallCount == 0 {
+				continue
+			}
+			if report.Tools == nil {
+				report.Tools = make(map[string]ToolMetricsReport)
+			}
+			report.Tools[name] = ToolMetricsReport{
+				CallCount:    t.CallCount,
+				ErrorCount:   t.ErrorCount,
+				P95LatencyMs: percentile(t.Latencies, 95),
+			}
+		}
 		reports = append(reports, report)
-
-		// Reset deltas
-		m.RequestCount = 0
-		m.ErrorCount = 0
-		m.AuthFailures = 0
-		m.Latencies = m.Latencies[:0]
 	}
 
 	return reports
 }
+
+// AckMetrics subtracts reports the API accepted from the counters. Calls and
+// requests recorded since CollectMetrics stay for the next report
+// (ActiveSessions is a gauge and is not touched).
+func (g *Gateway) AckMetrics(reports []MetricsReport) {
+	g.metricsMu.Lock()
+	defer g.metricsMu.Unlock()
+
+	for _, r := range reports {
+		m, ok := g.metrics[r.ConnectionID]
+		if !ok {
+			continue
+		}
+		m.RequestCount = max(m.RequestCount-r.RequestCount, 0)
+		m.ErrorCount = max(m.ErrorCount-r.ErrorCount, 0)
+		m.AuthFailures = max(m.AuthFailures-r.AuthFailures, 0)
+		m.PrevKeyUses = max(m.PrevKeyUses-r.PrevKeyUses, 0)
+		for name, tr := range r.Tools {
+			if t, ok := m.Tools[name]; ok {
+				t.CallCount = max(t.CallCount-tr.CallCount, 0)
+				t.ErrorCount = max(t.ErrorCount-tr.ErrorCount, 0)
+			}
+		}
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of samples
+func percentile(samples []float64, p float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	oneToHundred := make([]float64, 100)
+	for i := range oneToHundred {
+		oneToHundred[i] = float64(100 - i)
+	}
+	tests := []struct {
+		name    string
+		samples []float64
+		p       float64
+		want    float64
+	}{
+		{"empty", nil, 95, 0},
+		{"single", []float64{7}, 95, 7},
+		{"small window takes the max", []float64{5, 1, 3, 2, 4}, 95, 5},
+		{"twenty samples", []float64{20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, 95, 19},
+		{"1..100", oneToHundred, 95, 95},
+		{"median", oneToHundred, 50, 50},
+		{"minimum", oneToHundred, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.samples, tt.p); got != tt.want {
+				t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+
+	if oneToHundred[0] != 100 {
+		t.Error("percentile sorted its input")
+	}
+}
+
+func TestLatencyWindow(t *testing.T) {
+	t.Setenv("METRICS_LATENCY_WINDOW", "20")
+	g := New()
+
+	// Slow requests followed by a full window of fast ones
+	for i := 0; i < 10; i++ {
+		g.RecordRequest("conn-1", 1000, false)
+	}
+	for i := 0; i < 20; i++ {
+		g.RecordRequest("conn-1", 10, false)
+	}
+	reports := g.CollectMetrics()
+	if len(reports) != 1 || reports[0].P95LatencyMs != 10 {
+		t.Fatalf("reports = %+v, want P95 10 once the slow requests left the window", reports)
+	}
+	g.AckMetrics(reports)
+
+	// The window is kept across reports, so one sample doesn't make the P95
+	g.RecordRequest("conn-1", 1000, false)
+	reports = g.CollectMetrics()
+	if len(reports) != 1 || reports[0].RequestCount != 1 || reports[0].P95LatencyMs != 10 {
+		t.Errorf("reports = %+v, want 1 request with P95 10", reports)
+	}
+}
+
+func TestActiveSessionsGauge(t *testing.T) {
+	g := New()
+	g.ApplyConfig(GatewayConfig{Connections: []ConnectionConfig{
+		{ID: "conn-1", Slug: "one", Domain: "one.example.com", Profile: "time", Enabled: true},
+	}})
+	conn := g.GetConnection("one.example.com")
+	if conn == nil {
+		t.Fatal("connection not configured")
+	}
+
+	steps := []struct {
+		name  string
+		delta int // sessions opened (>0) or closed (<0) before reporting
+		want  int
+	}{
+		{"none", 0, 0},
+		{"two opened", 2, 2},
+		{"unchanged across a report", 0, 2},
+		{"one closed", -1, 1},
+		{"all closed", -1, 0},
+		{"extra close", -1, 0},
+		{"reopened", 1, 1},
+	}
+	for _, st := range steps {
+		for i := 0; i < st.delta; i++ {
+			g.IncrementSessions(conn)
+		}
+		for i := 0; i > st.delta; i-- {
+			g.DecrementSessions(conn)
+		}
+		reports := g.CollectMetrics()
+		got := 0
+		for _, r := range reports {
+			if r.ConnectionID == "conn-1" {
+				got = r.ActiveSessions
+			}
+		}
+		if got != st.want {
+			t.Errorf("%s: ActiveSessions = %d, want %d", st.name, got, st.want)
+		}
+		g.AckMetrics(reports)
+	}
+}
+
+func TestClientAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs string
+		ip    string
+		want  bool
+	}{
+		{"unset allows all", "", "203.0.113.9", true},
+		{"blank allows all", "  ", "203.0.113.9", true},
+		{"in range", "10.0.0.0/8, 192.168.1.5", "10.1.2.3", true},
+		{"single address", "10.0.0.0/8, 192.168.1.5", "192.168.1.5", true},
+		{"out of range", "10.0.0.0/8, 192.168.1.5", "192.168.1.6", false},
+		{"IPv6", "2001:db8::/32", "2001:db8::1", true},
+		{"IPv4 against IPv6 list", "2001:db8::/32", "10.1.2.3", false},
+		{"unknown address", "10.0.0.0/8", "", false},
+		{"invalid list denies all", "10.0.0.0/8, not-a-cidr", "10.1.2.3", false},
+		{"only separators denies all", ",,", "10.1.2.3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New()
+			g.ApplyConfig(GatewayConfig{Connections: []ConnectionConfig{{
+				ID: "conn-1", Slug: "one", Domain: "one.example.com", Profile: "time", Enabled: true,
+				EnvVars: map[string]string{"ALLOWED_CLIENT_CIDRS": tt.cidrs},
+			}}})
+			conn := g.GetConnection("one.example.com")
+			if conn == nil {
+				t.Fatal("connection not configured")
+			}
+			if got := g.ClientAllowed(conn, net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("ClientAllowed(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
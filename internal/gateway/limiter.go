@@ -0,0 +1,42 @@
+package gateway
+
+import "fmt"
+
+// ToolLimiter bounds how many tool calls a connection runs at once, so one
+// client can't flood a database or remote host with parallel requests.
+// It implements mcp.ToolLimiter.
+type ToolLimiter struct {
+	slots chan struct{}
+}
+
+func NewToolLimiter(limit int) *ToolLimiter {
+	return &ToolLimiter{slots: make(chan struct{}, toolConcurrencyLimit(limit))}
+}
+
+// toolConcurrencyLimit applies the default to a configured MaxToolConcurrency
+func toolConcurrencyLimit(limit int) int {
+	if limit <= 0 {
+		return 10
+	}
+	return limit
+}
+
+// Acquire takes a slot without waiting, returning an error if all are in use
+func (l *ToolLimiter) Acquire() error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+		return fmt.Errorf("connection too busy (%d tool calls already running), retry shortly", cap(l.slots))
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (l *ToolLimiter) Release() {
+	<-l.slots
+}
+
+// Limit returns the number of concurrent calls allowed
+func (l *ToolLimiter) Limit() int {
+	return cap(l.slots)
+}
@@ -0,0 +1,41 @@
+package gateway
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToolLimiter(t *testing.T) {
+	tests := []struct {
+		configured int
+		want       int
+	}{
+		{0, 10},
+		{-1, 10},
+		{1, 1},
+		{3, 3},
+	}
+	for _, tt := range tests {
+		l := NewToolLimiter(tt.configured)
+		if l.Limit() != tt.want {
+			t.Errorf("NewToolLimiter(%d).Limit() = %d, want %d", tt.configured, l.Limit(), tt.want)
+		}
+
+		// N concurrent calls run, the N+1th is turned away
+		for i := 0; i < tt.want; i++ {
+			if err := l.Acquire(); err != nil {
+				t.Fatalf("limit %d: call %d rejected: %v", tt.want, i+1, err)
+			}
+		}
+		err := l.Acquire()
+		if err == nil || !strings.Contains(err.Error(), "too busy") {
+			t.Fatalf("limit %d: call %d: err = %v, want too busy", tt.want, tt.want+1, err)
+		}
+
+		// A finished call frees its slot
+		l.Release()
+		if err := l.Acquire(); err != nil {
+			t.Errorf("limit %d: call after a release rejected: %v", tt.want, err)
+		}
+	}
+}
@@ -6,22 +6,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
+
+	"github.com/dublyo/mcp-gateway/internal/logging"
 )
 
 // Poller handles config sync and metrics reporting to the Dublyo API
 type Poller struct {
 	gateway      *Gateway
 	apiURL       string
-	token        string
 	syncInterval time.Duration
 	httpClient   *http.Client
 	failures     int
 	traefikDir   string
+	log          *slog.Logger
 }
 
 func NewPoller(gw *Gateway) *Poller {
@@ -45,9 +47,9 @@ func NewPoller(gw *Gateway) *Poller {
 	return &Poller{
 		gateway:      gw,
 		apiURL:       apiURL,
-		token:        os.Getenv("GATEWAY_TOKEN"),
 		syncInterval: syncInterval,
 		traefikDir:   traefikDir,
+		log:          logging.Component("poller"),
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second,
 		},
@@ -85,18 +87,18 @@ func (p *Poller) syncConfig() {
 	url := fmt.Sprintf("%s/internal/gateway/sync", p.apiURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Printf("[poller] sync request error: %v", err)
+		p.log.Error("sync request error", "err", err)
 		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Authorization", "Bearer "+p.gateway.GatewayToken())
 	req.Header.Set("X-Config-Version", strconv.FormatInt(p.gateway.Version(), 10))
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		p.failures++
 		if p.failures >= 5 {
-			log.Printf("[poller] sync failed %d consecutive times", p.failures)
+			p.log.Warn("sync failed repeatedly", "failures", p.failures, "err", err)
 		}
 		return
 	}
@@ -104,8 +106,8 @@ func (p *Poller) syncConfig() {
 
 	// Check for token refresh
 	if newToken := resp.Header.Get("X-Gateway-Token"); newToken != "" {
-		p.token = newToken
-		log.Printf("[poller] gateway token refreshed")
+		p.gateway.SetGatewayToken(newToken)
+		p.log.Info("gateway token refreshed")
 	}
 
 	switch resp.StatusCode {
@@ -115,19 +117,19 @@ func (p *Poller) syncConfig() {
 	case http.StatusOK:
 		p.failures = 0
 	case http.StatusUnauthorized, http.StatusForbidden:
-		log.Printf("[poller] auth failed (status %d) — token may be revoked", resp.StatusCode)
+		p.log.Error("auth failed, token may be revoked", "status", resp.StatusCode)
 		p.failures++
 		return
 	default:
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("[poller] sync unexpected status %d: %s", resp.StatusCode, string(body))
+		p.log.Warn("sync unexpected status", "status", resp.StatusCode, "body", string(body))
 		p.failures++
 		return
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("[poller] read body error: %v", err)
+		p.log.Error("read body error", "err", err)
 		return
 	}
 
@@ -136,12 +138,12 @@ func (p *Poller) syncConfig() {
 		Data    GatewayConfig `json:"data"`
 	}
 	if err := json.Unmarshal(body, &apiResp); err != nil {
-		log.Printf("[poller] decode error: %v", err)
+		p.log.Error("decode error", "err", err)
 		return
 	}
 
 	if !apiResp.Success {
-		log.Printf("[poller] API returned success=false")
+		p.log.Warn("API returned success=false")
 		return
 	}
 
@@ -151,13 +153,16 @@ func (p *Poller) syncConfig() {
 	// Generate Traefik dynamic config (optional — skip if dir is empty or not configured)
 	if p.traefikDir != "" {
 		if err := GenerateTraefikConfig(p.traefikDir, apiResp.Data.Connections); err != nil {
-			log.Printf("[poller] traefik config generation failed: %v", err)
+			p.log.Error("traefik config generation failed", "err", err)
 		}
 	}
 }
 
+// reportMetrics sends the metrics collected since the last accepted report.
+// Counts are only cleared once the API accepts them; after a failure they
+// are sent again, with whatever accumulated since, on the next tick.
 func (p *Poller) reportMetrics() {
-	reports := p.gateway.CollectAndResetMetrics()
+	reports := p.gateway.CollectMetrics()
 	if len(reports) == 0 {
 		return
 	}
@@ -176,17 +181,19 @@ func (p *Poller) reportMetrics() {
 		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Authorization", "Bearer "+p.gateway.GatewayToken())
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		log.Printf("[poller] metrics report failed: %v", err)
+		p.log.Warn("metrics report failed, retrying next interval", "err", err)
 		return
 	}
 	resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		log.Printf("[poller] metrics report status %d", resp.StatusCode)
+	if resp.StatusCode >= 300 {
+		p.log.Warn("metrics report rejected, retrying next interval", "status", resp.StatusCode)
+		return
 	}
+	p.gateway.AckMetrics(reports)
 }
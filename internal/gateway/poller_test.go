@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestPollerRotatesGatewayToken(t *testing.T) {
+	t.Setenv("GATEWAY_TOKEN", "old-token")
+	var gotAuth string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("X-Gateway-Token", "new-token")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer api.Close()
+	t.Setenv("DUBLYO_API_URL", api.URL)
+	t.Setenv("TRAEFIK_DYNAMIC_DIR", "")
+
+	gw := New()
+	p := NewPoller(gw)
+	if !gw.VerifyGatewayToken("old-token") {
+		t.Fatal("GATEWAY_TOKEN not accepted before rotation")
+	}
+
+	p.syncConfig()
+	if gotAuth != "Bearer old-token" {
+		t.Errorf("sync sent %q", gotAuth)
+	}
+
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"new-token", true},
+		{"old-token", false},
+		{"", false},
+		{"new-token ", false},
+	}
+	for _, tt := range tests {
+		if got := gw.VerifyGatewayToken(tt.token); got != tt.want {
+			t.Errorf("VerifyGatewayToken(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+
+	p.syncConfig()
+	if gotAuth != "Bearer new-token" {
+		t.Errorf("sync after rotation sent %q", gotAuth)
+	}
+}
+
+func TestReportMetricsRetries(t *testing.T) {
+	g := New()
+	var mu sync.Mutex // guards the step's settings and sent, shared with the API
+	var status, during int
+	var sent []int64 // request counts the API received, accepted or not
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var payload struct {
+			Metrics []MetricsReport `json:"metrics"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || len(payload.Metrics) != 1 {
+			t.Errorf("payload %+v, %v", payload, err)
+			return
+		}
+		sent = append(sent, payload.Metrics[0].RequestCount)
+		for i := 0; i < during; i++ {
+			g.RecordRequest("conn-1", 10, false)
+		}
+		if status == 0 {
+			// Drop the connection without answering
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	t.Setenv("DUBLYO_API_URL", srv.URL)
+	p := NewPoller(g)
+
+	steps := []struct {
+		name     string
+		requests int
+		status   int   // 0 drops the connection
+		wantSent int64 // -1 when nothing should be sent
+		during   int   // requests recorded while the report is in flight
+	}{
+		{"rejected", 3, http.StatusInternalServerError, 3, 0},
+		{"connection lost", 1, 0, 4, 0},
+		{"accepted with the backlog", 2, http.StatusOK, 6, 0},
+		{"nothing new", 0, http.StatusOK, -1, 0},
+		{"only new requests", 1, http.StatusAccepted, 1, 0},
+		{"redirect isn't acceptance", 1, http.StatusMultipleChoices, 1, 0},
+		{"retried", 0, http.StatusOK, 1, 0},
+		{"requests during an accepted report", 1, http.StatusOK, 1, 2},
+		{"kept for the next report", 0, http.StatusOK, 2, 0},
+	}
+	for _, st := range steps {
+		for i := 0; i < st.requests; i++ {
+			g.RecordRequest("conn-1", 10, false)
+		}
+		mu.Lock()
+		sent, status, during = nil, st.status, st.during
+		mu.Unlock()
+		p.reportMetrics()
+		mu.Lock()
+		switch {
+		case st.wantSent < 0 && len(sent) != 0:
+			t.Errorf("%s: sent %v, want nothing", st.name, sent)
+		case st.wantSent >= 0 && (len(sent) != 1 || sent[0] != st.wantSent):
+			t.Errorf("%s: sent %v, want one report of %d requests", st.name, sent, st.wantSent)
+		}
+		mu.Unlock()
+	}
+}
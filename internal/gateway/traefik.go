@@ -1,11 +1,13 @@
 package gateway
 
 import (
+	"crypto/subtle"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/dublyo/mcp-gateway/internal/logging"
+
 	"gopkg.in/yaml.v3"
 )
 
@@ -20,10 +22,10 @@ type TraefikHTTP struct {
 }
 
 type TraefikRouter struct {
-	Rule        string     `yaml:"rule"`
-	Service     string     `yaml:"service"`
-	EntryPoints []string   `yaml:"entryPoints"`
-	TLS        *TraefikTLS `yaml:"tls,omitempty"`
+	Rule        string      `yaml:"rule"`
+	Service     string      `yaml:"service"`
+	EntryPoints []string    `yaml:"entryPoints"`
+	TLS         *TraefikTLS `yaml:"tls,omitempty"`
 }
 
 type TraefikTLS struct {
@@ -42,8 +44,9 @@ type TraefikServer struct {
 	URL string `yaml:"url"`
 }
 
-// GenerateTraefikConfig writes the Traefik dynamic config file
-func GenerateTraefikConfig(dir string, connections []ConnectionConfig) error {
+// BuildTraefikConfig returns the Traefik dynamic config routing the enabled
+// connections' domains to the gateway
+func BuildTraefikConfig(connections []ConnectionConfig) TraefikDynamic {
 	cfg := TraefikDynamic{
 		HTTP: TraefikHTTP{
 			Routers: make(map[string]TraefikRouter),
@@ -69,25 +72,54 @@ func GenerateTraefikConfig(dir string, connections []ConnectionConfig) error {
 			TLS:         &TraefikTLS{CertResolver: "letsencrypt"},
 		}
 	}
+	return cfg
+}
 
+// renderTraefikConfig returns the contents of the dynamic config file
+func renderTraefikConfig(cfg TraefikDynamic) ([]byte, error) {
 	data, err := yaml.Marshal(cfg)
 	if err != nil {
-		return fmt.Errorf("marshal traefik config: %w", err)
+		return nil, fmt.Errorf("marshal traefik config: %w", err)
 	}
 
 	// Write with header comment
 	header := "# Auto-generated by mcp-gateway on config sync — DO NOT EDIT\n\n"
-	content := header + string(data)
+	return append([]byte(header), data...), nil
+}
+
+// GenerateTraefikConfig writes the Traefik dynamic config file
+func GenerateTraefikConfig(dir string, connections []ConnectionConfig) error {
+	cfg := BuildTraefikConfig(connections)
+	content, err := renderTraefikConfig(cfg)
+	if err != nil {
+		return err
+	}
 
 	path := filepath.Join(dir, "mcp-connections.yml")
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("create traefik dir: %w", err)
 	}
 
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	if err := os.WriteFile(path, content, 0644); err != nil {
 		return fmt.Errorf("write traefik config: %w", err)
 	}
 
-	log.Printf("[traefik] wrote dynamic config: %d routers", len(cfg.HTTP.Routers))
+	logging.Component("traefik").Info("wrote dynamic config", "routers", len(cfg.HTTP.Routers))
 	return nil
 }
+
+// TraefikConfig renders the dynamic config the last applied config's
+// connections produce, exactly as a sync writes it, without writing it
+func (g *Gateway) TraefikConfig() ([]byte, error) {
+	g.mu.RLock()
+	connections := g.configured
+	g.mu.RUnlock()
+	return renderTraefikConfig(BuildTraefikConfig(connections))
+}
+
+// VerifyGatewayToken reports whether token is the gateway's current token,
+// which operator endpoints require
+func (g *Gateway) VerifyGatewayToken(token string) bool {
+	want := g.GatewayToken()
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
@@ -0,0 +1,70 @@
+// Package logging sets up the gateway's leveled logger, a log/slog logger
+// configured by LOG_LEVEL and LOG_FORMAT, and carries per-request loggers in
+// contexts so code handling a request logs with its connection and request ID.
+package logging
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup installs the default slog logger: text (key=value pairs, the
+// default) or JSON lines, at LOG_LEVEL (debug, info, warn or error; default
+// info). Output of the standard log package goes through it at info level.
+func Setup() {
+	level := slog.LevelInfo
+	invalidLevel := ""
+	switch v := strings.ToLower(os.Getenv("LOG_LEVEL")); v {
+	case "", "info":
+	case "debug":
+		level = slog.LevelDebug
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		invalidLevel = v
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+	log.SetFlags(0) // slog adds the time
+
+	if invalidLevel != "" {
+		slog.Warn("invalid LOG_LEVEL, using info", "value", invalidLevel)
+	}
+	if format != "" && format != "json" && format != "text" {
+		slog.Warn("invalid LOG_FORMAT, using text", "value", format)
+	}
+}
+
+// Component returns the default logger tagged with the part of the gateway
+// logging
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying logger, for FromContext
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger ctx carries, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,25 @@
+package mcp
+
+import "fmt"
+
+// withDeprecation adds a note to a tool call's result that it was made by
+// alias, a deprecated old name of tool. The content and meta are copied
+// first, since a successful result's are shared with the cache.
+func withDeprecation(resp *JSONRPCResponse, alias, tool string) {
+	result, ok := resp.Result.(ToolCallResult)
+	if !ok {
+		return
+	}
+	note := fmt.Sprintf("Note: tool '%s' has been renamed to '%s'; the old name is deprecated and may be removed", alias, tool)
+	content := make([]ContentBlock, 0, len(result.Content)+1)
+	content = append(content, result.Content...)
+	result.Content = append(content, ContentBlock{Type: "text", Text: "\n\n" + note})
+
+	meta := make(map[string]interface{}, len(result.Meta)+1)
+	for k, v := range result.Meta {
+		meta[k] = v
+	}
+	meta["deprecated"] = map[string]interface{}{"alias": alias, "tool": tool}
+	result.Meta = meta
+	resp.Result = result
+}
@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+// aliasProfile is a fakeProfile whose tool-1 and legacy are old names of
+// tool-0; it still lists tool-1. tool-0's results may be cached.
+type aliasProfile struct{ fakeProfile }
+
+func (p *aliasProfile) CacheableTools() []string { return []string{"tool-0"} }
+
+func (p *aliasProfile) Aliases() map[string]string {
+	return map[string]string{"tool-1": "tool-0", "legacy": "tool-0", "tool-2": "tool-2", "unset": ""}
+}
+
+func TestAliases(t *testing.T) {
+	var ran []string
+	profile := &aliasProfile{fakeProfile{n: 3, call: func(name string, args map[string]interface{}) (string, error) {
+		ran = append(ran, name)
+		return "ran " + name, nil
+	}}}
+	h := NewHandler(profile, nil)
+
+	resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	var listed []string
+	for _, tool := range resp.Result.(ToolsListResult).Tools {
+		listed = append(listed, tool.Name)
+	}
+	if want := []string{"tool-0", "tool-2"}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("tools/list = %v, want %v", listed, want)
+	}
+
+	tests := []struct {
+		name     string
+		wantRun  string
+		wantNote bool
+	}{
+		{"legacy", "tool-0", true},
+		{"tool-1", "tool-0", true},
+		{"tool-0", "tool-0", false},
+		{"tool-2", "tool-2", false}, // an alias of itself is no alias
+		{"unset", "unset", false},   // nor is one without a target
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran = nil
+			resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tt.name + `","arguments":{}}}`))
+			result := resp.Result.(ToolCallResult)
+			if !reflect.DeepEqual(ran, []string{tt.wantRun}) {
+				t.Errorf("ran %v, want %s", ran, tt.wantRun)
+			}
+			if result.Content[0].Text != "ran "+tt.wantRun {
+				t.Errorf("content %q", result.Content[0].Text)
+			}
+			deprecated, _ := result.Meta["deprecated"].(map[string]interface{})
+			note := len(result.Content) == 2 && strings.Contains(result.Content[1].Text, "renamed to '"+tt.wantRun+"'")
+			if note != tt.wantNote || (deprecated != nil) != tt.wantNote {
+				t.Errorf("deprecation note %v, _meta.deprecated %v; want %v", note, deprecated, tt.wantNote)
+			}
+			if tt.wantNote && (deprecated["alias"] != tt.name || deprecated["tool"] != tt.wantRun) {
+				t.Errorf("_meta.deprecated = %v", deprecated)
+			}
+		})
+	}
+}
+
+func TestAliasCachedResult(t *testing.T) {
+	var ran []string
+	profile := &aliasProfile{fakeProfile{n: 1, call: func(name string, args map[string]interface{}) (string, error) {
+		ran = append(ran, name)
+		return "ran " + name, nil
+	}}}
+	h := NewHandler(profile, map[string]string{"TOOL_CACHE_TTL_SECONDS": "60"})
+
+	// The result is cached under the current name, and the note isn't
+	for i, name := range []string{"legacy", "tool-0", "legacy"} {
+		resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + name + `","arguments":{}}}`))
+		result := resp.Result.(ToolCallResult)
+		if note := len(result.Content) > 1; note != (name == "legacy") {
+			t.Errorf("call %d (%s): content %+v", i+1, name, result.Content)
+		}
+	}
+	if len(ran) != 1 {
+		t.Errorf("tool ran %d times, want once", len(ran))
+	}
+}
+
+func TestMathCalculateAlias(t *testing.T) {
+	h := NewHandler(&profiles.MathProfile{}, nil)
+	calls := []struct {
+		name     string
+		wantNote bool
+	}{
+		{"calculate", true},
+		{"evaluate_expression", false},
+		{"calculate", true},
+	}
+	for _, c := range calls {
+		resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + c.name + `","arguments":{"expression":"1+2"}}}`))
+		out, _ := json.Marshal(resp.Result)
+		result := resp.Result.(ToolCallResult)
+		if result.IsError || result.Content[0].Text != "1+2 = 3" {
+			t.Fatalf("%s: %s", c.name, out)
+		}
+		if note := len(result.Content) > 1; note != c.wantNote {
+			t.Errorf("%s: deprecation note %v, want %v: %s", c.name, note, c.wantNote, out)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"container/list"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultCacheSize is the number of results kept unless TOOL_CACHE_SIZE is set
+const defaultCacheSize = 256
+
+// resultCache keeps recent results of the tools a profile marks cacheable,
+// keyed by tool and arguments, evicting the least recently used beyond its
+// size. It is off unless the connection sets TOOL_CACHE_TTL_SECONDS.
+type resultCache struct {
+	mu      sync.Mutex
+	order   *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	content []ContentBlock
+	meta    map[string]interface{}
+	stored  time.Time
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// cacheSettings returns the connection's cache TTL, zero if caching is off,
+// and the number of entries to keep
+func cacheSettings(env map[string]string) (time.Duration, int) {
+	ttl := 0
+	if n, err := strconv.Atoi(env["TOOL_CACHE_TTL_SECONDS"]); err == nil && n > 0 {
+		ttl = n
+	}
+	size := defaultCacheSize
+	if n, err := strconv.Atoi(env["TOOL_CACHE_SIZE"]); err == nil && n > 0 {
+		size = n
+	}
+	return time.Duration(ttl) * time.Second, size
+}
+
+// cacheKey identifies a call by tool and arguments. encoding/json writes
+// map keys sorted, so equal arguments give equal keys whatever their order.
+func cacheKey(tool string, args map[string]interface{}) (string, bool) {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return "", false
+	}
+	return tool + "\x00" + string(raw), true
+}
+
+// get returns the entry for key if it is younger than ttl
+func (c *resultCache) get(key string, ttl time.Duration) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Since(entry.stored) >= ttl {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+// put stores a result, evicting the least recently used entries over size
+func (c *resultCache) put(key string, content []ContentBlock, meta map[string]interface{}, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &cacheEntry{key: key, content: content, meta: meta, stored: time.Now()}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+	} else {
+		c.entries[key] = c.order.PushFront(entry)
+	}
+	for c.order.Len() > size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// clear drops every entry, e.g. when the connection's config changes
+func (c *resultCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+}
@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/dublyo/mcp-gateway/internal/logging"
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+// defaultArgPrefix starts the env vars holding a connection's default tool
+// arguments: DEFAULT_<TOOL>_<ARG> for one tool, DEFAULT_<ARG> for every tool
+// that takes ARG (e.g. DEFAULT_SCHEMA, DEFAULT_QUERY_MAX_ROWS)
+const defaultArgPrefix = "DEFAULT_"
+
+// withDefaultArgs returns args with the connection's defaults added for the
+// arguments the client left out. Arguments the client passed always win, and
+// only arguments the tool's schema declares are filled in, converted to the
+// declared type.
+func (h *Handler) withDefaultArgs(tool string, args map[string]interface{}) map[string]interface{} {
+	env := h.envVars
+	hasDefaults := false
+	for key := range env {
+		if strings.HasPrefix(key, defaultArgPrefix) {
+			hasDefaults = true
+			break
+		}
+	}
+	if !hasDefaults {
+		return args
+	}
+
+	tools, err := profiles.ToolsFor(h.profile, env)
+	if err != nil {
+		return args
+	}
+	var props map[string]interface{}
+	for _, t := range tools {
+		if t.Name == tool {
+			props, _ = t.InputSchema["properties"].(map[string]interface{})
+			break
+		}
+	}
+
+	var merged map[string]interface{}
+	for arg, schema := range props {
+		if _, given := args[arg]; given {
+			continue
+		}
+		raw, ok := env[defaultArgPrefix+strings.ToUpper(tool+"_"+arg)]
+		if !ok {
+			raw, ok = env[defaultArgPrefix+strings.ToUpper(arg)]
+		}
+		if !ok || raw == "" {
+			continue
+		}
+		typ, _ := schema.(map[string]interface{})["type"].(string)
+		value, err := defaultArgValue(typ, raw)
+		if err != nil {
+			logging.Component("mcp").Warn("ignoring default argument", "profile", h.profile.ID(), "tool", tool, "arg", arg, "err", err)
+			continue
+		}
+		if merged == nil {
+			merged = make(map[string]interface{}, len(args)+1)
+			for k, v := range args {
+				merged[k] = v
+			}
+		}
+		merged[arg] = value
+	}
+	if merged == nil {
+		return args
+	}
+	return merged
+}
+
+// defaultArgValue converts an env var's text to the JSON type a tool
+// argument declares, as the client would have sent it
+func defaultArgValue(typ, raw string) (interface{}, error) {
+	switch typ {
+	case "integer", "number":
+		return strconv.ParseFloat(raw, 64)
+	case "boolean":
+		return strconv.ParseBool(raw)
+	case "array":
+		if strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			var list []interface{}
+			err := json.Unmarshal([]byte(raw), &list)
+			return list, err
+		}
+		var list []interface{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	case "object":
+		var obj map[string]interface{}
+		err := json.Unmarshal([]byte(raw), &obj)
+		return obj, err
+	}
+	return raw, nil
+}
@@ -1,29 +1,112 @@
 package mcp
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/dublyo/mcp-gateway/internal/logging"
 	"github.com/dublyo/mcp-gateway/internal/profiles"
 )
 
 // Handler processes MCP JSON-RPC messages for a specific profile
 type Handler struct {
-	profile profiles.Profile
-	envVars map[string]string
+	profile  profiles.Profile
+	envVars  map[string]string
+	guard    ToolGuard
+	limiter  ToolLimiter
+	recorder ToolRecorder
+	cache    *resultCache
+
+	// logLevel is the index in profiles.LogLevels of the least severe log
+	// entry forwarded to the client, set with logging/setLevel
+	logLevel atomic.Int32
+}
+
+// ToolGuard gates tool execution, e.g. a circuit breaker for a failing backend
+type ToolGuard interface {
+	// Allow returns an error if the call should be rejected without running
+	Allow() error
+	// Record reports the outcome of a call that was allowed
+	Record(success bool)
+}
+
+// ToolRecorder receives the outcome of every tool call, e.g. for per-tool metrics
+type ToolRecorder interface {
+	RecordTool(tool string, latencyMs float64, isError bool)
+}
+
+// ToolLimiter bounds the number of tool calls running at once
+type ToolLimiter interface {
+	// Acquire reserves a slot, returning an error if none is free
+	Acquire() error
+	// Release frees a slot reserved by Acquire
+	Release()
 }
 
 func NewHandler(profile profiles.Profile, envVars map[string]string) *Handler {
-	return &Handler{profile: profile, envVars: envVars}
+	h := &Handler{profile: profile, envVars: envVars, cache: newResultCache()}
+	h.logLevel.Store(int32(logLevelIndex(defaultLogLevel)))
+	return h
 }
 
 // UpdateEnvVars updates the environment variables without recreating the handler
 func (h *Handler) UpdateEnvVars(envVars map[string]string) {
+	// Cached results may come from a backend or settings that changed
+	if !maps.Equal(h.envVars, envVars) {
+		h.cache.clear()
+	}
 	h.envVars = envVars
 }
 
+// SetToolGuard installs a guard consulted before every tool call
+func (h *Handler) SetToolGuard(guard ToolGuard) {
+	h.guard = guard
+}
+
+// SetToolRecorder installs a recorder told about every tool call
+func (h *Handler) SetToolRecorder(recorder ToolRecorder) {
+	h.recorder = recorder
+}
+
+// SetToolLimiter installs a limiter on concurrent tool calls
+func (h *Handler) SetToolLimiter(limiter ToolLimiter) {
+	h.limiter = limiter
+}
+
 // HandleMessage processes a JSON-RPC request and returns a response
 func (h *Handler) HandleMessage(raw []byte) *JSONRPCResponse {
+	return h.HandleMessageNotify(raw, nil)
+}
+
+// HandleMessageNotify is HandleMessage for transports that can deliver
+// notifications while a request runs; tool log entries are passed to notify.
+// notify may be nil.
+func (h *Handler) HandleMessageNotify(raw []byte, notify func(JSONRPCNotification)) *JSONRPCResponse {
+	return h.HandleMessageContext(context.Background(), raw, notify)
+}
+
+// HandleMessageContext is HandleMessageNotify with a context carrying the
+// request's correlation ID (see WithRequestID), which is added to error
+// responses as data.requestId
+func (h *Handler) HandleMessageContext(ctx context.Context, raw []byte, notify func(JSONRPCNotification)) *JSONRPCResponse {
+	resp := h.dispatch(ctx, raw, notify)
+	if id := RequestID(ctx); id != "" && resp != nil && resp.Error != nil && resp.Error.Data == nil {
+		resp.Error.Data = map[string]interface{}{"requestId": id}
+	}
+	return resp
+}
+
+func (h *Handler) dispatch(ctx context.Context, raw []byte, notify func(JSONRPCNotification)) (resp *JSONRPCResponse) {
 	var req JSONRPCRequest
 	if err := json.Unmarshal(raw, &req); err != nil {
 		return &JSONRPCResponse{
@@ -32,6 +115,20 @@ func (h *Handler) HandleMessage(raw []byte) *JSONRPCResponse {
 		}
 	}
 
+	// A panic in a profile (tools/list, completions, …) must fail only this
+	// request, not take down the gateway. Tool calls run in the watchdog's
+	// goroutine, which recovers on its own.
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger(ctx).Error("panic handling request", "method", req.Method, "panic", r, "stack", string(debug.Stack()))
+			resp = &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error:   &JSONRPCError{Code: InternalError, Message: "Internal error"},
+			}
+		}
+	}()
+
 	if req.JSONRPC != "2.0" {
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
@@ -51,7 +148,11 @@ func (h *Handler) HandleMessage(raw []byte) *JSONRPCResponse {
 	case "tools/list":
 		return h.handleToolsList(req)
 	case "tools/call":
-		return h.handleToolsCall(req)
+		return h.handleToolsCall(ctx, req, notify)
+	case "logging/setLevel":
+		return h.handleSetLevel(req)
+	case "completion/complete":
+		return h.handleComplete(req)
 	case "notifications/cancelled":
 		return nil
 	default:
@@ -63,30 +164,117 @@ func (h *Handler) HandleMessage(raw []byte) *JSONRPCResponse {
 	}
 }
 
+// negotiateProtocolVersion picks the version to answer an initialize with:
+// the client's own if supported, otherwise the newest one we support that is
+// older than the client's. ok is false if the client only speaks revisions
+// older than any we support.
+func negotiateProtocolVersion(requested string) (version string, ok bool) {
+	if requested == "" {
+		return ProtocolVersion, true
+	}
+	for i := len(SupportedProtocolVersions) - 1; i >= 0; i-- {
+		if v := SupportedProtocolVersions[i]; v <= requested {
+			return v, true
+		}
+	}
+	return "", false
+}
+
 func (h *Handler) handleInitialize(req JSONRPCRequest) *JSONRPCResponse {
-	// MCP spec: server responds with its supported version, client decides compatibility.
-	// Never reject — just negotiate by returning our version.
+	paramsBytes, _ := json.Marshal(req.Params)
+	var params InitializeParams
+	json.Unmarshal(paramsBytes, &params)
+
+	// MCP spec: echo the client's version if we support it, otherwise offer
+	// one we do and let the client decide whether it can downgrade
+	version, ok := negotiateProtocolVersion(params.ProtocolVersion)
+	if !ok {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &JSONRPCError{
+				Code:    InvalidParams,
+				Message: "Unsupported protocol version",
+				Data: map[string]interface{}{
+					"supported": SupportedProtocolVersions,
+					"requested": params.ProtocolVersion,
+				},
+			},
+		}
+	}
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
 		Result: InitializeResult{
-			ProtocolVersion: ProtocolVersion,
+			ProtocolVersion: version,
 			Capabilities: Capabilities{
-				Tools: &ToolsCapability{},
+				Tools:       &ToolsCapability{ListChanged: true},
+				Logging:     &LoggingCapability{},
+				Completions: &CompletionsCapability{},
 			},
 			ServerInfo: ServerInfo{
 				Name:    "dublyo-mcp-gateway",
 				Version: "1.0.0",
 			},
+			Meta: map[string]interface{}{"limits": h.connectionLimits()},
 		},
 	}
 }
 
+// toolsPageSize is the number of tools returned per tools/list page. Profiles
+// with fewer tools are listed in full on the first page.
+const toolsPageSize = 100
+
 func (h *Handler) handleToolsList(req JSONRPCRequest) *JSONRPCResponse {
-	tools := h.profile.Tools()
-	defs := make([]ToolDef, len(tools))
+	var params ToolsListParams
+	if req.Params != nil {
+		paramsBytes, _ := json.Marshal(req.Params)
+		json.Unmarshal(paramsBytes, &params)
+	}
+	start := 0
+	if params.Cursor != "" {
+		var ok bool
+		if start, ok = decodeToolsCursor(params.Cursor); !ok {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error:   &JSONRPCError{Code: InvalidParams, Message: "Invalid cursor"},
+			}
+		}
+	}
+
+	tools, err := profiles.ToolsFor(h.profile, h.envVars)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error:   &JSONRPCError{Code: InternalError, Message: fmt.Sprintf("Failed to list tools: %s", err)},
+		}
+	}
+	// Old names are only accepted by tools/call; the list shows current ones
+	current := tools[:0:0]
+	for _, t := range tools {
+		if !profiles.IsAlias(h.profile, t.Name) {
+			current = append(current, t)
+		}
+	}
+	tools = current
+	// Profiles return tools in a stable order, so an offset identifies a page
+	if start > len(tools) {
+		start = len(tools)
+	}
+	end := start + toolsPageSize
+	result := ToolsListResult{}
+	if end < len(tools) {
+		result.NextCursor = encodeToolsCursor(end)
+	} else {
+		end = len(tools)
+	}
+
+	tools = profiles.WithDryRunArg(h.profile, tools[start:end])
+	result.Tools = make([]ToolDef, len(tools))
 	for i, t := range tools {
-		defs[i] = ToolDef{
+		result.Tools[i] = ToolDef{
 			Name:        t.Name,
 			Description: t.Description,
 			InputSchema: t.InputSchema,
@@ -95,11 +283,64 @@ func (h *Handler) handleToolsList(req JSONRPCRequest) *JSONRPCResponse {
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
-		Result:  ToolsListResult{Tools: defs},
+		Result:  result,
+	}
+}
+
+// encodeToolsCursor returns an opaque tools/list cursor for the given offset
+func encodeToolsCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("tools:" + strconv.Itoa(offset)))
+}
+
+func decodeToolsCursor(cursor string) (int, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), "tools:") {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "tools:"))
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+	return offset, true
+}
+
+// handleComplete suggests values for a tool argument. The gateway has no
+// prompts or resources, so other refs get an empty list.
+func (h *Handler) handleComplete(req JSONRPCRequest) *JSONRPCResponse {
+	paramsBytes, _ := json.Marshal(req.Params)
+	var params CompleteParams
+	if err := json.Unmarshal(paramsBytes, &params); err != nil || params.Argument.Name == "" {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error:   &JSONRPCError{Code: InvalidParams, Message: "Invalid completion params"},
+		}
+	}
+
+	var values []string
+	if params.Ref.Type == "ref/tool" {
+		// Suggestions are best effort: a backend that can't be reached
+		// (e.g. the database is down) just yields none
+		tool, _ := profiles.ResolveAlias(h.profile, params.Ref.Name)
+		values, _ = profiles.Complete(h.profile, tool, params.Argument.Name, params.Argument.Value, h.envVars)
+	}
+
+	completion := Completion{Values: values, Total: len(values)}
+	if completion.Values == nil {
+		completion.Values = []string{}
+	}
+	if len(values) > profiles.MaxCompletions {
+		completion.Values = values[:profiles.MaxCompletions]
+		completion.HasMore = true
+	}
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  CompleteResult{Completion: completion},
 	}
 }
 
-func (h *Handler) handleToolsCall(req JSONRPCRequest) *JSONRPCResponse {
+func (h *Handler) handleToolsCall(ctx context.Context, req JSONRPCRequest, notify func(JSONRPCNotification)) (resp *JSONRPCResponse) {
 	paramsBytes, _ := json.Marshal(req.Params)
 	var params ToolCallParams
 	if err := json.Unmarshal(paramsBytes, &params); err != nil {
@@ -109,8 +350,15 @@ func (h *Handler) handleToolsCall(req JSONRPCRequest) *JSONRPCResponse {
 			Error:   &JSONRPCError{Code: InvalidParams, Message: "Invalid tool call params"},
 		}
 	}
-
-	result, err := h.profile.CallTool(params.Name, params.Arguments, h.envVars)
+	// A call by a tool's old name runs the tool it was renamed to
+	if name, ok := profiles.ResolveAlias(h.profile, params.Name); ok {
+		alias := params.Name
+		params.Name = name
+		h.logger(ctx).Warn("deprecated tool name called", "alias", alias, "tool", name)
+		defer func() { withDeprecation(resp, alias, name) }()
+	}
+	params.Arguments = h.withDefaultArgs(params.Name, params.Arguments)
+	args, masker, err := h.interpolateArgs(params.Arguments)
 	if err != nil {
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
@@ -118,15 +366,279 @@ func (h *Handler) handleToolsCall(req JSONRPCRequest) *JSONRPCResponse {
 			Result: ToolCallResult{
 				Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error: %s", err.Error())}},
 				IsError: true,
+				Meta:    map[string]interface{}{"errorCode": profiles.ErrInvalidInput},
 			},
 		}
 	}
+	params.Arguments = args
+
+	if recorder := h.recorder; recorder != nil {
+		start := time.Now()
+		defer func() {
+			isError := resp.Error != nil
+			if result, ok := resp.Result.(ToolCallResult); ok && result.IsError {
+				isError = true
+			}
+			recorder.RecordTool(params.Name, float64(time.Since(start).Milliseconds()), isError)
+		}()
+	}
+
+	// Refuse rather than run a tool for real when it can't honor dry_run
+	if profiles.IsDryRun(params.Arguments) && !profiles.SupportsDryRun(h.profile, params.Name) {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result: ToolCallResult{
+				Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error: %s does not support dry_run", params.Name)}},
+				IsError: true,
+				Meta:    map[string]interface{}{"errorCode": profiles.ErrInvalidInput},
+			},
+		}
+	}
+
+	// Repeated calls of cacheable tools are answered from the cache, without
+	// taking a slot or reaching the backend
+	ttl, cacheSize := cacheSettings(h.envVars)
+	var key string
+	if ttl > 0 && profiles.IsCacheable(h.profile, params.Name) {
+		if k, ok := cacheKey(params.Name, params.Arguments); ok {
+			if entry, hit := h.cache.get(k, ttl); hit {
+				meta := map[string]interface{}{
+					"cached":          true,
+					"cacheAgeSeconds": int(time.Since(entry.stored).Seconds()),
+				}
+				for name, v := range entry.meta {
+					meta[name] = v
+				}
+				return &JSONRPCResponse{
+					JSONRPC: "2.0",
+					ID:      req.ID,
+					Result:  ToolCallResult{Content: entry.content, Meta: meta},
+				}
+			}
+			key = k
+		}
+	}
+
+	var release func()
+
+	// Take a concurrency slot before consulting the guard, so a rejected call
+	// doesn't use up a half-open breaker probe
+	// Calls a tool makes through its ToolCaller run inside the slot the
+	// calling tool already holds, one at a time
+	if limiter := h.limiter; limiter != nil && ctx.Value(nestedCallKey{}) == nil {
+		if err := limiter.Acquire(); err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Result: ToolCallResult{
+					Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error: %s", err.Error())}},
+					IsError: true,
+					Meta:    map[string]interface{}{"errorCode": profiles.ErrBusy},
+				},
+			}
+		}
+		release = limiter.Release
+	}
+	// A call abandoned by the watchdog takes over release, keeping its slot
+	// until it really returns
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
+	if h.guard != nil {
+		if err := h.guard.Allow(); err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Result: ToolCallResult{
+					Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error: %s", err.Error())}},
+					IsError: true,
+				},
+			}
+		}
+	}
+
+	// Profiles apply their own caps first; the collector enforces the
+	// gateway-wide MAX_TOOL_OUTPUT_BYTES on whatever they return
+	collector := newResultCollector(h.envVars)
+	// Tool log entries go to the gateway log, and to the client when it
+	// listens for notifications
+	logger := h.logger(ctx).With("tool", params.Name)
+	ctx = logging.WithLogger(ctx, logger)
+	var notifyLog profiles.LogFunc
+	if notify != nil {
+		notifyLog = h.logFunc(notify)
+	}
+	ctx = profiles.WithLogger(ctx, func(level, message string) {
+		message = masker.mask(message)
+		logger.Log(ctx, slogLevel(level), message)
+		if notifyLog != nil {
+			notifyLog(level, message)
+		}
+	})
+	ctx = profiles.WithToolCaller(ctx, h.callNested)
+	ctx, partial := profiles.WithPartialFlag(ctx)
+	originalBytes, err := h.runToolWatched(ctx, params, collector, &release)
+	if h.guard != nil {
+		h.guard.Record(!isBackendFailure(err))
+	}
+	if errors.Is(err, errToolPanicked) {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error:   &JSONRPCError{Code: InternalError, Message: fmt.Sprintf("Internal error in %s", params.Name)},
+		}
+	}
+	if err != nil {
+		callResult := ToolCallResult{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error: %s", masker.mask(err.Error()))}},
+			IsError: true,
+		}
+		meta := map[string]interface{}{}
+		var toolErr *profiles.ToolError
+		if errors.As(err, &toolErr) {
+			meta["errorCode"] = toolErr.Code
+		}
+		if id := RequestID(ctx); id != "" {
+			meta["requestId"] = id
+		}
+		logger.Warn("tool call failed", "err", masker.mask(err.Error()))
+		if len(meta) > 0 {
+			callResult.Meta = meta
+		}
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  callResult,
+		}
+	}
 
+	content, meta := collector.content(), collector.meta(originalBytes)
+	for i := range content {
+		content[i].Text = masker.mask(content[i].Text)
+	}
+	// An incomplete result would be served as if it were the whole answer
+	if key != "" && !partial() {
+		h.cache.put(key, content, meta, cacheSize)
+	}
+	// MOCK_MODE=record keeps complete results for MOCK_MODE=true to replay
+	if profiles.MockRecording(h.envVars) && meta == nil && !partial() {
+		if text, ok := textContent(content); ok {
+			if err := profiles.RecordMock(h.profile, params.Name, text, h.envVars); err != nil {
+				logger.Warn("recording mock response failed", "err", err)
+			}
+		}
+	}
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
 		Result: ToolCallResult{
-			Content: []ContentBlock{{Type: "text", Text: result}},
+			Content: content,
+			Meta:    meta,
 		},
 	}
 }
+
+// textContent joins a result's text blocks; ok is false if it has others
+func textContent(content []ContentBlock) (string, bool) {
+	var sb strings.Builder
+	for _, block := range content {
+		if block.Type != "text" {
+			return "", false
+		}
+		sb.WriteString(block.Text)
+	}
+	return sb.String(), true
+}
+
+type nestedCallKey struct{}
+
+// callNested makes a tool call on behalf of another tool (run_sequence). It
+// gets the same dry-run check, breaker, watchdog and metrics as a client's
+// call; log entries go to the calling tool's logger.
+func (h *Handler) callNested(ctx context.Context, name string, args map[string]interface{}) (string, bool, error) {
+	resp := h.handleToolsCall(context.WithValue(ctx, nestedCallKey{}, true), JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "tools/call",
+		Params:  ToolCallParams{Name: name, Arguments: args},
+	}, nil)
+	if resp.Error != nil {
+		return "", false, errors.New(resp.Error.Message)
+	}
+	result, _ := resp.Result.(ToolCallResult)
+	var sb strings.Builder
+	for _, block := range result.Content {
+		if block.Type != "text" {
+			fmt.Fprintf(&sb, "[%s %s, %d bytes base64]\n", block.Type, block.MimeType, len(block.Data))
+			continue
+		}
+		sb.WriteString(block.Text)
+	}
+	return sb.String(), result.IsError, nil
+}
+
+// runTool calls the profile, feeding its output to collector. It returns the
+// full output size when known (-1 for streamed output).
+func (h *Handler) runTool(ctx context.Context, params ToolCallParams, collector *resultCollector) (int, error) {
+	if result, ok, err := profiles.MockCall(h.profile, params.Name, params.Arguments, h.envVars); ok {
+		if err != nil {
+			return -1, err
+		}
+		collector.emit(result)
+		return len(result), nil
+	}
+
+	if profiles.ReturnsContent(h.profile, params.Name) {
+		blocks, err := h.profile.(profiles.ContentProfile).CallToolContent(ctx, params.Name, params.Arguments, h.envVars)
+		if err != nil {
+			return -1, err
+		}
+		n := 0
+		for _, block := range blocks {
+			n += len(block.Text) + len(block.Data)
+			if collector.add(block) != nil {
+				break
+			}
+		}
+		return n, nil
+	}
+
+	if sp, ok := h.profile.(profiles.StreamingProfile); ok {
+		err := sp.CallToolStream(ctx, params.Name, params.Arguments, h.envVars, collector.emit)
+		if errors.Is(err, profiles.ErrResultLimit) {
+			err = nil
+		}
+		return -1, err
+	}
+
+	var result string
+	var err error
+	if cp, ok := h.profile.(profiles.ContextProfile); ok {
+		result, err = cp.CallToolContext(ctx, params.Name, params.Arguments, h.envVars)
+	} else {
+		result, err = h.profile.CallTool(params.Name, params.Arguments, h.envVars)
+	}
+	if err != nil {
+		return -1, err
+	}
+	collector.emit(result)
+	return len(result), nil
+}
+
+// isBackendFailure reports whether err should count against the tool guard:
+// the backend failed or timed out, or couldn't be reached. Other errors, such
+// as bad input or an unknown tool, say nothing about the backend's health.
+func isBackendFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var toolErr *profiles.ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr.Code == profiles.ErrBackend || toolErr.Code == profiles.ErrTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,236 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+func TestIsBackendFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"success", nil, false},
+		{"backend error", &profiles.ToolError{Code: profiles.ErrBackend, Message: "connection failed"}, true},
+		{"timeout", &profiles.ToolError{Code: profiles.ErrTimeout, Message: "timed out"}, true},
+		{"invalid input", &profiles.ToolError{Code: profiles.ErrInvalidInput, Message: "bad"}, false},
+		{"forbidden", &profiles.ToolError{Code: profiles.ErrForbidden, Message: "read-only"}, false},
+		{"not configured", &profiles.ToolError{Code: profiles.ErrNotConfigured, Message: "DATABASE_URL is required"}, false},
+		{"plain input error", fmt.Errorf("template is required"), false},
+		{"unknown tool", fmt.Errorf("unknown tool: nope"), false},
+		{"dial error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"wrapped dial error", fmt.Errorf("query: %w", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("reset")}), true},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBackendFailure(tt.err); got != tt.want {
+				t.Errorf("isBackendFailure(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeProfile lists n tools, tool-0 to tool-<n-1>, that run call
+type fakeProfile struct {
+	n    int
+	call func(name string, args map[string]interface{}) (string, error)
+}
+
+func (p *fakeProfile) ID() string                      { return "fake" }
+func (p *fakeProfile) RequiredEnv() []profiles.EnvSpec { return nil }
+
+func (p *fakeProfile) Tools() []profiles.Tool {
+	tools := make([]profiles.Tool, p.n)
+	for i := range tools {
+		tools[i] = profiles.Tool{Name: fmt.Sprintf("tool-%d", i), InputSchema: map[string]interface{}{"type": "object"}}
+	}
+	return tools
+}
+
+func (p *fakeProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	if p.call == nil {
+		return name, nil
+	}
+	return p.call(name, args)
+}
+
+func TestToolsListPagination(t *testing.T) {
+	tests := []struct {
+		tools     int
+		wantPages []int
+	}{
+		{0, []int{0}},
+		{3, []int{3}},
+		{toolsPageSize, []int{toolsPageSize}},
+		{toolsPageSize + 1, []int{toolsPageSize, 1}},
+		{2*toolsPageSize + 50, []int{toolsPageSize, toolsPageSize, 50}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.tools), func(t *testing.T) {
+			h := NewHandler(&fakeProfile{n: tt.tools}, nil)
+			var pages []int
+			var names []string
+			cursor := ""
+			for len(pages) <= len(tt.wantPages) {
+				params := "{}"
+				if cursor != "" {
+					params = fmt.Sprintf(`{"cursor":%q}`, cursor)
+				}
+				resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":` + params + `}`))
+				if resp.Error != nil {
+					t.Fatalf("page %d: %s", len(pages)+1, resp.Error.Message)
+				}
+				result := resp.Result.(ToolsListResult)
+				pages = append(pages, len(result.Tools))
+				for _, tool := range result.Tools {
+					names = append(names, tool.Name)
+				}
+				if cursor = result.NextCursor; cursor == "" {
+					break
+				}
+			}
+			if fmt.Sprint(pages) != fmt.Sprint(tt.wantPages) {
+				t.Errorf("page sizes %v, want %v", pages, tt.wantPages)
+			}
+			// Every tool exactly once, in order
+			for i, name := range names {
+				if name != fmt.Sprintf("tool-%d", i) {
+					t.Fatalf("tool %d is %s", i, name)
+				}
+			}
+			if len(names) != tt.tools {
+				t.Errorf("listed %d tools, want %d", len(names), tt.tools)
+			}
+		})
+	}
+
+	cursors := []struct {
+		name    string
+		cursor  string
+		wantErr bool
+	}{
+		{"not base64", "nope", true},
+		{"negative offset", encodeToolsCursor(-1), true},
+		{"other prefix", "Zm9vOjE", true},
+		// e.g. after tools were removed: an empty last page
+		{"past the end", encodeToolsCursor(50), false},
+	}
+	h := NewHandler(&fakeProfile{n: 3}, nil)
+	for _, c := range cursors {
+		resp := h.HandleMessage([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{"cursor":%q}}`, c.cursor)))
+		if c.wantErr {
+			if resp.Error == nil || !strings.Contains(resp.Error.Message, "Invalid cursor") {
+				t.Errorf("%s: %+v, want Invalid cursor", c.name, resp)
+			}
+			continue
+		}
+		if resp.Error != nil || len(resp.Result.(ToolsListResult).Tools) != 0 {
+			t.Errorf("%s: %+v, want an empty page", c.name, resp)
+		}
+	}
+}
+
+// panicListProfile panics listing its tools
+type panicListProfile struct{ fakeProfile }
+
+func (p *panicListProfile) Tools() []profiles.Tool { panic("tools unavailable") }
+
+func TestPanicRecovery(t *testing.T) {
+	calls := &fakeProfile{n: 4, call: func(name string, args map[string]interface{}) (string, error) {
+		switch name {
+		case "tool-0":
+			panic("boom")
+		case "tool-1":
+			return args["query"].(string), nil
+		case "tool-2":
+			var counts map[string]int
+			counts[name]++
+		}
+		return "ok", nil
+	}}
+
+	tests := []struct {
+		name    string
+		profile profiles.Profile
+		request string
+		wantMsg string
+	}{
+		{"panic value", calls, `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"tool-0","arguments":{}}}`, "Internal error in tool-0"},
+		{"failed type assertion", calls, `{"jsonrpc":"2.0","id":"a-1","method":"tools/call","params":{"name":"tool-1","arguments":{}}}`, "Internal error in tool-1"},
+		{"nil map", calls, `{"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"name":"tool-2","arguments":{}}}`, "Internal error in tool-2"},
+		{"tools/list", &panicListProfile{}, `{"jsonrpc":"2.0","id":7,"method":"tools/list"}`, "Internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(tt.profile, nil)
+			resp := h.HandleMessage([]byte(tt.request))
+			if resp == nil || resp.Error == nil {
+				t.Fatalf("response %+v, want an error", resp)
+			}
+			if resp.Error.Code != InternalError || resp.Error.Message != tt.wantMsg {
+				t.Errorf("error %d %q, want %d %q", resp.Error.Code, resp.Error.Message, InternalError, tt.wantMsg)
+			}
+			var req JSONRPCRequest
+			json.Unmarshal([]byte(tt.request), &req)
+			if string(resp.ID) != string(req.ID) {
+				t.Errorf("id %s, want %s", resp.ID, req.ID)
+			}
+
+			// The handler keeps serving
+			resp = h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":10,"method":"ping"}`))
+			if resp == nil || resp.Error != nil {
+				t.Errorf("ping after the panic: %+v", resp)
+			}
+		})
+	}
+
+	h := NewHandler(calls, nil)
+	h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"tool-0","arguments":{}}}`))
+	resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"tool-3","arguments":{}}}`))
+	if resp == nil || resp.Error != nil || resp.Result.(ToolCallResult).IsError {
+		t.Errorf("call after a panic: %+v", resp)
+	}
+}
+
+func TestResponseIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{"int", `1`},
+		{"negative", `-5`},
+		{"beyond float64 precision", `9007199254740993`},
+		{"written as a float", `1.0`},
+		{"string", `"abc"`},
+		{"numeric string", `"1"`},
+		{"escaped string", `"café"`},
+		{"null", `null`},
+	}
+	h := NewHandler(&fakeProfile{n: 1}, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, method := range []string{`"ping"`, `"tools/call","params":{"name":"tool-0","arguments":{}}`, `"no/such/method"`} {
+				resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":` + tt.id + `,"method":` + method + `}`))
+				if resp == nil {
+					t.Fatalf("%s: no response", method)
+				}
+				out, err := json.Marshal(resp)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(string(out), `{"jsonrpc":"2.0","id":`+tt.id+`,`) {
+					t.Errorf("%s: response %s, want id %s", method, out, tt.id)
+				}
+			}
+		})
+	}
+}
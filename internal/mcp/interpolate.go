@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+// argEnvVarsKey names the connection env var listing the other env vars tool
+// arguments may reference as ${NAME}. Interpolation is off without it, and
+// only the listed names are resolved, never the gateway's process env, so a
+// secret can be put to use (Authorization: Bearer ${API_TOKEN}) without the
+// model ever seeing it. Resolved values are masked back to ${NAME} in tool
+// output and log entries.
+const argEnvVarsKey = "ARG_ENV_VARS"
+
+// minMaskedLen is the shortest resolved value masked in output; shorter
+// ones would mask unrelated text
+const minMaskedLen = 4
+
+// envRefRe matches ${NAME}, and $${NAME}, which stands for a literal ${NAME}
+var envRefRe = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// argMasker undoes interpolation in text leaving the gateway
+type argMasker struct {
+	replacer *strings.Replacer
+}
+
+func (m *argMasker) mask(s string) string {
+	if m == nil {
+		return s
+	}
+	return m.replacer.Replace(s)
+}
+
+// interpolateArgs returns args with ${NAME} references in its strings
+// replaced by the connection's value for NAME, and a masker for the values
+// used (nil if none). A reference to a name not listed in ARG_ENV_VARS, or
+// listed but not set, is an invalid input error.
+func (h *Handler) interpolateArgs(args map[string]interface{}) (map[string]interface{}, *argMasker, error) {
+	list := h.envVars[argEnvVarsKey]
+	if list == "" || len(args) == 0 {
+		return args, nil, nil
+	}
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
+
+	used := map[string]string{}
+	unknown := map[string]bool{}
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		switch val := v.(type) {
+		case string:
+			if !strings.Contains(val, "${") {
+				return val
+			}
+			return envRefRe.ReplaceAllStringFunc(val, func(ref string) string {
+				m := envRefRe.FindStringSubmatch(ref)
+				if m[1] == "$" {
+					return ref[1:]
+				}
+				value, ok := h.envVars[m[2]]
+				if !allowed[m[2]] || !ok {
+					unknown[m[2]] = true
+					return ref
+				}
+				used[m[2]] = value
+				return value
+			})
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(val))
+			for k, item := range val {
+				out[k] = walk(item)
+			}
+			return out
+		case []interface{}:
+			out := make([]interface{}, len(val))
+			for i, item := range val {
+				out[i] = walk(item)
+			}
+			return out
+		}
+		return v
+	}
+	out := walk(args).(map[string]interface{})
+
+	if len(unknown) > 0 {
+		names := make([]string, 0, len(unknown))
+		for name := range unknown {
+			names = append(names, "${"+name+"}")
+		}
+		sort.Strings(names)
+		return nil, nil, &profiles.ToolError{
+			Code:    profiles.ErrInvalidInput,
+			Message: "unknown variable(s) " + strings.Join(names, ", ") + " (only those listed in " + argEnvVarsKey + " can be referenced)",
+		}
+	}
+
+	// Longer values first, so one containing another is masked whole
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(used[names[i]]) > len(used[names[j]]) })
+	var pairs []string
+	for _, name := range names {
+		if value := used[name]; len(value) >= minMaskedLen {
+			pairs = append(pairs, value, "${"+name+"}")
+		}
+	}
+	if len(pairs) == 0 {
+		return out, nil, nil
+	}
+	return out, &argMasker{replacer: strings.NewReplacer(pairs...)}, nil
+}
@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolateArgs(t *testing.T) {
+	t.Setenv("PROCESS_SECRET", "from-the-process")
+	env := map[string]string{
+		argEnvVarsKey: "API_TOKEN, HOST,MISSING,PROCESS_SECRET",
+		"API_TOKEN":   "tok-123456",
+		"HOST":        "api.example.com",
+		"UNLISTED":    "hidden",
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "resolved",
+			env:  env,
+			args: map[string]interface{}{"header": "Authorization: Bearer ${API_TOKEN}", "n": 3.0},
+			want: map[string]interface{}{"header": "Authorization: Bearer tok-123456", "n": 3.0},
+		},
+		{
+			name: "nested",
+			env:  env,
+			args: map[string]interface{}{"headers": map[string]interface{}{"Host": "${HOST}"}, "urls": []interface{}{"https://${HOST}/${HOST}", true}},
+			want: map[string]interface{}{"headers": map[string]interface{}{"Host": "api.example.com"}, "urls": []interface{}{"https://api.example.com/api.example.com", true}},
+		},
+		{
+			name: "escaped",
+			env:  env,
+			args: map[string]interface{}{"text": "$${API_TOKEN} is ${API_TOKEN}"},
+			want: map[string]interface{}{"text": "${API_TOKEN} is tok-123456"},
+		},
+		{
+			name: "not references",
+			env:  env,
+			args: map[string]interface{}{"text": "$API_TOKEN ${} ${1X} {API_TOKEN}"},
+			want: map[string]interface{}{"text": "$API_TOKEN ${} ${1X} {API_TOKEN}"},
+		},
+		{
+			name: "interpolation off",
+			env:  map[string]string{"API_TOKEN": "tok-123456"},
+			args: map[string]interface{}{"header": "Bearer ${API_TOKEN}"},
+			want: map[string]interface{}{"header": "Bearer ${API_TOKEN}"},
+		},
+		{
+			name:    "not listed",
+			env:     env,
+			args:    map[string]interface{}{"header": "${UNLISTED}"},
+			wantErr: "${UNLISTED}",
+		},
+		{
+			name:    "listed but not set",
+			env:     env,
+			args:    map[string]interface{}{"a": "${MISSING}", "b": []interface{}{"${NOPE}"}},
+			wantErr: "${MISSING}, ${NOPE}",
+		},
+		{
+			name:    "process env is never used",
+			env:     env,
+			args:    map[string]interface{}{"header": "${PROCESS_SECRET}"},
+			wantErr: "${PROCESS_SECRET}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(&fakeProfile{}, tt.env)
+			got, _, err := h.interpolateArgs(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one naming %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolatedToolCall(t *testing.T) {
+	var seen string
+	profile := &fakeProfile{n: 2, call: func(name string, args map[string]interface{}) (string, error) {
+		seen, _ = args["header"].(string)
+		if name == "tool-1" {
+			return "", errors.New("401 for " + seen)
+		}
+		return "sent " + seen, nil
+	}}
+	h := NewHandler(profile, map[string]string{argEnvVarsKey: "API_TOKEN", "API_TOKEN": "tok-123456"})
+
+	tests := []struct {
+		tool     string
+		header   string
+		wantSeen string
+		wantText string
+	}{
+		{"tool-0", "Bearer ${API_TOKEN}", "Bearer tok-123456", "sent Bearer ${API_TOKEN}"},
+		{"tool-1", "Bearer ${API_TOKEN}", "Bearer tok-123456", "Error: 401 for Bearer ${API_TOKEN}"},
+		{"tool-0", "Bearer ${OTHER}", "", "Error: unknown variable(s) ${OTHER}"},
+	}
+	for _, tt := range tests {
+		seen = ""
+		resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tt.tool + `","arguments":{"header":"` + tt.header + `"}}}`))
+		if resp == nil || resp.Error != nil {
+			t.Fatalf("%s %s: %+v", tt.tool, tt.header, resp)
+		}
+		text := resp.Result.(ToolCallResult).Content[0].Text
+		if seen != tt.wantSeen {
+			t.Errorf("%s %s: profile saw %q, want %q", tt.tool, tt.header, seen, tt.wantSeen)
+		}
+		if !strings.HasPrefix(text, tt.wantText) || strings.Contains(text, "tok-123456") {
+			t.Errorf("%s %s: output %q, want %q", tt.tool, tt.header, text, tt.wantText)
+		}
+	}
+}
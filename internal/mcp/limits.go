@@ -0,0 +1,52 @@
+package mcp
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+// connectionLimits describes the limits a client runs into on this
+// connection, for the initialize result's _meta, so it can pace itself
+// instead of learning them from failures. Only numbers, flags and the
+// path/domain allowlists are included, never credentials or URLs.
+func (h *Handler) connectionLimits() map[string]interface{} {
+	env := h.envVars
+	limits := map[string]interface{}{
+		"toolTimeoutSeconds": int(toolTimeout(env).Seconds()),
+		"maxToolOutputBytes": newResultCollector(env).max,
+	}
+	for key, name := range map[string]string{
+		profiles.EnvRateLimit:          "rateLimitPerMinute",
+		profiles.EnvMaxSessions:        "maxSessions",
+		profiles.EnvMaxToolConcurrency: "maxConcurrentToolCalls",
+	} {
+		if n, err := strconv.Atoi(env[key]); err == nil {
+			limits[name] = n
+		}
+	}
+
+	// Report the settings the profile actually reads
+	for _, spec := range profiles.EnvSpecs(h.profile) {
+		switch spec.Name {
+		case "READ_ONLY":
+			limits["readOnly"] = profiles.ReadOnlyMode(env)
+		case "ALLOWED_PATHS", "ALLOWED_DOMAINS":
+			var list []string
+			for _, item := range strings.Split(env[spec.Name], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			if len(list) > 0 {
+				name := "allowedPaths"
+				if spec.Name == "ALLOWED_DOMAINS" {
+					name = "allowedDomains"
+				}
+				limits[name] = list
+			}
+		}
+	}
+	return limits
+}
@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/dublyo/mcp-gateway/internal/logging"
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+// defaultLogLevel applies until the client sends logging/setLevel
+const defaultLogLevel = "info"
+
+// logLevelIndex returns the position of level in profiles.LogLevels, or -1
+func logLevelIndex(level string) int {
+	for i, l := range profiles.LogLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// slogLevel maps an MCP log level to the gateway log's; notice and the
+// levels above error have no slog equivalent
+func slogLevel(level string) slog.Level {
+	switch idx := logLevelIndex(level); {
+	case idx <= 0:
+		return slog.LevelDebug
+	case idx <= 2:
+		return slog.LevelInfo
+	case idx == 3:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+// logger returns the gateway log's logger for a message handled in ctx,
+// tagged with the connection, profile and request ID
+func (h *Handler) logger(ctx context.Context) *slog.Logger {
+	logger := logging.Component("mcp").With("profile", h.profile.ID())
+	if id := h.envVars[profiles.EnvConnectionID]; id != "" {
+		logger = logger.With("connection", id)
+	}
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	return logger
+}
+
+// handleSetLevel sets the minimum level of log notifications for this
+// connection. The level is shared by all of the connection's sessions.
+func (h *Handler) handleSetLevel(req JSONRPCRequest) *JSONRPCResponse {
+	paramsBytes, _ := json.Marshal(req.Params)
+	var params SetLevelParams
+	json.Unmarshal(paramsBytes, &params)
+
+	idx := logLevelIndex(strings.ToLower(params.Level))
+	if idx < 0 {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &JSONRPCError{
+				Code:    InvalidParams,
+				Message: fmt.Sprintf("Invalid log level %q (expected one of %s)", params.Level, strings.Join(profiles.LogLevels, ", ")),
+			},
+		}
+	}
+	h.logLevel.Store(int32(idx))
+	return &JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]interface{}{}}
+}
+
+// logFunc returns a logger for a tool call that forwards entries at or above
+// the client's level as notifications/message
+func (h *Handler) logFunc(notify func(JSONRPCNotification)) profiles.LogFunc {
+	return func(level, message string) {
+		if idx := logLevelIndex(level); idx < 0 || int32(idx) < h.logLevel.Load() {
+			return
+		}
+		notify(JSONRPCNotification{
+			JSONRPC: "2.0",
+			Method:  "notifications/message",
+			Params:  LogMessageParams{Level: level, Logger: h.profile.ID(), Data: message},
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package mcp
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// networkProfile is a fakeProfile whose tools talk to other hosts
+type networkProfile struct{ fakeProfile }
+
+func (p *networkProfile) UsesNetwork() bool { return true }
+
+func TestMockRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorded.json")
+	calls := 0
+	profile := &networkProfile{fakeProfile{n: 3, call: func(name string, args map[string]interface{}) (string, error) {
+		calls++
+		switch name {
+		case "tool-1":
+			return "", errors.New("backend down")
+		case "tool-2":
+			return strings.Repeat("x", 64), nil
+		}
+		return "live " + name, nil
+	}}}
+	call := func(h *Handler, name string) ToolCallResult {
+		resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + name + `","arguments":{}}}`))
+		return resp.Result.(ToolCallResult)
+	}
+
+	rec := NewHandler(profile, map[string]string{"MOCK_MODE": "record", "MOCK_RECORD_PATH": path, "MAX_TOOL_OUTPUT_BYTES": "16"})
+	for _, name := range []string{"tool-0", "tool-1", "tool-2"} {
+		call(rec, name)
+	}
+	if calls != 3 {
+		t.Fatalf("recording ran %d calls, want 3", calls)
+	}
+
+	calls = 0
+	replay := NewHandler(profile, map[string]string{"MOCK_MODE": "true", "MOCK_RECORD_PATH": path})
+	if got := call(replay, "tool-0").Content[0].Text; !strings.HasPrefix(got, "[mock]") || !strings.HasSuffix(got, "\n\nlive tool-0") {
+		t.Errorf("replayed tool-0 = %q", got)
+	}
+	// Failed and truncated results aren't recorded
+	for _, name := range []string{"tool-1", "tool-2"} {
+		if got := call(replay, name).Content[0].Text; !strings.Contains(got, "fake/"+name+" called with") {
+			t.Errorf("replayed %s = %q, want the generic response", name, got)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("replay ran %d calls, want none", calls)
+	}
+}
@@ -0,0 +1,17 @@
+package mcp
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID attaches the correlation ID of the HTTP request carrying a
+// message, so it can be echoed in errors and logs
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the correlation ID attached to ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,165 @@
+package mcp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+const (
+	// DefaultMaxResultBytes caps a tool result unless MAX_TOOL_OUTPUT_BYTES is set
+	DefaultMaxResultBytes = 1024 * 1024
+	// resultBlockBytes is the size at which output is split into a new content block
+	resultBlockBytes = 64 * 1024
+)
+
+// resultCollector accumulates tool output into content blocks, enforcing a
+// total size limit so a large result never has to be held in memory whole
+type resultCollector struct {
+	max       int
+	size      int
+	blocks    []ContentBlock
+	cur       strings.Builder
+	pending   string // trailing bytes of an incomplete UTF-8 sequence
+	truncated bool
+}
+
+func newResultCollector(env map[string]string) *resultCollector {
+	max := DefaultMaxResultBytes
+	raw := env["MAX_TOOL_OUTPUT_BYTES"]
+	if raw == "" {
+		raw = env["MAX_RESULT_BYTES"] // older name, still honored
+	}
+	if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+		max = n
+	}
+	return &resultCollector{max: max}
+}
+
+// emit appends a chunk; it returns profiles.ErrResultLimit once the limit is hit
+func (c *resultCollector) emit(chunk string) error {
+	if c.truncated {
+		return profiles.ErrResultLimit
+	}
+	chunk = c.pending + chunk
+	c.pending = ""
+
+	// Hold back an incomplete rune at the end so blocks stay valid UTF-8
+	if tail := incompleteRuneTail(chunk); tail > 0 {
+		c.pending = chunk[len(chunk)-tail:]
+		chunk = chunk[:len(chunk)-tail]
+	}
+
+	if room := c.max - c.size; len(chunk) > room {
+		cut := room
+		for cut > 0 && !utf8.RuneStart(chunk[cut]) {
+			cut--
+		}
+		chunk = chunk[:cut]
+		c.truncated = true
+	}
+
+	for len(chunk) > 0 {
+		n := resultBlockBytes - c.cur.Len()
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		for n < len(chunk) && n > 0 && !utf8.RuneStart(chunk[n]) {
+			n--
+		}
+		if n == 0 {
+			c.flush()
+			continue
+		}
+		c.cur.WriteString(chunk[:n])
+		c.size += n
+		chunk = chunk[n:]
+		if c.cur.Len() >= resultBlockBytes {
+			c.flush()
+		}
+	}
+
+	if c.truncated {
+		return profiles.ErrResultLimit
+	}
+	return nil
+}
+
+// add appends a typed block from a ContentProfile; text goes through emit,
+// other blocks count their data against the limit and are dropped whole if
+// they don't fit
+func (c *resultCollector) add(block profiles.Content) error {
+	if block.Type == "text" {
+		return c.emit(block.Text)
+	}
+	if c.truncated {
+		return profiles.ErrResultLimit
+	}
+	if c.size+len(block.Data) > c.max {
+		c.truncated = true
+		return profiles.ErrResultLimit
+	}
+	c.flush()
+	c.blocks = append(c.blocks, ContentBlock{Type: block.Type, Data: block.Data, MimeType: block.MimeType})
+	c.size += len(block.Data)
+	return nil
+}
+
+func (c *resultCollector) flush() {
+	if c.cur.Len() > 0 {
+		c.blocks = append(c.blocks, ContentBlock{Type: "text", Text: c.cur.String()})
+		c.cur.Reset()
+	}
+}
+
+// content returns the collected blocks, with a note if output was truncated
+func (c *resultCollector) content() []ContentBlock {
+	if c.pending != "" && !c.truncated {
+		c.cur.WriteString(strings.ToValidUTF8(c.pending, "�"))
+		c.pending = ""
+	}
+	c.flush()
+	if c.truncated {
+		c.blocks = append(c.blocks, ContentBlock{
+			Type: "text",
+			Text: fmt.Sprintf("[output truncated at %d bytes (MAX_TOOL_OUTPUT_BYTES)]", c.max),
+		})
+	}
+	if len(c.blocks) == 0 {
+		c.blocks = []ContentBlock{{Type: "text", Text: ""}}
+	}
+	return c.blocks
+}
+
+// meta returns the truncation metadata for the result's _meta field, or nil
+// if nothing was cut. originalBytes is the full output size when known (<0 if not).
+func (c *resultCollector) meta(originalBytes int) map[string]interface{} {
+	if !c.truncated {
+		return nil
+	}
+	m := map[string]interface{}{
+		"truncated":     true,
+		"limitBytes":    c.max,
+		"returnedBytes": c.size,
+	}
+	if originalBytes >= 0 {
+		m["originalBytes"] = originalBytes
+	}
+	return m
+}
+
+// incompleteRuneTail returns the length of a truncated multi-byte rune at the end of s
+func incompleteRuneTail(s string) int {
+	for i := 1; i < utf8.UTFMax && i <= len(s); i++ {
+		if utf8.RuneStart(s[len(s)-i]) {
+			if !utf8.FullRuneInString(s[len(s)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
@@ -1,18 +1,20 @@
 package mcp
 
+import "encoding/json"
+
 // JSON-RPC 2.0 types
 type JSONRPCRequest struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      interface{} `json:"id,omitempty"` // can be int or string
-	Method  string      `json:"method"`
-	Params  interface{} `json:"params,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // number, string or null, echoed back verbatim
+	Method  string          `json:"method"`
+	Params  interface{}     `json:"params,omitempty"`
 }
 
 type JSONRPCResponse struct {
-	JSONRPC string        `json:"jsonrpc"`
-	ID      interface{}   `json:"id,omitempty"`
-	Result  interface{}   `json:"result,omitempty"`
-	Error   *JSONRPCError `json:"error,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
 }
 
 type JSONRPCError struct {
@@ -34,26 +36,73 @@ type ClientInfo struct {
 }
 
 type InitializeResult struct {
-	ProtocolVersion string       `json:"protocolVersion"`
-	Capabilities    Capabilities `json:"capabilities"`
-	ServerInfo      ServerInfo   `json:"serverInfo"`
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    Capabilities           `json:"capabilities"`
+	ServerInfo      ServerInfo             `json:"serverInfo"`
+	Meta            map[string]interface{} `json:"_meta,omitempty"`
 }
 
 type Capabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools       *ToolsCapability       `json:"tools,omitempty"`
+	Logging     *LoggingCapability     `json:"logging,omitempty"`
+	Completions *CompletionsCapability `json:"completions,omitempty"`
 }
 
 type ToolsCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+type LoggingCapability struct{}
+
+type CompletionsCapability struct{}
+
+// CompleteParams are the params of completion/complete. Besides the spec's
+// ref/prompt and ref/resource, ref.type "ref/tool" completes tool arguments.
+type CompleteParams struct {
+	Ref struct {
+		Type string `json:"type"`
+		Name string `json:"name,omitempty"`
+		URI  string `json:"uri,omitempty"`
+	} `json:"ref"`
+	Argument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"argument"`
+}
+
+type CompleteResult struct {
+	Completion Completion `json:"completion"`
+}
+
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+type SetLevelParams struct {
+	Level string `json:"level"`
+}
+
+// LogMessageParams are the params of a notifications/message notification
+type LogMessageParams struct {
+	Level  string      `json:"level"`
+	Logger string      `json:"logger,omitempty"`
+	Data   interface{} `json:"data"`
+}
+
 type ServerInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
 }
 
+type ToolsListParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
 type ToolsListResult struct {
-	Tools []ToolDef `json:"tools"`
+	Tools      []ToolDef `json:"tools"`
+	NextCursor string    `json:"nextCursor,omitempty"`
 }
 
 type ToolDef struct {
@@ -68,13 +117,28 @@ type ToolCallParams struct {
 }
 
 type ToolCallResult struct {
-	Content []ContentBlock `json:"content"`
-	IsError bool           `json:"isError,omitempty"`
+	Content []ContentBlock         `json:"content"`
+	IsError bool                   `json:"isError,omitempty"`
+	Meta    map[string]interface{} `json:"_meta,omitempty"`
 }
 
 type ContentBlock struct {
-	Type string `json:"type"` // "text"
-	Text string `json:"text"`
+	Type     string `json:"type"` // "text" or "image"
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"` // base64, for image blocks
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// MarshalJSON keeps the text field of text blocks even when it's empty
+func (b ContentBlock) MarshalJSON() ([]byte, error) {
+	if b.Type == "text" {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{b.Type, b.Text})
+	}
+	type block ContentBlock // without this method
+	return json.Marshal(block(b))
 }
 
 // Notification (no ID)
@@ -84,6 +148,9 @@ type JSONRPCNotification struct {
 	Params  interface{} `json:"params,omitempty"`
 }
 
+// ToolsListChanged tells a client to fetch tools/list again
+var ToolsListChanged = JSONRPCNotification{JSONRPC: "2.0", Method: "notifications/tools/list_changed"}
+
 // Standard error codes
 const (
 	ParseError     = -32700
@@ -93,4 +160,9 @@ const (
 	InternalError  = -32603
 )
 
+// ProtocolVersion is the latest MCP revision the gateway speaks
 const ProtocolVersion = "2025-11-25"
+
+// SupportedProtocolVersions lists every MCP revision the gateway can speak,
+// oldest first. Revisions are dates, so they compare as strings.
+var SupportedProtocolVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18", ProtocolVersion}
@@ -0,0 +1,92 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/dublyo/mcp-gateway/internal/logging"
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+const (
+	// DefaultToolTimeout bounds a tool call unless TOOL_TIMEOUT_SECONDS is set
+	DefaultToolTimeout = 5 * time.Minute
+	maxToolTimeout     = time.Hour
+)
+
+// toolTimeout returns the per-call limit from the connection's
+// TOOL_TIMEOUT_SECONDS env, clamped to an hour
+func toolTimeout(env map[string]string) time.Duration {
+	n, err := strconv.Atoi(env["TOOL_TIMEOUT_SECONDS"])
+	if err != nil || n <= 0 {
+		return DefaultToolTimeout
+	}
+	if d := time.Duration(n) * time.Second; d < maxToolTimeout {
+		return d
+	}
+	return maxToolTimeout
+}
+
+// errToolPanicked is returned for a call whose profile panicked; the
+// handler answers it with an InternalError response
+var errToolPanicked = errors.New("tool panicked")
+
+type toolOutcome struct {
+	originalBytes int
+	err           error
+}
+
+// runToolWatched runs the tool under a watchdog. Profiles that honor ctx stop
+// at the deadline on their own; for those that block regardless, the call is
+// abandoned and a timeout error returned, while the stuck goroutine is left to
+// finish in the background. It must not touch collector after a timeout.
+//
+// On timeout the function in *release (the caller's concurrency slot, if
+// any) is taken over and only called once the abandoned call really returns.
+func (h *Handler) runToolWatched(ctx context.Context, params ToolCallParams, collector *resultCollector, release *func()) (int, error) {
+	timeout := toolTimeout(h.envVars)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan toolOutcome, 1)
+	go func() {
+		// A panic here would take down the whole gateway, not just this request
+		defer func() {
+			if r := recover(); r != nil {
+				logging.FromContext(ctx).Error("tool panicked", "panic", r, "stack", string(debug.Stack()))
+				done <- toolOutcome{err: errToolPanicked}
+			}
+		}()
+		n, err := h.runTool(ctx, params, collector)
+		done <- toolOutcome{originalBytes: n, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.originalBytes, o.err
+	case <-timer.C:
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Warn("tool still running after its timeout, abandoning it", "timeout", timeout)
+	releaseSlot := *release
+	*release = nil
+	start := time.Now()
+	go func() {
+		<-done
+		logger.Info("abandoned tool call finished", "after_timeout", time.Since(start).Round(time.Second))
+		if releaseSlot != nil {
+			releaseSlot()
+		}
+	}()
+	return -1, &profiles.ToolError{
+		Code:    profiles.ErrTimeout,
+		Message: fmt.Sprintf("%s timed out after %s (TOOL_TIMEOUT_SECONDS)", params.Name, timeout),
+	}
+}
@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+func TestToolTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", DefaultToolTimeout},
+		{"abc", DefaultToolTimeout},
+		{"0", DefaultToolTimeout},
+		{"-5", DefaultToolTimeout},
+		{"30", 30 * time.Second},
+		{"99999", maxToolTimeout},
+	}
+	for _, tt := range tests {
+		if got := toolTimeout(map[string]string{"TOOL_TIMEOUT_SECONDS": tt.value}); got != tt.want {
+			t.Errorf("TOOL_TIMEOUT_SECONDS=%q: %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+// countingLimiter allows any number of calls and counts those in flight
+type countingLimiter struct{ inFlight atomic.Int32 }
+
+func (l *countingLimiter) Acquire() error { l.inFlight.Add(1); return nil }
+func (l *countingLimiter) Release()       { l.inFlight.Add(-1) }
+
+// fullLimiter rejects every call
+type fullLimiter struct{}
+
+func (fullLimiter) Acquire() error { return errors.New("connection too busy, retry shortly") }
+func (fullLimiter) Release()       {}
+
+func TestLimiterRejection(t *testing.T) {
+	ran := false
+	h := NewHandler(&fakeProfile{n: 1, call: func(name string, args map[string]interface{}) (string, error) {
+		ran = true
+		return "ran", nil
+	}}, nil)
+	h.SetToolLimiter(fullLimiter{})
+
+	resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"tool-0","arguments":{}}}`))
+	result := resp.Result.(ToolCallResult)
+	if ran {
+		t.Error("the tool ran without a slot")
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "too busy") {
+		t.Errorf("result %+v, want the limiter's error", result)
+	}
+	if code, _ := result.Meta["errorCode"].(string); code != profiles.ErrBusy {
+		t.Errorf("errorCode %q, want %q", code, profiles.ErrBusy)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	unblock := make(chan struct{})
+	profile := &fakeProfile{n: 3, call: func(name string, args map[string]interface{}) (string, error) {
+		switch name {
+		case "tool-1":
+			// Ignores its context, as a buggy profile might
+			<-unblock
+			return "late", nil
+		case "tool-2":
+			panic("boom")
+		}
+		return "quick", nil
+	}}
+	h := NewHandler(profile, map[string]string{"TOOL_TIMEOUT_SECONDS": "1"})
+	limiter := &countingLimiter{}
+	h.SetToolLimiter(limiter)
+
+	tests := []struct {
+		tool        string
+		wantText    string
+		wantCode    string
+		wantRPCErr  string
+		maxDuration time.Duration
+	}{
+		{tool: "tool-0", wantText: "quick", maxDuration: time.Second},
+		{tool: "tool-1", wantText: "tool-1 timed out after 1s", wantCode: profiles.ErrTimeout, maxDuration: 3 * time.Second},
+		{tool: "tool-2", wantRPCErr: "Internal error in tool-2", maxDuration: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			start := time.Now()
+			resp := h.HandleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tt.tool + `","arguments":{}}}`))
+			if elapsed := time.Since(start); elapsed > tt.maxDuration {
+				t.Errorf("took %s", elapsed)
+			}
+			if tt.wantRPCErr != "" {
+				if resp.Error == nil || resp.Error.Message != tt.wantRPCErr {
+					t.Fatalf("response %+v, want error %q", resp, tt.wantRPCErr)
+				}
+				return
+			}
+			result := resp.Result.(ToolCallResult)
+			if len(result.Content) != 1 || !strings.Contains(result.Content[0].Text, tt.wantText) {
+				t.Errorf("content %+v, want %q", result.Content, tt.wantText)
+			}
+			if code, _ := result.Meta["errorCode"].(string); code != tt.wantCode {
+				t.Errorf("errorCode %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+
+	// The abandoned call keeps its concurrency slot until it really returns
+	if n := limiter.inFlight.Load(); n != 1 {
+		t.Errorf("%d calls hold a slot, want the abandoned one", n)
+	}
+	close(unblock)
+	deadline := time.Now().Add(2 * time.Second)
+	for limiter.inFlight.Load() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := limiter.inFlight.Load(); n != 0 {
+		t.Errorf("%d slots still held after the abandoned call returned", n)
+	}
+}
@@ -0,0 +1,28 @@
+package profiles
+
+// AliasProvider is optionally implemented by profiles that renamed tools.
+// Aliases maps each old name to the tool's current one: a call by the old
+// name runs the current tool with a deprecation note in its result, while
+// tools/list only shows current names.
+type AliasProvider interface {
+	Aliases() map[string]string
+}
+
+// ResolveAlias returns the current name of tool on p, and whether tool was
+// an old name for it
+func ResolveAlias(p Profile, tool string) (string, bool) {
+	ap, ok := p.(AliasProvider)
+	if !ok {
+		return tool, false
+	}
+	if name, ok := ap.Aliases()[tool]; ok && name != "" && name != tool {
+		return name, true
+	}
+	return tool, false
+}
+
+// IsAlias reports whether tool is an old name of another tool on p
+func IsAlias(p Profile, tool string) bool {
+	_, ok := ResolveAlias(p, tool)
+	return ok
+}
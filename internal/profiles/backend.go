@@ -0,0 +1,33 @@
+package profiles
+
+// BackendProfile is optionally implemented by profiles whose tools talk to
+// the one backend a connection configures (a database, a Docker host, an
+// API), which can be down for every call at once. Only those connections
+// get a circuit breaker: profiles computing locally have nothing to guard,
+// and those reaching a different host on each call (fetch, dns) would let
+// one unreachable target block calls to the others.
+type BackendProfile interface {
+	HasBackend() bool
+}
+
+// HasBackend reports whether p's tools depend on a configured backend
+func HasBackend(p Profile) bool {
+	bp, ok := p.(BackendProfile)
+	return ok && bp.HasBackend()
+}
+
+// NetworkProfile is optionally implemented by profiles whose tools reach
+// other hosts without a configured backend, such as fetch and dns. Together
+// with BackendProfile it marks the tools MOCK_MODE stands in for.
+type NetworkProfile interface {
+	UsesNetwork() bool
+}
+
+// UsesNetwork reports whether p's tools contact a backend or other hosts
+func UsesNetwork(p Profile) bool {
+	if HasBackend(p) {
+		return true
+	}
+	np, ok := p.(NetworkProfile)
+	return ok && np.UsesNetwork()
+}
@@ -0,0 +1,60 @@
+package profiles
+
+import "testing"
+
+func TestHasBackend(t *testing.T) {
+	tests := []struct {
+		spec string
+		want bool
+	}{
+		{"database", true},
+		{"redis", true},
+		{"docker", true},
+		{"transform", false},
+		{"math", false},
+		{"time", false},
+		{"fetch", false},
+		{"transform,math", false},
+		{"transform,redis", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			p, err := Resolve(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := HasBackend(p); got != tt.want {
+				t.Errorf("HasBackend(%s) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsesNetwork(t *testing.T) {
+	tests := []struct {
+		spec string
+		want bool
+	}{
+		{"fetch", true},
+		{"webhook", true},
+		{"dns", true},
+		{"healthcheck", true},
+		{"database", true},
+		{"s3", true},
+		{"openapi", true},
+		{"transform", false},
+		{"math", false},
+		{"memory", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			p, err := Resolve(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := UsesNetwork(p); got != tt.want {
+				t.Errorf("UsesNetwork(%s) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
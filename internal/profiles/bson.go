@@ -0,0 +1,435 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Minimal BSON codec for the mongodb profile. Documents keep key order
+// (commands require the command name first) and decode to values that
+// marshal as MongoDB Extended JSON.
+
+type bsonElem struct {
+	Key   string
+	Value interface{}
+}
+
+// bsonDoc is an ordered BSON document
+type bsonDoc []bsonElem
+
+type bsonObjectID [12]byte
+
+type bsonBinary struct {
+	Subtype byte
+	Data    []byte
+}
+
+func (d bsonDoc) get(key string) interface{} {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+func (d bsonDoc) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range d {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := marshalJSONNoEscape(e.Key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := marshalJSONNoEscape(bsonToJSON(e.Value))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalJSONNoEscape is json.Marshal without HTML escaping of <, > and &
+func marshalJSONNoEscape(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// bsonToJSON maps BSON-only types to their Extended JSON form
+func bsonToJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case bsonObjectID:
+		return map[string]string{"$oid": hex.EncodeToString(val[:])}
+	case time.Time:
+		return map[string]string{"$date": val.UTC().Format(time.RFC3339Nano)}
+	case bsonBinary:
+		return map[string]interface{}{"$binary": map[string]string{
+			"base64":  base64.StdEncoding.EncodeToString(val.Data),
+			"subType": fmt.Sprintf("%02x", val.Subtype),
+		}}
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return map[string]string{"$numberDouble": strconv.FormatFloat(val, 'g', -1, 64)}
+		}
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = bsonToJSON(item)
+		}
+		return out
+	}
+	return v
+}
+
+// parseOrderedJSON decodes a JSON object into a bsonDoc, preserving key order
+// and converting {"$oid": ...} and {"$date": ...} wrappers
+func parseOrderedJSON(s string) (bsonDoc, error) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+	v, err := decodeOrderedJSON(dec, true)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := v.(bsonDoc)
+	if !ok {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	return doc, nil
+}
+
+// decodeOrderedJSON decodes the next JSON value, returning objects as bsonDoc
+// so key order survives. extended enables Extended JSON wrapper conversion.
+func decodeOrderedJSON(dec *json.Decoder, extended bool) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			doc := bsonDoc{}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ := keyTok.(string)
+				val, err := decodeOrderedJSON(dec, extended)
+				if err != nil {
+					return nil, err
+				}
+				doc = append(doc, bsonElem{key, val})
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			if !extended {
+				return doc, nil
+			}
+			return fromExtendedJSON(doc), nil
+		case '[':
+			arr := []interface{}{}
+			for dec.More() {
+				val, err := decodeOrderedJSON(dec, extended)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, val)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return arr, nil
+		}
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n, nil
+		}
+		return t.Float64()
+	}
+	return tok, nil
+}
+
+// toBSONValue converts a value decoded by encoding/json (unordered maps) for encoding
+func toBSONValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		doc := make(bsonDoc, 0, len(val))
+		for _, k := range keys {
+			doc = append(doc, bsonElem{k, toBSONValue(val[k])})
+		}
+		return fromExtendedJSON(doc)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = toBSONValue(item)
+		}
+		return out
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val)
+		}
+	}
+	return v
+}
+
+func fromExtendedJSON(doc bsonDoc) interface{} {
+	if len(doc) != 1 {
+		return doc
+	}
+	switch doc[0].Key {
+	case "$oid":
+		if s, ok := doc[0].Value.(string); ok {
+			if b, err := hex.DecodeString(s); err == nil && len(b) == 12 {
+				var id bsonObjectID
+				copy(id[:], b)
+				return id
+			}
+		}
+	case "$date":
+		if s, ok := doc[0].Value.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t
+			}
+		}
+		if n, ok := doc[0].Value.(int64); ok {
+			return time.UnixMilli(n)
+		}
+	}
+	return doc
+}
+
+func encodeBSON(doc bsonDoc) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, 0, 0})
+	for _, e := range doc {
+		if err := encodeBSONElem(&buf, e.Key, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte(0)
+	out := buf.Bytes()
+	binary.LittleEndian.PutUint32(out, uint32(len(out)))
+	return out, nil
+}
+
+func encodeBSONElem(buf *bytes.Buffer, key string, v interface{}) error {
+	writeKey := func(t byte) {
+		buf.WriteByte(t)
+		buf.WriteString(key)
+		buf.WriteByte(0)
+	}
+	switch val := v.(type) {
+	case nil:
+		writeKey(0x0A)
+	case bool:
+		writeKey(0x08)
+		if val {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case int:
+		writeKey(0x12)
+		binary.Write(buf, binary.LittleEndian, int64(val))
+	case int32:
+		writeKey(0x10)
+		binary.Write(buf, binary.LittleEndian, val)
+	case int64:
+		writeKey(0x12)
+		binary.Write(buf, binary.LittleEndian, val)
+	case float64:
+		writeKey(0x01)
+		binary.Write(buf, binary.LittleEndian, math.Float64bits(val))
+	case string:
+		writeKey(0x02)
+		binary.Write(buf, binary.LittleEndian, int32(len(val)+1))
+		buf.WriteString(val)
+		buf.WriteByte(0)
+	case bsonObjectID:
+		writeKey(0x07)
+		buf.Write(val[:])
+	case time.Time:
+		writeKey(0x09)
+		binary.Write(buf, binary.LittleEndian, val.UnixMilli())
+	case []byte:
+		writeKey(0x05)
+		binary.Write(buf, binary.LittleEndian, int32(len(val)))
+		buf.WriteByte(0)
+		buf.Write(val)
+	case bsonBinary:
+		writeKey(0x05)
+		binary.Write(buf, binary.LittleEndian, int32(len(val.Data)))
+		buf.WriteByte(val.Subtype)
+		buf.Write(val.Data)
+	case bsonDoc:
+		writeKey(0x03)
+		b, err := encodeBSON(val)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	case map[string]interface{}, []interface{}:
+		conv := toBSONValue(val)
+		if arr, ok := conv.([]interface{}); ok {
+			writeKey(0x04)
+			doc := make(bsonDoc, len(arr))
+			for i, item := range arr {
+				doc[i] = bsonElem{strconv.Itoa(i), item}
+			}
+			b, err := encodeBSON(doc)
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+			return nil
+		}
+		return encodeBSONElem(buf, key, conv)
+	default:
+		return fmt.Errorf("unsupported BSON value type %T for %q", v, key)
+	}
+	return nil
+}
+
+func decodeBSON(data []byte) (bsonDoc, error) {
+	if len(data) < 5 {
+		return nil, fmt.Errorf("BSON document too short")
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size > len(data) || size < 5 {
+		return nil, fmt.Errorf("invalid BSON document size")
+	}
+	r := &bsonReader{data: data[4 : size-1]}
+	doc := bsonDoc{}
+	for r.pos < len(r.data) {
+		t := r.byte()
+		key := r.cstring()
+		val, err := r.value(t)
+		if err != nil {
+			return nil, err
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		doc = append(doc, bsonElem{key, val})
+	}
+	return doc, r.err
+}
+
+type bsonReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bsonReader) take(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		if r.err == nil {
+			r.err = fmt.Errorf("truncated BSON document")
+		}
+		return make([]byte, n)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *bsonReader) byte() byte     { return r.take(1)[0] }
+func (r *bsonReader) int32() int32   { return int32(binary.LittleEndian.Uint32(r.take(4))) }
+func (r *bsonReader) int64() int64   { return int64(binary.LittleEndian.Uint64(r.take(8))) }
+func (r *bsonReader) uint64() uint64 { return binary.LittleEndian.Uint64(r.take(8)) }
+
+func (r *bsonReader) cstring() string {
+	i := bytes.IndexByte(r.data[r.pos:], 0)
+	if i < 0 {
+		r.err = fmt.Errorf("unterminated BSON cstring")
+		r.pos = len(r.data)
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+i])
+	r.pos += i + 1
+	return s
+}
+
+func (r *bsonReader) value(t byte) (interface{}, error) {
+	switch t {
+	case 0x01:
+		return math.Float64frombits(r.uint64()), nil
+	case 0x02, 0x0D, 0x0E: // string, JavaScript code, symbol
+		n := int(r.int32())
+		b := r.take(n)
+		if n > 0 {
+			b = b[:n-1]
+		}
+		return string(b), nil
+	case 0x03, 0x04:
+		if r.pos+4 > len(r.data) {
+			return nil, fmt.Errorf("truncated BSON document")
+		}
+		n := int(binary.LittleEndian.Uint32(r.data[r.pos:]))
+		sub, err := decodeBSON(r.take(n))
+		if err != nil {
+			return nil, err
+		}
+		if t == 0x03 {
+			return sub, nil
+		}
+		arr := make([]interface{}, len(sub))
+		for i, e := range sub {
+			arr[i] = e.Value
+		}
+		return arr, nil
+	case 0x05:
+		n := int(r.int32())
+		sub := r.byte()
+		return bsonBinary{Subtype: sub, Data: append([]byte(nil), r.take(n)...)}, nil
+	case 0x06, 0x0A, 0xFF, 0x7F: // undefined, null, min/max key
+		return nil, nil
+	case 0x07:
+		var id bsonObjectID
+		copy(id[:], r.take(12))
+		return id, nil
+	case 0x08:
+		return r.byte() != 0, nil
+	case 0x09:
+		return time.UnixMilli(r.int64()).UTC(), nil
+	case 0x0B:
+		pattern := r.cstring()
+		options := r.cstring()
+		return map[string]interface{}{"$regularExpression": map[string]string{"pattern": pattern, "options": options}}, nil
+	case 0x10:
+		return r.int32(), nil
+	case 0x11:
+		v := r.uint64()
+		return map[string]interface{}{"$timestamp": map[string]uint32{"t": uint32(v >> 32), "i": uint32(v)}}, nil
+	case 0x12:
+		return r.int64(), nil
+	case 0x13:
+		b := r.take(16)
+		return map[string]string{"$numberDecimal": "0x" + hex.EncodeToString(b)}, nil
+	}
+	return nil, fmt.Errorf("unsupported BSON type 0x%02x", t)
+}
@@ -0,0 +1,46 @@
+package profiles
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// CacheableProvider is optionally implemented by profiles with read-only
+// tools whose result depends only on their arguments for a while, so a
+// connection with a tool cache (TOOL_CACHE_TTL_SECONDS) may answer repeated
+// identical calls without running them again
+type CacheableProvider interface {
+	CacheableTools() []string
+}
+
+// IsCacheable reports whether results of tool on p may be cached
+func IsCacheable(p Profile, tool string) bool {
+	cp, ok := p.(CacheableProvider)
+	if !ok {
+		return false
+	}
+	for _, name := range cp.CacheableTools() {
+		if name == tool {
+			return true
+		}
+	}
+	return false
+}
+
+type partialKey struct{}
+
+// WithPartialFlag returns a context in which a tool can report with
+// markPartial that its result is incomplete, such as a search cut short by
+// its timeout, and a function telling whether it did. Incomplete results are
+// not cached.
+func WithPartialFlag(ctx context.Context) (context.Context, func() bool) {
+	flag := new(atomic.Bool)
+	return context.WithValue(ctx, partialKey{}, flag), flag.Load
+}
+
+// markPartial flags the result of the tool running with ctx as incomplete
+func markPartial(ctx context.Context) {
+	if flag, ok := ctx.Value(partialKey{}).(*atomic.Bool); ok {
+		flag.Store(true)
+	}
+}
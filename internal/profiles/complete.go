@@ -0,0 +1,71 @@
+package profiles
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxCompletions caps the number of values returned for one completion request
+const MaxCompletions = 100
+
+// CompletionProvider is optionally implemented by profiles that can suggest
+// values for a tool argument, e.g. table names, as the user types them.
+// Returned values need not be filtered; Complete matches them against partial.
+type CompletionProvider interface {
+	Complete(tool, arg, partial string, env map[string]string) ([]string, error)
+}
+
+// Complete returns suggestions for argument arg of tool that match partial.
+// Profiles without a CompletionProvider, or whose provider has nothing for
+// the argument, fall back to the enum declared in the tool's input schema.
+func Complete(p Profile, tool, arg, partial string, env map[string]string) ([]string, error) {
+	if cp, ok := p.(CompletionProvider); ok {
+		values, err := cp.Complete(tool, arg, partial, env)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) > 0 {
+			return matchCompletions(values, partial), nil
+		}
+	}
+
+	tools, err := ToolsFor(p, env)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tools {
+		if t.Name != tool {
+			continue
+		}
+		props, _ := t.InputSchema["properties"].(map[string]interface{})
+		prop, _ := props[arg].(map[string]interface{})
+		var values []string
+		switch enum := prop["enum"].(type) {
+		case []string:
+			values = enum
+		case []interface{}:
+			for _, v := range enum {
+				values = append(values, fmt.Sprintf("%v", v))
+			}
+		}
+		return matchCompletions(values, partial), nil
+	}
+	return nil, nil
+}
+
+// matchCompletions returns the values starting with partial, followed by
+// those containing it elsewhere, ignoring case
+func matchCompletions(values []string, partial string) []string {
+	needle := strings.ToLower(partial)
+	var prefix, contains []string
+	for _, v := range values {
+		lower := strings.ToLower(v)
+		switch {
+		case strings.HasPrefix(lower, needle):
+			prefix = append(prefix, v)
+		case strings.Contains(lower, needle):
+			contains = append(contains, v)
+		}
+	}
+	return append(prefix, contains...)
+}
@@ -0,0 +1,244 @@
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CompositeProfile serves the tools of several profiles on one connection,
+// e.g. "filesystem,git". Tool names must be unique across its members.
+type CompositeProfile struct {
+	members []Profile
+	owner   map[string]Profile // static tool name → member serving it
+}
+
+// Resolve returns the profile for a connection's profile setting: a single
+// registered ID, or a comma-separated list of them served together
+func Resolve(spec string) (Profile, error) {
+	if !strings.Contains(spec, ",") {
+		p, ok := Get(spec)
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %s", spec)
+		}
+		return p, nil
+	}
+
+	var members []Profile
+	seen := map[string]bool{}
+	for _, id := range strings.Split(spec, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		p, ok := Get(id)
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %s", id)
+		}
+		members = append(members, p)
+	}
+	if len(members) == 1 {
+		return members[0], nil
+	}
+	return NewComposite(members...)
+}
+
+// NewComposite combines members into one profile, failing if two of them
+// declare a tool with the same name
+func NewComposite(members ...Profile) (*CompositeProfile, error) {
+	c := &CompositeProfile{members: members, owner: map[string]Profile{}}
+	for _, m := range members {
+		for _, t := range m.Tools() {
+			if prev, ok := c.owner[t.Name]; ok {
+				return nil, fmt.Errorf("profiles %s and %s both define tool %s", prev.ID(), m.ID(), t.Name)
+			}
+			c.owner[t.Name] = m
+		}
+	}
+	return c, nil
+}
+
+func (p *CompositeProfile) ID() string {
+	ids := make([]string, len(p.members))
+	for i, m := range p.members {
+		ids[i] = m.ID()
+	}
+	return strings.Join(ids, ",")
+}
+
+func (p *CompositeProfile) Tools() []Tool {
+	var tools []Tool
+	for _, m := range p.members {
+		tools = append(tools, m.Tools()...)
+	}
+	return tools
+}
+
+// ToolsFor merges the members' tools for env, including tools generated
+// from env, which can only be checked for collisions here
+func (p *CompositeProfile) ToolsFor(env map[string]string) ([]Tool, error) {
+	var tools []Tool
+	from := map[string]string{}
+	for _, m := range p.members {
+		mt, err := ToolsFor(m, env)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.ID(), err)
+		}
+		for _, t := range mt {
+			if prev, ok := from[t.Name]; ok {
+				return nil, fmt.Errorf("profiles %s and %s both define tool %s", prev, m.ID(), t.Name)
+			}
+			from[t.Name] = m.ID()
+		}
+		tools = append(tools, mt...)
+	}
+	return tools, nil
+}
+
+// member returns the profile serving tool
+func (p *CompositeProfile) member(tool string, env map[string]string) (Profile, error) {
+	if m, ok := p.owner[tool]; ok {
+		return m, nil
+	}
+	for _, m := range p.members {
+		if _, ok := m.(DynamicToolsProvider); !ok {
+			continue
+		}
+		tools, err := ToolsFor(m, env)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.ID(), err)
+		}
+		for _, t := range tools {
+			if t.Name == tool {
+				return m, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown tool: %s", tool)
+}
+
+func (p *CompositeProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	m, err := p.member(name, env)
+	if err != nil {
+		return "", err
+	}
+	return m.CallTool(name, args, env)
+}
+
+// CallToolStream lets each member keep its own calling convention: streaming
+// members stream, the others emit their whole result at once
+func (p *CompositeProfile) CallToolStream(ctx context.Context, name string, args map[string]interface{}, env map[string]string, emit func(chunk string) error) error {
+	m, err := p.member(name, env)
+	if err != nil {
+		return err
+	}
+	if sp, ok := m.(StreamingProfile); ok {
+		return sp.CallToolStream(ctx, name, args, env, emit)
+	}
+	var result string
+	if cp, ok := m.(ContextProfile); ok {
+		result, err = cp.CallToolContext(ctx, name, args, env)
+	} else {
+		result, err = m.CallTool(name, args, env)
+	}
+	if err != nil {
+		return err
+	}
+	return emit(result)
+}
+
+// RequiredEnv merges the members' env specs; a variable is required if any
+// member requires it
+func (p *CompositeProfile) RequiredEnv() []EnvSpec {
+	var specs []EnvSpec
+	index := map[string]int{}
+	for _, m := range p.members {
+		for _, spec := range EnvSpecs(m) {
+			if i, ok := index[spec.Name]; ok {
+				specs[i].Required = specs[i].Required || spec.Required
+				continue
+			}
+			index[spec.Name] = len(specs)
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// HasBackend reports whether any member depends on a configured backend
+func (p *CompositeProfile) HasBackend() bool {
+	for _, m := range p.members {
+		if HasBackend(m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *CompositeProfile) DryRunTools() []string {
+	var tools []string
+	for _, m := range p.members {
+		if dp, ok := m.(DryRunProvider); ok {
+			tools = append(tools, dp.DryRunTools()...)
+		}
+	}
+	return tools
+}
+
+func (p *CompositeProfile) CacheableTools() []string {
+	var tools []string
+	for _, m := range p.members {
+		if cp, ok := m.(CacheableProvider); ok {
+			tools = append(tools, cp.CacheableTools()...)
+		}
+	}
+	return tools
+}
+
+// Aliases merges the members' old tool names, except any another member
+// still uses for a tool of its own
+func (p *CompositeProfile) Aliases() map[string]string {
+	aliases := map[string]string{}
+	for _, m := range p.members {
+		if ap, ok := m.(AliasProvider); ok {
+			for alias, name := range ap.Aliases() {
+				if _, taken := p.owner[alias]; taken {
+					continue
+				}
+				aliases[alias] = name
+			}
+		}
+	}
+	return aliases
+}
+
+func (p *CompositeProfile) ContentTools() []string {
+	var tools []string
+	for _, m := range p.members {
+		if cp, ok := m.(ContentProfile); ok {
+			tools = append(tools, cp.ContentTools()...)
+		}
+	}
+	return tools
+}
+
+func (p *CompositeProfile) CallToolContent(ctx context.Context, name string, args map[string]interface{}, env map[string]string) ([]Content, error) {
+	m, err := p.member(name, env)
+	if err != nil {
+		return nil, err
+	}
+	cp, ok := m.(ContentProfile)
+	if !ok {
+		return nil, fmt.Errorf("%s does not return content blocks", name)
+	}
+	return cp.CallToolContent(ctx, name, args, env)
+}
+
+func (p *CompositeProfile) Complete(tool, arg, partial string, env map[string]string) ([]string, error) {
+	m, err := p.member(tool, env)
+	if err != nil {
+		return nil, nil
+	}
+	return Complete(m, tool, arg, partial, env)
+}
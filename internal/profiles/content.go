@@ -0,0 +1,40 @@
+package profiles
+
+import "context"
+
+// Content is one block of a typed tool result. Type is "text" (Text set) or
+// "image" (Data holds base64 bytes of MimeType).
+type Content struct {
+	Type     string
+	Text     string
+	Data     string
+	MimeType string
+}
+
+// TextContent returns a text block
+func TextContent(text string) Content {
+	return Content{Type: "text", Text: text}
+}
+
+// ContentProfile is optionally implemented by profiles with tools whose
+// results are better returned as typed content blocks (an image, say) than
+// as text. The tools ContentTools lists are called through CallToolContent;
+// the others, and clients of CallTool, keep getting text.
+type ContentProfile interface {
+	ContentTools() []string
+	CallToolContent(ctx context.Context, name string, args map[string]interface{}, env map[string]string) ([]Content, error)
+}
+
+// ReturnsContent reports whether tool on p returns typed content blocks
+func ReturnsContent(p Profile, tool string) bool {
+	cp, ok := p.(ContentProfile)
+	if !ok {
+		return false
+	}
+	for _, name := range cp.ContentTools() {
+		if name == tool {
+			return true
+		}
+	}
+	return false
+}
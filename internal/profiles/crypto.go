@@ -1,17 +1,26 @@
 package profiles
 
 import (
+	"crypto"
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"hash"
+	"io"
 	"math/big"
+	"os"
 	"strings"
 )
 
@@ -92,6 +101,73 @@ func (p *CryptoProfile) Tools() []Tool {
 				"required": []string{"token"},
 			},
 		},
+		{
+			Name:        "hash_file",
+			Description: "Hash a file (under ALLOWED_PATHS) using MD5, SHA1, SHA256, or SHA512",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path":      map[string]interface{}{"type": "string", "description": "File path to hash"},
+					"algorithm": map[string]interface{}{"type": "string", "description": "Algorithm: md5, sha1, sha256, sha512 (default sha256)"},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "verify_checksum",
+			Description: "Check a file (under ALLOWED_PATHS) against an expected checksum",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path":      map[string]interface{}{"type": "string", "description": "File path to check"},
+					"expected":  map[string]interface{}{"type": "string", "description": "Expected hex digest (a sha256sum-style line also works)"},
+					"algorithm": map[string]interface{}{"type": "string", "description": "Algorithm: md5, sha1, sha256, sha512 (default: inferred from the digest length)"},
+				},
+				"required": []string{"path", "expected"},
+			},
+		},
+		{
+			Name:        "generate_keypair",
+			Description: "Generate an Ed25519 or RSA key pair, PEM-encoded (PKCS#8 private key, PKIX public key)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{"type": "string", "description": "Key type: ed25519, rsa (default ed25519)"},
+					"bits": map[string]interface{}{"type": "integer", "description": "RSA key size: 2048, 3072, 4096 (default 2048)"},
+				},
+			},
+		},
+		{
+			Name:        "sign",
+			Description: "Sign a message with a PEM private key (Ed25519, or RSA-PSS with SHA-256)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"message":     map[string]interface{}{"type": "string", "description": "Message to sign"},
+					"private_key": map[string]interface{}{"type": "string", "description": "PEM-encoded private key (PKCS#8 or PKCS#1)"},
+				},
+				"required": []string{"message", "private_key"},
+			},
+		},
+		{
+			Name:        "verify",
+			Description: "Verify an Ed25519 or RSA-PSS (SHA-256) signature with a PEM public key",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"message":    map[string]interface{}{"type": "string", "description": "Message that was signed"},
+					"signature":  map[string]interface{}{"type": "string", "description": "Base64-encoded signature"},
+					"public_key": map[string]interface{}{"type": "string", "description": "PEM-encoded public key or certificate"},
+				},
+				"required": []string{"message", "signature", "public_key"},
+			},
+		},
+	}
+}
+
+func (p *CryptoProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "ALLOWED_PATHS", Required: false, Description: "Comma-separated directories hash_file and verify_checksum may read"},
 	}
 }
 
@@ -109,6 +185,16 @@ func (p *CryptoProfile) CallTool(name string, args map[string]interface{}, env m
 		return p.generateRandomBytes(args)
 	case "jwt_decode":
 		return p.jwtDecode(args)
+	case "hash_file":
+		return p.hashFile(args, env)
+	case "verify_checksum":
+		return p.verifyChecksum(args, env)
+	case "generate_keypair":
+		return p.generateKeypair(args)
+	case "sign":
+		return p.sign(args)
+	case "verify":
+		return p.verify(args)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
@@ -291,3 +377,247 @@ func (p *CryptoProfile) jwtDecode(args map[string]interface{}) (string, error) {
 
 	return fmt.Sprintf("Header:\n  %s\n\nPayload:\n  %s\n\nSignature: %s\n\n⚠️ Signature NOT verified (decode only)", header, payload, parts[2]), nil
 }
+
+// newHash returns a hash for one of the algorithms the hash tools accept,
+// along with its display name
+func newHash(algo string) (hash.Hash, string, error) {
+	switch strings.ToLower(algo) {
+	case "md5":
+		return md5.New(), "MD5", nil
+	case "sha1":
+		return sha1.New(), "SHA1", nil
+	case "", "sha256":
+		return sha256.New(), "SHA256", nil
+	case "sha512":
+		return sha512.New(), "SHA512", nil
+	}
+	return nil, "", invalidInputf("unsupported algorithm: %s (use md5, sha1, sha256, sha512)", algo)
+}
+
+// digestFile streams the file at path through h. Reading requires
+// ALLOWED_PATHS, since unlike the filesystem profile it is optional here.
+func digestFile(path string, h hash.Hash, env map[string]string) (int64, error) {
+	allowed := parseAllowedPaths(env["ALLOWED_PATHS"])
+	if len(allowed) == 0 {
+		return 0, notConfiguredf("ALLOWED_PATHS must be configured to hash files")
+	}
+	if err := validatePath(path, allowed); err != nil {
+		return 0, forbiddenf("%s", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, invalidInputf("cannot open file: %s", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("cannot stat file: %s", err)
+	}
+	if !info.Mode().IsRegular() {
+		return 0, invalidInputf("%s is not a regular file", path)
+	}
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read file: %s", err)
+	}
+	return n, nil
+}
+
+func (p *CryptoProfile) hashFile(args map[string]interface{}, env map[string]string) (string, error) {
+	path := getStr(args, "path")
+	h, name, err := newHash(getStr(args, "algorithm"))
+	if err != nil {
+		return "", err
+	}
+	size, err := digestFile(path, h, env)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: %s\nFile: %s\nSize: %d bytes (%s)", name, hex.EncodeToString(h.Sum(nil)), path, size, humanBytes(float64(size))), nil
+}
+
+func (p *CryptoProfile) verifyChecksum(args map[string]interface{}, env map[string]string) (string, error) {
+	path := getStr(args, "path")
+	// Accept "<digest>  <file>" lines as printed by sha256sum and friends
+	fields := strings.Fields(getStr(args, "expected"))
+	if len(fields) == 0 {
+		return "", invalidInputf("expected is required")
+	}
+	expected, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return "", invalidInputf("expected must be a hex digest")
+	}
+
+	algo := getStr(args, "algorithm")
+	if algo == "" {
+		switch len(expected) {
+		case md5.Size:
+			algo = "md5"
+		case sha1.Size:
+			algo = "sha1"
+		case sha256.Size:
+			algo = "sha256"
+		case sha512.Size:
+			algo = "sha512"
+		default:
+			return "", invalidInputf("cannot infer algorithm from a %d-byte digest, pass algorithm", len(expected))
+		}
+	}
+	h, name, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	if len(expected) != h.Size() {
+		return "", invalidInputf("expected digest is %d bytes but %s digests are %d bytes", len(expected), name, h.Size())
+	}
+
+	size, err := digestFile(path, h, env)
+	if err != nil {
+		return "", err
+	}
+	actual := h.Sum(nil)
+	result := "MISMATCH"
+	if subtle.ConstantTimeCompare(actual, expected) == 1 {
+		result = "MATCH"
+	}
+	return fmt.Sprintf("Checksum: %s\nAlgorithm: %s\nExpected: %s\nActual:   %s\nFile: %s (%d bytes)",
+		result, name, hex.EncodeToString(expected), hex.EncodeToString(actual), path, size), nil
+}
+
+func (p *CryptoProfile) generateKeypair(args map[string]interface{}) (string, error) {
+	keyType := strings.ToLower(getStr(args, "type"))
+	var priv crypto.Signer
+	var desc string
+	switch keyType {
+	case "", "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", fmt.Errorf("key generation failed: %s", err)
+		}
+		priv, desc = key, "Ed25519"
+	case "rsa":
+		bits := int(getFloat(args, "bits"))
+		if bits == 0 {
+			bits = 2048
+		}
+		if bits != 2048 && bits != 3072 && bits != 4096 {
+			return "", invalidInputf("bits must be 2048, 3072 or 4096")
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return "", fmt.Errorf("key generation failed: %s", err)
+		}
+		priv, desc = key, fmt.Sprintf("RSA %d-bit", bits)
+	default:
+		return "", invalidInputf("unsupported key type: %s (use ed25519 or rsa)", keyType)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode private key: %s", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return "", fmt.Errorf("cannot encode public key: %s", err)
+	}
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+	return fmt.Sprintf("%s key pair\n\nWARNING: the private key below is sensitive. Store it in a secret manager and "+
+		"do not paste it into logs, tickets or chat.\n\nPublic key:\n%s\nPrivate key:\n%s", desc, pubPEM, privPEM), nil
+}
+
+func (p *CryptoProfile) sign(args map[string]interface{}) (string, error) {
+	message := getStr(args, "message")
+	if message == "" {
+		return "", invalidInputf("message is required")
+	}
+	block, _ := pem.Decode([]byte(getStr(args, "private_key")))
+	if block == nil {
+		return "", invalidInputf("private_key must be a PEM-encoded key")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return "", invalidInputf("cannot parse private key: %s", err)
+	}
+
+	var sig []byte
+	var scheme string
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		sig, scheme = ed25519.Sign(k, []byte(message)), "Ed25519"
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(message))
+		sig, err = rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		if err != nil {
+			return "", fmt.Errorf("signing failed: %s", err)
+		}
+		scheme = "RSA-PSS SHA-256"
+	default:
+		return "", invalidInputf("unsupported key type %T (use an Ed25519 or RSA key)", key)
+	}
+
+	return fmt.Sprintf("Algorithm: %s\nSignature (base64): %s", scheme, base64.StdEncoding.EncodeToString(sig)), nil
+}
+
+func (p *CryptoProfile) verify(args map[string]interface{}) (string, error) {
+	message := getStr(args, "message")
+	if message == "" {
+		return "", invalidInputf("message is required")
+	}
+	sigText := strings.TrimSpace(getStr(args, "signature"))
+	sig, err := base64.StdEncoding.DecodeString(sigText)
+	if err != nil {
+		if sig, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(sigText, "=")); err != nil {
+			return "", invalidInputf("signature must be base64")
+		}
+	}
+	block, _ := pem.Decode([]byte(getStr(args, "public_key")))
+	if block == nil {
+		return "", invalidInputf("public_key must be a PEM-encoded key")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, certErr := x509.ParseCertificate(block.Bytes)
+		if certErr != nil {
+			return "", invalidInputf("cannot parse certificate: %s", certErr)
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return "", invalidInputf("cannot parse public key: %s", err)
+	}
+
+	var valid bool
+	var scheme string
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		valid, scheme = ed25519.Verify(k, []byte(message), sig), "Ed25519"
+	case *rsa.PublicKey:
+		digest := sha256.Sum256([]byte(message))
+		// Auto salt length accepts signatures from tools that use the maximum salt
+		valid = rsa.VerifyPSS(k, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil
+		scheme = "RSA-PSS SHA-256"
+	default:
+		return "", invalidInputf("unsupported key type %T (use an Ed25519 or RSA key)", key)
+	}
+
+	if valid {
+		return fmt.Sprintf("Signature: VALID\nAlgorithm: %s", scheme), nil
+	}
+	return fmt.Sprintf("Signature: INVALID\nAlgorithm: %s", scheme), nil
+}
@@ -1,18 +1,24 @@
 package profiles
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DatabaseProfile struct{}
 
-func (p *DatabaseProfile) ID() string { return "database" }
+// blockedSQLKeywords are refused even when READ_ONLY=false
+var blockedSQLKeywords = []string{"DROP ", "TRUNCATE ", "ALTER ", "GRANT ", "REVOKE "}
+
+func (p *DatabaseProfile) ID() string       { return "database" }
+func (p *DatabaseProfile) HasBackend() bool { return true }
 
 func (p *DatabaseProfile) Tools() []Tool {
 	return []Tool{
@@ -29,11 +35,15 @@ func (p *DatabaseProfile) Tools() []Tool {
 		},
 		{
 			Name:        "list_tables",
-			Description: "List all tables in the database",
+			Description: "List the tables in a schema, a page at a time, optionally with approximate row counts and comments",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"schema": map[string]interface{}{"type": "string", "description": "Schema name (default 'public')"},
+					"schema":           map[string]interface{}{"type": "string", "description": "Schema name (default 'public')"},
+					"include_counts":   map[string]interface{}{"type": "boolean", "description": "Add approximate row counts from planner statistics (no table scans)"},
+					"include_comments": map[string]interface{}{"type": "boolean", "description": "Add table comments (COMMENT ON TABLE)"},
+					"limit":            map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Tables per page (default %d, max %d)", listTablesDefaultLimit, listTablesMaxLimit)},
+					"offset":           map[string]interface{}{"type": "integer", "description": "Tables to skip, for the next page"},
 				},
 			},
 		},
@@ -60,57 +70,189 @@ func (p *DatabaseProfile) Tools() []Tool {
 				"required": []string{"sql"},
 			},
 		},
+		{
+			Name:        "table_stats",
+			Description: "Show a table's approximate row count, size and last vacuum/analyze, optionally with sample rows",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table":  map[string]interface{}{"type": "string", "description": "Table name"},
+					"schema": map[string]interface{}{"type": "string", "description": "Schema name (default 'public')"},
+					"sample": map[string]interface{}{"type": "integer", "description": "Number of sample rows to return (default 0, max 20)"},
+				},
+				"required": []string{"table"},
+			},
+		},
+		{
+			Name:        "aggregate",
+			Description: "Summarize a column in one call: row, null and distinct counts, min/max/avg for numbers, min/max for dates and times, and the most common values otherwise. Scans the whole table.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"table":  map[string]interface{}{"type": "string", "description": "Table name"},
+					"column": map[string]interface{}{"type": "string", "description": "Column to summarize"},
+					"schema": map[string]interface{}{"type": "string", "description": "Schema name (default 'public')"},
+					"top":    map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Most common values to list for non-numeric columns (default %d, at most MAX_ROWS)", aggregateDefaultTop)},
+				},
+				"required": []string{"table", "column"},
+			},
+		},
+		{
+			Name:        "test_connection",
+			Description: "Check that DATABASE_URL is reachable and the credentials work, without running a user query",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "db_stats",
+			Description: "Show server version, connection usage, database size and slow queries",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
 	}
 }
 
+func (p *DatabaseProfile) RequiredEnv() []EnvSpec {
+	return append([]EnvSpec{
+		{Name: "DATABASE_URL", Required: true, Description: "PostgreSQL connection string"},
+		{Name: "READ_ONLY", Required: false, Description: "Set to false to allow non-SELECT statements (default true)"},
+		{Name: "MAX_ROWS", Required: false, Description: "Maximum rows returned per query (default 100, max 1000)"},
+	}, sshTunnelEnv...)
+}
+
 func (p *DatabaseProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	return p.CallToolContext(context.Background(), name, args, env)
+}
+
+func (p *DatabaseProfile) CallToolContext(ctx context.Context, name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "query":
-		return p.query(args, env)
+		return p.query(ctx, args, env)
 	case "list_tables":
 		return p.listTables(args, env)
 	case "describe_table":
 		return p.describeTable(args, env)
 	case "explain_query":
 		return p.explainQuery(args, env)
+	case "table_stats":
+		return p.tableStats(args, env)
+	case "aggregate":
+		return p.aggregate(ctx, args, env)
+	case "test_connection":
+		return p.testConnection(env)
+	case "db_stats":
+		return p.dbStats(env)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
 }
 
+// Complete suggests table and schema names from the connected database
+func (p *DatabaseProfile) Complete(tool, arg, partial string, env map[string]string) ([]string, error) {
+	var q string
+	switch arg {
+	case "table":
+		q = `SELECT table_name FROM information_schema.tables
+			WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+			AND table_name ILIKE '%' || $1 || '%'
+			ORDER BY table_name LIMIT 100`
+	case "schema":
+		q = `SELECT schema_name FROM information_schema.schemata
+			WHERE schema_name NOT IN ('pg_catalog', 'information_schema') AND schema_name NOT LIKE 'pg_toast%'
+			AND schema_name ILIKE '%' || $1 || '%'
+			ORDER BY schema_name LIMIT 100`
+	default:
+		return nil, nil
+	}
+
+	db, err := p.getDB(env)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), dbCheckTimeout)
+	defer cancel()
+
+	// Escape LIKE wildcards so partial is matched literally
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(partial)
+	rows, err := db.QueryContext(ctx, q, escaped)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %s", err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			names = append(names, name)
+		}
+	}
+	return names, rows.Err()
+}
+
 func (p *DatabaseProfile) getDB(env map[string]string) (*sql.DB, error) {
 	dsn := env["DATABASE_URL"]
 	if dsn == "" {
-		return nil, fmt.Errorf("DATABASE_URL is not configured")
+		return nil, notConfiguredf("DATABASE_URL is not configured")
 	}
-	db, err := sql.Open("postgres", dsn)
+	tunnel, err := sshTunnelFor(env)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect: %s", err)
+		return nil, err
+	}
+	var db *sql.DB
+	if tunnel != nil {
+		// The DSN's host is dialed from the bastion
+		connector, err := pq.NewConnector(dsn)
+		if err != nil {
+			return nil, notConfiguredf("invalid DATABASE_URL: %s", err)
+		}
+		connector.Dialer(tunnel)
+		db = sql.OpenDB(connector)
+	} else if db, err = sql.Open("postgres", dsn); err != nil {
+		return nil, backendErrorf("failed to connect: %s", err)
 	}
 	db.SetMaxOpenConns(1)
 	db.SetMaxIdleConns(0)
 	return db, nil
 }
 
-func (p *DatabaseProfile) query(args map[string]interface{}, env map[string]string) (string, error) {
+// dbMaxRows returns the connection's MAX_ROWS, capped at 1000
+func dbMaxRows(env map[string]string) int {
+	maxRows := 100
+	if n, err := strconv.Atoi(env["MAX_ROWS"]); err == nil && n > 0 {
+		maxRows = n
+	}
+	if maxRows > 1000 {
+		maxRows = 1000
+	}
+	return maxRows
+}
+
+// slowQueryThreshold is the duration above which query logs a warning
+const slowQueryThreshold = time.Second
+
+func (p *DatabaseProfile) query(ctx context.Context, args map[string]interface{}, env map[string]string) (string, error) {
 	sqlStr := getStr(args, "sql")
 	if sqlStr == "" {
-		return "", fmt.Errorf("sql is required")
+		return "", invalidInputf("sql is required")
 	}
 
 	// Safety: only allow SELECT and WITH (CTE) statements
 	normalized := strings.ToUpper(strings.TrimSpace(sqlStr))
 	if !strings.HasPrefix(normalized, "SELECT") && !strings.HasPrefix(normalized, "WITH") {
-		readOnly := env["READ_ONLY"]
-		if readOnly == "" || readOnly == "true" {
-			return "", fmt.Errorf("only SELECT queries are allowed (READ_ONLY mode)")
+		if ReadOnlyMode(env) {
+			return "", forbiddenf("only SELECT queries are allowed (READ_ONLY mode)")
 		}
 	}
 
 	// Block dangerous statements even in write mode
-	for _, kw := range []string{"DROP ", "TRUNCATE ", "ALTER ", "GRANT ", "REVOKE "} {
+	for _, kw := range blockedSQLKeywords {
 		if strings.Contains(normalized, kw) {
-			return "", fmt.Errorf("%s statements are blocked for safety", strings.TrimSpace(kw))
+			return "", forbiddenf("%s statements are blocked for safety", strings.TrimSpace(kw))
 		}
 	}
 
@@ -120,15 +262,7 @@ func (p *DatabaseProfile) query(args map[string]interface{}, env map[string]stri
 	}
 	defer db.Close()
 
-	maxRows := 100
-	if mr := env["MAX_ROWS"]; mr != "" {
-		if n, err := strconv.Atoi(mr); err == nil && n > 0 {
-			maxRows = n
-		}
-	}
-	if maxRows > 1000 {
-		maxRows = 1000
-	}
+	maxRows := dbMaxRows(env)
 
 	// Add LIMIT if not present (only for SELECT/WITH queries)
 	isSelect := strings.HasPrefix(normalized, "SELECT") || strings.HasPrefix(normalized, "WITH")
@@ -136,15 +270,39 @@ func (p *DatabaseProfile) query(args map[string]interface{}, env map[string]stri
 		sqlStr = sqlStr + fmt.Sprintf(" LIMIT %d", maxRows)
 	}
 
-	rows, err := db.Query(sqlStr)
+	logf(ctx, "debug", "running query: %s", sqlStr)
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, sqlStr)
 	if err != nil {
+		logf(ctx, "error", "query failed after %s: %s", time.Since(start).Round(time.Millisecond), err)
 		return "", fmt.Errorf("query failed: %s", err)
 	}
 	defer rows.Close()
 
+	columns, results, err := scanRowMaps(rows)
+	if err != nil {
+		return "", err
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if elapsed > slowQueryThreshold {
+		logf(ctx, "warning", "slow query: %d rows in %s", len(results), elapsed)
+	} else {
+		logf(ctx, "info", "query returned %d rows in %s", len(results), elapsed)
+	}
+
+	if len(results) == 0 {
+		return fmt.Sprintf("Query returned 0 rows\nColumns: %s", strings.Join(columns, ", ")), nil
+	}
+
+	output, _ := json.MarshalIndent(results, "", "  ")
+	return fmt.Sprintf("Rows: %d\nColumns: %s\n\n%s", len(results), strings.Join(columns, ", "), string(output)), nil
+}
+
+// scanRowMaps reads all rows into column→value maps, with []byte values as strings
+func scanRowMaps(rows *sql.Rows) ([]string, []map[string]interface{}, error) {
 	columns, err := rows.Columns()
 	if err != nil {
-		return "", fmt.Errorf("failed to get columns: %s", err)
+		return nil, nil, fmt.Errorf("failed to get columns: %s", err)
 	}
 
 	var results []map[string]interface{}
@@ -168,20 +326,32 @@ func (p *DatabaseProfile) query(args map[string]interface{}, env map[string]stri
 		}
 		results = append(results, row)
 	}
-
-	if len(results) == 0 {
-		return fmt.Sprintf("Query returned 0 rows\nColumns: %s", strings.Join(columns, ", ")), nil
-	}
-
-	output, _ := json.MarshalIndent(results, "", "  ")
-	return fmt.Sprintf("Rows: %d\nColumns: %s\n\n%s", len(results), strings.Join(columns, ", "), string(output)), nil
+	return columns, results, nil
 }
 
+const (
+	listTablesDefaultLimit = 200
+	listTablesMaxLimit     = 1000
+)
+
 func (p *DatabaseProfile) listTables(args map[string]interface{}, env map[string]string) (string, error) {
 	schema := getStr(args, "schema")
 	if schema == "" {
 		schema = "public"
 	}
+	withCounts, _ := args["include_counts"].(bool)
+	withComments, _ := args["include_comments"].(bool)
+	limit := int(getFloat(args, "limit"))
+	if limit <= 0 {
+		limit = listTablesDefaultLimit
+	}
+	if limit > listTablesMaxLimit {
+		limit = listTablesMaxLimit
+	}
+	offset := int(getFloat(args, "offset"))
+	if offset < 0 {
+		return "", invalidInputf("offset must not be negative")
+	}
 
 	db, err := p.getDB(env)
 	if err != nil {
@@ -189,36 +359,71 @@ func (p *DatabaseProfile) listTables(args map[string]interface{}, env map[string
 	}
 	defer db.Close()
 
+	// Counts come from pg_class.reltuples, the planner's estimate, so even
+	// huge tables cost nothing to count
 	rows, err := db.Query(`
-		SELECT table_name, table_type
-		FROM information_schema.tables
-		WHERE table_schema = $1
-		ORDER BY table_name
-	`, schema)
+		SELECT t.table_name, t.table_type,
+			CASE WHEN $2 AND t.table_type = 'BASE TABLE' THEN c.reltuples::bigint END,
+			CASE WHEN $3 THEN obj_description(c.oid, 'pg_class') END,
+			count(*) OVER ()
+		FROM information_schema.tables t
+		LEFT JOIN pg_catalog.pg_namespace n ON n.nspname = t.table_schema
+		LEFT JOIN pg_catalog.pg_class c ON c.relnamespace = n.oid AND c.relname = t.table_name
+		WHERE t.table_schema = $1
+		ORDER BY t.table_name
+		LIMIT $4 OFFSET $5
+	`, schema, withCounts, withComments, limit, offset)
 	if err != nil {
 		return "", fmt.Errorf("query failed: %s", err)
 	}
 	defer rows.Close()
 
 	var lines []string
-	count := 0
+	total := 0
 	for rows.Next() {
 		var name, tableType string
-		rows.Scan(&name, &tableType)
-		lines = append(lines, fmt.Sprintf("  %s (%s)", name, tableType))
-		count++
+		var estimate *int64
+		var comment *string
+		if err := rows.Scan(&name, &tableType, &estimate, &comment, &total); err != nil {
+			return "", fmt.Errorf("query failed: %s", err)
+		}
+		line := fmt.Sprintf("  %s (%s)", name, tableType)
+		if estimate != nil {
+			if *estimate < 0 {
+				line += ", rows unknown (never analyzed)"
+			} else {
+				line += fmt.Sprintf(", ~%d rows", *estimate)
+			}
+		}
+		if comment != nil && *comment != "" {
+			line += " -- " + strings.ReplaceAll(*comment, "\n", " ")
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("query failed: %s", err)
 	}
 
-	if count == 0 {
+	if len(lines) == 0 {
+		if offset > 0 {
+			return fmt.Sprintf("No tables in schema '%s' past offset %d", schema, offset), nil
+		}
 		return fmt.Sprintf("No tables found in schema '%s'", schema), nil
 	}
-	return fmt.Sprintf("Tables in '%s' (%d):\n%s", schema, count, strings.Join(lines, "\n")), nil
+	if offset == 0 && len(lines) == total {
+		return fmt.Sprintf("Tables in '%s' (%d):\n%s", schema, total, strings.Join(lines, "\n")), nil
+	}
+	result := fmt.Sprintf("Tables in '%s' (%d-%d of %d):\n%s", schema, offset+1, offset+len(lines), total, strings.Join(lines, "\n"))
+	if next := offset + len(lines); next < total {
+		result += fmt.Sprintf("\n\n... %d more; continue with offset=%d", total-next, next)
+	}
+	return result, nil
 }
 
 func (p *DatabaseProfile) describeTable(args map[string]interface{}, env map[string]string) (string, error) {
 	table := getStr(args, "table")
 	if table == "" {
-		return "", fmt.Errorf("table is required")
+		return "", invalidInputf("table is required")
 	}
 	schema := getStr(args, "schema")
 	if schema == "" {
@@ -234,7 +439,8 @@ func (p *DatabaseProfile) describeTable(args map[string]interface{}, env map[str
 	rows, err := db.Query(`
 		SELECT
 			column_name, data_type, character_maximum_length,
-			is_nullable, column_default
+			is_nullable, column_default,
+			col_description(format('%I.%I', table_schema, table_name)::regclass, ordinal_position)
 		FROM information_schema.columns
 		WHERE table_schema = $1 AND table_name = $2
 		ORDER BY ordinal_position
@@ -253,8 +459,8 @@ func (p *DatabaseProfile) describeTable(args map[string]interface{}, env map[str
 	for rows.Next() {
 		var colName, dataType, nullable string
 		var maxLen *int
-		var defaultVal *string
-		rows.Scan(&colName, &dataType, &maxLen, &nullable, &defaultVal)
+		var defaultVal, comment *string
+		rows.Scan(&colName, &dataType, &maxLen, &nullable, &defaultVal, &comment)
 
 		typeStr := dataType
 		if maxLen != nil {
@@ -264,7 +470,11 @@ func (p *DatabaseProfile) describeTable(args map[string]interface{}, env map[str
 		if defaultVal != nil {
 			defStr = *defaultVal
 		}
-		lines = append(lines, fmt.Sprintf("%-30s %-20s %-10s %-30s", colName, typeStr, nullable, defStr))
+		line := fmt.Sprintf("%-30s %-20s %-10s %-30s", colName, typeStr, nullable, defStr)
+		if comment != nil && *comment != "" {
+			line += " -- " + strings.ReplaceAll(*comment, "\n", " ")
+		}
+		lines = append(lines, line)
 		count++
 	}
 
@@ -272,6 +482,12 @@ func (p *DatabaseProfile) describeTable(args map[string]interface{}, env map[str
 		return fmt.Sprintf("Table '%s.%s' not found", schema, table), nil
 	}
 
+	if constraints, err := p.tableConstraints(db, schema, table); err == nil && len(constraints) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, "Constraints:")
+		lines = append(lines, constraints...)
+	}
+
 	// Also show indexes
 	idxRows, err := db.Query(`
 		SELECT indexname, indexdef
@@ -292,24 +508,212 @@ func (p *DatabaseProfile) describeTable(args map[string]interface{}, env map[str
 	return strings.Join(lines, "\n"), nil
 }
 
+func (p *DatabaseProfile) tableStats(args map[string]interface{}, env map[string]string) (string, error) {
+	table := getStr(args, "table")
+	if table == "" {
+		return "", invalidInputf("table is required")
+	}
+	schema := getStr(args, "schema")
+	if schema == "" {
+		schema = "public"
+	}
+	sample := int(getFloat(args, "sample"))
+	if sample < 0 {
+		sample = 0
+	}
+	if sample > 20 {
+		sample = 20
+	}
+
+	db, err := p.getDB(env)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	// reltuples is the planner's estimate, so no full scan is needed
+	var estimate float64
+	var kind, totalSize, tableSize, indexSize string
+	var live, dead *int64
+	var lastVacuum, lastAutoVacuum, lastAnalyze, lastAutoAnalyze *time.Time
+	err = db.QueryRow(`
+		SELECT c.reltuples, c.relkind,
+			pg_size_pretty(pg_total_relation_size(c.oid)),
+			pg_size_pretty(pg_relation_size(c.oid)),
+			pg_size_pretty(pg_indexes_size(c.oid)),
+			s.n_live_tup, s.n_dead_tup,
+			s.last_vacuum, s.last_autovacuum, s.last_analyze, s.last_autoanalyze
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+		WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN ('r', 'p', 'm')
+	`, schema, table).Scan(&estimate, &kind, &totalSize, &tableSize, &indexSize,
+		&live, &dead, &lastVacuum, &lastAutoVacuum, &lastAnalyze, &lastAutoAnalyze)
+	if err == sql.ErrNoRows {
+		return fmt.Sprintf("Table '%s.%s' not found", schema, table), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query failed: %s", err)
+	}
+
+	latest := func(a, b *time.Time) string {
+		if a == nil || (b != nil && b.After(*a)) {
+			a = b
+		}
+		if a == nil {
+			return "never"
+		}
+		return a.UTC().Format("2006-01-02 15:04:05 UTC")
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Table: %s.%s", schema, table))
+	if estimate < 0 {
+		// -1 means the table has never been vacuumed or analyzed (PostgreSQL 14+)
+		lines = append(lines, "Rows (estimate): unknown, table not analyzed yet")
+	} else {
+		lines = append(lines, fmt.Sprintf("Rows (estimate): %.0f", estimate))
+	}
+	if live != nil && dead != nil {
+		lines = append(lines, fmt.Sprintf("Live/dead tuples: %d / %d", *live, *dead))
+	}
+	lines = append(lines, fmt.Sprintf("Size: %s total (%s table, %s indexes)", totalSize, tableSize, indexSize))
+	lines = append(lines, fmt.Sprintf("Last vacuum: %s", latest(lastVacuum, lastAutoVacuum)))
+	lines = append(lines, fmt.Sprintf("Last analyze: %s", latest(lastAnalyze, lastAutoAnalyze)))
+
+	if sample == 0 {
+		return strings.Join(lines, "\n"), nil
+	}
+
+	// SYSTEM sampling reads whole pages, so ask for a few times more rows than
+	// needed; small tables are read directly since sampling could return nothing
+	from := pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(table)
+	if estimate > 1000 {
+		pct := 100 * float64(sample) * 5 / estimate
+		if pct < 0.01 {
+			pct = 0.01
+		}
+		if pct < 100 {
+			from += fmt.Sprintf(" TABLESAMPLE SYSTEM (%.4f)", pct)
+		}
+	}
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s LIMIT %d", from, sample))
+	if err != nil {
+		return "", fmt.Errorf("sample failed: %s", err)
+	}
+	defer rows.Close()
+	_, results, err := scanRowMaps(rows)
+	if err != nil {
+		return "", err
+	}
+	output, _ := json.MarshalIndent(results, "", "  ")
+	lines = append(lines, "", fmt.Sprintf("Sample rows (%d):", len(results)), string(output))
+	return strings.Join(lines, "\n"), nil
+}
+
+// tableConstraints describes the primary key, foreign keys (with the columns
+// they reference), unique and check constraints of a table, one per line
+func (p *DatabaseProfile) tableConstraints(db *sql.DB, schema, table string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT tc.constraint_name, tc.constraint_type, kcu.column_name,
+			ref.table_schema, ref.table_name, ref.column_name, cc.check_clause
+		FROM information_schema.table_constraints tc
+		LEFT JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_schema = tc.constraint_schema
+			AND kcu.constraint_name = tc.constraint_name
+			AND kcu.table_name = tc.table_name
+		LEFT JOIN information_schema.referential_constraints rc
+			ON rc.constraint_schema = tc.constraint_schema
+			AND rc.constraint_name = tc.constraint_name
+		LEFT JOIN information_schema.key_column_usage ref
+			ON ref.constraint_schema = rc.unique_constraint_schema
+			AND ref.constraint_name = rc.unique_constraint_name
+			AND ref.ordinal_position = kcu.position_in_unique_constraint
+		LEFT JOIN information_schema.check_constraints cc
+			ON cc.constraint_schema = tc.constraint_schema
+			AND cc.constraint_name = tc.constraint_name
+		WHERE tc.table_schema = $1 AND tc.table_name = $2
+			AND tc.constraint_type IN ('PRIMARY KEY', 'FOREIGN KEY', 'UNIQUE', 'CHECK')
+		ORDER BY CASE tc.constraint_type
+				WHEN 'PRIMARY KEY' THEN 1 WHEN 'FOREIGN KEY' THEN 2 WHEN 'UNIQUE' THEN 3 ELSE 4 END,
+			tc.constraint_name, kcu.ordinal_position
+	`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type constraint struct {
+		name, kind, check string
+		columns, refCols  []string
+		refTable          string
+	}
+	var order []string
+	byName := map[string]*constraint{}
+	for rows.Next() {
+		var name, kind string
+		var column, refSchema, refTable, refColumn, check *string
+		if err := rows.Scan(&name, &kind, &column, &refSchema, &refTable, &refColumn, &check); err != nil {
+			continue
+		}
+		c, ok := byName[name]
+		if !ok {
+			c = &constraint{name: name, kind: kind}
+			byName[name] = c
+			order = append(order, name)
+		}
+		if column != nil {
+			c.columns = append(c.columns, *column)
+		}
+		if refTable != nil && refColumn != nil {
+			c.refTable = *refTable
+			if refSchema != nil && *refSchema != schema {
+				c.refTable = *refSchema + "." + *refTable
+			}
+			c.refCols = append(c.refCols, *refColumn)
+		}
+		if check != nil {
+			c.check = *check
+		}
+	}
+
+	var lines []string
+	for _, name := range order {
+		c := byName[name]
+		cols := strings.Join(c.columns, ", ")
+		switch c.kind {
+		case "FOREIGN KEY":
+			lines = append(lines, fmt.Sprintf("  FOREIGN KEY (%s) -> %s(%s)  [%s]", cols, c.refTable, strings.Join(c.refCols, ", "), name))
+		case "CHECK":
+			// Postgres reports each NOT NULL column as a CHECK here; the Nullable column already covers those
+			if strings.HasSuffix(c.check, "IS NOT NULL") && strings.HasSuffix(name, "_not_null") {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("  CHECK %s  [%s]", c.check, name))
+		default:
+			lines = append(lines, fmt.Sprintf("  %s (%s)  [%s]", c.kind, cols, name))
+		}
+	}
+	return lines, nil
+}
+
 func (p *DatabaseProfile) explainQuery(args map[string]interface{}, env map[string]string) (string, error) {
 	sqlStr := getStr(args, "sql")
 	if sqlStr == "" {
-		return "", fmt.Errorf("sql is required")
+		return "", invalidInputf("sql is required")
 	}
 
 	// EXPLAIN ANALYZE actually executes the query, so enforce same safety checks
 	normalized := strings.ToUpper(strings.TrimSpace(sqlStr))
 	if !strings.HasPrefix(normalized, "SELECT") && !strings.HasPrefix(normalized, "WITH") {
-		readOnly := env["READ_ONLY"]
-		if readOnly == "" || readOnly == "true" {
-			return "", fmt.Errorf("only SELECT queries can be explained (READ_ONLY mode)")
+		if ReadOnlyMode(env) {
+			return "", forbiddenf("only SELECT queries can be explained (READ_ONLY mode)")
 		}
 	}
 
-	for _, kw := range []string{"DROP ", "TRUNCATE ", "ALTER ", "GRANT ", "REVOKE "} {
+	for _, kw := range blockedSQLKeywords {
 		if strings.Contains(normalized, kw) {
-			return "", fmt.Errorf("%s statements cannot be explained for safety", strings.TrimSpace(kw))
+			return "", forbiddenf("%s statements cannot be explained for safety", strings.TrimSpace(kw))
 		}
 	}
 
@@ -333,3 +737,137 @@ func (p *DatabaseProfile) explainQuery(args map[string]interface{}, env map[stri
 	}
 	return fmt.Sprintf("Query Plan:\n\n%s", strings.Join(lines, "\n")), nil
 }
+
+// dbCheckTimeout bounds test_connection and db_stats so an unreachable
+// server fails fast instead of hanging on the driver's defaults
+const dbCheckTimeout = 5 * time.Second
+
+func (p *DatabaseProfile) testConnection(env map[string]string) (string, error) {
+	db, err := p.getDB(env)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := db.PingContext(ctx); err != nil {
+		return "", backendErrorf("cannot connect: %s", err)
+	}
+	latency := time.Since(start)
+
+	var version, database, user string
+	if err := db.QueryRowContext(ctx, "SELECT current_setting('server_version'), current_database(), current_user").
+		Scan(&version, &database, &user); err != nil {
+		return "", backendErrorf("connected, but the server did not answer: %s", err)
+	}
+	return fmt.Sprintf("Connection: OK\nServer: PostgreSQL %s\nDatabase: %s\nUser: %s\nLatency: %s",
+		version, database, user, latency.Round(time.Millisecond)), nil
+}
+
+func (p *DatabaseProfile) dbStats(env map[string]string) (string, error) {
+	db, err := p.getDB(env)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*dbCheckTimeout)
+	defer cancel()
+
+	var version, database, size string
+	var maxConns int
+	err = db.QueryRowContext(ctx, `
+		SELECT current_setting('server_version'), current_database(),
+			pg_size_pretty(pg_database_size(current_database())),
+			current_setting('max_connections')::int
+	`).Scan(&version, &database, &size, &maxConns)
+	if err != nil {
+		return "", backendErrorf("query failed: %s", err)
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Server: PostgreSQL %s", version))
+	lines = append(lines, fmt.Sprintf("Database: %s (%s)", database, size))
+
+	// Connections by state across the whole server
+	total := 0
+	var states []string
+	if rows, err := db.QueryContext(ctx, `
+		SELECT COALESCE(state, 'background'), count(*)
+		FROM pg_stat_activity
+		GROUP BY 1 ORDER BY 2 DESC
+	`); err == nil {
+		for rows.Next() {
+			var state string
+			var n int
+			rows.Scan(&state, &n)
+			total += n
+			states = append(states, fmt.Sprintf("%s %d", state, n))
+		}
+		rows.Close()
+	}
+	lines = append(lines, fmt.Sprintf("Connections: %d of %d (%s)", total, maxConns, strings.Join(states, ", ")))
+
+	var commits, rollbacks, deadlocks int64
+	var hitRatio sql.NullFloat64
+	if err := db.QueryRowContext(ctx, `
+		SELECT xact_commit, xact_rollback, deadlocks,
+			round(100.0 * blks_hit / NULLIF(blks_hit + blks_read, 0), 2)
+		FROM pg_stat_database WHERE datname = current_database()
+	`).Scan(&commits, &rollbacks, &deadlocks, &hitRatio); err == nil {
+		lines = append(lines, fmt.Sprintf("Transactions: %d committed, %d rolled back, %d deadlocks", commits, rollbacks, deadlocks))
+		if hitRatio.Valid {
+			lines = append(lines, fmt.Sprintf("Cache hit ratio: %.2f%%", hitRatio.Float64))
+		}
+	}
+
+	// Statements that have been running for a while right now
+	lines = append(lines, "", "Long-running queries (> 5s):")
+	running := 0
+	if rows, err := db.QueryContext(ctx, `
+		SELECT pid, now() - query_start, left(regexp_replace(query, '\s+', ' ', 'g'), 120)
+		FROM pg_stat_activity
+		WHERE state = 'active' AND pid <> pg_backend_pid()
+			AND now() - query_start > interval '5 seconds'
+		ORDER BY 2 DESC LIMIT 5
+	`); err == nil {
+		for rows.Next() {
+			var pid int
+			var duration, query string
+			rows.Scan(&pid, &duration, &query)
+			lines = append(lines, fmt.Sprintf("  pid %d, %s: %s", pid, duration, query))
+			running++
+		}
+		rows.Close()
+	}
+	if running == 0 {
+		lines = append(lines, "  none")
+	}
+
+	// Historical slow statements, only if pg_stat_statements is installed (13+ column names)
+	if rows, err := db.QueryContext(ctx, `
+		SELECT calls, round(mean_exec_time::numeric, 1), left(regexp_replace(query, '\s+', ' ', 'g'), 120)
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY mean_exec_time DESC LIMIT 5
+	`); err == nil {
+		var slow []string
+		for rows.Next() {
+			var calls int64
+			var mean float64
+			var query string
+			rows.Scan(&calls, &mean, &query)
+			slow = append(slow, fmt.Sprintf("  %.1f ms avg over %d calls: %s", mean, calls, query))
+		}
+		rows.Close()
+		if len(slow) > 0 {
+			lines = append(lines, "", "Slowest statements (pg_stat_statements):")
+			lines = append(lines, slow...)
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,151 @@
+package profiles
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const aggregateDefaultTop = 10
+
+// Column types aggregate treats as numbers or times (min/max, and avg for
+// numbers); other types get their most common values instead. json and xml
+// have no equality operator, so they are only counted.
+var (
+	aggregateNumericTypes = map[string]bool{
+		"smallint": true, "integer": true, "bigint": true, "numeric": true,
+		"real": true, "double precision": true, "money": true,
+	}
+	aggregateUncomparableTypes = map[string]bool{"json": true, "xml": true}
+)
+
+// aggregate summarizes one column: row, null and distinct counts, min/max
+// (and avg) for numbers and times, the most common values for the rest. The
+// SQL is built from the column's catalog entry with quoted identifiers and
+// runs in a read-only transaction.
+func (p *DatabaseProfile) aggregate(ctx context.Context, args map[string]interface{}, env map[string]string) (string, error) {
+	table := getStr(args, "table")
+	column := getStr(args, "column")
+	if table == "" || column == "" {
+		return "", invalidInputf("table and column are required")
+	}
+	schema := getStr(args, "schema")
+	if schema == "" {
+		schema = "public"
+	}
+	top := aggregateDefaultTop
+	if v, ok := args["top"]; ok && v != nil {
+		top = int(getFloat(args, "top"))
+	}
+	if top < 0 {
+		return "", invalidInputf("top must not be negative")
+	}
+	if maxRows := dbMaxRows(env); top > maxRows {
+		top = maxRows
+	}
+
+	db, err := p.getDB(env)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var dataType string
+	err = db.QueryRowContext(ctx, `
+		SELECT data_type FROM information_schema.columns
+		WHERE table_schema = $1 AND table_name = $2 AND column_name = $3
+	`, schema, table, column).Scan(&dataType)
+	if err == sql.ErrNoRows {
+		return fmt.Sprintf("Column '%s' not found in table '%s.%s'", column, schema, table), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query failed: %s", err)
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", backendErrorf("begin failed: %s", err)
+	}
+	defer tx.Rollback()
+
+	from := pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(table)
+	col := pq.QuoteIdentifier(column)
+	numeric := aggregateNumericTypes[dataType]
+	temporal := strings.HasPrefix(dataType, "timestamp") || strings.HasPrefix(dataType, "time") ||
+		dataType == "date" || dataType == "interval"
+	equatable := !aggregateUncomparableTypes[dataType]
+
+	selects := []string{"count(*)", fmt.Sprintf("count(%s)", col)}
+	if equatable {
+		selects = append(selects, fmt.Sprintf("count(DISTINCT %s)", col))
+	}
+	if numeric || temporal {
+		selects = append(selects, fmt.Sprintf("min(%s)::text", col), fmt.Sprintf("max(%s)::text", col))
+	}
+	if numeric && dataType != "money" {
+		selects = append(selects, fmt.Sprintf("avg(%s)::float8", col))
+	}
+
+	logf(ctx, "debug", "aggregating %s.%s", from, col)
+	var total, nonNull, distinct int64
+	var minVal, maxVal *string
+	var avg *float64
+	dest := []interface{}{&total, &nonNull}
+	if equatable {
+		dest = append(dest, &distinct)
+	}
+	if numeric || temporal {
+		dest = append(dest, &minVal, &maxVal)
+	}
+	if numeric && dataType != "money" {
+		dest = append(dest, &avg)
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT "+strings.Join(selects, ", ")+" FROM "+from).Scan(dest...); err != nil {
+		return "", fmt.Errorf("query failed: %s", err)
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Column: %s.%s.%s (%s)", schema, table, column, dataType))
+	lines = append(lines, fmt.Sprintf("Rows: %d", total))
+	lines = append(lines, fmt.Sprintf("Non-null: %d (%d null)", nonNull, total-nonNull))
+	if equatable {
+		lines = append(lines, fmt.Sprintf("Distinct: %d", distinct))
+	}
+	if minVal != nil && maxVal != nil {
+		lines = append(lines, fmt.Sprintf("Min: %s", *minVal), fmt.Sprintf("Max: %s", *maxVal))
+	}
+	if avg != nil {
+		lines = append(lines, fmt.Sprintf("Avg: %s", strconv.FormatFloat(*avg, 'f', -1, 64)))
+	}
+
+	if numeric || temporal || !equatable || top == 0 || nonNull == 0 {
+		return strings.Join(lines, "\n"), nil
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s::text, count(*) FROM %s WHERE %s IS NOT NULL GROUP BY 1 ORDER BY 2 DESC, 1 LIMIT $1",
+		col, from, col), top)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %s", err)
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		var count int64
+		if err := rows.Scan(&value, &count); err != nil {
+			return "", fmt.Errorf("scan failed: %s", err)
+		}
+		values = append(values, fmt.Sprintf("  %-30s %d (%.1f%%)", strings.ReplaceAll(value, "\n", " "), count, 100*float64(count)/float64(total)))
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("query failed: %s", err)
+	}
+	lines = append(lines, "", fmt.Sprintf("Top %d values:", len(values)))
+	lines = append(lines, values...)
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,196 @@
+package profiles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Connection identity and limits the gateway adds to every connection's
+// env, so tools can report which connection served them. Values set in the
+// connection config under these names are overwritten.
+const (
+	EnvConnectionID      = "MCP_CONNECTION_ID"
+	EnvConnectionSlug    = "MCP_CONNECTION_SLUG"
+	EnvConnectionDomain  = "MCP_CONNECTION_DOMAIN"
+	EnvConnectionProfile = "MCP_PROFILE"
+	EnvServerID          = "MCP_SERVER_ID"
+	EnvGatewayID         = "MCP_GATEWAY_ID"
+
+	// Effective limits, with defaults applied
+	EnvRateLimit          = "MCP_RATE_LIMIT"
+	EnvMaxSessions        = "MCP_MAX_SESSIONS"
+	EnvMaxToolConcurrency = "MCP_MAX_TOOL_CONCURRENCY"
+)
+
+// DiagnosticsProfile has side-effect-free tools for checking that auth,
+// routing and the transport work end to end on a new connection, plus
+// run_sequence for clients that prefer to send a plan of tool calls at once
+type DiagnosticsProfile struct{}
+
+func (p *DiagnosticsProfile) ID() string { return "diagnostics" }
+
+func (p *DiagnosticsProfile) Tools() []Tool {
+	return []Tool{
+		{
+			Name:        "echo",
+			Description: "Return the arguments exactly as the gateway received them",
+			InputSchema: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": true,
+				"properties": map[string]interface{}{
+					"message": map[string]interface{}{"type": "string", "description": "Text to echo back; any other arguments are echoed too"},
+				},
+			},
+		},
+		{
+			Name:        "whoami",
+			Description: "Show which connection, profile and server handled this call",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "now",
+			Description: "Return the gateway's current time, to check clock skew and round-trip latency",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "describe_tools",
+			Description: "Return this connection's tools with their descriptions and input schemas as JSON, as tools/list does",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tool": map[string]interface{}{"type": "string", "description": "Only describe this tool"},
+				},
+			},
+		},
+		{
+			Name: "run_sequence",
+			Description: "Call several tools of this connection in order and return every result in one response. " +
+				"Each step is a normal tool call, subject to the same timeouts and limits.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"steps": map[string]interface{}{
+						"type":        "array",
+						"description": fmt.Sprintf("Tool calls to make, in order (at most %d)", sequenceMaxSteps),
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"tool": map[string]interface{}{"type": "string", "description": "Tool name"},
+								"args": map[string]interface{}{"type": "object", "description": "Tool arguments"},
+							},
+							"required": []string{"tool"},
+						},
+					},
+					"stop_on_error": map[string]interface{}{"type": "boolean", "description": "Skip the remaining steps after one fails (default true)"},
+					"format":        map[string]interface{}{"type": "string", "enum": []string{"text", "json"}, "description": "text: a readable report (default); json: an array of {step, tool, status, output}"},
+				},
+				"required": []string{"steps"},
+			},
+		},
+	}
+}
+
+func (p *DiagnosticsProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	return p.CallToolContext(context.Background(), name, args, env)
+}
+
+func (p *DiagnosticsProfile) CallToolContext(ctx context.Context, name string, args map[string]interface{}, env map[string]string) (string, error) {
+	switch name {
+	case "run_sequence":
+		return p.runSequence(ctx, args)
+	case "echo":
+		return p.echo(args)
+	case "whoami":
+		return p.whoami(env), nil
+	case "now":
+		return p.now(), nil
+	case "describe_tools":
+		return p.describeTools(args, env)
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+func (p *DiagnosticsProfile) echo(args map[string]interface{}) (string, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	out, err := json.MarshalIndent(args, "", "  ")
+	if err != nil {
+		return "", invalidInputf("arguments are not JSON-encodable: %v", err)
+	}
+	return string(out), nil
+}
+
+// describeTools lists the tools of the whole connection, which may serve
+// other profiles next to this one
+func (p *DiagnosticsProfile) describeTools(args map[string]interface{}, env map[string]string) (string, error) {
+	var conn Profile = p
+	if spec := env[EnvConnectionProfile]; spec != "" {
+		resolved, err := Resolve(spec)
+		if err != nil {
+			return "", notConfiguredf("%s", err)
+		}
+		conn = resolved
+	}
+	tools, err := ToolsFor(conn, env)
+	if err != nil {
+		return "", backendErrorf("cannot list tools: %s", err)
+	}
+	tools = WithDryRunArg(conn, tools)
+
+	type toolDescription struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		InputSchema map[string]interface{} `json:"inputSchema"`
+	}
+	only := getStr(args, "tool")
+	out := []toolDescription{}
+	for _, t := range tools {
+		if only == "" || t.Name == only {
+			out = append(out, toolDescription{t.Name, t.Description, t.InputSchema})
+		}
+	}
+	if only != "" && len(out) == 0 {
+		return "", invalidInputf("no tool named %s on this connection", only)
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("cannot encode tools: %s", err)
+	}
+	return string(data), nil
+}
+
+func (p *DiagnosticsProfile) whoami(env map[string]string) string {
+	var sb strings.Builder
+	for _, f := range []struct{ label, key string }{
+		{"Connection", EnvConnectionID},
+		{"Slug", EnvConnectionSlug},
+		{"Domain", EnvConnectionDomain},
+		{"Profile", EnvConnectionProfile},
+		{"Server", EnvServerID},
+		{"Gateway", EnvGatewayID},
+	} {
+		value := env[f.key]
+		if value == "" {
+			value = "(unknown)"
+		}
+		fmt.Fprintf(&sb, "%-11s %s\n", f.label+":", value)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (p *DiagnosticsProfile) now() string {
+	now := time.Now()
+	return fmt.Sprintf("UTC:   %s\nLocal: %s\nUnix:  %d (ms %d)",
+		now.UTC().Format(time.RFC3339Nano), now.Format(time.RFC3339Nano), now.Unix(), now.UnixMilli())
+}
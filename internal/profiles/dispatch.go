@@ -0,0 +1,50 @@
+package profiles
+
+import "fmt"
+
+// ToolHandler executes a single tool
+type ToolHandler func(args map[string]interface{}, env map[string]string) (string, error)
+
+// Dispatcher maps tool names to their handlers. Profiles can build one in
+// Handlers() and delegate CallTool to Dispatcher.Call instead of hand-writing
+// a switch over tool names.
+type Dispatcher map[string]ToolHandler
+
+// HandlerProvider is implemented by profiles that dispatch through a Dispatcher.
+// The package tests check registered profiles implementing it for tools that
+// are listed in Tools() but have no handler.
+type HandlerProvider interface {
+	Handlers() Dispatcher
+}
+
+// Call runs the handler registered for name
+func (d Dispatcher) Call(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	h, ok := d[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+	return h(args, env)
+}
+
+// argsOnly adapts a handler that doesn't need env
+func argsOnly(fn func(args map[string]interface{}) (string, error)) ToolHandler {
+	return func(args map[string]interface{}, env map[string]string) (string, error) {
+		return fn(args)
+	}
+}
+
+// checkHandlers returns the tools listed by p that have no registered handler
+func checkHandlers(p Profile) []string {
+	hp, ok := p.(HandlerProvider)
+	if !ok {
+		return nil
+	}
+	handlers := hp.Handlers()
+	var missing []string
+	for _, t := range p.Tools() {
+		if _, ok := handlers[t.Name]; !ok {
+			missing = append(missing, t.Name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,73 @@
+package profiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+// dispatchProfile lists tools and dispatches only some of them
+type dispatchProfile struct {
+	tools    []string
+	handlers []string
+}
+
+func (p *dispatchProfile) ID() string             { return "dispatch-test" }
+func (p *dispatchProfile) RequiredEnv() []EnvSpec { return nil }
+func (p *dispatchProfile) Handlers() Dispatcher   { return p.dispatcher() }
+func (p *dispatchProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	return p.dispatcher().Call(name, args, env)
+}
+
+func (p *dispatchProfile) Tools() []Tool {
+	tools := make([]Tool, len(p.tools))
+	for i, name := range p.tools {
+		tools[i] = Tool{Name: name}
+	}
+	return tools
+}
+
+func (p *dispatchProfile) dispatcher() Dispatcher {
+	d := Dispatcher{}
+	for _, name := range p.handlers {
+		name := name
+		d[name] = argsOnly(func(map[string]interface{}) (string, error) { return name, nil })
+	}
+	return d
+}
+
+func TestCheckHandlers(t *testing.T) {
+	tests := []struct {
+		name     string
+		tools    []string
+		handlers []string
+		want     []string
+	}{
+		{"all handled", []string{"a", "b"}, []string{"a", "b"}, nil},
+		{"extra handler", []string{"a"}, []string{"a", "b"}, nil},
+		{"missing handler", []string{"a", "b", "c"}, []string{"b"}, []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &dispatchProfile{tools: tt.tools, handlers: tt.handlers}
+			if got := checkHandlers(p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkHandlers = %v, want %v", got, tt.want)
+			}
+			for _, name := range tt.handlers {
+				if out, err := p.CallTool(name, nil, nil); err != nil || out != name {
+					t.Errorf("CallTool(%s) = %q, %v", name, out, err)
+				}
+			}
+			if _, err := p.CallTool("unlisted", nil, nil); err == nil {
+				t.Error("CallTool of an unknown tool succeeded")
+			}
+		})
+	}
+}
+
+func TestRegistryHandlers(t *testing.T) {
+	for id, p := range Registry {
+		if missing := checkHandlers(p); len(missing) > 0 {
+			t.Errorf("profile %s lists tools without handlers: %v", id, missing)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package profiles
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -11,6 +13,8 @@ type DnsProfile struct{}
 
 func (p *DnsProfile) ID() string { return "dns" }
 
+func (p *DnsProfile) UsesNetwork() bool { return true }
+
 func (p *DnsProfile) Tools() []Tool {
 	return []Tool{
 		{
@@ -42,28 +46,86 @@ func (p *DnsProfile) Tools() []Tool {
 		},
 		{
 			Name:        "check_port",
-			Description: "Check if a TCP port is open on a host",
+			Description: "Check if a TCP port is open on a host, reporting the address (and IP version) connected to",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"host": map[string]interface{}{"type": "string", "description": "Hostname or IP address"},
-					"port": map[string]interface{}{"type": "integer", "description": "Port number to check"},
+					"host":       map[string]interface{}{"type": "string", "description": "Hostname or IP address"},
+					"port":       map[string]interface{}{"type": "integer", "description": "Port number to check"},
+					"ip_version": ipVersionProperty(),
 				},
 				"required": []string{"host", "port"},
 			},
 		},
+		{
+			Name:        "traceroute",
+			Description: "Trace the network path to a host, listing each hop's address and round-trip time. Without raw socket access it reports DNS, TCP connect and TLS timings instead.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"host":     map[string]interface{}{"type": "string", "description": "Hostname or IP address"},
+					"max_hops": map[string]interface{}{"type": "integer", "description": "Maximum number of hops (default 20, max 30)"},
+					"port":     map[string]interface{}{"type": "integer", "description": "TCP port for the connection timing fallback (default 443)"},
+				},
+				"required": []string{"host"},
+			},
+		},
 		{
 			Name:        "resolve_host",
-			Description: "Resolve a hostname to all its IP addresses",
+			Description: "Resolve a hostname to all its IP addresses, or only its IPv4 or IPv6 ones",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"host": map[string]interface{}{"type": "string", "description": "Hostname to resolve"},
+					"host":       map[string]interface{}{"type": "string", "description": "Hostname to resolve"},
+					"ip_version": ipVersionProperty(),
 				},
 				"required": []string{"host"},
 			},
 		},
+		{
+			Name:        "whois",
+			Description: "Look up WHOIS registration data for a domain (registrar, dates, name servers)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"domain": map[string]interface{}{"type": "string", "description": "Domain name, e.g. example.com"},
+					"raw":    map[string]interface{}{"type": "boolean", "description": "Return the unparsed WHOIS response (default false)"},
+				},
+				"required": []string{"domain"},
+			},
+		},
+		{
+			Name:        "dnssec_check",
+			Description: "Check whether a domain is DNSSEC-signed and validate its chain of trust from the root",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"domain": map[string]interface{}{"type": "string", "description": "Domain or zone name, e.g. example.com"},
+				},
+				"required": []string{"domain"},
+			},
+		},
+	}
+}
+
+func (p *DnsProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "DNS_RESOLVER", Required: false, Description: "Resolver used by dnssec_check, host[:port] (default 1.1.1.1:53)"},
+	}
+}
+
+// dnsLookupTypes are the record_type values dns_lookup understands
+var dnsLookupTypes = []string{"ALL", "A", "AAAA", "MX", "TXT", "CNAME", "NS"}
+
+func (p *DnsProfile) Complete(tool, arg, partial string, env map[string]string) ([]string, error) {
+	if tool == "dns_lookup" && arg == "record_type" {
+		return dnsLookupTypes, nil
 	}
+	return nil, nil
+}
+
+func (p *DnsProfile) CacheableTools() []string {
+	return []string{"dns_lookup", "reverse_lookup", "resolve_host", "whois", "dnssec_check"}
 }
 
 func (p *DnsProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
@@ -76,6 +138,12 @@ func (p *DnsProfile) CallTool(name string, args map[string]interface{}, env map[
 		return p.checkPort(args)
 	case "resolve_host":
 		return p.resolveHost(args)
+	case "traceroute":
+		return p.traceroute(args)
+	case "whois":
+		return p.whoisLookup(args)
+	case "dnssec_check":
+		return p.dnssecCheck(args, env)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
@@ -180,16 +248,26 @@ func (p *DnsProfile) checkPort(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("port must be between 1 and 65535")
 	}
 
-	addr := fmt.Sprintf("%s:%d", host, port)
+	version, err := ipVersionArg(args)
+	if err != nil {
+		return "", err
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	start := time.Now()
-	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	conn, err := net.DialTimeout("tcp"+version, addr, 5*time.Second)
 	elapsed := time.Since(start)
 
 	if err != nil {
-		return fmt.Sprintf("Port %d on %s: CLOSED (timeout: %s)\nError: %s", port, host, elapsed.Round(time.Millisecond), err), nil
+		out := fmt.Sprintf("Port %d on %s: CLOSED (timeout: %s)\nError: %s", port, host, elapsed.Round(time.Millisecond), err)
+		if version != "" {
+			out += "\nIP version: IPv" + version + " only"
+		}
+		return out, nil
 	}
+	remote := addrFamily(conn.RemoteAddr())
 	conn.Close()
-	return fmt.Sprintf("Port %d on %s: OPEN (response time: %s)", port, host, elapsed.Round(time.Millisecond)), nil
+	return fmt.Sprintf("Port %d on %s: OPEN (response time: %s)\nConnected to: %s", port, host, elapsed.Round(time.Millisecond), remote), nil
 }
 
 func (p *DnsProfile) resolveHost(args map[string]interface{}) (string, error) {
@@ -197,11 +275,22 @@ func (p *DnsProfile) resolveHost(args map[string]interface{}) (string, error) {
 	if host == "" {
 		return "", fmt.Errorf("host is required")
 	}
-	ips, err := net.LookupHost(host)
+	version, err := ipVersionArg(args)
+	if err != nil {
+		return "", err
+	}
+	ips, err := net.DefaultResolver.LookupIP(context.Background(), "ip"+version, host)
 	if err != nil {
+		if version != "" {
+			return "", fmt.Errorf("resolve failed (IPv%s only): %s", version, err)
+		}
 		return "", fmt.Errorf("resolve failed: %s", err)
 	}
-	return fmt.Sprintf("Host %s resolves to:\n  %s", host, strings.Join(ips, "\n  ")), nil
+	lines := make([]string, len(ips))
+	for i, ip := range ips {
+		lines[i] = fmt.Sprintf("%s (%s)", ip, addressFamily(ip))
+	}
+	return fmt.Sprintf("Host %s resolves to:\n  %s", host, strings.Join(lines, "\n  ")), nil
 }
 
 func getFloat(m map[string]interface{}, key string) float64 {
@@ -0,0 +1,306 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DNSSEC validation queries the resolver with github.com/miekg/dns, which
+// exposes the RRSIG/DNSKEY/DS records the standard library resolver hides,
+// and validates the chain of trust itself, from the root trust anchors down.
+
+const (
+	defaultDNSResolver = "1.1.1.1:53"
+	dnsQueryTimeout    = 5 * time.Second
+)
+
+// Root zone trust anchors (KSK-2017 and KSK-2024), as published by IANA
+var rootTrustAnchors = []*dns.DS{
+	{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET}, KeyTag: 20326, Algorithm: dns.RSASHA256, DigestType: dns.SHA256, Digest: "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"},
+	{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET}, KeyTag: 38696, Algorithm: dns.RSASHA256, DigestType: dns.SHA256, Digest: "683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16"},
+}
+
+var dsDigestTypes = map[uint8]string{dns.SHA1: "SHA-1", dns.SHA256: "SHA-256", dns.SHA384: "SHA-384"}
+
+// dnssecCheck walks the chain of trust from the root to the domain's zone
+func (p *DnsProfile) dnssecCheck(args map[string]interface{}, env map[string]string) (string, error) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(getStr(args, "domain"))), ".")
+	if domain == "" {
+		return "", invalidInputf("domain is required")
+	}
+	if strings.ContainsAny(domain, " /:@") {
+		return "", invalidInputf("invalid domain: %s", domain)
+	}
+	resolver := env["DNS_RESOLVER"]
+	if resolver == "" {
+		resolver = defaultDNSResolver
+	}
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(resolver, "53")
+	}
+
+	// Candidate zone cuts from the root down: ".", "com.", "example.com.", ...
+	labels := strings.Split(domain, ".")
+	names := []string{"."}
+	for i := len(labels) - 1; i >= 0; i-- {
+		names = append(names, strings.Join(labels[i:], ".")+".")
+	}
+
+	var lines []string
+	report := func(status, reason string) string {
+		out := fmt.Sprintf("DNSSEC check for %s: %s\nResolver: %s\n", domain, status, resolver)
+		if reason != "" {
+			out += "Reason: " + reason + "\n"
+		}
+		return out + "\nChain:\n" + strings.Join(lines, "\n")
+	}
+
+	zone, idx := ".", 0
+	trustedDS := rootTrustAnchors
+	var zoneAlgs []string
+	for {
+		keys, keyRRs, err := dnsKeysFor(resolver, zone)
+		if err != nil {
+			return "", err
+		}
+		if len(keys) == 0 {
+			lines = append(lines, fmt.Sprintf("  %-24s DS present but no DNSKEY records", zone))
+			return report("BOGUS", fmt.Sprintf("%s has a DS record in its parent but publishes no DNSKEY", zone)), nil
+		}
+
+		// A key the parent vouches for (or a root trust anchor) must sign the DNSKEY set
+		var anchored []*dns.DNSKEY
+		for _, k := range keys {
+			for _, ds := range trustedDS {
+				if dsMatchesKey(ds, zone, k) {
+					anchored = append(anchored, k)
+					break
+				}
+			}
+		}
+		if len(anchored) == 0 {
+			lines = append(lines, fmt.Sprintf("  %-24s no DNSKEY matches the parent's DS (%s)", zone, describeDS(trustedDS)))
+			return report("BOGUS", fmt.Sprintf("no DNSKEY in %s matches the DS published by its parent", zone)), nil
+		}
+		sig, err := verifyRRset(keyRRs, dns.TypeDNSKEY, anchored, zone)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("  %-24s DNSKEY set not validly signed: %s", zone, err))
+			return report("BOGUS", fmt.Sprintf("DNSKEY set of %s: %s", zone, err)), nil
+		}
+		zoneAlgs = keyAlgorithms(keys)
+		lines = append(lines, fmt.Sprintf("  %-24s secure (keys %s; DS %s; sig expires %s)",
+			zone, describeKeys(keys), describeDS(trustedDS), dnssecTime(sig.Expiration)))
+
+		// Find the next zone cut below this one
+		next := ""
+		var nextDS []*dns.DS
+		for j := idx + 1; j < len(names) && next == ""; j++ {
+			name := names[j]
+			dsRRs, err := dnsQueryRRs(resolver, name, dns.TypeDS)
+			if err != nil {
+				return "", err
+			}
+			for _, rr := range dsRRs {
+				if ds, ok := rr.(*dns.DS); ok {
+					nextDS = append(nextDS, ds)
+				}
+			}
+			if len(nextDS) > 0 {
+				if _, err := verifyRRset(dsRRs, dns.TypeDS, keys, zone); err != nil {
+					lines = append(lines, fmt.Sprintf("  %-24s DS set not validly signed by %s: %s", name, zone, err))
+					return report("BOGUS", fmt.Sprintf("DS records for %s: %s", name, err)), nil
+				}
+				next, idx = name, j
+				break
+			}
+
+			// No DS: either not a zone cut, or an unsigned delegation
+			soaRRs, err := dnsQueryRRs(resolver, name, dns.TypeSOA)
+			if err != nil {
+				return "", err
+			}
+			for _, rr := range soaRRs {
+				if _, ok := rr.(*dns.SOA); ok {
+					lines = append(lines, fmt.Sprintf("  %-24s unsigned delegation (no DS in %s)", name, zone))
+					return report("INSECURE", fmt.Sprintf("%s is delegated without a DS record, so the chain of trust ends at %s", name, zone)), nil
+				}
+			}
+		}
+		if next == "" {
+			break
+		}
+		zone, trustedDS = next, nextDS
+	}
+
+	return report("SECURE", "") + fmt.Sprintf("\n\nZone: %s\nAlgorithms: %s", zone, strings.Join(zoneAlgs, ", ")), nil
+}
+
+// dnsKeysFor fetches a zone's DNSKEY set, returning the keys and the records
+// (with RRSIGs) for signature checks
+func dnsKeysFor(resolver, zone string) ([]*dns.DNSKEY, []dns.RR, error) {
+	rrs, err := dnsQueryRRs(resolver, zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, nil, err
+	}
+	var keys []*dns.DNSKEY
+	for _, rr := range rrs {
+		if k, ok := rr.(*dns.DNSKEY); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys, rrs, nil
+}
+
+// verifyRRset checks that at least one RRSIG in rrs covering the rtype records
+// was made by one of keys, is within its validity period, and verifies.
+// It returns that RRSIG.
+func verifyRRset(rrs []dns.RR, rtype uint16, keys []*dns.DNSKEY, signer string) (*dns.RRSIG, error) {
+	var records []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range rrs {
+		switch {
+		case rr.Header().Rrtype == rtype:
+			records = append(records, rr)
+		case rr.Header().Rrtype == dns.TypeRRSIG:
+			if sig := rr.(*dns.RRSIG); sig.TypeCovered == rtype {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty RRset")
+	}
+	if len(sigs) == 0 {
+		return nil, errors.New("no RRSIG records")
+	}
+
+	now := time.Now()
+	lastErr := errors.New("no RRSIG made by a trusted key")
+	for _, sig := range sigs {
+		if !strings.EqualFold(sig.SignerName, signer) {
+			continue
+		}
+		for _, k := range keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+				continue
+			}
+			if !sig.ValidityPeriod(now) {
+				// Serial number arithmetic (RFC 1982), as the timestamps wrap
+				if int32(sig.Inception-uint32(now.Unix())) > 0 {
+					lastErr = fmt.Errorf("signature not valid until %s", dnssecTime(sig.Inception))
+				} else {
+					lastErr = fmt.Errorf("signature expired %s", dnssecTime(sig.Expiration))
+				}
+				continue
+			}
+			if err := sig.Verify(k, records); err != nil {
+				lastErr = fmt.Errorf("signature does not verify: %s", err)
+				continue
+			}
+			return sig, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// dsMatchesKey reports whether ds is the digest of key as published at owner
+func dsMatchesKey(ds *dns.DS, owner string, key *dns.DNSKEY) bool {
+	if ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+		return false
+	}
+	// ToDS digests the key under its own owner name
+	k := *key
+	k.Hdr.Name = owner
+	computed := k.ToDS(ds.DigestType)
+	return computed != nil && strings.EqualFold(computed.Digest, ds.Digest)
+}
+
+// dnsQueryRRs returns the answer records owned by name, including RRSIGs
+func dnsQueryRRs(resolver, name string, rtype uint16) ([]dns.RR, error) {
+	resp, err := dnsExchange(resolver, name, rtype)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, backendErrorf("resolver returned %s for %s", dns.RcodeToString[resp.Rcode], name)
+	}
+	var out []dns.RR
+	for _, rr := range resp.Answer {
+		if strings.EqualFold(rr.Header().Name, name) {
+			out = append(out, rr)
+		}
+	}
+	return out, nil
+}
+
+// dnsExchange sends a query with the DO and CD bits set, so the resolver
+// returns signatures and leaves validation to us, retrying over TCP if truncated
+func dnsExchange(resolver, name string, rtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, rtype)
+	msg.CheckingDisabled = true
+	msg.SetEdns0(4096, true)
+
+	client := &dns.Client{Net: "udp", Timeout: dnsQueryTimeout}
+	resp, _, err := client.Exchange(msg, resolver)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.Exchange(msg, resolver)
+	}
+	if err != nil {
+		return nil, backendErrorf("no answer from resolver %s for %s: %s", resolver, name, err)
+	}
+	return resp, nil
+}
+
+func dnssecTime(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format("2006-01-02 15:04 MST")
+}
+
+func algorithmName(alg uint8) string {
+	if name, ok := dns.AlgorithmToString[alg]; ok {
+		return fmt.Sprintf("%s (%d)", name, alg)
+	}
+	return fmt.Sprintf("algorithm %d", alg)
+}
+
+func keyAlgorithms(keys []*dns.DNSKEY) []string {
+	seen := map[uint8]bool{}
+	var out []string
+	for _, k := range keys {
+		if !seen[k.Algorithm] {
+			seen[k.Algorithm] = true
+			out = append(out, algorithmName(k.Algorithm))
+		}
+	}
+	return out
+}
+
+func describeKeys(keys []*dns.DNSKEY) string {
+	var parts []string
+	for _, k := range keys {
+		role := "ZSK"
+		if k.Flags&dns.SEP != 0 {
+			role = "KSK"
+		}
+		parts = append(parts, fmt.Sprintf("%s %d/%s", role, k.KeyTag(), algorithmName(k.Algorithm)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeDS(set []*dns.DS) string {
+	var parts []string
+	for _, ds := range set {
+		digest := dsDigestTypes[ds.DigestType]
+		if digest == "" {
+			digest = fmt.Sprintf("digest %d", ds.DigestType)
+		}
+		parts = append(parts, fmt.Sprintf("%d/%s", ds.KeyTag, digest))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,170 @@
+package profiles
+
+import (
+	"crypto"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testZone is a signed zone served by testResolver
+type testZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestZone(t *testing.T, name string) *testZone {
+	t.Helper()
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ED25519,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testZone{name: name, key: key, priv: priv.(crypto.Signer)}
+}
+
+// sign returns rrset with an RRSIG made by z, valid from inception to expiration
+func (z *testZone) sign(t *testing.T, rrset []dns.RR, inception, expiration time.Time) []dns.RR {
+	t.Helper()
+	h := rrset[0].Header()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: h.Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: h.Ttl},
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.name,
+		Algorithm:  z.key.Algorithm,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(z.priv, rrset); err != nil {
+		t.Fatal(err)
+	}
+	return append(append([]dns.RR{}, rrset...), sig)
+}
+
+func (z *testZone) ds(t *testing.T) *dns.DS {
+	t.Helper()
+	ds := z.key.ToDS(dns.SHA256)
+	if ds == nil {
+		t.Fatal("ToDS failed")
+	}
+	return ds
+}
+
+// testResolver answers from records, keyed by name and type
+type testResolver map[string][]dns.RR
+
+func (r testResolver) add(name string, rtype uint16, rrs ...dns.RR) {
+	key := dns.Fqdn(name) + "/" + dns.TypeToString[rtype]
+	r[key] = append(r[key], rrs...)
+}
+
+func (r testResolver) serve(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		q := req.Question[0]
+		resp.Answer = r[strings.ToLower(q.Name)+"/"+dns.TypeToString[q.Qtype]]
+		w.WriteMsg(resp)
+	})}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestDNSSECCheck(t *testing.T) {
+	now := time.Now()
+	valid := func(z *testZone, rrs ...dns.RR) []dns.RR {
+		return z.sign(t, rrs, now.Add(-time.Hour), now.Add(24*time.Hour))
+	}
+	soa := func(name string) dns.RR {
+		rr, _ := dns.NewRR(name + " 3600 IN SOA ns." + name + " admin." + name + " 1 7200 3600 1209600 300")
+		return rr
+	}
+
+	root := newTestZone(t, ".")
+	com := newTestZone(t, "com.")
+	example := newTestZone(t, "example.com.")
+	bogus := newTestZone(t, "bogus.com.")
+	expired := newTestZone(t, "expired.com.")
+	forged := newTestZone(t, "forged.com.")
+	impostor := newTestZone(t, "forged.com.")
+
+	r := testResolver{}
+	r.add(".", dns.TypeDNSKEY, valid(root, root.key)...)
+	r.add("com.", dns.TypeDS, valid(root, com.ds(t))...)
+	r.add("com.", dns.TypeDNSKEY, valid(com, com.key)...)
+	r.add("example.com.", dns.TypeDS, valid(com, example.ds(t))...)
+	r.add("example.com.", dns.TypeDNSKEY, valid(example, example.key)...)
+
+	// Signed by its own key, but the signature bytes are corrupted
+	r.add("bogus.com.", dns.TypeDS, valid(com, bogus.ds(t))...)
+	bogusKeys := valid(bogus, bogus.key)
+	sig := bogusKeys[1].(*dns.RRSIG)
+	sig.Signature = strings.Repeat("A", len(sig.Signature)-4) + "AA=="
+	r.add("bogus.com.", dns.TypeDNSKEY, bogusKeys...)
+
+	r.add("expired.com.", dns.TypeDS, valid(com, expired.ds(t))...)
+	r.add("expired.com.", dns.TypeDNSKEY, expired.sign(t, []dns.RR{expired.key}, now.Add(-48*time.Hour), now.Add(-24*time.Hour))...)
+
+	// The parent vouches for one key, the zone serves another
+	r.add("forged.com.", dns.TypeDS, valid(com, forged.ds(t))...)
+	r.add("forged.com.", dns.TypeDNSKEY, valid(impostor, impostor.key)...)
+
+	r.add("insecure.com.", dns.TypeSOA, soa("insecure.com."))
+
+	resolver := r.serve(t)
+	saved := rootTrustAnchors
+	rootTrustAnchors = []*dns.DS{root.ds(t)}
+	defer func() { rootTrustAnchors = saved }()
+
+	tests := []struct {
+		domain string
+		want   []string
+	}{
+		{"example.com", []string{"example.com: SECURE", "Zone: example.com.", "ED25519 (15)"}},
+		{"www.example.com", []string{"www.example.com: SECURE", "Zone: example.com."}},
+		{"insecure.com", []string{"INSECURE", "insecure.com. is delegated without a DS record"}},
+		{"bogus.com", []string{"BOGUS", "DNSKEY set of bogus.com.", "does not verify"}},
+		{"expired.com", []string{"BOGUS", "signature expired"}},
+		{"forged.com", []string{"BOGUS", "no DNSKEY in forged.com. matches the DS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			out, err := (&DnsProfile{}).dnssecCheck(map[string]interface{}{"domain": tt.domain}, map[string]string{"DNS_RESOLVER": resolver})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output lacks %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+
+	t.Run("untrusted root", func(t *testing.T) {
+		rootTrustAnchors = saved
+		defer func() { rootTrustAnchors = []*dns.DS{root.ds(t)} }()
+		out, err := (&DnsProfile{}).dnssecCheck(map[string]interface{}{"domain": "example.com"}, map[string]string{"DNS_RESOLVER": resolver})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "BOGUS") || !strings.Contains(out, "no DNSKEY in . matches") {
+			t.Errorf("output:\n%s", out)
+		}
+	})
+}
@@ -7,13 +7,16 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 )
 
 type DockerProfile struct{}
 
-func (p *DockerProfile) ID() string { return "docker" }
+func (p *DockerProfile) ID() string       { return "docker" }
+func (p *DockerProfile) HasBackend() bool { return true }
 
 func (p *DockerProfile) Tools() []Tool {
 	return []Tool{
@@ -30,6 +33,23 @@ func (p *DockerProfile) Tools() []Tool {
 				},
 			},
 		},
+		{
+			Name:        "docker_compose_ps",
+			Description: "List the containers of a Docker Compose project, grouped by service",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"project": map[string]interface{}{
+						"type":        "string",
+						"description": "Compose project name (default: all compose projects)",
+					},
+					"all": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Include stopped containers (default: false)",
+					},
+				},
+			},
+		},
 		{
 			Name:        "docker_logs",
 			Description: "Get container logs (stdout/stderr)",
@@ -96,41 +116,92 @@ func (p *DockerProfile) Tools() []Tool {
 	}
 }
 
-func (p *DockerProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
-	dockerHost := env["DOCKER_HOST"]
-	if dockerHost == "" {
-		dockerHost = "unix:///var/run/docker.sock"
+func (p *DockerProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "DOCKER_HOST", Required: false, Description: "Docker API endpoint (default unix:///var/run/docker.sock)"},
+		{Name: "READ_ONLY", Required: false, Description: "Set to false to allow restart/exec (default true)"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 30, max 300)"},
 	}
+}
 
-	readOnly := strings.ToLower(env["READ_ONLY"]) != "false"
+func (p *DockerProfile) DryRunTools() []string {
+	return []string{"docker_restart", "docker_exec"}
+}
+
+func newDockerEndpoint(env map[string]string) dockerEndpoint {
+	d := dockerEndpoint{host: env["DOCKER_HOST"], timeout: httpTimeout(env, 30*time.Second)}
+	if d.host == "" {
+		d.host = "unix:///var/run/docker.sock"
+	}
+	return d
+}
+
+// Complete suggests container names, including stopped containers
+func (p *DockerProfile) Complete(tool, arg, partial string, env map[string]string) ([]string, error) {
+	if arg != "container" {
+		return nil, nil
+	}
+	data, err := p.dockerAPI(newDockerEndpoint(env), "GET", "/containers/json?all=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	var containers []struct {
+		Names []string `json:"Names"`
+	}
+	if err := json.Unmarshal(data, &containers); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %s", err)
+	}
+	var names []string
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			names = append(names, strings.TrimPrefix(c.Names[0], "/"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (p *DockerProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	d := newDockerEndpoint(env)
+
+	readOnly := ReadOnlyMode(env)
 
 	switch name {
 	case "docker_list":
-		return p.dockerList(dockerHost, args)
+		return p.dockerList(d, args)
+	case "docker_compose_ps":
+		return p.dockerComposePs(d, args)
 	case "docker_logs":
-		return p.dockerLogs(dockerHost, args)
+		return p.dockerLogs(d, args)
 	case "docker_inspect":
-		return p.dockerInspect(dockerHost, args)
+		return p.dockerInspect(d, args)
 	case "docker_stats":
-		return p.dockerStats(dockerHost, args)
+		return p.dockerStats(d, args)
 	case "docker_restart":
 		if readOnly {
-			return "", fmt.Errorf("docker_restart requires READ_ONLY=false")
+			return "", forbiddenf("docker_restart requires READ_ONLY=false")
 		}
-		return p.dockerRestart(dockerHost, args)
+		return p.dockerRestart(d, args)
 	case "docker_exec":
 		if readOnly {
-			return "", fmt.Errorf("docker_exec requires READ_ONLY=false")
+			return "", forbiddenf("docker_exec requires READ_ONLY=false")
 		}
-		return p.dockerExec(dockerHost, args)
+		return p.dockerExec(d, args)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
 }
 
+// dockerEndpoint is the Docker API address and request timeout for a connection
+type dockerEndpoint struct {
+	host    string
+	timeout time.Duration
+}
+
 // dockerAPI makes an HTTP request to the Docker socket API
-func (p *DockerProfile) dockerAPI(dockerHost, method, path string, body io.Reader) ([]byte, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
+func (p *DockerProfile) dockerAPI(d dockerEndpoint, method, path string, body io.Reader) ([]byte, error) {
+	client := &http.Client{Timeout: d.timeout}
+	dockerHost := d.host
 
 	if strings.HasPrefix(dockerHost, "unix://") {
 		socketPath := strings.TrimPrefix(dockerHost, "unix://")
@@ -155,7 +226,7 @@ func (p *DockerProfile) dockerAPI(dockerHost, method, path string, body io.Reade
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("docker API error: %s", err)
+		return nil, backendErrorf("docker API error: %s", err)
 	}
 	defer resp.Body.Close()
 
@@ -170,14 +241,14 @@ func (p *DockerProfile) dockerAPI(dockerHost, method, path string, body io.Reade
 	return data, nil
 }
 
-func (p *DockerProfile) dockerList(dockerHost string, args map[string]interface{}) (string, error) {
+func (p *DockerProfile) dockerList(d dockerEndpoint, args map[string]interface{}) (string, error) {
 	path := "/containers/json"
 	all, _ := args["all"].(bool)
 	if all {
 		path += "?all=true"
 	}
 
-	data, err := p.dockerAPI(dockerHost, "GET", path, nil)
+	data, err := p.dockerAPI(d, "GET", path, nil)
 	if err != nil {
 		return "", err
 	}
@@ -223,13 +294,104 @@ func (p *DockerProfile) dockerList(dockerHost string, args map[string]interface{
 	return fmt.Sprintf("Containers (%d):\n\n%s", len(containers), strings.Join(lines, "\n")), nil
 }
 
-func (p *DockerProfile) dockerLogs(dockerHost string, args map[string]interface{}) (string, error) {
+const composeProjectLabel = "com.docker.compose.project"
+
+func (p *DockerProfile) dockerComposePs(d dockerEndpoint, args map[string]interface{}) (string, error) {
+	project := getStr(args, "project")
+	label := composeProjectLabel
+	if project != "" {
+		label += "=" + project
+	}
+	filters, _ := json.Marshal(map[string][]string{"label": {label}})
+	query := url.Values{"filters": {string(filters)}}
+	if all, _ := args["all"].(bool); all {
+		query.Set("all", "true")
+	}
+
+	data, err := p.dockerAPI(d, "GET", "/containers/json?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var containers []struct {
+		ID     string            `json:"Id"`
+		Names  []string          `json:"Names"`
+		Image  string            `json:"Image"`
+		State  string            `json:"State"`
+		Status string            `json:"Status"`
+		Labels map[string]string `json:"Labels"`
+	}
+	if err := json.Unmarshal(data, &containers); err != nil {
+		return "", fmt.Errorf("failed to parse response: %s", err)
+	}
+	if len(containers) == 0 {
+		if project != "" {
+			return fmt.Sprintf("No containers found for compose project '%s'", project), nil
+		}
+		return "No compose containers found", nil
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		a, b := containers[i].Labels, containers[j].Labels
+		if a[composeProjectLabel] != b[composeProjectLabel] {
+			return a[composeProjectLabel] < b[composeProjectLabel]
+		}
+		if a["com.docker.compose.service"] != b["com.docker.compose.service"] {
+			return a["com.docker.compose.service"] < b["com.docker.compose.service"]
+		}
+		return strings.Join(containers[i].Names, ",") < strings.Join(containers[j].Names, ",")
+	})
+
+	var lines []string
+	currentProject, currentService := "", ""
+	for i, c := range containers {
+		proj := c.Labels[composeProjectLabel]
+		if i == 0 || proj != currentProject {
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, fmt.Sprintf("Project: %s", proj))
+			lines = append(lines, fmt.Sprintf("%-20s %-12s %-30s %-20s %-10s %s", "SERVICE", "ID", "NAME", "IMAGE", "STATE", "STATUS"))
+			lines = append(lines, strings.Repeat("-", 110))
+			currentProject, currentService = proj, ""
+		}
+
+		// Print the service name once per group
+		service := c.Labels["com.docker.compose.service"]
+		shown := service
+		if service == currentService {
+			shown = ""
+		}
+		currentService = service
+
+		id := c.ID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if len(name) > 30 {
+			name = name[:27] + "..."
+		}
+		img := c.Image
+		if len(img) > 20 {
+			img = img[:17] + "..."
+		}
+		lines = append(lines, fmt.Sprintf("%-20s %-12s %-30s %-20s %-10s %s", shown, id, name, img, c.State, c.Status))
+	}
+
+	return fmt.Sprintf("Compose containers (%d):\n\n%s", len(containers), strings.Join(lines, "\n")), nil
+}
+
+func (p *DockerProfile) dockerLogs(d dockerEndpoint, args map[string]interface{}) (string, error) {
 	container := getStr(args, "container")
 	if container == "" {
-		return "", fmt.Errorf("container is required")
+		return "", invalidInputf("container is required")
 	}
 	if strings.ContainsAny(container, " ;|&$`/") {
-		return "", fmt.Errorf("invalid container name")
+		return "", invalidInputf("invalid container name")
 	}
 
 	tail := int(getFloat(args, "tail"))
@@ -241,7 +403,7 @@ func (p *DockerProfile) dockerLogs(dockerHost string, args map[string]interface{
 	}
 
 	path := fmt.Sprintf("/containers/%s/logs?stdout=true&stderr=true&tail=%d", container, tail)
-	data, err := p.dockerAPI(dockerHost, "GET", path, nil)
+	data, err := p.dockerAPI(d, "GET", path, nil)
 	if err != nil {
 		return "", err
 	}
@@ -254,16 +416,16 @@ func (p *DockerProfile) dockerLogs(dockerHost string, args map[string]interface{
 	return fmt.Sprintf("Logs for %s (last %d lines):\n\n%s", container, tail, result), nil
 }
 
-func (p *DockerProfile) dockerInspect(dockerHost string, args map[string]interface{}) (string, error) {
+func (p *DockerProfile) dockerInspect(d dockerEndpoint, args map[string]interface{}) (string, error) {
 	container := getStr(args, "container")
 	if container == "" {
-		return "", fmt.Errorf("container is required")
+		return "", invalidInputf("container is required")
 	}
 	if strings.ContainsAny(container, " ;|&$`/") {
-		return "", fmt.Errorf("invalid container name")
+		return "", invalidInputf("invalid container name")
 	}
 
-	data, err := p.dockerAPI(dockerHost, "GET", fmt.Sprintf("/containers/%s/json", container), nil)
+	data, err := p.dockerAPI(d, "GET", fmt.Sprintf("/containers/%s/json", container), nil)
 	if err != nil {
 		return "", err
 	}
@@ -312,16 +474,16 @@ func (p *DockerProfile) dockerInspect(dockerHost string, args map[string]interfa
 	return strings.Join(lines, "\n"), nil
 }
 
-func (p *DockerProfile) dockerStats(dockerHost string, args map[string]interface{}) (string, error) {
+func (p *DockerProfile) dockerStats(d dockerEndpoint, args map[string]interface{}) (string, error) {
 	container := getStr(args, "container")
 
 	if container != "" {
 		if strings.ContainsAny(container, " ;|&$`/") {
-			return "", fmt.Errorf("invalid container name")
+			return "", invalidInputf("invalid container name")
 		}
 		// Single container stats
 		path := fmt.Sprintf("/containers/%s/stats?stream=false", container)
-		data, err := p.dockerAPI(dockerHost, "GET", path, nil)
+		data, err := p.dockerAPI(d, "GET", path, nil)
 		if err != nil {
 			return "", err
 		}
@@ -329,7 +491,7 @@ func (p *DockerProfile) dockerStats(dockerHost string, args map[string]interface
 	}
 
 	// List all running containers, get stats for each
-	listData, err := p.dockerAPI(dockerHost, "GET", "/containers/json", nil)
+	listData, err := p.dockerAPI(d, "GET", "/containers/json", nil)
 	if err != nil {
 		return "", err
 	}
@@ -355,7 +517,7 @@ func (p *DockerProfile) dockerStats(dockerHost string, args map[string]interface
 		}
 
 		path := fmt.Sprintf("/containers/%s/stats?stream=false", id)
-		data, err := p.dockerAPI(dockerHost, "GET", path, nil)
+		data, err := p.dockerAPI(d, "GET", path, nil)
 		if err != nil {
 			results = append(results, fmt.Sprintf("%s (%s): error - %s", name, id, err))
 			continue
@@ -371,38 +533,57 @@ func (p *DockerProfile) dockerStats(dockerHost string, args map[string]interface
 	return fmt.Sprintf("Stats for %d containers:\n\n%s", len(containers), strings.Join(results, "\n\n")), nil
 }
 
-func (p *DockerProfile) dockerRestart(dockerHost string, args map[string]interface{}) (string, error) {
+func (p *DockerProfile) dockerRestart(d dockerEndpoint, args map[string]interface{}) (string, error) {
 	container := getStr(args, "container")
 	if container == "" {
-		return "", fmt.Errorf("container is required")
+		return "", invalidInputf("container is required")
 	}
 	if strings.ContainsAny(container, " ;|&$`/") {
-		return "", fmt.Errorf("invalid container name")
+		return "", invalidInputf("invalid container name")
+	}
+
+	if IsDryRun(args) {
+		state, err := p.containerState(d, container)
+		if err != nil {
+			return "", err
+		}
+		return dryRunf("would restart container %s (currently %s)", container, state), nil
 	}
 
-	_, err := p.dockerAPI(dockerHost, "POST", fmt.Sprintf("/containers/%s/restart?t=10", container), nil)
+	_, err := p.dockerAPI(d, "POST", fmt.Sprintf("/containers/%s/restart?t=10", container), nil)
 	if err != nil {
 		return "", err
 	}
 	return fmt.Sprintf("Container %s restarted successfully", container), nil
 }
 
-func (p *DockerProfile) dockerExec(dockerHost string, args map[string]interface{}) (string, error) {
+func (p *DockerProfile) dockerExec(d dockerEndpoint, args map[string]interface{}) (string, error) {
 	container := getStr(args, "container")
 	if container == "" {
-		return "", fmt.Errorf("container is required")
+		return "", invalidInputf("container is required")
 	}
 	if strings.ContainsAny(container, " ;|&$`/") {
-		return "", fmt.Errorf("invalid container name")
+		return "", invalidInputf("invalid container name")
 	}
 
 	command := getStr(args, "command")
 	if command == "" {
-		return "", fmt.Errorf("command is required")
+		return "", invalidInputf("command is required")
 	}
 
 	// Create exec instance
 	cmdParts := strings.Fields(command)
+	if IsDryRun(args) {
+		state, err := p.containerState(d, container)
+		if err != nil {
+			return "", err
+		}
+		if state != "running" {
+			return "", invalidInputf("container %s is %s, exec needs a running container", container, state)
+		}
+		cmdJSON, _ := json.Marshal(cmdParts)
+		return dryRunf("would run %s in container %s", cmdJSON, container), nil
+	}
 	execConfig := map[string]interface{}{
 		"AttachStdout": true,
 		"AttachStderr": true,
@@ -410,7 +591,7 @@ func (p *DockerProfile) dockerExec(dockerHost string, args map[string]interface{
 	}
 	configJSON, _ := json.Marshal(execConfig)
 
-	data, err := p.dockerAPI(dockerHost, "POST", fmt.Sprintf("/containers/%s/exec", container), strings.NewReader(string(configJSON)))
+	data, err := p.dockerAPI(d, "POST", fmt.Sprintf("/containers/%s/exec", container), strings.NewReader(string(configJSON)))
 	if err != nil {
 		return "", err
 	}
@@ -424,7 +605,7 @@ func (p *DockerProfile) dockerExec(dockerHost string, args map[string]interface{
 
 	// Start exec
 	startConfig, _ := json.Marshal(map[string]interface{}{"Detach": false, "Tty": false})
-	output, err := p.dockerAPI(dockerHost, "POST", fmt.Sprintf("/exec/%s/start", execID), strings.NewReader(string(startConfig)))
+	output, err := p.dockerAPI(d, "POST", fmt.Sprintf("/exec/%s/start", execID), strings.NewReader(string(startConfig)))
 	if err != nil {
 		return "", err
 	}
@@ -542,3 +723,21 @@ func humanBytes(b float64) string {
 	}
 	return fmt.Sprintf("%.1f PB", b)
 }
+
+// containerState returns the state (running, exited, ...) of a container, which
+// also confirms it exists
+func (p *DockerProfile) containerState(d dockerEndpoint, container string) (string, error) {
+	data, err := p.dockerAPI(d, "GET", fmt.Sprintf("/containers/%s/json", container), nil)
+	if err != nil {
+		return "", err
+	}
+	var info struct {
+		State struct {
+			Status string `json:"Status"`
+		} `json:"State"`
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return "", fmt.Errorf("failed to parse container: %s", err)
+	}
+	return info.State.Status, nil
+}
@@ -0,0 +1,67 @@
+package profiles
+
+import "fmt"
+
+// DryRunProvider is optionally implemented by profiles whose tools change
+// state. Each tool it lists validates its input as usual and then, if the
+// call has dry_run set, reports what it would do instead of doing it.
+type DryRunProvider interface {
+	DryRunTools() []string
+}
+
+// SupportsDryRun reports whether tool on p honors the dry_run argument
+func SupportsDryRun(p Profile, tool string) bool {
+	dp, ok := p.(DryRunProvider)
+	if !ok {
+		return false
+	}
+	for _, name := range dp.DryRunTools() {
+		if name == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// WithDryRunArg returns tools with a dry_run argument added to the schema of
+// each tool that supports it. The input slice and schemas are not modified.
+func WithDryRunArg(p Profile, tools []Tool) []Tool {
+	if _, ok := p.(DryRunProvider); !ok {
+		return tools
+	}
+	out := make([]Tool, len(tools))
+	for i, t := range tools {
+		out[i] = t
+		if !SupportsDryRun(p, t.Name) {
+			continue
+		}
+		schema := make(map[string]interface{}, len(t.InputSchema))
+		for k, v := range t.InputSchema {
+			schema[k] = v
+		}
+		props := map[string]interface{}{}
+		if existing, ok := t.InputSchema["properties"].(map[string]interface{}); ok {
+			for k, v := range existing {
+				props[k] = v
+			}
+		}
+		props["dry_run"] = map[string]interface{}{
+			"type":        "boolean",
+			"description": "Validate the call and report what would happen without doing it",
+		}
+		schema["properties"] = props
+		out[i].InputSchema = schema
+	}
+	return out
+}
+
+// IsDryRun reports whether a tool call asked for a dry run
+func IsDryRun(args map[string]interface{}) bool {
+	v, _ := args["dry_run"].(bool)
+	return v
+}
+
+// dryRunf formats the result of a dry run
+func dryRunf(format string, args ...interface{}) string {
+	return "[dry run] " + fmt.Sprintf(format, args...)
+}
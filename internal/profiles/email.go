@@ -2,7 +2,9 @@ package profiles
 
 import (
 	"fmt"
+	"mime"
 	"net"
+	"net/mail"
 	"net/smtp"
 	"strconv"
 	"strings"
@@ -10,7 +12,8 @@ import (
 
 type EmailProfile struct{}
 
-func (p *EmailProfile) ID() string { return "email" }
+func (p *EmailProfile) ID() string       { return "email" }
+func (p *EmailProfile) HasBackend() bool { return true }
 
 func (p *EmailProfile) Tools() []Tool {
 	return []Tool{
@@ -20,10 +23,11 @@ func (p *EmailProfile) Tools() []Tool {
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"to":      map[string]interface{}{"type": "string", "description": "Recipient email address(es), comma-separated"},
-					"subject": map[string]interface{}{"type": "string", "description": "Email subject"},
-					"body":    map[string]interface{}{"type": "string", "description": "Email body (plain text)"},
-					"cc":      map[string]interface{}{"type": "string", "description": "CC recipients (optional, comma-separated)"},
+					"to":       map[string]interface{}{"type": "string", "description": "Recipient email address(es), comma-separated"},
+					"subject":  map[string]interface{}{"type": "string", "description": "Email subject"},
+					"body":     map[string]interface{}{"type": "string", "description": "Email body (plain text)"},
+					"cc":       map[string]interface{}{"type": "string", "description": "CC recipients (optional, comma-separated)"},
+					"bcc":      map[string]interface{}{"type": "string", "description": "BCC recipients (optional, comma-separated); they receive the email without appearing in its headers"},
 					"reply_to": map[string]interface{}{"type": "string", "description": "Reply-To address (optional)"},
 				},
 				"required": []string{"to", "subject", "body"},
@@ -39,6 +43,7 @@ func (p *EmailProfile) Tools() []Tool {
 					"subject": map[string]interface{}{"type": "string", "description": "Email subject"},
 					"html":    map[string]interface{}{"type": "string", "description": "HTML content"},
 					"cc":      map[string]interface{}{"type": "string", "description": "CC recipients (optional, comma-separated)"},
+					"bcc":     map[string]interface{}{"type": "string", "description": "BCC recipients (optional, comma-separated); they receive the email without appearing in its headers"},
 				},
 				"required": []string{"to", "subject", "html"},
 			},
@@ -57,6 +62,21 @@ func (p *EmailProfile) Tools() []Tool {
 	}
 }
 
+func (p *EmailProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "SMTP_HOST", Required: true, Description: "SMTP server hostname"},
+		{Name: "FROM_ADDRESS", Required: true, Description: "Sender email address"},
+		{Name: "SMTP_PORT", Required: false, Description: "SMTP port (default 587)"},
+		{Name: "SMTP_USER", Required: false, Description: "SMTP username"},
+		{Name: "SMTP_PASS", Required: false, Description: "SMTP password"},
+		{Name: "FROM_NAME", Required: false, Description: "Sender display name"},
+	}
+}
+
+func (p *EmailProfile) DryRunTools() []string {
+	return []string{"send_email", "send_html_email"}
+}
+
 func (p *EmailProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "send_email":
@@ -93,6 +113,9 @@ func (p *EmailProfile) sendEmail(args map[string]interface{}, env map[string]str
 	if to == "" || subject == "" {
 		return "", fmt.Errorf("to and subject are required")
 	}
+	if err := checkHeaderValue("subject", subject); err != nil {
+		return "", err
+	}
 
 	var body string
 	if isHTML {
@@ -108,22 +131,47 @@ func (p *EmailProfile) sendEmail(args map[string]interface{}, env map[string]str
 	if fromName == "" {
 		fromName = "Dublyo MCP"
 	}
+	if strings.ContainsAny(fromName, "\r\n") {
+		return "", notConfiguredf("FROM_NAME must not contain line breaks")
+	}
+	if addr, err := mail.ParseAddress(from); err != nil || addr.Address != from {
+		return "", notConfiguredf("invalid FROM_ADDRESS: %s", from)
+	}
+	sender := (&mail.Address{Name: fromName, Address: from}).String()
 
-	recipients := parseEmails(to)
-	ccList := parseEmails(getStr(args, "cc"))
-	allRecipients := append(recipients, ccList...)
+	toList, err := parseAddresses("to", to)
+	if err != nil {
+		return "", err
+	}
+	ccList, err := parseAddresses("cc", getStr(args, "cc"))
+	if err != nil {
+		return "", err
+	}
+	replyTo, err := parseAddresses("reply_to", getStr(args, "reply_to"))
+	if err != nil {
+		return "", err
+	}
+	bccList, err := parseAddresses("bcc", getStr(args, "bcc"))
+	if err != nil {
+		return "", err
+	}
+	recipients := addressesOf(toList)
+	visible := append(recipients, addressesOf(ccList)...)
+	// Bcc recipients are only in the envelope, never in a header
+	allRecipients := append(visible, addressesOf(bccList)...)
 
-	// Build message
+	// Build message. Every header value is checked for line breaks above, and
+	// display names and the subject are RFC 2047 encoded when not plain ASCII.
 	var msg strings.Builder
-	msg.WriteString(fmt.Sprintf("From: %s <%s>\r\n", fromName, from))
-	msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(recipients, ", ")))
+	msg.WriteString(fmt.Sprintf("From: %s\r\n", sender))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", formatAddresses(toList)))
 	if len(ccList) > 0 {
-		msg.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(ccList, ", ")))
+		msg.WriteString(fmt.Sprintf("Cc: %s\r\n", formatAddresses(ccList)))
 	}
-	if replyTo := getStr(args, "reply_to"); replyTo != "" {
-		msg.WriteString(fmt.Sprintf("Reply-To: %s\r\n", replyTo))
+	if len(replyTo) > 0 {
+		msg.WriteString(fmt.Sprintf("Reply-To: %s\r\n", formatAddresses(replyTo)))
 	}
-	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject)))
 	msg.WriteString("MIME-Version: 1.0\r\n")
 	if isHTML {
 		msg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
@@ -133,18 +181,38 @@ func (p *EmailProfile) sendEmail(args map[string]interface{}, env map[string]str
 	msg.WriteString("\r\n")
 	msg.WriteString(body)
 
+	if len(allRecipients) == 0 {
+		return "", fmt.Errorf("no valid recipients")
+	}
+	bccLine := ""
+	if len(bccList) > 0 {
+		bccLine = fmt.Sprintf("\nBcc: %s", strings.Join(addressesOf(bccList), ", "))
+	}
+	if IsDryRun(args) {
+		return dryRunf("would send email via %s:%d\nTo: %s%s\nSubject: %s\nFrom: %s <%s>\nSize: %d bytes",
+			host, port, strings.Join(visible, ", "), bccLine, subject, fromName, from, msg.Len()), nil
+	}
+
 	addr := fmt.Sprintf("%s:%d", host, port)
 	var auth smtp.Auth
 	if user != "" && pass != "" {
 		auth = smtp.PlainAuth("", user, pass, host)
 	}
 
-	if err := smtp.SendMail(addr, auth, from, allRecipients, []byte(msg.String())); err != nil {
+	accepted, rejected, err := deliverMail(addr, host, auth, from, allRecipients, []byte(msg.String()))
+	if err != nil {
+		if len(rejected) > 0 {
+			return "", fmt.Errorf("failed to send email: %s\n%s", err, formatRejected(allRecipients, rejected))
+		}
 		return "", fmt.Errorf("failed to send email: %s", err)
 	}
+	if len(rejected) > 0 {
+		return fmt.Sprintf("Email sent to %d of %d recipients\nDelivered: %s\n%s\nSubject: %s\nFrom: %s <%s>",
+			len(accepted), len(allRecipients), strings.Join(accepted, ", "), formatRejected(allRecipients, rejected), subject, fromName, from), nil
+	}
 
-	return fmt.Sprintf("Email sent successfully!\nTo: %s\nSubject: %s\nFrom: %s <%s>",
-		strings.Join(recipients, ", "), subject, fromName, from), nil
+	return fmt.Sprintf("Email sent successfully!\nTo: %s%s\nSubject: %s\nFrom: %s <%s>",
+		strings.Join(recipients, ", "), bccLine, subject, fromName, from), nil
 }
 
 func (p *EmailProfile) validateEmail(args map[string]interface{}) (string, error) {
@@ -182,16 +250,58 @@ func (p *EmailProfile) validateEmail(args map[string]interface{}) (string, error
 	return strings.Join(lines, "\n"), nil
 }
 
-func parseEmails(s string) []string {
-	if s == "" {
-		return nil
+// checkHeaderValue rejects an argument going into a message header that
+// contains a line break, which would let it add headers of its own (e.g. a
+// Bcc smuggled in through the subject)
+func checkHeaderValue(field, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return invalidInputf("%s must not contain line breaks", field)
+	}
+	return nil
+}
+
+// parseAddresses parses a comma-separated address list argument, each entry
+// a bare address or "Name <address>"
+func parseAddresses(field, s string) ([]*mail.Address, error) {
+	if err := checkHeaderValue(field, s); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
 	}
-	var emails []string
-	for _, e := range strings.Split(s, ",") {
-		e = strings.TrimSpace(e)
-		if e != "" {
-			emails = append(emails, e)
+	addrs, err := mail.ParseAddressList(s)
+	if err != nil {
+		return nil, invalidInputf("invalid %s address list %q: %s", field, s, err)
+	}
+	return addrs, nil
+}
+
+// formatRejected lists the recipients the server refused, in send order,
+// with its reply for each
+func formatRejected(recipients []string, rejected map[string]string) string {
+	lines := []string{"Failed:"}
+	for _, rcpt := range recipients {
+		if reply, ok := rejected[rcpt]; ok {
+			lines = append(lines, fmt.Sprintf("  %s: %s", rcpt, reply))
 		}
 	}
+	return strings.Join(lines, "\n")
+}
+
+// addressesOf returns the bare addresses of addrs, for the SMTP envelope
+func addressesOf(addrs []*mail.Address) []string {
+	emails := make([]string, len(addrs))
+	for i, a := range addrs {
+		emails[i] = a.Address
+	}
 	return emails
 }
+
+// formatAddresses renders addrs for a header
+func formatAddresses(addrs []*mail.Address) string {
+	formatted := make([]string, len(addrs))
+	for i, a := range addrs {
+		formatted[i] = a.String()
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,66 @@
+package profiles
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"net/textproto"
+)
+
+// deliverMail sends msg like smtp.SendMail, except that a recipient the
+// server refuses doesn't abort the send: the message goes to the accepted
+// ones, and rejected maps each refused address to the server's reply. It
+// fails only when the server can't be used or accepts no recipient.
+func deliverMail(addr, host string, auth smtp.Auth, from string, to []string, msg []byte) (accepted []string, rejected map[string]string, err error) {
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return nil, nil, err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return nil, nil, errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return nil, nil, err
+	}
+
+	rejected = map[string]string{}
+	for _, rcpt := range to {
+		err := c.Rcpt(rcpt)
+		var reply *textproto.Error
+		switch {
+		case err == nil:
+			accepted = append(accepted, rcpt)
+		case errors.As(err, &reply):
+			rejected[rcpt] = fmt.Sprintf("%d %s", reply.Code, reply.Msg)
+		default:
+			return nil, nil, err
+		}
+	}
+	if len(accepted) == 0 {
+		return nil, rejected, errors.New("all recipients were rejected")
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	return accepted, rejected, c.Quit()
+}
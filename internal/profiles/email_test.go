@@ -0,0 +1,168 @@
+package profiles
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP accepts every message and keeps its envelope and data
+type fakeSMTP struct {
+	mu       sync.Mutex
+	rcpts    []string
+	messages []string
+}
+
+// serve listens on loopback and returns the SMTP_HOST and SMTP_PORT for it
+func (f *fakeSMTP) serve(t *testing.T) (host, port string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(textproto.NewConn(conn))
+		}
+	}()
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port
+}
+
+func (f *fakeSMTP) handle(c *textproto.Conn) {
+	defer c.Close()
+	c.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 fake")
+		case "RCPT":
+			f.mu.Lock()
+			f.rcpts = append(f.rcpts, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			f.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.messages = append(f.messages, string(data))
+			f.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestEmailHeaderInjection(t *testing.T) {
+	var smtp fakeSMTP
+	host, port := smtp.serve(t)
+	env := map[string]string{"SMTP_HOST": host, "SMTP_PORT": port, "FROM_ADDRESS": "gateway@example.com"}
+	p := &EmailProfile{}
+
+	tests := []struct {
+		name     string
+		args     map[string]interface{}
+		fromName string
+		wantCode string
+	}{
+		{name: "newline in subject", args: map[string]interface{}{"subject": "Hi\nBcc: victim@example.net"}, wantCode: ErrInvalidInput},
+		{name: "CRLF in subject", args: map[string]interface{}{"subject": "Hi\r\nBcc: victim@example.net"}, wantCode: ErrInvalidInput},
+		{name: "carriage return in subject", args: map[string]interface{}{"subject": "Hi\rthere"}, wantCode: ErrInvalidInput},
+		{name: "newline in to", args: map[string]interface{}{"to": "ann@example.com\r\nBcc: victim@example.net"}, wantCode: ErrInvalidInput},
+		{name: "newline in cc", args: map[string]interface{}{"cc": "bob@example.com\nX-Spam: no"}, wantCode: ErrInvalidInput},
+		{name: "newline in reply_to", args: map[string]interface{}{"reply_to": "bob@example.com\n"}, wantCode: ErrInvalidInput},
+		{name: "invalid address", args: map[string]interface{}{"to": "ann at example.com"}, wantCode: ErrInvalidInput},
+		{name: "newline in FROM_NAME", fromName: "Gateway\r\nBcc: victim@example.net", wantCode: ErrNotConfigured},
+		{name: "clean", args: map[string]interface{}{"cc": "Bob <bob@example.com>", "reply_to": "help@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string]interface{}{"to": "ann@example.com", "subject": "Hello", "body": "Hi Ann"}
+			for k, v := range tt.args {
+				args[k] = v
+			}
+			env := maps.Clone(env)
+			if tt.fromName != "" {
+				env["FROM_NAME"] = tt.fromName
+			}
+			smtp.mu.Lock()
+			sentBefore := len(smtp.messages)
+			smtp.mu.Unlock()
+
+			_, err := p.CallTool("send_email", args, env)
+			var toolErr *ToolError
+			switch {
+			case tt.wantCode == "" && err != nil:
+				t.Fatalf("send failed: %v", err)
+			case tt.wantCode != "" && (!errors.As(err, &toolErr) || toolErr.Code != tt.wantCode):
+				t.Fatalf("err = %v, want a %s error", err, tt.wantCode)
+			}
+			smtp.mu.Lock()
+			defer smtp.mu.Unlock()
+			if sent := len(smtp.messages) > sentBefore; sent != (tt.wantCode == "") {
+				t.Errorf("message sent = %v", sent)
+			}
+		})
+	}
+}
+
+func TestEmailMessageHeaders(t *testing.T) {
+	var smtp fakeSMTP
+	host, port := smtp.serve(t)
+	env := map[string]string{"SMTP_HOST": host, "SMTP_PORT": port, "FROM_ADDRESS": "gateway@example.com", "FROM_NAME": "Équipe"}
+
+	args := map[string]interface{}{
+		"to":      "Zoë <zoe@example.com>, ann@example.com",
+		"bcc":     "audit@example.com",
+		"subject": "Café au lait",
+		"body":    "Bonjour",
+	}
+	if _, err := (&EmailProfile{}).CallTool("send_email", args, env); err != nil {
+		t.Fatal(err)
+	}
+	smtp.mu.Lock()
+	defer smtp.mu.Unlock()
+	if len(smtp.messages) != 1 {
+		t.Fatalf("%d messages sent", len(smtp.messages))
+	}
+	// The DATA reader turns CRLF into LF
+	header, _, _ := strings.Cut(smtp.messages[0], "\n\n")
+	want := []string{
+		"From: =?utf-8?q?=C3=89quipe?= <gateway@example.com>",
+		"To: =?utf-8?q?Zo=C3=AB?= <zoe@example.com>, <ann@example.com>",
+		"Subject: =?UTF-8?q?Caf=C3=A9_au_lait?=",
+	}
+	for _, line := range want {
+		if !strings.Contains(header+"\n", line+"\n") {
+			t.Errorf("header missing %q:\n%s", line, header)
+		}
+	}
+	if strings.Contains(header, "audit@example.com") {
+		t.Errorf("Bcc recipient in the header:\n%s", header)
+	}
+	if got := strings.Join(smtp.rcpts, ","); got != "zoe@example.com,ann@example.com,audit@example.com" {
+		t.Errorf("envelope recipients %s", got)
+	}
+}
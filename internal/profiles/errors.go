@@ -0,0 +1,41 @@
+package profiles
+
+import "fmt"
+
+// Tool error codes surfaced to clients so they can react without string matching
+const (
+	ErrNotConfigured = "not_configured"
+	ErrInvalidInput  = "invalid_input"
+	ErrBackend       = "backend_error"
+	ErrForbidden     = "forbidden"
+	ErrTimeout       = "timeout"
+	ErrBusy          = "busy" // the connection's tool call limit is reached; retry shortly
+)
+
+// ToolError is an error with a machine-readable code
+type ToolError struct {
+	Code    string
+	Message string
+}
+
+func (e *ToolError) Error() string { return e.Message }
+
+func toolErrorf(code, format string, args ...interface{}) error {
+	return &ToolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func notConfiguredf(format string, args ...interface{}) error {
+	return toolErrorf(ErrNotConfigured, format, args...)
+}
+
+func invalidInputf(format string, args ...interface{}) error {
+	return toolErrorf(ErrInvalidInput, format, args...)
+}
+
+func backendErrorf(format string, args ...interface{}) error {
+	return toolErrorf(ErrBackend, format, args...)
+}
+
+func forbiddenf(format string, args ...interface{}) error {
+	return toolErrorf(ErrForbidden, format, args...)
+}
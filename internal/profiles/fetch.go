@@ -1,11 +1,18 @@
 package profiles
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"html"
 	"io"
-	"net"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,11 +22,13 @@ type FetchProfile struct{}
 
 func (p *FetchProfile) ID() string { return "fetch" }
 
+func (p *FetchProfile) UsesNetwork() bool { return true }
+
 func (p *FetchProfile) Tools() []Tool {
 	return []Tool{
 		{
 			Name:        "fetch_url",
-			Description: "Fetch a URL and return the response body as text",
+			Description: "Fetch a URL and return the response body as text. HEAD returns just the status and headers, OPTIONS the headers (e.g. Allow) and any body; with if_none_match/if_modified_since a 304 Not Modified is reported as such, for cache validation",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -29,16 +38,29 @@ func (p *FetchProfile) Tools() []Tool {
 					},
 					"method": map[string]interface{}{
 						"type":        "string",
-						"description": "HTTP method (GET, POST, etc.). Defaults to GET.",
+						"description": "HTTP method (GET, POST, HEAD, OPTIONS, etc.). Defaults to GET.",
 						"default":     "GET",
 					},
+					"if_none_match": map[string]interface{}{
+						"type":        "string",
+						"description": "Send If-None-Match with this ETag, as the server gave it (quotes included), to check whether a cached copy is still current",
+					},
+					"if_modified_since": map[string]interface{}{
+						"type":        "string",
+						"description": "Send If-Modified-Since with this time (HTTP date or RFC 3339), to check whether a cached copy is still current",
+					},
 					"headers": map[string]interface{}{
 						"type":        "object",
 						"description": "Custom headers to include",
 					},
 					"body": map[string]interface{}{
+						"type":        []string{"string", "object"},
+						"description": "Request body (for POST/PUT): a string, or an object of fields for json, form and multipart",
+					},
+					"body_type": map[string]interface{}{
 						"type":        "string",
-						"description": "Request body (for POST/PUT)",
+						"enum":        []string{"raw", "json", "form", "multipart"},
+						"description": "How to encode body and set Content-Type: raw sends a string as is (default for strings), json (default for objects), form (application/x-www-form-urlencoded), multipart (multipart/form-data; a field given as {\"filename\", \"content_base64\", \"content_type\"} is sent as a file)",
 					},
 				},
 				"required": []string{"url"},
@@ -46,7 +68,7 @@ func (p *FetchProfile) Tools() []Tool {
 		},
 		{
 			Name:        "fetch_html",
-			Description: "Fetch a URL and extract readable text content (strips HTML tags)",
+			Description: "Fetch a URL and extract readable text content (strips HTML tags, or with mode=readability returns just the main article)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -54,6 +76,11 @@ func (p *FetchProfile) Tools() []Tool {
 						"type":        "string",
 						"description": "The URL to fetch",
 					},
+					"mode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"text", "readability"},
+						"description": "text: all of the page's text (default); readability: the title and main content only, without navigation, sidebars and footers",
+					},
 				},
 				"required": []string{"url"},
 			},
@@ -61,6 +88,15 @@ func (p *FetchProfile) Tools() []Tool {
 	}
 }
 
+func (p *FetchProfile) RequiredEnv() []EnvSpec {
+	return append([]EnvSpec{
+		{Name: "ALLOWED_DOMAINS", Required: false, Description: "Comma-separated domain allowlist"},
+		{Name: "USER_AGENT", Required: false, Description: "User-Agent header for outbound requests"},
+		{Name: "MAX_RESPONSE_SIZE", Required: false, Description: "Maximum response bytes to read (default 5MB)"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 30, max 300)"},
+	}, outboundHTTPEnv...)
+}
+
 func (p *FetchProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "fetch_url":
@@ -72,35 +108,121 @@ func (p *FetchProfile) CallTool(name string, args map[string]interface{}, env ma
 	}
 }
 
+// CallToolStream streams fetch_url bodies in chunks so large responses are
+// never buffered whole; other tools return their result as a single chunk
+func (p *FetchProfile) CallToolStream(ctx context.Context, name string, args map[string]interface{}, env map[string]string, emit func(chunk string) error) error {
+	if name != "fetch_url" {
+		out, err := p.CallTool(name, args, env)
+		if err != nil {
+			return err
+		}
+		return emit(out)
+	}
+
+	logf(ctx, "debug", "fetching %s", getStr(args, "url"))
+	start := time.Now()
+	resp, maxSize, err := p.doFetch(args, env)
+	if err != nil {
+		logf(ctx, "error", "fetch failed after %s: %s", time.Since(start).Round(time.Millisecond), err)
+		return err
+	}
+	defer resp.Body.Close()
+	logf(ctx, "info", "%s responded %s in %s", resp.Request.URL.Host, resp.Status, time.Since(start).Round(time.Millisecond))
+
+	if fetchBodyless(resp) {
+		return emit(fetchHeadersOnly(resp))
+	}
+	if resp.Request.Method == http.MethodOptions {
+		if err := emit(fetchHeadersOnly(resp) + "\n"); err != nil {
+			return err
+		}
+	}
+
+	contentLength := "unknown"
+	if resp.ContentLength >= 0 {
+		contentLength = strconv.FormatInt(resp.ContentLength, 10)
+	}
+	if err := emit(fmt.Sprintf("Status: %d %s\nContent-Type: %s\nContent-Length: %s\n\n",
+		resp.StatusCode, resp.Status, resp.Header.Get("Content-Type"), contentLength)); err != nil {
+		return err
+	}
+
+	limited := io.LimitReader(resp.Body, int64(maxSize))
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := limited.Read(buf)
+		if n > 0 {
+			if emitErr := emit(string(buf[:n])); emitErr != nil {
+				return emitErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read failed: %s", err)
+		}
+	}
+}
+
 func (p *FetchProfile) fetchURL(args map[string]interface{}, env map[string]string) (string, error) {
+	resp, maxSize, err := p.doFetch(args, env)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if fetchBodyless(resp) {
+		return fetchHeadersOnly(resp), nil
+	}
+
+	limited := io.LimitReader(resp.Body, int64(maxSize))
+	data, err := io.ReadAll(limited)
+	if err != nil {
+		return "", fmt.Errorf("read failed: %s", err)
+	}
+
+	if resp.Request.Method == http.MethodOptions {
+		return fetchHeadersOnly(resp) + "\n" + string(data), nil
+	}
+	return fmt.Sprintf("Status: %d %s\nContent-Type: %s\nContent-Length: %d\n\n%s",
+		resp.StatusCode, resp.Status, resp.Header.Get("Content-Type"), len(data), string(data)), nil
+}
+
+// doFetch performs the fetch_url request and returns the response with the body size cap
+func (p *FetchProfile) doFetch(args map[string]interface{}, env map[string]string) (*http.Response, int, error) {
 	rawURL := getStr(args, "url")
 	if rawURL == "" {
-		return "", fmt.Errorf("url is required")
+		return nil, 0, fmt.Errorf("url is required")
 	}
 
 	if err := validateURL(rawURL, env); err != nil {
-		return "", err
+		return nil, 0, err
 	}
 
-	method := getStr(args, "method")
+	method := strings.ToUpper(getStr(args, "method"))
 	if method == "" {
 		method = "GET"
 	}
 
-	body := getStr(args, "body")
+	bodyType := getStr(args, "body_type")
+	body, contentType, err := encodeFetchBody(args["body"], bodyType)
+	if err != nil {
+		return nil, 0, err
+	}
 	var bodyReader io.Reader
-	if body != "" {
-		bodyReader = strings.NewReader(body)
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
 	}
 
 	req, err := http.NewRequest(method, rawURL, bodyReader)
 	if err != nil {
-		return "", fmt.Errorf("invalid request: %s", err)
+		return nil, 0, fmt.Errorf("invalid request: %s", err)
 	}
 
 	ua := env["USER_AGENT"]
 	if ua == "" {
-		ua = "Dublyo-MCP-Fetch/1.0"
+		ua = userAgent(env, "Dublyo-MCP-Fetch/1.0")
 	}
 	req.Header.Set("User-Agent", ua)
 
@@ -110,6 +232,22 @@ func (p *FetchProfile) fetchURL(args map[string]interface{}, env map[string]stri
 			req.Header.Set(k, fmt.Sprintf("%v", v))
 		}
 	}
+	if etag := getStr(args, "if_none_match"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if since := getStr(args, "if_modified_since"); since != "" {
+		t, err := http.ParseTime(since)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339, since); err != nil {
+				return nil, 0, invalidInputf("if_modified_since must be an HTTP date or RFC 3339 time, got %q", since)
+			}
+		}
+		req.Header.Set("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+	}
+	// A multipart Content-Type must carry the boundary actually used
+	if contentType != "" && (bodyType == "multipart" || req.Header.Get("Content-Type") == "") {
+		req.Header.Set("Content-Type", contentType)
+	}
 
 	maxSize := 5 * 1024 * 1024
 	if ms := env["MAX_RESPONSE_SIZE"]; ms != "" {
@@ -118,30 +256,185 @@ func (p *FetchProfile) fetchURL(args map[string]interface{}, env map[string]stri
 		}
 	}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 5 {
-				return fmt.Errorf("too many redirects")
-			}
-			return nil
-		},
+	client, err := withDefaultHeaders(safeHTTPClient(httpTimeout(env, 30*time.Second)), env)
+	if err != nil {
+		return nil, 0, err
 	}
-
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("fetch failed: %s", err)
+		return nil, 0, fmt.Errorf("fetch failed: %s", err)
 	}
-	defer resp.Body.Close()
+	return resp, maxSize, nil
+}
 
-	limited := io.LimitReader(resp.Body, int64(maxSize))
-	data, err := io.ReadAll(limited)
-	if err != nil {
-		return "", fmt.Errorf("read failed: %s", err)
+// fetchBodyless reports whether resp has no body worth reading: the answer
+// to a HEAD request, or a 304 to a conditional one
+func fetchBodyless(resp *http.Response) bool {
+	return resp.Request.Method == http.MethodHead || resp.StatusCode == http.StatusNotModified
+}
+
+// fetchHeadersOnly describes a response by its status and headers, noting
+// when a conditional request found the cached copy still current
+func fetchHeadersOnly(resp *http.Response) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Status: %d %s\n", resp.StatusCode, resp.Status)
+	if resp.StatusCode == http.StatusNotModified {
+		var sent []string
+		for _, name := range []string{"If-None-Match", "If-Modified-Since"} {
+			if v := resp.Request.Header.Get(name); v != "" {
+				sent = append(sent, name+": "+v)
+			}
+		}
+		b.WriteString("Not Modified: the cached copy is still current")
+		if len(sent) > 0 {
+			b.WriteString(" (" + strings.Join(sent, ", ") + ")")
+		}
+		b.WriteString("; no body was sent\n")
+	}
+	if allow := resp.Header.Get("Allow"); allow != "" && resp.Request.Method == http.MethodOptions {
+		fmt.Fprintf(&b, "Allowed methods: %s\n", allow)
 	}
 
-	return fmt.Sprintf("Status: %d %s\nContent-Type: %s\nContent-Length: %d\n\n%s",
-		resp.StatusCode, resp.Status, resp.Header.Get("Content-Type"), len(data), string(data)), nil
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.WriteString("\nHeaders:\n")
+	for _, name := range names {
+		for _, v := range resp.Header[name] {
+			fmt.Fprintf(&b, "  %s: %s\n", name, v)
+		}
+	}
+	return b.String()
+}
+
+// encodeFetchBody encodes the fetch_url body argument as bodyType, returning
+// the bytes to send (nil for no body) and the Content-Type they need
+func encodeFetchBody(body interface{}, bodyType string) ([]byte, string, error) {
+	if body == nil || body == "" {
+		return nil, "", nil
+	}
+	fields, isObject := body.(map[string]interface{})
+	if bodyType == "" {
+		bodyType = "raw"
+		if isObject {
+			bodyType = "json"
+		}
+	}
+
+	switch bodyType {
+	case "raw":
+		s, ok := body.(string)
+		if !ok {
+			return nil, "", invalidInputf("body must be a string for body_type raw")
+		}
+		return []byte(s), "", nil
+
+	case "json":
+		if s, ok := body.(string); ok {
+			if !json.Valid([]byte(s)) {
+				return nil, "", invalidInputf("body is not valid JSON")
+			}
+			return []byte(s), "application/json", nil
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, "", invalidInputf("body can't be encoded as JSON: %v", err)
+		}
+		return data, "application/json", nil
+
+	case "form":
+		if s, ok := body.(string); ok {
+			if _, err := url.ParseQuery(s); err != nil {
+				return nil, "", invalidInputf("body is not a valid form encoding: %v", err)
+			}
+			return []byte(s), "application/x-www-form-urlencoded", nil
+		}
+		if !isObject {
+			return nil, "", invalidInputf("body must be an object of fields for body_type form")
+		}
+		values := url.Values{}
+		for k, v := range fields {
+			if list, ok := v.([]interface{}); ok {
+				for _, item := range list {
+					values.Add(k, formValue(item))
+				}
+				continue
+			}
+			values.Set(k, formValue(v))
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+
+	case "multipart":
+		if !isObject {
+			return nil, "", invalidInputf("body must be an object of fields for body_type multipart")
+		}
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for _, k := range keys {
+			file, ok := fields[k].(map[string]interface{})
+			if !ok {
+				mw.WriteField(k, formValue(fields[k]))
+				continue
+			}
+			filename := getStr(file, "filename")
+			if filename == "" {
+				return nil, "", invalidInputf("file field %s needs a filename", k)
+			}
+			content, err := base64.StdEncoding.DecodeString(getStr(file, "content_base64"))
+			if err != nil {
+				return nil, "", invalidInputf("file field %s: content_base64 is not valid base64: %v", k, err)
+			}
+			ctype := getStr(file, "content_type")
+			if ctype == "" {
+				ctype = "application/octet-stream"
+			}
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+				multipartQuoter.Replace(k), multipartQuoter.Replace(filename)))
+			h.Set("Content-Type", ctype)
+			part, err := mw.CreatePart(h)
+			if err != nil {
+				return nil, "", err
+			}
+			part.Write(content)
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), mw.FormDataContentType(), nil
+
+	default:
+		return nil, "", invalidInputf("unknown body_type %q (use raw, json, form or multipart)", bodyType)
+	}
+}
+
+// multipartQuoter escapes a quoted Content-Disposition parameter, as
+// mime/multipart does for CreateFormFile
+var multipartQuoter = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// formValue renders a scalar form field; nested values are sent as JSON
+func formValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
 }
 
 func (p *FetchProfile) fetchHTML(args map[string]interface{}, env map[string]string) (string, error) {
@@ -149,13 +442,17 @@ func (p *FetchProfile) fetchHTML(args map[string]interface{}, env map[string]str
 	if rawURL == "" {
 		return "", fmt.Errorf("url is required")
 	}
+	mode := getStr(args, "mode")
+	if mode != "" && mode != "text" && mode != "readability" {
+		return "", invalidInputf("mode must be text or readability")
+	}
 	if err := validateURL(rawURL, env); err != nil {
 		return "", err
 	}
 
 	ua := env["USER_AGENT"]
 	if ua == "" {
-		ua = "Dublyo-MCP-Fetch/1.0"
+		ua = userAgent(env, "Dublyo-MCP-Fetch/1.0")
 	}
 
 	req, err := http.NewRequest("GET", rawURL, nil)
@@ -164,7 +461,10 @@ func (p *FetchProfile) fetchHTML(args map[string]interface{}, env map[string]str
 	}
 	req.Header.Set("User-Agent", ua)
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client, err := withDefaultHeaders(safeHTTPClient(httpTimeout(env, 30*time.Second)), env)
+	if err != nil {
+		return "", err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("fetch failed: %s", err)
@@ -183,6 +483,13 @@ func (p *FetchProfile) fetchHTML(args map[string]interface{}, env map[string]str
 		return "", fmt.Errorf("read failed: %s", err)
 	}
 
+	if mode == "readability" {
+		if title, content, ok := extractReadable(string(data)); ok {
+			return fmt.Sprintf("URL: %s\nStatus: %d\nTitle: %s\n\n%s", rawURL, resp.StatusCode, title, content), nil
+		}
+		// Nothing article-like on the page; fall back to all of its text
+	}
+
 	// Simple HTML tag stripping
 	text := stripHTML(string(data))
 	return fmt.Sprintf("URL: %s\nStatus: %d\n\n%s", rawURL, resp.StatusCode, text), nil
@@ -198,13 +505,7 @@ func validateURL(rawURL string, env map[string]string) error {
 		return fmt.Errorf("only http/https URLs are supported")
 	}
 
-	// SSRF prevention: block private IP ranges
 	host := u.Hostname()
-	if ip := net.ParseIP(host); ip != nil {
-		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-			return fmt.Errorf("access to private/local IPs is blocked")
-		}
-	}
 
 	// Domain whitelist
 	if allowed := env["ALLOWED_DOMAINS"]; allowed != "" {
@@ -222,28 +523,48 @@ func validateURL(rawURL string, env map[string]string) error {
 		}
 	}
 
+	// SSRF prevention: block private IP ranges, including hostnames that resolve to them
+	if err := checkResolvedHost(host); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// stripHTML removes tags, and the contents of script, style and noscript
+// elements, and decodes HTML entities in the remaining text
 func stripHTML(s string) string {
 	var result strings.Builder
 	inTag := false
-	for _, r := range s {
-		if r == '<' {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '<' {
+			// Script and style bodies are code, not text, and noscript holds
+			// fallback markup: skip to the end tag
+			for _, raw := range []string{"script", "style", "noscript"} {
+				if hasPrefixFold(s[i+1:], raw) {
+					end := indexFold(s[i:], "</"+raw)
+					if end < 0 {
+						end = len(s) - i
+					}
+					i += end
+					break
+				}
+			}
 			inTag = true
 			continue
 		}
-		if r == '>' {
+		if c == '>' {
 			inTag = false
-			result.WriteRune(' ')
+			result.WriteByte(' ')
 			continue
 		}
 		if !inTag {
-			result.WriteRune(r)
+			result.WriteByte(c)
 		}
 	}
 	// Collapse whitespace
-	text := result.String()
+	text := strings.ReplaceAll(html.UnescapeString(result.String()), "\u00a0", " ")
 	lines := strings.Split(text, "\n")
 	var cleaned []string
 	for _, line := range lines {
@@ -254,3 +575,21 @@ func stripHTML(s string) string {
 	}
 	return strings.Join(cleaned, "\n")
 }
+
+// hasPrefixFold reports whether s starts with the tag name prefix, ignoring case
+func hasPrefixFold(s, prefix string) bool {
+	if len(s) <= len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return false
+	}
+	return !isTagNameChar(s[len(prefix)])
+}
+
+// indexFold is strings.Index ignoring case, for an ASCII substr
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,451 @@
+package profiles
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// fetch_html's readability mode scores the page's blocks the way Mozilla's
+// Readability (after Arc90) does and returns only the best-scoring content
+// container, dropping navigation, sidebars, footers and other boilerplate.
+// The parser is deliberately forgiving: it builds a rough tree, closing
+// elements when their end tag shows up and ignoring stray end tags.
+
+type htmlNode struct {
+	tag      string // "" for text nodes
+	text     string // unescaped text, for text nodes
+	classID  string // lowercased class and id attributes
+	parent   *htmlNode
+	children []*htmlNode
+
+	score  float64
+	scored bool
+}
+
+var (
+	htmlVoidElements = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+		"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+	}
+	// Elements whose content is raw text, not markup
+	htmlRawTextElements = map[string]bool{"script": true, "style": true, "title": true, "textarea": true}
+	// Elements that never hold article content
+	htmlDroppedElements = map[string]bool{
+		"script": true, "style": true, "noscript": true, "template": true, "svg": true, "iframe": true,
+		"nav": true, "aside": true, "footer": true, "form": true, "button": true, "select": true,
+	}
+	// Elements that start a new line when rendered as text
+	htmlBlockElements = map[string]bool{
+		"address": true, "article": true, "blockquote": true, "br": true, "dd": true, "div": true, "dl": true,
+		"dt": true, "figcaption": true, "figure": true, "h1": true, "h2": true, "h3": true, "h4": true,
+		"h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true, "ol": true, "p": true,
+		"pre": true, "section": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+	}
+	// Block elements set off by a blank line
+	htmlParagraphElements = map[string]bool{
+		"blockquote": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"ol": true, "p": true, "pre": true, "table": true, "ul": true, "dl": true, "figure": true,
+	}
+	// Opening one of these closes an open element of the same kind (<p>a<p>b)
+	htmlAutoClose = map[string]bool{"p": true, "li": true, "dt": true, "dd": true, "tr": true, "td": true, "th": true, "option": true}
+
+	htmlClassIDRe    = regexp.MustCompile(`(?i)\b(?:class|id)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	unlikelyContent  = regexp.MustCompile(`banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|legends|menu|modal|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|ad-break|agegate|pagination|pager|popup|promo|subscribe|newsletter`)
+	maybeContent     = regexp.MustCompile(`and|article|body|column|content|main|shadow`)
+	positiveClassIDs = regexp.MustCompile(`article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeClassIDs = regexp.MustCompile(`-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// parseHTML builds a node tree from s
+func parseHTML(s string) *htmlNode {
+	root := &htmlNode{tag: "#root"}
+	cur := root
+	addText := func(t string) {
+		if t != "" {
+			cur.children = append(cur.children, &htmlNode{text: html.UnescapeString(t), parent: cur})
+		}
+	}
+
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			addText(s)
+			break
+		}
+		addText(s[:lt])
+		s = s[lt:]
+
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				return root
+			}
+			s = s[end+3:]
+			continue
+		case strings.HasPrefix(s, "<!"), strings.HasPrefix(s, "<?"):
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				return root
+			}
+			s = s[end+1:]
+			continue
+		case strings.HasPrefix(s, "</"):
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				return root
+			}
+			name := strings.ToLower(strings.TrimSpace(s[2:end]))
+			s = s[end+1:]
+			for n := cur; n != root; n = n.parent {
+				if n.tag == name {
+					cur = n.parent
+					break
+				}
+			}
+			continue
+		}
+
+		// Start tag: a name, then attributes up to a '>' outside quotes
+		nameEnd := 1
+		for nameEnd < len(s) && isTagNameChar(s[nameEnd]) {
+			nameEnd++
+		}
+		if nameEnd == 1 {
+			addText("<")
+			s = s[1:]
+			continue
+		}
+		name := strings.ToLower(s[1:nameEnd])
+		end, quote := nameEnd, byte(0)
+		for end < len(s) && (quote != 0 || s[end] != '>') {
+			switch {
+			case quote != 0 && s[end] == quote:
+				quote = 0
+			case quote == 0 && (s[end] == '"' || s[end] == '\''):
+				quote = s[end]
+			}
+			end++
+		}
+		if end >= len(s) {
+			return root
+		}
+		attrs := s[nameEnd:end]
+		s = s[end+1:]
+
+		if htmlAutoClose[name] && cur.tag == name {
+			cur = cur.parent
+		}
+		node := &htmlNode{tag: name, parent: cur}
+		for _, m := range htmlClassIDRe.FindAllStringSubmatch(attrs, -1) {
+			node.classID += " " + strings.ToLower(m[1]+m[2]+m[3])
+		}
+		cur.children = append(cur.children, node)
+
+		if htmlRawTextElements[name] {
+			closeTag := "</" + name
+			end := indexFold(s, closeTag)
+			if end < 0 {
+				end = len(s)
+			}
+			if name == "title" || name == "textarea" {
+				node.children = []*htmlNode{{text: html.UnescapeString(s[:end]), parent: node}}
+			}
+			s = s[end:]
+			if gt := strings.IndexByte(s, '>'); gt >= 0 {
+				s = s[gt+1:]
+			}
+			continue
+		}
+		if !htmlVoidElements[name] && !strings.HasSuffix(strings.TrimSpace(attrs), "/") {
+			cur = node
+		}
+	}
+	return root
+}
+
+func isTagNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
+
+// find returns the first element named tag, depth first
+func (n *htmlNode) find(tag string) *htmlNode {
+	for _, c := range n.children {
+		if c.tag == tag {
+			return c
+		}
+		if found := c.find(tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// innerText renders n's text, with line breaks around block elements and a
+// blank line around paragraphs
+func (n *htmlNode) innerText() string {
+	var w textWriter
+	n.writeText(&w, false)
+	return w.String()
+}
+
+func (n *htmlNode) writeText(w *textWriter, pre bool) {
+	if n.tag == "" {
+		if pre {
+			w.write(n.text)
+			return
+		}
+		words := strings.Fields(n.text)
+		if len(words) == 0 {
+			if n.text != "" {
+				w.space()
+			}
+			return
+		}
+		if strings.TrimLeft(n.text, " \t\r\n") != n.text {
+			w.space()
+		}
+		w.write(strings.Join(words, " "))
+		if strings.TrimRight(n.text, " \t\r\n") != n.text {
+			w.space()
+		}
+		return
+	}
+	if n.tag == "title" {
+		return
+	}
+
+	lines := 0
+	switch {
+	case htmlParagraphElements[n.tag]:
+		lines = 2
+	case htmlBlockElements[n.tag]:
+		lines = 1
+	}
+	w.lineBreak(lines)
+	switch n.tag {
+	case "li":
+		w.write("- ")
+	case "td", "th":
+		w.space()
+	}
+	for _, c := range n.children {
+		c.writeText(w, pre || n.tag == "pre")
+	}
+	w.lineBreak(lines)
+}
+
+// textWriter collects rendered text, merging the line breaks and spaces
+// that neighbouring elements ask for
+type textWriter struct {
+	sb       strings.Builder
+	newlines int  // newlines at the end of the text so far
+	spaced   bool // the text so far ends in whitespace
+}
+
+func (w *textWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	w.sb.WriteString(s)
+	trimmed := strings.TrimRight(s, "\n")
+	if trimmed == "" {
+		w.newlines += len(s)
+	} else {
+		w.newlines = len(s) - len(trimmed)
+	}
+	last := s[len(s)-1]
+	w.spaced = last == ' ' || last == '\t' || last == '\n'
+}
+
+// space separates words, unless at the start of a line
+func (w *textWriter) space() {
+	if w.sb.Len() > 0 && !w.spaced {
+		w.write(" ")
+	}
+}
+
+// lineBreak ends the current line, leaving n-1 blank lines after it
+func (w *textWriter) lineBreak(n int) {
+	if w.sb.Len() == 0 {
+		return
+	}
+	for w.newlines < n {
+		w.write("\n")
+	}
+}
+
+func (w *textWriter) String() string {
+	lines := strings.Split(w.sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// prune removes elements that never hold content and those whose class or
+// id mark them as page furniture
+func (n *htmlNode) prune() {
+	kept := n.children[:0]
+	for _, c := range n.children {
+		if c.tag != "" && c.tag != "body" && c.tag != "html" && c.tag != "article" && c.tag != "main" {
+			if htmlDroppedElements[c.tag] || (unlikelyContent.MatchString(c.classID) && !maybeContent.MatchString(c.classID)) {
+				continue
+			}
+		}
+		c.prune()
+		kept = append(kept, c)
+	}
+	n.children = kept
+}
+
+func (n *htmlNode) each(fn func(*htmlNode)) {
+	for _, c := range n.children {
+		if c.tag != "" {
+			fn(c)
+			c.each(fn)
+		}
+	}
+}
+
+// classWeight is Readability's bonus or penalty for an element's class/id
+func (n *htmlNode) classWeight() float64 {
+	switch {
+	case negativeClassIDs.MatchString(n.classID) && !positiveClassIDs.MatchString(n.classID):
+		return -25
+	case positiveClassIDs.MatchString(n.classID):
+		return 25
+	}
+	return 0
+}
+
+// initScore gives a candidate container its starting score from its tag
+func (n *htmlNode) initScore() {
+	if n.scored {
+		return
+	}
+	n.scored = true
+	switch n.tag {
+	case "div", "article", "main":
+		n.score = 5
+	case "pre", "td", "blockquote":
+		n.score = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		n.score = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		n.score = -5
+	}
+	n.score += n.classWeight()
+}
+
+// linkDensity is the share of n's text that sits inside links
+func (n *htmlNode) linkDensity(textLen int) float64 {
+	if textLen == 0 {
+		return 0
+	}
+	linkLen := 0
+	n.each(func(c *htmlNode) {
+		if c.tag == "a" {
+			linkLen += len(c.innerText())
+		}
+	})
+	return math.Min(1, float64(linkLen)/float64(textLen))
+}
+
+// hasBlockChild reports whether n contains a block element, which keeps a
+// div from being scored as a paragraph itself
+func (n *htmlNode) hasBlockChild() bool {
+	for _, c := range n.children {
+		if c.tag != "" && (htmlBlockElements[c.tag] && c.tag != "br" || c.hasBlockChild()) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractReadable returns the page title and the text of its main content,
+// or ok=false if no block of content could be found
+func extractReadable(page string) (title, content string, ok bool) {
+	doc := parseHTML(page)
+	if t := doc.find("title"); t != nil {
+		title = strings.Join(strings.Fields(t.innerText()), " ")
+	}
+	if title == "" {
+		if h := doc.find("h1"); h != nil {
+			title = strings.Join(strings.Fields(h.innerText()), " ")
+		}
+	}
+
+	doc.prune()
+
+	// Score paragraphs and credit their parent and grandparent containers
+	var candidates []*htmlNode
+	doc.each(func(n *htmlNode) {
+		switch n.tag {
+		case "p", "pre", "td", "blockquote":
+		case "div", "section":
+			if n.hasBlockChild() {
+				return
+			}
+		default:
+			return
+		}
+		text := n.innerText()
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text)/100), 3)
+		for level, ancestor := 0, n.parent; level < 2 && ancestor != nil && ancestor != doc; level, ancestor = level+1, ancestor.parent {
+			if !ancestor.scored {
+				ancestor.initScore()
+				candidates = append(candidates, ancestor)
+			}
+			if level == 0 {
+				ancestor.score += score
+			} else {
+				ancestor.score += score / 2
+			}
+		}
+	})
+
+	var top *htmlNode
+	for _, c := range candidates {
+		c.score *= 1 - c.linkDensity(len(c.innerText()))
+		if top == nil || c.score > top.score {
+			top = c
+		}
+	}
+	if top == nil {
+		return title, "", false
+	}
+
+	// Siblings that score well, or are substantial paragraphs, belong to the
+	// article too (e.g. a lead paragraph outside the main div)
+	parts := []*htmlNode{top}
+	if top.parent != nil {
+		threshold := math.Max(10, top.score*0.2)
+		parts = parts[:0]
+		for _, sib := range top.parent.children {
+			if sib.tag == "" {
+				continue
+			}
+			include := sib == top || (sib.scored && sib.score >= threshold)
+			if !include && sib.tag == "p" {
+				text := sib.innerText()
+				include = len(text) > 80 && sib.linkDensity(len(text)) < 0.25
+			}
+			if include {
+				parts = append(parts, sib)
+			}
+		}
+	}
+
+	var w textWriter
+	for _, part := range parts {
+		part.writeText(&w, false)
+		w.lineBreak(2)
+	}
+	return title, w.String(), true
+}
@@ -0,0 +1,37 @@
+package profiles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string // with whitespace runs collapsed
+	}{
+		{
+			"page with inline scripts and entities",
+			`<html><head><title>T &amp; C</title><style>body { color: red }</style>
+<script>var a = 1 < 2; document.write("<p>hi</p>");</script></head>
+<body><p>Fish&nbsp;&amp;&nbsp;chips &lt;3</p><SCRIPT type="module">alert('<b>')</SCRIPT>
+<noscript><img src="x.gif">Please enable JavaScript</noscript><p>caf&eacute; &#8364;5 &#x263A;</p></body></html>`,
+			"T & C Fish & chips <3 café €5 ☺",
+		},
+		{"tag names starting like script", "<p>a</p><scripts>kept</scripts><p>b</p>", "a kept b"},
+		{"unclosed script", "<p>x</p><script>never closed", "x"},
+		{"multiline text", "<div>one\n\n  <br>\n two</div>", "one two"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stripHTML(tt.html)
+			if strings.Join(strings.Fields(got), " ") != tt.want {
+				t.Errorf("stripHTML = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(got, "\n\n") {
+				t.Errorf("blank lines kept: %q", got)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package profiles
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,9 +12,8 @@ import (
 )
 
 type FilesKnowledgeProfile struct {
-	mu     sync.RWMutex
-	cache  map[string]*filesKnowledgeSource
-	client *http.Client
+	mu    sync.RWMutex
+	cache map[string]*filesKnowledgeSource
 }
 
 type filesKnowledgeIndexDoc struct {
@@ -42,12 +42,15 @@ type filesKnowledgeChunkDoc struct {
 }
 
 type filesKnowledgeSource struct {
-	URL         string
-	Version     string
-	FetchedAt   time.Time
-	Files       []filesKnowledgeIndexFile
-	Chunks      []filesKnowledgeChunk
-	ChunksCount int
+	URL          string
+	Version      string
+	FetchedAt    time.Time
+	ETag         string
+	LastModified string
+	Files        []filesKnowledgeIndexFile
+	Chunks       []filesKnowledgeChunk
+	ChunksCount  int
+	index        *knowledgeIndex
 }
 
 type filesKnowledgeChunk struct {
@@ -65,7 +68,8 @@ type filesKnowledgeMatch struct {
 	Score float64
 }
 
-func (p *FilesKnowledgeProfile) ID() string { return "files-knowledge" }
+func (p *FilesKnowledgeProfile) ID() string       { return "files-knowledge" }
+func (p *FilesKnowledgeProfile) HasBackend() bool { return true }
 
 func (p *FilesKnowledgeProfile) Tools() []Tool {
 	return []Tool{
@@ -87,6 +91,7 @@ func (p *FilesKnowledgeProfile) Tools() []Tool {
 						"type":        "integer",
 						"description": "Maximum characters per returned snippet (default 900)",
 					},
+					"timeout_ms": knowledgeTimeoutProperty(),
 				},
 				"required": []string{"query"},
 			},
@@ -123,10 +128,30 @@ func (p *FilesKnowledgeProfile) Tools() []Tool {
 	}
 }
 
+func (p *FilesKnowledgeProfile) RequiredEnv() []EnvSpec {
+	return append([]EnvSpec{
+		{Name: "FILES_INDEX_URL", Required: true, Description: "URL of the uploaded files index"},
+		{Name: "FILES_INDEX_VERSION", Required: false, Description: "Expected index version; a change forces a refresh, and while it matches the cached index is never downloaded again"},
+		{Name: "REFRESH_INTERVAL_SECONDS", Required: false, Description: "Index cache lifetime (default 300)"},
+		{Name: "MAX_DOWNLOAD_BYTES", Required: false, Description: "Maximum index size in bytes"},
+		{Name: "MAX_RESULTS", Required: false, Description: "Default number of search matches"},
+		{Name: "USER_AGENT", Required: false, Description: "User-Agent header for index requests"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 30, max 300)"},
+	}, outboundHTTPEnv...)
+}
+
+func (p *FilesKnowledgeProfile) CacheableTools() []string {
+	return []string{"search_files_knowledge", "list_files"}
+}
+
 func (p *FilesKnowledgeProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	return p.CallToolContext(context.Background(), name, args, env)
+}
+
+func (p *FilesKnowledgeProfile) CallToolContext(ctx context.Context, name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "search_files_knowledge":
-		return p.searchFilesKnowledge(args, env)
+		return p.searchFilesKnowledge(ctx, args, env)
 	case "source_status":
 		return p.sourceStatus(env, false)
 	case "list_files":
@@ -138,7 +163,7 @@ func (p *FilesKnowledgeProfile) CallTool(name string, args map[string]interface{
 	}
 }
 
-func (p *FilesKnowledgeProfile) searchFilesKnowledge(args map[string]interface{}, env map[string]string) (string, error) {
+func (p *FilesKnowledgeProfile) searchFilesKnowledge(ctx context.Context, args map[string]interface{}, env map[string]string) (string, error) {
 	query := strings.TrimSpace(getStr(args, "query"))
 	if query == "" {
 		return "", fmt.Errorf("query is required")
@@ -177,24 +202,17 @@ func (p *FilesKnowledgeProfile) searchFilesKnowledge(args map[string]interface{}
 		return "", fmt.Errorf("query must contain letters or numbers")
 	}
 
-	matches := make([]filesKnowledgeMatch, 0, len(source.Chunks))
-	for _, chunk := range source.Chunks {
-		score := scoreFilesKnowledgeChunk(chunk, queryLower, terms)
-		if score <= 0 {
-			continue
-		}
-		matches = append(matches, filesKnowledgeMatch{Chunk: chunk, Score: score})
-	}
-
-	sort.Slice(matches, func(i, j int) bool {
-		if matches[i].Score == matches[j].Score {
-			return len(matches[i].Chunk.Content) > len(matches[j].Chunk.Content)
-		}
-		return matches[i].Score > matches[j].Score
-	})
+	ctx, cancel := knowledgeSearchContext(ctx, args)
+	defer cancel()
+	chunks := source.Chunks
+	scores, scored, candidates := source.index.search(ctx, queryLower, terms, limit,
+		func(i int, phrase bool, occ []int) float64 {
+			return scoreFilesKnowledgeChunk(chunks[i], phrase, terms, occ)
+		})
 
-	if len(matches) > limit {
-		matches = matches[:limit]
+	matches := make([]filesKnowledgeMatch, len(scores))
+	for i, s := range scores {
+		matches[i] = filesKnowledgeMatch{Chunk: chunks[s.index], Score: s.score}
 	}
 
 	var out strings.Builder
@@ -208,6 +226,10 @@ func (p *FilesKnowledgeProfile) searchFilesKnowledge(args map[string]interface{}
 	if warning != "" {
 		out.WriteString(fmt.Sprintf("Note: %s\n", warning))
 	}
+	if scored < candidates {
+		markPartial(ctx)
+		out.WriteString(fmt.Sprintf("Note: search timed out after scoring %d of %d matching chunks; results are the best among those\n", scored, candidates))
+	}
 
 	if len(matches) == 0 {
 		out.WriteString("\nNo relevant matches found for this query.")
@@ -321,6 +343,11 @@ func (p *FilesKnowledgeProfile) ensureSource(env map[string]string, force bool)
 		time.Since(current.FetchedAt) < time.Duration(refreshSeconds)*time.Second {
 		return current, "", nil
 	}
+	// The dashboard bumps FILES_INDEX_VERSION whenever the files change, so
+	// an index of the expected version needs no download at all
+	if !force && current != nil && version != "" && current.Version == version {
+		return current, "", nil
+	}
 
 	maxBytes := envInt(env["MAX_DOWNLOAD_BYTES"], 50*1024*1024)
 	if maxBytes < 1024 {
@@ -330,19 +357,31 @@ func (p *FilesKnowledgeProfile) ensureSource(env map[string]string, force bool)
 		maxBytes = 150 * 1024 * 1024
 	}
 
-	client := p.httpClient()
+	client, err := p.httpClient(env)
+	if err != nil {
+		return nil, "", err
+	}
 	req, err := http.NewRequest("GET", rawURL, nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to build request: %s", err)
 	}
 
-	userAgent := strings.TrimSpace(env["USER_AGENT"])
-	if userAgent == "" {
-		userAgent = "Dublyo-Files-Knowledge/1.0"
+	ua := strings.TrimSpace(env["USER_AGENT"])
+	if ua == "" {
+		ua = userAgent(env, "Dublyo-Files-Knowledge/1.0")
 	}
-	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("User-Agent", ua)
 	req.Header.Set("Accept", "application/json,text/plain;q=0.5,*/*;q=0.1")
 
+	if current != nil && !force {
+		if current.ETag != "" {
+			req.Header.Set("If-None-Match", current.ETag)
+		}
+		if current.LastModified != "" {
+			req.Header.Set("If-Modified-Since", current.LastModified)
+		}
+	}
+
 	resp, err := client.Do(req)
 	if err != nil {
 		if current != nil {
@@ -352,6 +391,16 @@ func (p *FilesKnowledgeProfile) ensureSource(env map[string]string, force bool)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && current != nil {
+		refreshed := *current
+		refreshed.FetchedAt = time.Now()
+		p.mu.Lock()
+		p.ensureCacheLocked()
+		p.cache[rawURL] = &refreshed
+		p.mu.Unlock()
+		return &refreshed, "", nil
+	}
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		if current != nil {
 			return current, fmt.Sprintf("using cached index because endpoint returned HTTP %d", resp.StatusCode), nil
@@ -406,12 +455,15 @@ func (p *FilesKnowledgeProfile) ensureSource(env map[string]string, force bool)
 	}
 
 	source := &filesKnowledgeSource{
-		URL:         rawURL,
-		Version:     sourceVersion,
-		FetchedAt:   time.Now(),
-		Files:       doc.Files,
-		Chunks:      chunkList,
-		ChunksCount: len(chunkList),
+		URL:          rawURL,
+		Version:      sourceVersion,
+		FetchedAt:    time.Now(),
+		ETag:         strings.TrimSpace(resp.Header.Get("ETag")),
+		LastModified: strings.TrimSpace(resp.Header.Get("Last-Modified")),
+		Files:        doc.Files,
+		Chunks:       chunkList,
+		ChunksCount:  len(chunkList),
+		index:        indexFilesKnowledge(chunkList),
 	}
 
 	p.mu.Lock()
@@ -428,30 +480,35 @@ func (p *FilesKnowledgeProfile) ensureCacheLocked() {
 	}
 }
 
-func (p *FilesKnowledgeProfile) httpClient() *http.Client {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.client == nil {
-		p.client = &http.Client{Timeout: 30 * time.Second}
+func (p *FilesKnowledgeProfile) httpClient(env map[string]string) (*http.Client, error) {
+	return withDefaultHeaders(safeHTTPClient(httpTimeout(env, 30*time.Second)), env)
+}
+
+func indexFilesKnowledge(chunks []filesKnowledgeChunk) *knowledgeIndex {
+	lower := make([]string, len(chunks))
+	length := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		lower[i], length[i] = chunk.lower, len(chunk.Content)
 	}
-	return p.client
+	return buildKnowledgeIndex(lower, length)
 }
 
-func scoreFilesKnowledgeChunk(chunk filesKnowledgeChunk, queryLower string, terms []string) float64 {
+// scoreFilesKnowledgeChunk scores a chunk for a query: phrase is whether it
+// contains the whole query, occ[i] how often it contains terms[i]
+func scoreFilesKnowledgeChunk(chunk filesKnowledgeChunk, phrase bool, terms []string, occ []int) float64 {
 	score := 0.0
-	if strings.Contains(chunk.lower, queryLower) {
+	if phrase {
 		score += 8
 	}
 
 	headingLower := strings.ToLower(chunk.Heading)
 	fileLower := strings.ToLower(chunk.FileName)
-	for _, term := range terms {
+	for i, term := range terms {
 		if term == "" {
 			continue
 		}
-		occ := strings.Count(chunk.lower, term)
-		if occ > 0 {
-			score += float64(occ)
+		if occ[i] > 0 {
+			score += float64(occ[i])
 		}
 		if strings.Contains(headingLower, term) {
 			score += 2.0
@@ -461,7 +518,7 @@ func scoreFilesKnowledgeChunk(chunk filesKnowledgeChunk, queryLower string, term
 		}
 	}
 
-	if len(chunk.Content) < 1200 {
+	if len(chunk.Content) < knowledgeShortChunk {
 		score += 0.3
 	}
 	return score
@@ -1,6 +1,7 @@
 package profiles
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -27,7 +28,7 @@ func (p *FilesystemProfile) Tools() []Tool {
 		},
 		{
 			Name:        "write_file",
-			Description: "Write content to a file (creates or overwrites)",
+			Description: "Write content to a file (creates or overwrites, requires READ_ONLY=false)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -62,18 +63,46 @@ func (p *FilesystemProfile) Tools() []Tool {
 		},
 		{
 			Name:        "get_file_info",
-			Description: "Get file metadata (size, modified date, permissions)",
+			Description: "Get file metadata (size, modified date, permissions), optionally with the MIME type and, for text files, line and word counts",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"path": map[string]interface{}{"type": "string", "description": "File path"},
+					"path":    map[string]interface{}{"type": "string", "description": "File path"},
+					"details": map[string]interface{}{"type": "boolean", "description": "Also detect the MIME type and count lines and words of text files (reads the file)"},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "file_checksum",
+			Description: "Compute a file's checksum (md5, sha1 or sha256), optionally verifying it against an expected value",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path":      map[string]interface{}{"type": "string", "description": "File path"},
+					"algorithm": map[string]interface{}{"type": "string", "enum": []string{"md5", "sha1", "sha256"}, "description": "Hash algorithm (default sha256)"},
+					"expected":  map[string]interface{}{"type": "string", "description": "Hex checksum the file must have; a mismatch is an error"},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "tail_file",
+			Description: "Show the last lines of a file, then follow it like tail -f and return the lines appended within timeout_seconds. Truncation and log rotation are handled.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path":            map[string]interface{}{"type": "string", "description": "File path"},
+					"timeout_seconds": map[string]interface{}{"type": "number", "description": "How long to follow the file (default 10, max 300)"},
+					"lines":           map[string]interface{}{"type": "integer", "description": "Existing lines to show first (default 10, max 1000)"},
+					"max_lines":       map[string]interface{}{"type": "integer", "description": "Stop early after this many new lines (default and max 10000)"},
 				},
 				"required": []string{"path"},
 			},
 		},
 		{
 			Name:        "create_directory",
-			Description: "Create a new directory (including parents)",
+			Description: "Create a new directory (including parents, requires READ_ONLY=false)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -84,7 +113,7 @@ func (p *FilesystemProfile) Tools() []Tool {
 		},
 		{
 			Name:        "move_file",
-			Description: "Move or rename a file",
+			Description: "Move or rename a file (requires READ_ONLY=false)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -112,9 +141,27 @@ func (p *FilesystemProfile) Tools() []Tool {
 	}
 }
 
+func (p *FilesystemProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "ALLOWED_PATHS", Required: true, Description: "Comma-separated directories the profile may access"},
+		{Name: "READ_ONLY", Required: false, Description: "Set to false to allow write_file, create_directory and move_file (default true)"},
+	}
+}
+
+func (p *FilesystemProfile) DryRunTools() []string {
+	return []string{"write_file", "create_directory", "move_file"}
+}
+
 func (p *FilesystemProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	allowed := parseAllowedPaths(env["ALLOWED_PATHS"])
 
+	switch name {
+	case "write_file", "create_directory", "move_file":
+		if ReadOnlyMode(env) {
+			return "", forbiddenf("%s requires READ_ONLY=false", name)
+		}
+	}
+
 	switch name {
 	case "read_file":
 		path := getStr(args, "path")
@@ -133,6 +180,15 @@ func (p *FilesystemProfile) CallTool(name string, args map[string]interface{}, e
 		if err := validatePath(path, allowed); err != nil {
 			return "", err
 		}
+		if IsDryRun(args) {
+			if info, err := os.Stat(path); err == nil {
+				if info.IsDir() {
+					return "", invalidInputf("%s is a directory", path)
+				}
+				return dryRunf("would overwrite %s (%d bytes) with %d bytes", path, info.Size(), len(content)), nil
+			}
+			return dryRunf("would create %s with %d bytes", path, len(content)), nil
+		}
 		// Ensure parent directory exists
 		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 			return "", fmt.Errorf("cannot create parent directory: %s", err)
@@ -201,14 +257,46 @@ func (p *FilesystemProfile) CallTool(name string, args map[string]interface{}, e
 		if err != nil {
 			return "", fmt.Errorf("cannot stat file: %s", err)
 		}
-		return fmt.Sprintf("Name: %s\nSize: %d bytes\nMode: %s\nModified: %s\nIsDir: %v",
-			info.Name(), info.Size(), info.Mode(), info.ModTime().Format("2006-01-02 15:04:05"), info.IsDir()), nil
+		result := fmt.Sprintf("Name: %s\nSize: %d bytes\nMode: %s\nModified: %s\nIsDir: %v",
+			info.Name(), info.Size(), info.Mode(), info.ModTime().Format("2006-01-02 15:04:05"), info.IsDir())
+		if details, _ := args["details"].(bool); details && info.Mode().IsRegular() {
+			extra, err := fileDetails(path, info.Size())
+			if err != nil {
+				return "", err
+			}
+			result += extra
+		}
+		return result, nil
+
+	case "file_checksum":
+		path := getStr(args, "path")
+		if err := validatePath(path, allowed); err != nil {
+			return "", err
+		}
+		return fileChecksum(path, getStr(args, "algorithm"), getStr(args, "expected"))
+
+	case "tail_file":
+		var sb strings.Builder
+		err := p.tailFile(context.Background(), args, allowed, func(chunk string) error {
+			sb.WriteString(chunk)
+			return nil
+		})
+		return sb.String(), err
 
 	case "create_directory":
 		path := getStr(args, "path")
 		if err := validatePath(path, allowed); err != nil {
 			return "", err
 		}
+		if IsDryRun(args) {
+			if info, err := os.Stat(path); err == nil {
+				if !info.IsDir() {
+					return "", invalidInputf("%s exists and is not a directory", path)
+				}
+				return dryRunf("%s already exists, nothing to do", path), nil
+			}
+			return dryRunf("would create directory %s", path), nil
+		}
 		if err := os.MkdirAll(path, 0755); err != nil {
 			return "", fmt.Errorf("cannot create directory: %s", err)
 		}
@@ -223,6 +311,15 @@ func (p *FilesystemProfile) CallTool(name string, args map[string]interface{}, e
 		if err := validatePath(dst, allowed); err != nil {
 			return "", err
 		}
+		if IsDryRun(args) {
+			if _, err := os.Stat(src); err != nil {
+				return "", fmt.Errorf("cannot move file: %s", err)
+			}
+			if _, err := os.Stat(dst); err == nil {
+				return dryRunf("would move %s -> %s, replacing the existing %s", src, dst, dst), nil
+			}
+			return dryRunf("would move %s -> %s", src, dst), nil
+		}
 		if err := os.Rename(src, dst); err != nil {
 			return "", fmt.Errorf("cannot move file: %s", err)
 		}
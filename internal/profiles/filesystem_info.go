@@ -0,0 +1,119 @@
+package profiles
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxCountBytes bounds the text files get_file_info counts lines and words in
+const maxCountBytes = 50 * 1024 * 1024
+
+// fileChecksum hashes a file as it is read, so its size doesn't matter, and
+// compares the digest with expected if one is given
+func fileChecksum(path, algorithm, expected string) (string, error) {
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "", "sha256":
+		algorithm, h = "sha256", sha256.New()
+	case "sha1":
+		algorithm, h = "sha1", sha1.New()
+	case "md5":
+		algorithm, h = "md5", md5.New()
+	default:
+		return "", invalidInputf("unsupported algorithm %s (use md5, sha1 or sha256)", algorithm)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open file: %s", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		return "", invalidInputf("%s is a directory", path)
+	}
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("cannot read file: %s", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	result := fmt.Sprintf("%s  %s\nAlgorithm: %s\nSize: %d bytes", sum, path, algorithm, n)
+	if expected = strings.TrimSpace(expected); expected != "" {
+		if !strings.EqualFold(expected, sum) {
+			return "", invalidInputf("checksum mismatch for %s: %s is %s, expected %s", path, algorithm, sum, strings.ToLower(expected))
+		}
+		result += "\nVerified: matches expected checksum"
+	}
+	return result, nil
+}
+
+// fileDetails returns the MIME type of a regular file, sniffed from its
+// first 512 bytes, and for text files their line and word counts
+func fileDetails(path string, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open file: %s", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("cannot read file: %s", err)
+	}
+	mime := http.DetectContentType(head[:n])
+	details := "\nMIME type: " + mime
+	if !strings.HasPrefix(mime, "text/") {
+		return details, nil
+	}
+	if size > maxCountBytes {
+		return details + fmt.Sprintf("\nLines/words: not counted (file larger than %d MB)", maxCountBytes/1024/1024), nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("cannot read file: %s", err)
+	}
+	lines, words := 0, 0
+	inWord := false
+	buf := make([]byte, 32*1024)
+	var carry []byte // an incomplete rune at the end of the previous read
+	for {
+		n, err := f.Read(buf)
+		chunk := append(carry, buf[:n]...)
+		carry = nil
+		for len(chunk) > 0 {
+			r, width := utf8.DecodeRune(chunk)
+			if r == utf8.RuneError && !utf8.FullRune(chunk) && err == nil {
+				carry = append(carry, chunk...)
+				break
+			}
+			chunk = chunk[width:]
+			if r == '\n' {
+				lines++
+			}
+			if unicode.IsSpace(r) {
+				inWord = false
+			} else if !inWord {
+				inWord = true
+				words++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot read file: %s", err)
+		}
+	}
+	return details + fmt.Sprintf("\nLines: %d\nWords: %d", lines, words), nil
+}
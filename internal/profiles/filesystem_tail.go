@@ -0,0 +1,231 @@
+package profiles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	tailDefaultTimeout = 10 * time.Second
+	tailMaxTimeout     = 5 * time.Minute
+	tailDefaultLines   = 10
+	tailMaxLines       = 1000
+	tailMaxNewLines    = 10000
+	tailPollInterval   = 250 * time.Millisecond
+	tailBackscanBytes  = 64 * 1024 // how far back the initial lines are looked for
+)
+
+// CallToolStream streams tail_file's lines as they are appended; other tools
+// return their result as a single chunk
+func (p *FilesystemProfile) CallToolStream(ctx context.Context, name string, args map[string]interface{}, env map[string]string, emit func(chunk string) error) error {
+	if name != "tail_file" {
+		out, err := p.CallTool(name, args, env)
+		if err != nil {
+			return err
+		}
+		return emit(out)
+	}
+	return p.tailFile(ctx, args, parseAllowedPaths(env["ALLOWED_PATHS"]), emit)
+}
+
+// tailFile prints the last lines of a file and then, like tail -F, the lines
+// appended to it until timeout_seconds pass or ctx is done. A truncated file
+// is read again from the start and a rotated one (renamed and recreated) is
+// reopened under its path.
+func (p *FilesystemProfile) tailFile(ctx context.Context, args map[string]interface{}, allowed []string, emit func(chunk string) error) error {
+	path := getStr(args, "path")
+	if err := validatePath(path, allowed); err != nil {
+		return err
+	}
+	timeout := tailDefaultTimeout
+	if s := getFloat(args, "timeout_seconds"); s > 0 {
+		timeout = time.Duration(s * float64(time.Second))
+	}
+	if timeout > tailMaxTimeout {
+		timeout = tailMaxTimeout
+	}
+	initial := tailDefaultLines
+	if v, ok := args["lines"]; ok && v != nil {
+		initial = int(getFloat(args, "lines"))
+	}
+	if initial < 0 || initial > tailMaxLines {
+		return invalidInputf("lines must be between 0 and %d", tailMaxLines)
+	}
+	maxNew := tailMaxNewLines
+	if n := int(getFloat(args, "max_lines")); n > 0 && n < maxNew {
+		maxNew = n
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open file: %s", err)
+	}
+	defer func() { f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot stat file: %s", err)
+	}
+	if info.IsDir() {
+		return invalidInputf("%s is a directory", path)
+	}
+
+	if err := emit(fmt.Sprintf("Following %s for %s\n\n", path, timeout)); err != nil {
+		return err
+	}
+	offset := info.Size()
+	if initial > 0 {
+		last, err := lastLines(f, offset, initial)
+		if err != nil {
+			return fmt.Errorf("cannot read file: %s", err)
+		}
+		if err := emit(last); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	buf := make([]byte, 32*1024)
+	var partial []byte // an appended line still missing its newline
+	newLines := 0
+	stopped := "timeout"
+
+	// readNew emits whole lines appended since offset, returning false once
+	// max_lines is reached
+	readNew := func() (bool, error) {
+		for {
+			n, err := f.ReadAt(buf, offset)
+			offset += int64(n)
+			chunk := append(partial, buf[:n]...)
+			end := bytes.LastIndexByte(chunk, '\n')
+			partial = append([]byte(nil), chunk[end+1:]...)
+			if len(partial) > tailBackscanBytes {
+				// Not a log file, or a very long line: pass it on unterminated
+				chunk, partial, end = append(chunk, '\n'), nil, len(chunk)
+			}
+			if end >= 0 {
+				lines := chunk[:end+1]
+				if room := maxNew - newLines; bytes.Count(lines, []byte("\n")) > room {
+					lines = lines[:nthLineEnd(lines, room)]
+				}
+				newLines += bytes.Count(lines, []byte("\n"))
+				if emitErr := emit(string(lines)); emitErr != nil {
+					return false, emitErr
+				}
+				if newLines >= maxNew {
+					return false, nil
+				}
+			}
+			if err == io.EOF || n == 0 {
+				return true, nil
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				stopped = "cancelled"
+			}
+			break loop
+		case <-ticker.C:
+		}
+
+		cur, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("cannot stat file: %s", err)
+		}
+		if cur.Size() < offset {
+			logf(ctx, "info", "%s was truncated, reading from the start", path)
+			if err := emit("--- file truncated ---\n"); err != nil {
+				return err
+			}
+			offset, partial = 0, nil
+		}
+		more, err := readNew()
+		if err != nil {
+			return err
+		}
+		if !more {
+			stopped = fmt.Sprintf("max_lines (%d) reached", maxNew)
+			break loop
+		}
+
+		// A rotated file has been renamed and a new one created at path; the
+		// old one was just read to its end, so switch over
+		if byPath, err := os.Stat(path); err == nil && !os.SameFile(cur, byPath) {
+			next, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f, offset, partial = next, 0, nil
+			logf(ctx, "info", "%s was rotated, following the new file", path)
+			if err := emit("--- file rotated ---\n"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(partial) > 0 {
+		if err := emit(string(partial) + "\n"); err != nil {
+			return err
+		}
+	}
+	return emit(fmt.Sprintf("\n--- stopped (%s) after %s: %d new lines ---", stopped, time.Since(start).Round(time.Second), newLines))
+}
+
+// lastLines returns up to n whole lines before offset in f, looking back at
+// most tailBackscanBytes
+func lastLines(f *os.File, offset int64, n int) (string, error) {
+	from := offset - tailBackscanBytes
+	if from < 0 {
+		from = 0
+	}
+	data := make([]byte, offset-from)
+	if _, err := f.ReadAt(data, from); err != nil && err != io.EOF {
+		return "", err
+	}
+	text := string(data)
+	if from > 0 {
+		// The first line is probably cut off
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	out := strings.Join(lines, "")
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	return out, nil
+}
+
+// nthLineEnd returns the length of the first n lines of b
+func nthLineEnd(b []byte, n int) int {
+	end := 0
+	for i := 0; i < n; i++ {
+		end += bytes.IndexByte(b[end:], '\n') + 1
+	}
+	return end
+}
@@ -5,8 +5,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type GitProfile struct{}
@@ -37,6 +39,14 @@ func (p *GitProfile) Tools() []Tool {
 						"type":        "string",
 						"description": "Branch name (default: current branch)",
 					},
+					"skip": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of commits to skip, for paging through history (default 0)",
+					},
+					"max_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum output size in bytes (default 50000); fewer commits are shown if they don't fit",
+					},
 				},
 			},
 		},
@@ -54,10 +64,23 @@ func (p *GitProfile) Tools() []Tool {
 						"type":        "string",
 						"description": "Limit diff to a specific file path",
 					},
+					"files": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Limit diff to these file paths",
+					},
 					"staged": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Show staged (cached) changes instead of unstaged",
 					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Byte offset into the diff to start from, for paging through large diffs (default 0)",
+					},
+					"max_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum bytes of diff to return (default 50000); the output says which offset continues it",
+					},
 				},
 			},
 		},
@@ -67,7 +90,9 @@ func (p *GitProfile) Tools() []Tool {
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"file": map[string]interface{}{"type": "string", "description": "File path to blame (relative to repo root)"},
+					"file":       map[string]interface{}{"type": "string", "description": "File path to blame (relative to repo root)"},
+					"start_line": map[string]interface{}{"type": "integer", "description": "First line to blame (default 1)"},
+					"end_line":   map[string]interface{}{"type": "integer", "description": "Last line to blame (default: end of file)"},
 				},
 				"required": []string{"file"},
 			},
@@ -95,12 +120,44 @@ func (p *GitProfile) Tools() []Tool {
 						"type":        "string",
 						"description": "Commit hash or reference (default: HEAD)",
 					},
+					"file": map[string]interface{}{
+						"type":        "string",
+						"description": "Show only this file's diff in the commit, in full (default: --stat of all files)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "git_stats",
+			Description: "Summarize commits and lines added/removed per author, and the most-changed files",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"since": map[string]interface{}{
+						"type":        "string",
+						"description": "Only count commits after this date, e.g. \"2 weeks ago\" or 2024-01-01 (default: all history)",
+					},
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Branch, commit or range to summarize (default: current branch)",
+					},
+					"top_files": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of most-changed files to list (default 10, max 50)",
+					},
 				},
 			},
 		},
 	}
 }
 
+func (p *GitProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "REPO_PATH", Required: true, Description: "Path to the git repository"},
+		{Name: "MAX_LOG_ENTRIES", Required: false, Description: "Default number of commits for git_log (default 50)"},
+	}
+}
+
 func (p *GitProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	repoPath := env["REPO_PATH"]
 	if repoPath == "" {
@@ -126,27 +183,86 @@ func (p *GitProfile) CallTool(name string, args map[string]interface{}, env map[
 		return p.gitBranches(repoPath, args)
 	case "git_show":
 		return p.gitShow(repoPath, args)
+	case "git_stats":
+		return p.gitStats(repoPath, args)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
 }
 
 func (p *GitProfile) runGit(repoPath string, args ...string) (string, error) {
+	out, err := p.gitOutput(repoPath, args...)
+	if err != nil {
+		return "", err
+	}
+	return truncateGitOutput(out), nil
+}
+
+// gitOutput runs git and returns its full output, for callers that parse it
+func (p *GitProfile) gitOutput(repoPath string, args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
 	cmd.Dir = repoPath
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("git error: %s\n%s", err, string(out))
 	}
-	result := strings.TrimSpace(string(out))
+	return string(out), nil
+}
+
+// gitMaxOutput is the default output size, and gitMaxPage the largest page
+// git_log and git_diff return when asked for max_bytes
+const (
+	gitMaxOutput = 50000
+	gitMaxPage   = 1000000
+)
+
+func truncateGitOutput(out string) string {
+	result := strings.TrimSpace(out)
 	if result == "" {
-		return "(no output)", nil
+		return "(no output)"
 	}
 	// Truncate very long output
-	if len(result) > 50000 {
-		result = result[:50000] + "\n... (truncated)"
+	if len(result) > gitMaxOutput {
+		result = result[:gitMaxOutput] + "\n... (truncated)"
 	}
-	return result, nil
+	return result
+}
+
+// gitMaxBytes reads the max_bytes argument
+func gitMaxBytes(args map[string]interface{}) (int, error) {
+	n := int(getFloat(args, "max_bytes"))
+	switch {
+	case n < 0:
+		return 0, invalidInputf("max_bytes must be positive")
+	case n == 0:
+		return gitMaxOutput, nil
+	case n > gitMaxPage:
+		return gitMaxPage, nil
+	}
+	return n, nil
+}
+
+// gitPathArgs returns the file and files arguments as a pathspec list
+func gitPathArgs(args map[string]interface{}) ([]string, error) {
+	var paths []string
+	if file := getStr(args, "file"); file != "" {
+		paths = append(paths, file)
+	}
+	if list, ok := args["files"].([]interface{}); ok {
+		for _, item := range list {
+			path, ok := item.(string)
+			if !ok || path == "" {
+				return nil, invalidInputf("files must be a list of paths")
+			}
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range paths {
+		if strings.Contains(path, "..") {
+			return nil, fmt.Errorf("invalid file path")
+		}
+	}
+	return paths, nil
 }
 
 func (p *GitProfile) gitLog(repoPath string, args map[string]interface{}, env map[string]string) (string, error) {
@@ -162,7 +278,20 @@ func (p *GitProfile) gitLog(repoPath string, args map[string]interface{}, env ma
 		maxEntries = 500
 	}
 
-	gitArgs := []string{"log", fmt.Sprintf("-n%d", maxEntries), "--format=%H | %an | %ad | %s", "--date=short"}
+	skip := int(getFloat(args, "skip"))
+	if skip < 0 {
+		return "", invalidInputf("skip must not be negative")
+	}
+	maxBytes, err := gitMaxBytes(args)
+	if err != nil {
+		return "", err
+	}
+
+	// Ask for one commit more than shown to learn whether there are more
+	gitArgs := []string{"log", fmt.Sprintf("-n%d", maxEntries+1), "--format=%H | %an | %ad | %s", "--date=short"}
+	if skip > 0 {
+		gitArgs = append(gitArgs, fmt.Sprintf("--skip=%d", skip))
+	}
 
 	branch := getStr(args, "branch")
 	if branch != "" {
@@ -173,7 +302,36 @@ func (p *GitProfile) gitLog(repoPath string, args map[string]interface{}, env ma
 		gitArgs = append(gitArgs, branch)
 	}
 
-	return p.runGit(repoPath, gitArgs...)
+	out, err := p.gitOutput(repoPath, gitArgs...)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if lines[0] == "" {
+		if skip > 0 {
+			return fmt.Sprintf("(no commits after skipping %d)", skip), nil
+		}
+		return "(no output)", nil
+	}
+
+	more := len(lines) > maxEntries
+	if more {
+		lines = lines[:maxEntries]
+	}
+	size := 0
+	for i, line := range lines {
+		size += len(line) + 1
+		if size > maxBytes && i > 0 {
+			lines, more = lines[:i], true
+			break
+		}
+	}
+
+	result := strings.Join(lines, "\n")
+	if more {
+		result += fmt.Sprintf("\n... more commits; continue with skip=%d", skip+len(lines))
+	}
+	return result, nil
 }
 
 func (p *GitProfile) gitDiff(repoPath string, args map[string]interface{}) (string, error) {
@@ -192,15 +350,52 @@ func (p *GitProfile) gitDiff(repoPath string, args map[string]interface{}) (stri
 		gitArgs = append(gitArgs, ref)
 	}
 
-	file := getStr(args, "file")
-	if file != "" {
-		if strings.Contains(file, "..") {
-			return "", fmt.Errorf("invalid file path")
-		}
-		gitArgs = append(gitArgs, "--", file)
+	paths, err := gitPathArgs(args)
+	if err != nil {
+		return "", err
+	}
+	if len(paths) > 0 {
+		gitArgs = append(append(gitArgs, "--"), paths...)
 	}
 
-	return p.runGit(repoPath, gitArgs...)
+	offset := int(getFloat(args, "offset"))
+	if offset < 0 {
+		return "", invalidInputf("offset must not be negative")
+	}
+	maxBytes, err := gitMaxBytes(args)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := p.gitOutput(repoPath, gitArgs...)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(out) == "" {
+		return "(no output)", nil
+	}
+	if offset >= len(out) {
+		return fmt.Sprintf("(offset %d is past the end of the diff, which is %d bytes)", offset, len(out)), nil
+	}
+
+	// Pages end at a line break where possible, so hunks split cleanly
+	page := out[offset:]
+	if len(page) <= maxBytes {
+		if offset == 0 {
+			return strings.TrimSpace(page), nil
+		}
+		return fmt.Sprintf("%s\n... end of diff (bytes %d-%d of %d)", strings.TrimRight(page, "\n"), offset, len(out), len(out)), nil
+	}
+	cut := strings.LastIndexByte(page[:maxBytes], '\n') + 1
+	if cut == 0 {
+		cut = maxBytes
+		for cut > 0 && !utf8.RuneStart(page[cut]) {
+			cut--
+		}
+	}
+	end := offset + cut
+	return fmt.Sprintf("%s\n... diff continues (bytes %d-%d of %d); continue with offset=%d",
+		strings.TrimRight(page[:cut], "\n"), offset, end, len(out), end), nil
 }
 
 func (p *GitProfile) gitBlame(repoPath string, args map[string]interface{}) (string, error) {
@@ -211,7 +406,24 @@ func (p *GitProfile) gitBlame(repoPath string, args map[string]interface{}) (str
 	if strings.Contains(file, "..") {
 		return "", fmt.Errorf("invalid file path")
 	}
-	return p.runGit(repoPath, "blame", "--date=short", file)
+
+	gitArgs := []string{"blame", "--date=short"}
+	start := int(getFloat(args, "start_line"))
+	end := int(getFloat(args, "end_line"))
+	if start < 0 || end < 0 || (end > 0 && start > end) {
+		return "", fmt.Errorf("invalid line range")
+	}
+	if start > 0 || end > 0 {
+		if start == 0 {
+			start = 1
+		}
+		lineRange := fmt.Sprintf("%d,", start)
+		if end > 0 {
+			lineRange += strconv.Itoa(end)
+		}
+		gitArgs = append(gitArgs, "-L", lineRange)
+	}
+	return p.runGit(repoPath, append(gitArgs, "--", file)...)
 }
 
 func (p *GitProfile) gitBranches(repoPath string, args map[string]interface{}) (string, error) {
@@ -231,5 +443,143 @@ func (p *GitProfile) gitShow(repoPath string, args map[string]interface{}) (stri
 	if strings.ContainsAny(ref, " ;|&$`") {
 		return "", fmt.Errorf("invalid ref")
 	}
-	return p.runGit(repoPath, "show", "--stat", "--format=Commit: %H%nAuthor: %an <%ae>%nDate:   %ad%n%n%s%n%n%b", ref)
+	format := "--format=Commit: %H%nAuthor: %an <%ae>%nDate:   %ad%n%n%s%n%n%b"
+
+	file := getStr(args, "file")
+	if file == "" {
+		return p.runGit(repoPath, "show", "--stat", format, ref)
+	}
+	if strings.Contains(file, "..") {
+		return "", fmt.Errorf("invalid file path")
+	}
+	return p.runGit(repoPath, "show", "--stat", "--patch", format, ref, "--", file)
+}
+
+// gitStatsMaxCommits bounds the history git_stats reads
+const gitStatsMaxCommits = 10000
+
+type gitAuthorStats struct {
+	name           string
+	commits        int
+	added, removed int
+}
+
+type gitFileStats struct {
+	path    string
+	commits int
+	changed int
+}
+
+func (p *GitProfile) gitStats(repoPath string, args map[string]interface{}) (string, error) {
+	// Each commit starts with a NUL-prefixed author line, followed by
+	// "added<TAB>removed<TAB>path" per file ("-" counts for binary files)
+	gitArgs := []string{"log", fmt.Sprintf("-n%d", gitStatsMaxCommits), "--no-merges", "--numstat", "--format=%x00%aN"}
+
+	since := getStr(args, "since")
+	if since != "" {
+		gitArgs = append(gitArgs, "--since="+since)
+	}
+	ref := getStr(args, "ref")
+	if ref != "" {
+		if strings.ContainsAny(ref, " ;|&$`") || strings.HasPrefix(ref, "-") {
+			return "", fmt.Errorf("invalid ref")
+		}
+		gitArgs = append(gitArgs, ref)
+	}
+	topFiles := int(getFloat(args, "top_files"))
+	if topFiles <= 0 {
+		topFiles = 10
+	}
+	if topFiles > 50 {
+		topFiles = 50
+	}
+
+	out, err := p.gitOutput(repoPath, append(gitArgs, "--")...)
+	if err != nil {
+		return "", err
+	}
+
+	authors := map[string]*gitAuthorStats{}
+	files := map[string]*gitFileStats{}
+	var current *gitAuthorStats
+	commits := 0
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "\x00") {
+			name := strings.TrimPrefix(line, "\x00")
+			if authors[name] == nil {
+				authors[name] = &gitAuthorStats{name: name}
+			}
+			current = authors[name]
+			current.commits++
+			commits++
+			continue
+		}
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 || current == nil {
+			continue
+		}
+		added, _ := strconv.Atoi(parts[0])
+		removed, _ := strconv.Atoi(parts[1])
+		current.added += added
+		current.removed += removed
+		path := parts[2]
+		if files[path] == nil {
+			files[path] = &gitFileStats{path: path}
+		}
+		files[path].commits++
+		files[path].changed += added + removed
+	}
+
+	scope := "all history"
+	if since != "" {
+		scope = "since " + since
+	}
+	if ref != "" {
+		scope += " on " + ref
+	}
+	if commits == 0 {
+		return fmt.Sprintf("No commits found (%s)", scope), nil
+	}
+
+	byAuthor := make([]*gitAuthorStats, 0, len(authors))
+	for _, a := range authors {
+		byAuthor = append(byAuthor, a)
+	}
+	sort.Slice(byAuthor, func(i, j int) bool {
+		if byAuthor[i].commits != byAuthor[j].commits {
+			return byAuthor[i].commits > byAuthor[j].commits
+		}
+		return byAuthor[i].name < byAuthor[j].name
+	})
+	byFile := make([]*gitFileStats, 0, len(files))
+	for _, f := range files {
+		byFile = append(byFile, f)
+	}
+	sort.Slice(byFile, func(i, j int) bool {
+		if byFile[i].changed != byFile[j].changed {
+			return byFile[i].changed > byFile[j].changed
+		}
+		return byFile[i].path < byFile[j].path
+	})
+	if len(byFile) > topFiles {
+		byFile = byFile[:topFiles]
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("%d commits by %d authors (%s, merges excluded)", commits, len(byAuthor), scope))
+	if commits == gitStatsMaxCommits {
+		lines = append(lines, fmt.Sprintf("Only the latest %d commits were counted", gitStatsMaxCommits))
+	}
+	lines = append(lines, "")
+	lines = append(lines, fmt.Sprintf("%-30s %8s %10s %10s", "Author", "Commits", "Added", "Removed"))
+	lines = append(lines, strings.Repeat("-", 61))
+	for _, a := range byAuthor {
+		lines = append(lines, fmt.Sprintf("%-30s %8d %10d %10d", a.name, a.commits, a.added, a.removed))
+	}
+	lines = append(lines, "")
+	lines = append(lines, "Most changed files (lines added + removed):")
+	for _, f := range byFile {
+		lines = append(lines, fmt.Sprintf("  %8d  %s (%d commits)", f.changed, f.path, f.commits))
+	}
+	return truncateGitOutput(strings.Join(lines, "\n")), nil
 }
@@ -0,0 +1,341 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+type GraphQLProfile struct{}
+
+func (p *GraphQLProfile) ID() string       { return "graphql" }
+func (p *GraphQLProfile) HasBackend() bool { return true }
+
+func (p *GraphQLProfile) Tools() []Tool {
+	return []Tool{
+		{
+			Name:        "graphql_query",
+			Description: "Execute a GraphQL query or mutation against the configured endpoint",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "GraphQL query document",
+					},
+					"variables": map[string]interface{}{
+						"type":        "object",
+						"description": "Query variables",
+					},
+					"operation_name": map[string]interface{}{
+						"type":        "string",
+						"description": "Operation to run when the document defines several",
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+		{
+			Name:        "graphql_introspect",
+			Description: "Summarize the endpoint's schema: root queries, mutations and types",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"type_name": map[string]interface{}{
+						"type":        "string",
+						"description": "Show the fields of a single type instead of the overview",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (p *GraphQLProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "GRAPHQL_ENDPOINT", Required: true, Description: "GraphQL HTTP endpoint URL"},
+		{Name: "GRAPHQL_TOKEN", Required: false, Description: "Bearer token sent in the Authorization header"},
+		{Name: "ALLOW_PRIVATE_TARGETS", Required: false, Description: "Set to 'true' to allow an endpoint on a private network"},
+		{Name: "TIMEOUT_SECONDS", Required: false, Description: "Deprecated: use HTTP_TIMEOUT_SECONDS, which takes precedence"},
+		{Name: "MAX_RESPONSE_SIZE", Required: false, Description: "Maximum response bytes to read (default 5MB)"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 30, max 300)"},
+	}
+}
+
+// graphqlTimeout returns HTTP_TIMEOUT_SECONDS, or the deprecated
+// TIMEOUT_SECONDS when only that is set, with the same defaults and limits
+func graphqlTimeout(env map[string]string) time.Duration {
+	if env["HTTP_TIMEOUT_SECONDS"] == "" && env["TIMEOUT_SECONDS"] != "" {
+		env = map[string]string{"HTTP_TIMEOUT_SECONDS": env["TIMEOUT_SECONDS"]}
+	}
+	return httpTimeout(env, 30*time.Second)
+}
+
+func (p *GraphQLProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	switch name {
+	case "graphql_query":
+		return p.query(args, env)
+	case "graphql_introspect":
+		return p.introspect(args, env)
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+func (p *GraphQLProfile) query(args map[string]interface{}, env map[string]string) (string, error) {
+	query := getStr(args, "query")
+	if strings.TrimSpace(query) == "" {
+		return "", invalidInputf("query is required")
+	}
+
+	payload := map[string]interface{}{"query": query}
+	if vars, ok := args["variables"].(map[string]interface{}); ok && len(vars) > 0 {
+		payload["variables"] = vars
+	}
+	if op := getStr(args, "operation_name"); op != "" {
+		payload["operationName"] = op
+	}
+
+	resp, err := p.post(payload, env)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to format response: %s", err)
+	}
+	return string(out), nil
+}
+
+const graphqlIntrospectionQuery = `query {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      name
+      kind
+      description
+      fields { name description args { name type { ...TypeRef } } type { ...TypeRef } }
+      inputFields { name type { ...TypeRef } }
+      enumValues { name }
+    }
+  }
+}
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name } } }
+}`
+
+type gqlTypeRef struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	OfType *gqlTypeRef `json:"ofType"`
+}
+
+type gqlField struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Args        []struct {
+		Name string     `json:"name"`
+		Type gqlTypeRef `json:"type"`
+	} `json:"args"`
+	Type gqlTypeRef `json:"type"`
+}
+
+type gqlType struct {
+	Name        string     `json:"name"`
+	Kind        string     `json:"kind"`
+	Description string     `json:"description"`
+	Fields      []gqlField `json:"fields"`
+	InputFields []gqlField `json:"inputFields"`
+	EnumValues  []struct {
+		Name string `json:"name"`
+	} `json:"enumValues"`
+}
+
+func (p *GraphQLProfile) introspect(args map[string]interface{}, env map[string]string) (string, error) {
+	resp, err := p.post(map[string]interface{}{"query": graphqlIntrospectionQuery}, env)
+	if err != nil {
+		return "", err
+	}
+	if errs, ok := resp["errors"]; ok && resp["data"] == nil {
+		out, _ := json.MarshalIndent(errs, "", "  ")
+		return "", backendErrorf("introspection failed: %s", out)
+	}
+
+	raw, _ := json.Marshal(resp["data"])
+	var data struct {
+		Schema struct {
+			QueryType    *struct{ Name string } `json:"queryType"`
+			MutationType *struct{ Name string } `json:"mutationType"`
+			Types        []gqlType              `json:"types"`
+		} `json:"__schema"`
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return "", backendErrorf("unexpected introspection response: %s", err)
+	}
+
+	types := map[string]gqlType{}
+	for _, t := range data.Schema.Types {
+		types[t.Name] = t
+	}
+
+	if typeName := getStr(args, "type_name"); typeName != "" {
+		t, ok := types[typeName]
+		if !ok {
+			return "", invalidInputf("type %s not found", typeName)
+		}
+		return formatGraphQLType(t), nil
+	}
+
+	var sb strings.Builder
+	writeRoot := func(label string, root *struct{ Name string }) {
+		if root == nil {
+			return
+		}
+		t := types[root.Name]
+		sb.WriteString(fmt.Sprintf("%s (%s): %d fields\n", label, root.Name, len(t.Fields)))
+		for _, f := range t.Fields {
+			sb.WriteString("  " + formatGraphQLField(f) + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	writeRoot("Queries", data.Schema.QueryType)
+	writeRoot("Mutations", data.Schema.MutationType)
+
+	byKind := map[string][]string{}
+	for _, t := range data.Schema.Types {
+		if strings.HasPrefix(t.Name, "__") {
+			continue
+		}
+		if data.Schema.QueryType != nil && t.Name == data.Schema.QueryType.Name {
+			continue
+		}
+		if data.Schema.MutationType != nil && t.Name == data.Schema.MutationType.Name {
+			continue
+		}
+		byKind[t.Kind] = append(byKind[t.Kind], t.Name)
+	}
+	for _, kind := range []string{"OBJECT", "INPUT_OBJECT", "INTERFACE", "UNION", "ENUM", "SCALAR"} {
+		names := byKind[kind]
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+		sb.WriteString(fmt.Sprintf("%s (%d): %s\n", kind, len(names), strings.Join(names, ", ")))
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+func (p *GraphQLProfile) post(payload map[string]interface{}, env map[string]string) (map[string]interface{}, error) {
+	endpoint := strings.TrimSpace(env["GRAPHQL_ENDPOINT"])
+	if endpoint == "" {
+		return nil, notConfiguredf("GRAPHQL_ENDPOINT is not configured")
+	}
+	if allowPrivateTargets(env) {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, notConfiguredf("GRAPHQL_ENDPOINT must be an http/https URL")
+		}
+	} else if err := validateURL(endpoint, env); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, invalidInputf("invalid variables: %s", err)
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Dublyo-MCP-GraphQL/1.0")
+	if token := env["GRAPHQL_TOKEN"]; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	timeout := graphqlTimeout(env)
+	client := safeHTTPClient(timeout)
+	if allowPrivateTargets(env) {
+		client = privateHTTPClient(timeout)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, backendErrorf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	maxSize := envInt(env["MAX_RESPONSE_SIZE"], 5*1024*1024)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, backendErrorf("read failed: %s", err)
+	}
+	if len(data) > maxSize {
+		return nil, backendErrorf("response exceeds %d bytes (MAX_RESPONSE_SIZE)", maxSize)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, backendErrorf("HTTP %d: non-JSON response: %s", resp.StatusCode, truncateRunes(string(data), 500))
+	}
+	return result, nil
+}
+
+func formatGraphQLTypeRef(t gqlTypeRef) string {
+	switch t.Kind {
+	case "NON_NULL":
+		if t.OfType != nil {
+			return formatGraphQLTypeRef(*t.OfType) + "!"
+		}
+	case "LIST":
+		if t.OfType != nil {
+			return "[" + formatGraphQLTypeRef(*t.OfType) + "]"
+		}
+	}
+	return t.Name
+}
+
+func formatGraphQLField(f gqlField) string {
+	s := f.Name
+	if len(f.Args) > 0 {
+		parts := make([]string, len(f.Args))
+		for i, a := range f.Args {
+			parts[i] = a.Name + ": " + formatGraphQLTypeRef(a.Type)
+		}
+		s += "(" + strings.Join(parts, ", ") + ")"
+	}
+	s += ": " + formatGraphQLTypeRef(f.Type)
+	if f.Description != "" {
+		s += "  # " + truncateRunes(normalizeWhitespace(f.Description), 100)
+	}
+	return s
+}
+
+func formatGraphQLType(t gqlType) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s\n", t.Kind, t.Name))
+	if t.Description != "" {
+		sb.WriteString(normalizeWhitespace(t.Description) + "\n")
+	}
+	for _, f := range t.Fields {
+		sb.WriteString("  " + formatGraphQLField(f) + "\n")
+	}
+	for _, f := range t.InputFields {
+		sb.WriteString("  " + formatGraphQLField(f) + "\n")
+	}
+	for _, v := range t.EnumValues {
+		sb.WriteString("  " + v.Name + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,28 @@
+package profiles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGraphQLTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want time.Duration
+	}{
+		{"default", map[string]string{}, 30 * time.Second},
+		{"http timeout", map[string]string{"HTTP_TIMEOUT_SECONDS": "45"}, 45 * time.Second},
+		{"deprecated alias", map[string]string{"TIMEOUT_SECONDS": "45"}, 45 * time.Second},
+		{"alias clamped", map[string]string{"TIMEOUT_SECONDS": "100000"}, 300 * time.Second},
+		{"alias invalid", map[string]string{"TIMEOUT_SECONDS": "soon"}, 30 * time.Second},
+		{"http timeout wins", map[string]string{"HTTP_TIMEOUT_SECONDS": "10", "TIMEOUT_SECONDS": "45"}, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := graphqlTimeout(tt.env); got != tt.want {
+				t.Errorf("graphqlTimeout = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
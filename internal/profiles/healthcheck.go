@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
 	"time"
 )
@@ -14,16 +15,19 @@ type HealthcheckProfile struct{}
 
 func (p *HealthcheckProfile) ID() string { return "healthcheck" }
 
+func (p *HealthcheckProfile) UsesNetwork() bool { return true }
+
 func (p *HealthcheckProfile) Tools() []Tool {
 	return []Tool{
 		{
 			Name:        "ping_url",
-			Description: "Check if a URL is reachable and measure response time",
+			Description: "Check if a URL is reachable and measure response time, reporting the address (and IP version) connected to",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"url":    map[string]interface{}{"type": "string", "description": "URL to check"},
-					"method": map[string]interface{}{"type": "string", "description": "HTTP method (default GET)"},
+					"url":        map[string]interface{}{"type": "string", "description": "URL to check"},
+					"method":     map[string]interface{}{"type": "string", "description": "HTTP method (default GET)"},
+					"ip_version": ipVersionProperty(),
 				},
 				"required": []string{"url"},
 			},
@@ -65,22 +69,29 @@ func (p *HealthcheckProfile) Tools() []Tool {
 	}
 }
 
+func (p *HealthcheckProfile) RequiredEnv() []EnvSpec {
+	return append([]EnvSpec{
+		{Name: "ALLOW_PRIVATE_TARGETS", Required: false, Description: "Set to true to allow checking private/internal hosts (default false)"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 15, max 300)"},
+	}, outboundHTTPEnv...)
+}
+
 func (p *HealthcheckProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "ping_url":
-		return p.pingURL(args)
+		return p.pingURL(args, env)
 	case "check_ssl":
-		return p.checkSSL(args)
+		return p.checkSSL(args, env)
 	case "check_headers":
-		return p.checkHeaders(args)
+		return p.checkHeaders(args, env)
 	case "check_redirect_chain":
-		return p.checkRedirectChain(args)
+		return p.checkRedirectChain(args, env)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
 }
 
-func (p *HealthcheckProfile) pingURL(args map[string]interface{}) (string, error) {
+func (p *HealthcheckProfile) pingURL(args map[string]interface{}, env map[string]string) (string, error) {
 	rawURL := getStr(args, "url")
 	if rawURL == "" {
 		return "", fmt.Errorf("url is required")
@@ -89,20 +100,37 @@ func (p *HealthcheckProfile) pingURL(args map[string]interface{}) (string, error
 	if method == "" {
 		method = "GET"
 	}
+	version, err := ipVersionArg(args)
+	if err != nil {
+		return "", err
+	}
 
 	req, err := http.NewRequest(method, rawURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("invalid request: %s", err)
 	}
-	req.Header.Set("User-Agent", "Dublyo-Healthcheck/1.0")
+	req.Header.Set("User-Agent", userAgent(env, "Dublyo-Healthcheck/1.0"))
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	// The last connection used is the one that answered, after any redirects
+	remote := "unknown"
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { remote = addrFamily(info.Conn.RemoteAddr()) },
+	}))
+
+	client, err := withDefaultHeaders(withIPVersion(healthcheckClient(env), allowPrivateTargets(env), version), env)
+	if err != nil {
+		return "", err
+	}
 	start := time.Now()
 	resp, err := client.Do(req)
 	elapsed := time.Since(start)
 
 	if err != nil {
-		return fmt.Sprintf("URL: %s\nStatus: UNREACHABLE\nError: %s\nResponse Time: %s", rawURL, err, elapsed.Round(time.Millisecond)), nil
+		out := fmt.Sprintf("URL: %s\nStatus: UNREACHABLE\nError: %s\nResponse Time: %s", rawURL, err, elapsed.Round(time.Millisecond))
+		if version != "" {
+			out += "\nIP Version: IPv" + version + " only"
+		}
+		return out, nil
 	}
 	defer resp.Body.Close()
 	io.Copy(io.Discard, resp.Body)
@@ -112,14 +140,14 @@ func (p *HealthcheckProfile) pingURL(args map[string]interface{}) (string, error
 		status = "DOWN"
 	}
 
-	return fmt.Sprintf("URL: %s\nStatus: %s\nHTTP Status: %d %s\nResponse Time: %s\nContent-Type: %s\nServer: %s",
+	return fmt.Sprintf("URL: %s\nStatus: %s\nHTTP Status: %d %s\nResponse Time: %s\nConnected To: %s\nContent-Type: %s\nServer: %s",
 		rawURL, status, resp.StatusCode, http.StatusText(resp.StatusCode),
-		elapsed.Round(time.Millisecond),
+		elapsed.Round(time.Millisecond), remote,
 		resp.Header.Get("Content-Type"),
 		resp.Header.Get("Server")), nil
 }
 
-func (p *HealthcheckProfile) checkSSL(args map[string]interface{}) (string, error) {
+func (p *HealthcheckProfile) checkSSL(args map[string]interface{}, env map[string]string) (string, error) {
 	domain := getStr(args, "domain")
 	if domain == "" {
 		return "", fmt.Errorf("domain is required")
@@ -130,7 +158,11 @@ func (p *HealthcheckProfile) checkSSL(args map[string]interface{}) (string, erro
 	}
 
 	addr := fmt.Sprintf("%s:%d", domain, port)
-	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, &tls.Config{})
+	dialer := safeDialer()
+	if allowPrivateTargets(env) {
+		dialer = &net.Dialer{Timeout: 10 * time.Second}
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{})
 	if err != nil {
 		return fmt.Sprintf("SSL check for %s:\nStatus: FAILED\nError: %s", domain, err), nil
 	}
@@ -174,7 +206,7 @@ func (p *HealthcheckProfile) checkSSL(args map[string]interface{}) (string, erro
 		strings.Join(chain, "\n")), nil
 }
 
-func (p *HealthcheckProfile) checkHeaders(args map[string]interface{}) (string, error) {
+func (p *HealthcheckProfile) checkHeaders(args map[string]interface{}, env map[string]string) (string, error) {
 	rawURL := getStr(args, "url")
 	if rawURL == "" {
 		return "", fmt.Errorf("url is required")
@@ -184,9 +216,12 @@ func (p *HealthcheckProfile) checkHeaders(args map[string]interface{}) (string,
 	if err != nil {
 		return "", fmt.Errorf("invalid request: %s", err)
 	}
-	req.Header.Set("User-Agent", "Dublyo-Healthcheck/1.0")
+	req.Header.Set("User-Agent", userAgent(env, "Dublyo-Healthcheck/1.0"))
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client, err := withDefaultHeaders(healthcheckClient(env), env)
+	if err != nil {
+		return "", err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %s", err)
@@ -227,7 +262,7 @@ func (p *HealthcheckProfile) checkHeaders(args map[string]interface{}) (string,
 	return strings.Join(lines, "\n"), nil
 }
 
-func (p *HealthcheckProfile) checkRedirectChain(args map[string]interface{}) (string, error) {
+func (p *HealthcheckProfile) checkRedirectChain(args map[string]interface{}, env map[string]string) (string, error) {
 	rawURL := getStr(args, "url")
 	if rawURL == "" {
 		return "", fmt.Errorf("url is required")
@@ -236,11 +271,12 @@ func (p *HealthcheckProfile) checkRedirectChain(args map[string]interface{}) (st
 	var chain []string
 	currentURL := rawURL
 
-	client := &http.Client{
-		Timeout: 15 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+	client, err := withDefaultHeaders(healthcheckClient(env), env)
+	if err != nil {
+		return "", err
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
 	}
 
 	for i := 0; i < 10; i++ {
@@ -248,7 +284,7 @@ func (p *HealthcheckProfile) checkRedirectChain(args map[string]interface{}) (st
 		if err != nil {
 			break
 		}
-		req.Header.Set("User-Agent", "Dublyo-Healthcheck/1.0")
+		req.Header.Set("User-Agent", userAgent(env, "Dublyo-Healthcheck/1.0"))
 
 		resp, err := client.Do(req)
 		if err != nil {
@@ -270,6 +306,19 @@ func (p *HealthcheckProfile) checkRedirectChain(args map[string]interface{}) (st
 	return fmt.Sprintf("Redirect chain for %s:\n\n%s", rawURL, strings.Join(chain, "\n")), nil
 }
 
+// allowPrivateTargets reports whether the operator opted in to checking internal hosts
+func allowPrivateTargets(env map[string]string) bool {
+	return env["ALLOW_PRIVATE_TARGETS"] == "true"
+}
+
+func healthcheckClient(env map[string]string) *http.Client {
+	timeout := httpTimeout(env, 15*time.Second)
+	if allowPrivateTargets(env) {
+		return privateHTTPClient(timeout)
+	}
+	return safeHTTPClient(timeout)
+}
+
 func tlsVersionString(v uint16) string {
 	switch v {
 	case tls.VersionTLS10:
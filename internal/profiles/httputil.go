@@ -0,0 +1,309 @@
+package profiles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Shared transports for outbound HTTP. safeTransport refuses to connect to
+// private, loopback and link-local addresses; the check runs on the resolved
+// IP at dial time, so a public hostname that resolves to an internal address
+// (DNS rebinding) is still blocked. Both honor HTTP_PROXY/HTTPS_PROXY/NO_PROXY;
+// see newSafeTransport for how the check applies through a proxy.
+var (
+	safeTransport    = newSafeTransport(safeDialer(), "tcp", http.ProxyFromEnvironment)
+	privateTransport = newTransport(privateDialer(), "tcp")
+)
+
+// newTransport returns a transport dialing network ("tcp", "tcp4" or "tcp6")
+func newTransport(dialer *net.Dialer, network string) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newSafeTransport is newTransport for a safeDialer when requests may go
+// through a proxy, where the dial-time check would see the proxy's address
+// instead of the target's. The target host is resolved and checked before a
+// request is handed to the proxy, and the proxy itself, which the operator
+// configured, may be on a private network. The proxy resolves the target
+// again, so through one the check can't stop DNS rebinding.
+func newSafeTransport(dialer *net.Dialer, network string, proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	t := newTransport(dialer, network)
+	var proxies sync.Map // addresses of the proxies returned below
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil {
+			return u, err
+		}
+		if err := checkProxiedHost(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		proxies.Store(proxyAddr(u), true)
+		return u, nil
+	}
+	direct := privateDialer()
+	dial := t.DialContext
+	t.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		if _, isProxy := proxies.Load(addr); isProxy {
+			return direct.DialContext(ctx, network, addr)
+		}
+		return dial(ctx, network, addr)
+	}
+	return t
+}
+
+// proxyAddr returns the host:port the transport dials for proxy u
+func proxyAddr(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// privateDialer returns a dialer without destination checks
+func privateDialer() *net.Dialer {
+	return &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+}
+
+// safeDialer returns a dialer that rejects blocked destination IPs after DNS resolution
+func safeDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip != nil && isBlockedIP(ip) {
+				return fmt.Errorf("access to private/local IPs is blocked (%s)", ip)
+			}
+			return nil
+		},
+	}
+}
+
+// httpTimeout returns the connection's HTTP_TIMEOUT_SECONDS clamped to
+// 1–300s, or fallback when it is unset or invalid
+func httpTimeout(env map[string]string, fallback time.Duration) time.Duration {
+	n, err := strconv.Atoi(env["HTTP_TIMEOUT_SECONDS"])
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	if n > 300 {
+		n = 300
+	}
+	return time.Duration(n) * time.Second
+}
+
+// safeHTTPClient returns a client on the shared SSRF-safe transport that
+// follows at most 5 redirects
+func safeHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     safeTransport,
+		CheckRedirect: limitRedirects,
+	}
+}
+
+// privateHTTPClient is like safeHTTPClient but allows private destinations.
+// Only use it where an operator has explicitly opted in.
+func privateHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     privateTransport,
+		CheckRedirect: limitRedirects,
+	}
+}
+
+func limitRedirects(req *http.Request, via []*http.Request) error {
+	if len(via) >= 5 {
+		return fmt.Errorf("too many redirects")
+	}
+	return nil
+}
+
+// outboundHTTPEnv are the per-connection request defaults honored by the
+// profiles that make HTTP requests on a user's behalf (fetch, webhook,
+// healthcheck and the knowledge profiles)
+var outboundHTTPEnv = []EnvSpec{
+	{Name: "HTTP_USER_AGENT", Required: false, Description: "User-Agent for all outbound requests, replacing the profile's own"},
+	{Name: "HTTP_DEFAULT_HEADERS", Required: false, Description: "JSON object of headers sent with every outbound request, e.g. {\"X-Api-Key\": \"...\"}; headers a call sets itself take precedence"},
+}
+
+// userAgent returns the connection's HTTP_USER_AGENT, or fallback
+func userAgent(env map[string]string, fallback string) string {
+	if ua := strings.TrimSpace(env["HTTP_USER_AGENT"]); ua != "" {
+		return ua
+	}
+	return fallback
+}
+
+// withDefaultHeaders returns client adding the connection's HTTP_USER_AGENT
+// and HTTP_DEFAULT_HEADERS to requests that don't set those headers
+// themselves. Apply it last, over withIPVersion, since it wraps the transport.
+func withDefaultHeaders(client *http.Client, env map[string]string) (*http.Client, error) {
+	headers := http.Header{}
+	if ua := strings.TrimSpace(env["HTTP_USER_AGENT"]); ua != "" {
+		headers.Set("User-Agent", ua)
+	}
+	if raw := strings.TrimSpace(env["HTTP_DEFAULT_HEADERS"]); raw != "" {
+		var defaults map[string]string
+		if err := json.Unmarshal([]byte(raw), &defaults); err != nil {
+			return nil, notConfiguredf("HTTP_DEFAULT_HEADERS must be a JSON object of header names to string values: %s", err)
+		}
+		for name, value := range defaults {
+			headers.Set(name, value)
+		}
+	}
+	if len(headers) == 0 {
+		return client, nil
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = &defaultHeadersTransport{base: base, headers: headers}
+	return &wrapped, nil
+}
+
+// defaultHeadersTransport fills in headers a request lacks. Beyond
+// User-Agent they only go to the host first requested, so credentials in
+// HTTP_DEFAULT_HEADERS don't follow a redirect to another site.
+type defaultHeadersTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *defaultHeadersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	first := req
+	for first.Response != nil && first.Response.Request != nil {
+		first = first.Response.Request
+	}
+	sameHost := first.URL.Host == req.URL.Host
+
+	var missing http.Header
+	for name, values := range t.headers {
+		if _, set := req.Header[name]; set || (!sameHost && name != "User-Agent") {
+			continue
+		}
+		if missing == nil {
+			missing = http.Header{}
+		}
+		missing[name] = values
+	}
+	if missing == nil {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	for name, values := range missing {
+		req.Header[name] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Ranges not covered by the net.IP helpers that still reach internal hosts
+var blockedNets = mustParseCIDRs(
+	"0.0.0.0/8",     // "this network"
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"64:ff9b::/96",  // NAT64, embeds an IPv4 address
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isBlockedIP reports whether outbound requests to ip must be refused
+func isBlockedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkResolvedHost resolves host and returns an error if any address is blocked.
+// This gives an early, readable error; the dial-time check in safeDialer remains
+// authoritative since DNS answers can change between here and the connection.
+func checkResolvedHost(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if isBlockedIP(ip) {
+			return fmt.Errorf("access to private/local IPs is blocked")
+		}
+		return nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		// Let the request itself surface resolution failures
+		return nil
+	}
+	for _, ip := range ips {
+		if isBlockedIP(ip) {
+			return fmt.Errorf("host %s resolves to a private/local IP (%s)", host, ip)
+		}
+	}
+	return nil
+}
+
+// checkProxiedHost is checkResolvedHost for a request going through a proxy:
+// no connection to the target is dialed here, so a host that doesn't resolve
+// can't be checked and is refused
+func checkProxiedHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if isBlockedIP(ip) {
+			return fmt.Errorf("access to private/local IPs is blocked (%s)", ip)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s to check it before using the proxy: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if isBlockedIP(addr.IP) {
+			return fmt.Errorf("host %s resolves to a private/local IP (%s)", host, addr.IP)
+		}
+	}
+	return nil
+}
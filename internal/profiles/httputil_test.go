@@ -0,0 +1,92 @@
+package profiles
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSafeTransportProxy(t *testing.T) {
+	// A forward proxy on loopback, a private address safeDialer refuses
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		io.WriteString(w, "via proxy to "+r.URL.Host)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	transport := newSafeTransport(safeDialer(), "tcp", http.ProxyURL(proxyURL))
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	tests := []struct {
+		name   string
+		target string
+		errMsg string // "" when the request must go through the proxy
+	}{
+		{name: "public target through private proxy", target: "http://203.0.113.5/"},
+		{name: "public target with port", target: "http://203.0.113.5:8080/path"},
+		{name: "loopback target", target: "http://127.0.0.1:6379/", errMsg: "private/local IPs is blocked"},
+		{name: "private target", target: "http://10.0.0.1/", errMsg: "private/local IPs is blocked"},
+		{name: "metadata target", target: "http://169.254.169.254/latest/meta-data/", errMsg: "private/local IPs is blocked"},
+		{name: "localhost name", target: "http://localhost/", errMsg: "resolves to a private/local IP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := proxied.Load()
+			resp, err := client.Get(tt.target)
+			if tt.errMsg != "" {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("GET %s through the proxy succeeded", tt.target)
+				}
+				if !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("error %q, want it to contain %q", err, tt.errMsg)
+				}
+				if proxied.Load() != before {
+					t.Error("the request reached the proxy")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GET %s: %v", tt.target, err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			target, _ := url.Parse(tt.target)
+			if want := "via proxy to " + target.Host; string(body) != want {
+				t.Errorf("body %q, want %q", body, want)
+			}
+		})
+	}
+
+	// Without a proxy the dial-time check still applies to the target
+	direct := &http.Client{Transport: newSafeTransport(safeDialer(), "tcp", http.ProxyURL(nil))}
+	if resp, err := direct.Get(proxy.URL); err == nil {
+		resp.Body.Close()
+		t.Error("direct GET of a loopback address succeeded")
+	} else if !strings.Contains(err.Error(), "private/local IPs is blocked") {
+		t.Errorf("direct GET error %q", err)
+	}
+}
+
+func TestProxyAddr(t *testing.T) {
+	tests := map[string]string{
+		"http://proxy.example.com":       "proxy.example.com:80",
+		"https://proxy.example.com":      "proxy.example.com:443",
+		"socks5://10.0.0.1":              "10.0.0.1:1080",
+		"http://10.0.0.1:3128":           "10.0.0.1:3128",
+		"http://user:pass@[2001:db8::1]": "[2001:db8::1]:80",
+	}
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := proxyAddr(u); got != want {
+			t.Errorf("proxyAddr(%s) = %s, want %s", raw, got, want)
+		}
+	}
+}
@@ -44,7 +44,7 @@ func (p *IpProfile) Tools() []Tool {
 				"type": "object",
 				"properties": map[string]interface{}{
 					"ip":   map[string]interface{}{"type": "string", "description": "IP address to check"},
-					"cidr": map[string]interface{}{"type": "string", "description": "CIDR range"},
+					"cidr": map[string]interface{}{"type": "string", "description": "CIDR range, or several separated by commas"},
 				},
 				"required": []string{"ip", "cidr"},
 			},
@@ -64,6 +64,10 @@ func (p *IpProfile) Tools() []Tool {
 	}
 }
 
+func (p *IpProfile) CacheableTools() []string {
+	return []string{"ip_info", "cidr_info", "subnet_calculator"}
+}
+
 func (p *IpProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "ip_info":
@@ -203,16 +207,48 @@ func (p *IpProfile) ipInRange(args map[string]interface{}) (string, error) {
 	if ip == nil {
 		return "", fmt.Errorf("invalid IP: %s", ipStr)
 	}
-	_, ipNet, err := net.ParseCIDR(cidr)
+	nets, err := ParseCIDRList(cidr)
 	if err != nil {
-		return "", fmt.Errorf("invalid CIDR: %s", err)
+		return "", err
 	}
-	if ipNet.Contains(ip) {
-		return fmt.Sprintf("YES — %s is within %s", ipStr, cidr), nil
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return fmt.Sprintf("YES — %s is within %s", ipStr, ipNet), nil
+		}
 	}
 	return fmt.Sprintf("NO — %s is NOT within %s", ipStr, cidr), nil
 }
 
+// ParseCIDRList parses comma-separated CIDR ranges. A bare address is taken
+// as a range holding just that address.
+func ParseCIDRList(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP: %s", item)
+			}
+			size := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, size = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(size, size)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
 func (p *IpProfile) subnetCalculator(args map[string]interface{}) (string, error) {
 	networkStr := getStr(args, "network")
 	hostsNeeded := int(getFloat(args, "hosts_needed"))
@@ -0,0 +1,82 @@
+package profiles
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Network tools take an ip_version argument to force IPv4 or IPv6, e.g. to
+// tell apart a dual-stack host's two paths. "auto" leaves the choice to the
+// resolver and dialer as usual.
+
+// ipVersionProperty is the schema of the ip_version argument
+func ipVersionProperty() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"enum":        []string{"auto", "4", "6"},
+		"description": "IP version to resolve and connect over: auto (default), 4 or 6",
+	}
+}
+
+// ipVersionArg returns the call's ip_version: "" for auto, "4" or "6"
+func ipVersionArg(args map[string]interface{}) (string, error) {
+	v := strings.ToLower(formValue(args["ip_version"]))
+	switch strings.TrimPrefix(v, "ipv") {
+	case "", "auto":
+		return "", nil
+	case "4":
+		return "4", nil
+	case "6":
+		return "6", nil
+	}
+	return "", invalidInputf("ip_version must be auto, 4 or 6, got %q", v)
+}
+
+// addressFamily names the IP version of ip
+func addressFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// addrFamily describes a connection's remote address with its IP version,
+// e.g. "93.184.216.34:443 (IPv4)"
+func addrFamily(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return addr.String()
+	}
+	return addr.String() + " (" + addressFamily(ip) + ")"
+}
+
+type ipVersionKey struct {
+	private bool
+	version string
+}
+
+// ipVersionTransports are the shared transports restricted to one IP version:
+// they dial tcp4 or tcp6 only, so hostnames resolve to addresses of that
+// version alone
+var ipVersionTransports = map[ipVersionKey]*http.Transport{
+	{false, "4"}: newSafeTransport(safeDialer(), "tcp4", http.ProxyFromEnvironment),
+	{false, "6"}: newSafeTransport(safeDialer(), "tcp6", http.ProxyFromEnvironment),
+	{true, "4"}:  newTransport(privateDialer(), "tcp4"),
+	{true, "6"}:  newTransport(privateDialer(), "tcp6"),
+}
+
+// withIPVersion returns client restricted to IP version ("" leaves it as is).
+// private must match whether client may reach private destinations.
+func withIPVersion(client *http.Client, private bool, version string) *http.Client {
+	if version == "" {
+		return client
+	}
+	restricted := *client
+	restricted.Transport = ipVersionTransports[ipVersionKey{private, version}]
+	return &restricted
+}
@@ -0,0 +1,232 @@
+package profiles
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// Knowledge searches only score the chunks containing a query term, found
+// through an inverted index built when the source is loaded. Many candidates
+// are scored in parallel, in batches taken by up to GOMAXPROCS workers, and
+// a search whose context ends first (its timeout_ms, or the tool timeout)
+// returns the best matches among the chunks scored by then.
+
+const (
+	// knowledgeScoreBatch is how many chunks a worker scores between checks
+	// of the context
+	knowledgeScoreBatch = 512
+	// knowledgeParallelMin is the index size from which scoring is spread
+	// over several goroutines
+	knowledgeParallelMin = 8192
+)
+
+// knowledgeScore is the score of the chunk at index
+type knowledgeScore struct {
+	index int
+	score float64
+}
+
+// knowledgeTimeoutProperty is the schema of the search tools' timeout_ms
+func knowledgeTimeoutProperty() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "integer",
+		"description": "Stop searching after this many milliseconds and return the best matches found so far (default: no limit beyond the tool timeout)",
+	}
+}
+
+// knowledgeSearchContext applies the call's timeout_ms to ctx
+func knowledgeSearchContext(ctx context.Context, args map[string]interface{}) (context.Context, context.CancelFunc) {
+	if ms := int(getFloat(args, "timeout_ms")); ms > 0 {
+		return context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+	}
+	return context.WithCancel(ctx)
+}
+
+// scoreKnowledge scores chunks 0..n-1 and returns those scoring above zero,
+// best first: by score, then longer content (length), then index, so the
+// order doesn't depend on how the work was scheduled. scored is how many
+// chunks were scored, less than n when ctx ended first.
+func scoreKnowledge(ctx context.Context, n int, score func(i int) float64, length func(i int) int) (matches []knowledgeScore, scored int) {
+	workers := 1
+	if n >= knowledgeParallelMin {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var next, done atomic.Int64
+	found := make([][]knowledgeScore, workers)
+	work := func(w int) {
+		for ctx.Err() == nil {
+			start := int(next.Add(knowledgeScoreBatch)) - knowledgeScoreBatch
+			if start >= n {
+				return
+			}
+			end := min(start+knowledgeScoreBatch, n)
+			for i := start; i < end; i++ {
+				if s := score(i); s > 0 {
+					found[w] = append(found[w], knowledgeScore{index: i, score: s})
+				}
+			}
+			done.Add(int64(end - start))
+		}
+	}
+	if workers == 1 {
+		work(0)
+	} else {
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				work(w)
+			}(w)
+		}
+		wg.Wait()
+	}
+
+	for _, f := range found {
+		matches = append(matches, f...)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if la, lb := length(a.index), length(b.index); la != lb {
+			return la > lb
+		}
+		return a.index < b.index
+	})
+	return matches, int(done.Load())
+}
+
+// knowledgeIndex maps the tokens of a source's chunks (runs of letters and
+// digits, as tokenize splits them) to the chunks containing them
+type knowledgeIndex struct {
+	postings map[string][]knowledgePosting
+	lower    []string // each chunk's lowercased text, as scored
+	length   []int    // each chunk's content length, for ordering ties
+	// short are the chunks below knowledgeShortChunk, which score without
+	// matching anything, longest first
+	short []int
+}
+
+// knowledgePosting is how often a token occurs in a chunk
+type knowledgePosting struct {
+	chunk int
+	count int
+}
+
+// knowledgeShortChunk is the content length under which a chunk gets a
+// small bonus from the scoring functions
+const knowledgeShortChunk = 1200
+
+// buildKnowledgeIndex indexes chunks by their lowercased text and content
+// length
+func buildKnowledgeIndex(lower []string, length []int) *knowledgeIndex {
+	ix := &knowledgeIndex{postings: map[string][]knowledgePosting{}, lower: lower, length: length}
+	for i, text := range lower {
+		counts := map[string]int{}
+		for _, token := range strings.FieldsFunc(text, notTermRune) {
+			counts[token]++
+		}
+		for token, n := range counts {
+			ix.postings[token] = append(ix.postings[token], knowledgePosting{chunk: i, count: n})
+		}
+		if length[i] < knowledgeShortChunk {
+			ix.short = append(ix.short, i)
+		}
+	}
+	sort.SliceStable(ix.short, func(a, b int) bool { return length[ix.short[a]] > length[ix.short[b]] })
+	return ix
+}
+
+// notTermRune reports whether r separates tokens, as in tokenize
+func notTermRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// termCounts returns how often term occurs in each chunk containing it,
+// counted like strings.Count over the chunk's text. A term is letters and
+// digits only, so each occurrence lies within one token and the counts add
+// up from the tokens containing it.
+func (ix *knowledgeIndex) termCounts(term string) map[int]int {
+	counts := map[int]int{}
+	for token, postings := range ix.postings {
+		if len(token) < len(term) {
+			continue
+		}
+		per := 1
+		if token != term {
+			if per = strings.Count(token, term); per == 0 {
+				continue
+			}
+		}
+		for _, p := range postings {
+			counts[p.chunk] += p.count * per
+		}
+	}
+	return counts
+}
+
+// search ranks the chunks for a query, returning up to limit of them best
+// first, as scoring every chunk and sorting with scoreKnowledge would.
+// score(i, phrase, occ) scores chunk i given whether it contains the whole
+// query and each term's occurrences. scored and candidates tell how many of
+// the chunks containing a term were scored before ctx ended.
+func (ix *knowledgeIndex) search(ctx context.Context, queryLower string, terms []string, limit int, score func(i int, phrase bool, occ []int) float64) (matches []knowledgeScore, scored, candidates int) {
+	counts := make([]map[int]int, len(terms))
+	var cands []int
+	seen := map[int]bool{}
+	for t, term := range terms {
+		counts[t] = ix.termCounts(term)
+		for c := range counts[t] {
+			if !seen[c] {
+				seen[c] = true
+				cands = append(cands, c)
+			}
+		}
+	}
+	sort.Ints(cands)
+
+	matches, scored = scoreKnowledge(ctx, len(cands), func(k int) float64 {
+		c := cands[k]
+		occ := make([]int, len(terms))
+		all := true
+		for t := range terms {
+			occ[t] = counts[t][c]
+			all = all && occ[t] > 0
+		}
+		// A chunk containing the whole query contains each of its terms
+		return score(c, all && strings.Contains(ix.lower[c], queryLower), occ)
+	}, func(k int) int { return ix.length[cands[k]] })
+	for i := range matches {
+		matches[i].index = cands[matches[i].index]
+	}
+
+	// Chunks without any term can only score through their length bonus,
+	// below any chunk with a term, and rank among themselves as ix.short
+	if scored == len(cands) && len(matches) < limit {
+		none := make([]int, len(terms))
+		for _, c := range ix.short {
+			if len(matches) >= limit {
+				break
+			}
+			if seen[c] {
+				continue
+			}
+			if s := score(c, false, none); s > 0 {
+				matches = append(matches, knowledgeScore{index: c, score: s})
+			}
+		}
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, scored, len(cands)
+}
@@ -0,0 +1,188 @@
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cachedWPKnowledge returns a profile whose source is already loaded from
+// content, with the env to search it
+func cachedWPKnowledge(content string) (*WordPressKnowledgeProfile, map[string]string) {
+	const url = "https://example.com/llms.txt"
+	chunks := splitKnowledgeChunks(content)
+	p := &WordPressKnowledgeProfile{cache: map[string]*wpKnowledgeSource{}}
+	p.cache[p.cacheKey(url, "")] = &wpKnowledgeSource{
+		URL:       url,
+		FetchedAt: time.Now(),
+		Chunks:    chunks,
+		index:     indexWPKnowledge(chunks),
+	}
+	return p, map[string]string{"LLMS_TXT_URL": url}
+}
+
+func TestKnowledgeSearchPartial(t *testing.T) {
+	p, env := cachedWPKnowledge("# Install\nRun the installer.\n\n# Plugins\nPlugins extend the installer.\n")
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		wantPartial bool
+	}{
+		{"complete", context.Background(), false},
+		{"timed out", expired, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, partial := WithPartialFlag(tt.ctx)
+			out, err := p.CallToolContext(ctx, "search_knowledge", map[string]interface{}{"query": "installer"}, env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if partial() != tt.wantPartial {
+				t.Errorf("partial = %v, want %v", partial(), tt.wantPartial)
+			}
+			if strings.Contains(out, "search timed out") != tt.wantPartial {
+				t.Errorf("output:\n%s", out)
+			}
+		})
+	}
+}
+
+// knowledgeWords are drawn for synthetic chunks; some contain others, so
+// terms also match inside longer tokens
+var knowledgeWords = []string{
+	"install", "installer", "reinstall", "plugin", "plugins", "theme", "themes",
+	"the", "and", "to", "of", "wp", "cli", "rest", "api", "cache", "caching",
+	"user", "users", "role", "admin", "Admin", "café", "cafe", "Straße",
+	"2024", "v2", "php8", "multisite", "network", "settings", "permalinks",
+}
+
+// syntheticWPChunks returns n chunks of random words, of lengths on both
+// sides of knowledgeShortChunk
+func syntheticWPChunks(n int, rng *rand.Rand) []wpKnowledgeChunk {
+	words := func(count int) string {
+		var b strings.Builder
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				b.WriteString([]string{" ", " ", ", ", ". ", "-", "/"}[rng.Intn(6)])
+			}
+			b.WriteString(knowledgeWords[rng.Intn(len(knowledgeWords))])
+		}
+		return b.String()
+	}
+	chunks := make([]wpKnowledgeChunk, n)
+	for i := range chunks {
+		chunks[i] = buildKnowledgeChunk(words(1+rng.Intn(3)), words(5+rng.Intn(300)))
+	}
+	return chunks
+}
+
+// linearKnowledgeSearch is the reference for knowledgeIndex.search: it
+// counts the terms in every chunk and scores them all
+func linearKnowledgeSearch(lower []string, length []int, queryLower string, terms []string, limit int, score func(i int, phrase bool, occ []int) float64) []knowledgeScore {
+	matches, _ := scoreKnowledge(context.Background(), len(lower), func(i int) float64 {
+		occ := make([]int, len(terms))
+		for t, term := range terms {
+			occ[t] = strings.Count(lower[i], term)
+		}
+		return score(i, strings.Contains(lower[i], queryLower), occ)
+	}, func(i int) int { return length[i] })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+var knowledgeQueries = []string{
+	"plugin",
+	"install",
+	"stall",
+	"install the plugin",
+	"wp-cli",
+	"REST API caching",
+	"café",
+	"straße",
+	"2024",
+	"a theme",
+	"nothing matches this",
+}
+
+func TestKnowledgeIndexMatchesLinear(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	wp := syntheticWPChunks(600, rng)
+	files := make([]filesKnowledgeChunk, len(wp))
+	for i, c := range wp {
+		name := []string{"install-guide.md", "themes.txt", "api.md"}[rng.Intn(3)]
+		files[i] = filesKnowledgeChunk{FileName: name, Heading: c.Heading, Content: c.Content,
+			lower: strings.ToLower(c.Heading + "\n" + name + "\n" + c.Content)}
+	}
+
+	for _, query := range knowledgeQueries {
+		queryLower := strings.ToLower(query)
+		terms := uniqueTerms(tokenize(query))
+		profiles := []struct {
+			name  string
+			index *knowledgeIndex
+			score func(i int, phrase bool, occ []int) float64
+		}{
+			{"wordpress", indexWPKnowledge(wp), func(i int, phrase bool, occ []int) float64 {
+				return scoreKnowledgeChunk(wp[i], phrase, terms, occ)
+			}},
+			{"files", indexFilesKnowledge(files), func(i int, phrase bool, occ []int) float64 {
+				return scoreFilesKnowledgeChunk(files[i], phrase, terms, occ)
+			}},
+		}
+		for _, pr := range profiles {
+			for _, limit := range []int{1, 5, 20} {
+				t.Run(fmt.Sprintf("%s/%s/%d", pr.name, query, limit), func(t *testing.T) {
+					got, scored, candidates := pr.index.search(context.Background(), queryLower, terms, limit, pr.score)
+					if scored != candidates {
+						t.Errorf("scored %d of %d candidates", scored, candidates)
+					}
+					want := linearKnowledgeSearch(pr.index.lower, pr.index.length, queryLower, terms, limit, pr.score)
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("index search\n%v\nlinear\n%v", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkKnowledgeSearch(b *testing.B) {
+	chunks := syntheticWPChunks(20000, rand.New(rand.NewSource(1)))
+	index := indexWPKnowledge(chunks)
+
+	for _, query := range []string{"plugin", "stall", "REST API caching", "nothing matches this"} {
+		queryLower := strings.ToLower(query)
+		terms := uniqueTerms(tokenize(query))
+		score := func(i int, phrase bool, occ []int) float64 {
+			return scoreKnowledgeChunk(chunks[i], phrase, terms, occ)
+		}
+		b.Run("indexed/"+query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				index.search(context.Background(), queryLower, terms, 5, score)
+			}
+		})
+		// A timeout_ms stops the scoring; gathering candidates still runs to the end
+		b.Run("1ms budget/"+query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				index.search(ctx, queryLower, terms, 5, score)
+				cancel()
+			}
+		})
+		b.Run("linear/"+query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linearKnowledgeSearch(index.lower, index.length, queryLower, terms, 5, score)
+			}
+		})
+	}
+}
@@ -0,0 +1,651 @@
+package profiles
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesProfile exposes read-only cluster inspection over the Kubernetes REST API
+type KubernetesProfile struct{}
+
+func (p *KubernetesProfile) ID() string       { return "kubernetes" }
+func (p *KubernetesProfile) HasBackend() bool { return true }
+
+func (p *KubernetesProfile) Tools() []Tool {
+	return []Tool{
+		{
+			Name:        "k8s_list_pods",
+			Description: "List pods in the namespace with status, restarts and age",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"label_selector": map[string]interface{}{
+						"type":        "string",
+						"description": "Label selector, e.g. app=web,tier!=cache",
+					},
+				},
+			},
+		},
+		{
+			Name:        "k8s_pod_logs",
+			Description: "Get recent logs from a pod",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pod": map[string]interface{}{
+						"type":        "string",
+						"description": "Pod name",
+					},
+					"container": map[string]interface{}{
+						"type":        "string",
+						"description": "Container name (required for multi-container pods)",
+					},
+					"tail": map[string]interface{}{
+						"type":        "number",
+						"description": "Number of lines from the end (default 100, max 1000)",
+					},
+					"previous": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Logs of the previous terminated container instance",
+					},
+				},
+				"required": []string{"pod"},
+			},
+		},
+		{
+			Name:        "k8s_describe",
+			Description: "Show details of a resource (pod, deployment, statefulset, daemonset, service, configmap, ingress, job, cronjob, node)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"kind": map[string]interface{}{
+						"type":        "string",
+						"description": "Resource kind",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Resource name",
+					},
+				},
+				"required": []string{"kind", "name"},
+			},
+		},
+		{
+			Name:        "k8s_events",
+			Description: "List recent events in the namespace, newest first",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"object": map[string]interface{}{
+						"type":        "string",
+						"description": "Only events for the object with this name",
+					},
+					"warnings_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Only Warning events",
+					},
+					"limit": map[string]interface{}{
+						"type":        "number",
+						"description": "Maximum events (default 50)",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (p *KubernetesProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "KUBECONFIG", Required: false, Description: "Path to a kubeconfig file (default: in-cluster service account)"},
+		{Name: "K8S_NAMESPACE", Required: false, Description: "Namespace to operate in (default: service account namespace or 'default')"},
+		{Name: "K8S_CONTEXT", Required: false, Description: "Kubeconfig context (default: current-context)"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 30, max 300)"},
+	}
+}
+
+func (p *KubernetesProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	client, err := newKubeClient(env)
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
+	case "k8s_list_pods":
+		return p.listPods(client, args)
+	case "k8s_pod_logs":
+		return p.podLogs(client, args)
+	case "k8s_describe":
+		return p.describe(client, args)
+	case "k8s_events":
+		return p.events(client, args)
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+type kubeClient struct {
+	server    string
+	token     string
+	namespace string
+	http      *http.Client
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// newKubeClient builds a client from KUBECONFIG, falling back to the
+// in-cluster service account
+func newKubeClient(env map[string]string) (*kubeClient, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	c := &kubeClient{}
+
+	if path := env["KUBECONFIG"]; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, notConfiguredf("cannot read KUBECONFIG: %s", err)
+		}
+		var cfg kubeconfig
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, notConfiguredf("invalid kubeconfig: %s", err)
+		}
+
+		ctxName := env["K8S_CONTEXT"]
+		if ctxName == "" {
+			ctxName = cfg.CurrentContext
+		}
+		found := false
+		var clusterName, userName string
+		for _, ctx := range cfg.Contexts {
+			if ctx.Name == ctxName {
+				clusterName, userName, c.namespace = ctx.Context.Cluster, ctx.Context.User, ctx.Context.Namespace
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, notConfiguredf("context %q not found in kubeconfig", ctxName)
+		}
+
+		for _, cl := range cfg.Clusters {
+			if cl.Name != clusterName {
+				continue
+			}
+			c.server = cl.Cluster.Server
+			tlsConfig.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify
+			ca, err := kubeconfigBytes(cl.Cluster.CertificateAuthorityData, cl.Cluster.CertificateAuthority)
+			if err != nil {
+				return nil, notConfiguredf("cluster CA: %s", err)
+			}
+			if len(ca) > 0 {
+				pool := x509.NewCertPool()
+				if !pool.AppendCertsFromPEM(ca) {
+					return nil, notConfiguredf("cluster CA contains no certificates")
+				}
+				tlsConfig.RootCAs = pool
+			}
+		}
+		if c.server == "" {
+			return nil, notConfiguredf("cluster %q not found in kubeconfig", clusterName)
+		}
+
+		for _, u := range cfg.Users {
+			if u.Name != userName {
+				continue
+			}
+			c.token = u.User.Token
+			if c.token == "" && u.User.TokenFile != "" {
+				tok, err := os.ReadFile(u.User.TokenFile)
+				if err != nil {
+					return nil, notConfiguredf("user token file: %s", err)
+				}
+				c.token = strings.TrimSpace(string(tok))
+			}
+			cert, err := kubeconfigBytes(u.User.ClientCertificateData, u.User.ClientCertificate)
+			if err != nil {
+				return nil, notConfiguredf("client certificate: %s", err)
+			}
+			key, err := kubeconfigBytes(u.User.ClientKeyData, u.User.ClientKey)
+			if err != nil {
+				return nil, notConfiguredf("client key: %s", err)
+			}
+			if len(cert) > 0 && len(key) > 0 {
+				pair, err := tls.X509KeyPair(cert, key)
+				if err != nil {
+					return nil, notConfiguredf("invalid client certificate: %s", err)
+				}
+				tlsConfig.Certificates = []tls.Certificate{pair}
+			}
+		}
+	} else {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, notConfiguredf("KUBECONFIG is not set and not running in a cluster")
+		}
+		if port == "" {
+			port = "443"
+		}
+		c.server = "https://" + host + ":" + port
+		if strings.Contains(host, ":") {
+			c.server = "https://[" + host + "]:" + port
+		}
+
+		tok, err := os.ReadFile(serviceAccountDir + "/token")
+		if err != nil {
+			return nil, notConfiguredf("cannot read service account token: %s", err)
+		}
+		c.token = strings.TrimSpace(string(tok))
+		if ca, err := os.ReadFile(serviceAccountDir + "/ca.crt"); err == nil {
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(ca)
+			tlsConfig.RootCAs = pool
+		}
+		if ns, err := os.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+			c.namespace = strings.TrimSpace(string(ns))
+		}
+	}
+
+	if ns := env["K8S_NAMESPACE"]; ns != "" {
+		c.namespace = ns
+	}
+	if c.namespace == "" {
+		c.namespace = "default"
+	}
+	c.server = strings.TrimRight(c.server, "/")
+	c.http = &http.Client{
+		Timeout:   httpTimeout(env, 30*time.Second),
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	return c, nil
+}
+
+// kubeconfigBytes returns inline base64 data, or the contents of the referenced file
+func kubeconfigBytes(data, path string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if path != "" {
+		return os.ReadFile(path)
+	}
+	return nil, nil
+}
+
+// get performs a GET against the API server; only reads are ever issued
+func (c *kubeClient) get(path string, query url.Values) ([]byte, error) {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %s", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, backendErrorf("kubernetes API error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %s", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		msg := string(data)
+		if json.Unmarshal(data, &status) == nil && status.Message != "" {
+			msg = status.Message
+		}
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, invalidInputf("%s", msg)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return nil, forbiddenf("kubernetes API %d: %s", resp.StatusCode, msg)
+		}
+		return nil, backendErrorf("kubernetes API %d: %s", resp.StatusCode, msg)
+	}
+	return data, nil
+}
+
+type kubeMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+	Labels            map[string]string `json:"labels"`
+}
+
+type kubePod struct {
+	Metadata kubeMeta `json:"metadata"`
+	Spec     struct {
+		NodeName   string `json:"nodeName"`
+		Containers []struct {
+			Name  string `json:"name"`
+			Image string `json:"image"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase             string `json:"phase"`
+		Reason            string `json:"reason"`
+		PodIP             string `json:"podIP"`
+		ContainerStatuses []struct {
+			Name         string `json:"name"`
+			Ready        bool   `json:"ready"`
+			RestartCount int    `json:"restartCount"`
+			State        map[string]struct {
+				Reason string `json:"reason"`
+			} `json:"state"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// podStatus mirrors kubectl's STATUS column: a waiting/terminated reason wins over the phase
+func podStatus(pod kubePod) string {
+	status := pod.Status.Phase
+	if pod.Status.Reason != "" {
+		status = pod.Status.Reason
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		for state, detail := range cs.State {
+			if state != "running" && detail.Reason != "" {
+				return detail.Reason
+			}
+		}
+	}
+	return status
+}
+
+func (p *KubernetesProfile) listPods(c *kubeClient, args map[string]interface{}) (string, error) {
+	query := url.Values{}
+	if sel := getStr(args, "label_selector"); sel != "" {
+		query.Set("labelSelector", sel)
+	}
+
+	data, err := c.get("/api/v1/namespaces/"+url.PathEscape(c.namespace)+"/pods", query)
+	if err != nil {
+		return "", err
+	}
+	var list struct {
+		Items []kubePod `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return "", fmt.Errorf("failed to parse response: %s", err)
+	}
+	if len(list.Items) == 0 {
+		return fmt.Sprintf("No pods found in namespace %s", c.namespace), nil
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("%-45s %-7s %-20s %-9s %-8s %s", "NAME", "READY", "STATUS", "RESTARTS", "AGE", "NODE"))
+	lines = append(lines, strings.Repeat("-", 110))
+	for _, pod := range list.Items {
+		ready, restarts := 0, 0
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Ready {
+				ready++
+			}
+			restarts += cs.RestartCount
+		}
+		name := pod.Metadata.Name
+		if len(name) > 45 {
+			name = name[:42] + "..."
+		}
+		lines = append(lines, fmt.Sprintf("%-45s %-7s %-20s %-9d %-8s %s",
+			name, fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers)), podStatus(pod),
+			restarts, kubeAge(pod.Metadata.CreationTimestamp), pod.Spec.NodeName))
+	}
+
+	return fmt.Sprintf("Pods in %s (%d):\n\n%s", c.namespace, len(list.Items), strings.Join(lines, "\n")), nil
+}
+
+func (p *KubernetesProfile) podLogs(c *kubeClient, args map[string]interface{}) (string, error) {
+	pod := getStr(args, "pod")
+	if pod == "" {
+		return "", invalidInputf("pod is required")
+	}
+
+	tail := int(getFloat(args, "tail"))
+	if tail <= 0 {
+		tail = 100
+	}
+	if tail > 1000 {
+		tail = 1000
+	}
+
+	query := url.Values{}
+	query.Set("tailLines", fmt.Sprintf("%d", tail))
+	query.Set("timestamps", "true")
+	if container := getStr(args, "container"); container != "" {
+		query.Set("container", container)
+	}
+	if previous, _ := args["previous"].(bool); previous {
+		query.Set("previous", "true")
+	}
+
+	data, err := c.get("/api/v1/namespaces/"+url.PathEscape(c.namespace)+"/pods/"+url.PathEscape(pod)+"/log", query)
+	if err != nil {
+		return "", err
+	}
+	logs := strings.TrimRight(string(data), "\n")
+	if logs == "" {
+		return "(no logs)", nil
+	}
+	return logs, nil
+}
+
+// kubeResources maps describe kinds to their API paths
+var kubeResources = map[string]struct {
+	path       string
+	namespaced bool
+}{
+	"pod":         {"/api/v1", true},
+	"service":     {"/api/v1", true},
+	"configmap":   {"/api/v1", true},
+	"node":        {"/api/v1", false},
+	"deployment":  {"/apis/apps/v1", true},
+	"statefulset": {"/apis/apps/v1", true},
+	"daemonset":   {"/apis/apps/v1", true},
+	"replicaset":  {"/apis/apps/v1", true},
+	"job":         {"/apis/batch/v1", true},
+	"cronjob":     {"/apis/batch/v1", true},
+	"ingress":     {"/apis/networking.k8s.io/v1", true},
+}
+
+func (p *KubernetesProfile) describe(c *kubeClient, args map[string]interface{}) (string, error) {
+	kind := strings.ToLower(getStr(args, "kind"))
+	name := getStr(args, "name")
+	if kind == "" || name == "" {
+		return "", invalidInputf("kind and name are required")
+	}
+	// Accept plurals (pods, ingresses) as well as singular kinds
+	if _, ok := kubeResources[kind]; !ok {
+		if trimmed := strings.TrimSuffix(kind, "es"); kubeResources[trimmed].path != "" {
+			kind = trimmed
+		} else {
+			kind = strings.TrimSuffix(kind, "s")
+		}
+	}
+	res, ok := kubeResources[kind]
+	if !ok {
+		kinds := make([]string, 0, len(kubeResources))
+		for k := range kubeResources {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		return "", invalidInputf("unsupported kind %q (supported: %s)", kind, strings.Join(kinds, ", "))
+	}
+
+	plural := kind + "s"
+	if kind == "ingress" {
+		plural = "ingresses"
+	}
+	path := res.path
+	if res.namespaced {
+		path += "/namespaces/" + url.PathEscape(c.namespace)
+	}
+	path += "/" + plural + "/" + url.PathEscape(name)
+
+	data, err := c.get(path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return "", fmt.Errorf("failed to parse response: %s", err)
+	}
+	// managedFields and the last-applied annotation are noise for a describe view
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		delete(meta, "managedFields")
+		if ann, ok := meta["annotations"].(map[string]interface{}); ok {
+			delete(ann, "kubectl.kubernetes.io/last-applied-configuration")
+		}
+	}
+
+	out, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("failed to format resource: %s", err)
+	}
+	return fmt.Sprintf("%s/%s (namespace %s):\n\n%s", kind, name, c.namespace, strings.TrimRight(string(out), "\n")), nil
+}
+
+func (p *KubernetesProfile) events(c *kubeClient, args map[string]interface{}) (string, error) {
+	query := url.Values{}
+	var selectors []string
+	if obj := getStr(args, "object"); obj != "" {
+		selectors = append(selectors, "involvedObject.name="+obj)
+	}
+	if warn, _ := args["warnings_only"].(bool); warn {
+		selectors = append(selectors, "type=Warning")
+	}
+	if len(selectors) > 0 {
+		query.Set("fieldSelector", strings.Join(selectors, ","))
+	}
+
+	limit := int(getFloat(args, "limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	data, err := c.get("/api/v1/namespaces/"+url.PathEscape(c.namespace)+"/events", query)
+	if err != nil {
+		return "", err
+	}
+
+	var list struct {
+		Items []struct {
+			Type           string    `json:"type"`
+			Reason         string    `json:"reason"`
+			Message        string    `json:"message"`
+			Count          int       `json:"count"`
+			LastTimestamp  time.Time `json:"lastTimestamp"`
+			EventTime      time.Time `json:"eventTime"`
+			InvolvedObject struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"involvedObject"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return "", fmt.Errorf("failed to parse response: %s", err)
+	}
+	if len(list.Items) == 0 {
+		return fmt.Sprintf("No events found in namespace %s", c.namespace), nil
+	}
+
+	items := list.Items
+	for i := range items {
+		if items[i].LastTimestamp.IsZero() {
+			items[i].LastTimestamp = items[i].EventTime
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].LastTimestamp.After(items[j].LastTimestamp) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("%-8s %-8s %-22s %-35s %s", "AGE", "TYPE", "REASON", "OBJECT", "MESSAGE"))
+	lines = append(lines, strings.Repeat("-", 120))
+	for _, ev := range items {
+		object := strings.ToLower(ev.InvolvedObject.Kind) + "/" + ev.InvolvedObject.Name
+		if len(object) > 35 {
+			object = object[:32] + "..."
+		}
+		msg := normalizeWhitespace(ev.Message)
+		if ev.Count > 1 {
+			msg = fmt.Sprintf("(x%d) %s", ev.Count, msg)
+		}
+		lines = append(lines, fmt.Sprintf("%-8s %-8s %-22s %-35s %s",
+			kubeAge(ev.LastTimestamp), ev.Type, ev.Reason, object, truncateRunes(msg, 200)))
+	}
+
+	return fmt.Sprintf("Events in %s (%d of %d):\n\n%s", c.namespace, len(items), len(list.Items), strings.Join(lines, "\n")), nil
+}
+
+// kubeAge formats an age the way kubectl does (45s, 12m, 3h, 5d)
+func kubeAge(t time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,34 @@
+package profiles
+
+import (
+	"context"
+	"fmt"
+)
+
+// LogLevels are the MCP log levels (RFC 5424 severities), least severe first
+var LogLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// LogFunc receives log entries a tool emits while it runs
+type LogFunc func(level, message string)
+
+// ContextProfile is optionally implemented by profiles that want a context
+// for their tool calls, e.g. to send log entries to the client with logf
+type ContextProfile interface {
+	CallToolContext(ctx context.Context, name string, args map[string]interface{}, env map[string]string) (string, error)
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context that carries log to the tool being called
+func WithLogger(ctx context.Context, log LogFunc) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// logf sends a log entry to ctx's logger, if it carries one. The gateway's
+// logger writes it to the gateway log and, at or above the level the client
+// asked for, to the client.
+func logf(ctx context.Context, level, format string, args ...interface{}) {
+	if log, ok := ctx.Value(loggerKey{}).(LogFunc); ok && log != nil {
+		log(level, fmt.Sprintf(format, args...))
+	}
+}
@@ -12,10 +12,15 @@ type MathProfile struct{}
 
 func (p *MathProfile) ID() string { return "math" }
 
+// Aliases keeps calculate, the old name of evaluate_expression, working
+func (p *MathProfile) Aliases() map[string]string {
+	return map[string]string{"calculate": "evaluate_expression"}
+}
+
 func (p *MathProfile) Tools() []Tool {
 	return []Tool{
 		{
-			Name:        "calculate",
+			Name:        "evaluate_expression",
 			Description: "Evaluate a mathematical expression. Supports: +, -, *, /, %, ^, sqrt(), abs(), ceil(), floor(), round(), log(), log2(), log10(), sin(), cos(), tan(), pi, e",
 			InputSchema: map[string]interface{}{
 				"type": "object",
@@ -85,7 +90,7 @@ func (p *MathProfile) Tools() []Tool {
 
 func (p *MathProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
-	case "calculate":
+	case "evaluate_expression":
 		return p.calculate(args)
 	case "statistics":
 		return p.statistics(args)
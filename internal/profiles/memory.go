@@ -44,10 +44,40 @@ func (p *MemoryProfile) Tools() []Tool {
 						"type":        "string",
 						"description": "The key to retrieve",
 					},
+					"include_version": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Prefix the value with its version, for use with compare_and_set",
+					},
 				},
 				"required": []string{"key"},
 			},
 		},
+		{
+			Name:        "compare_and_set",
+			Description: "Store a value only if the key still holds the expected value (or version), so concurrent agents don't overwrite each other. Leave expected empty to create a key only if it doesn't exist yet.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"key": map[string]interface{}{
+						"type":        "string",
+						"description": "The key to set",
+					},
+					"value": map[string]interface{}{
+						"type":        "string",
+						"description": "The new value",
+					},
+					"expected": map[string]interface{}{
+						"type":        "string",
+						"description": "The value the key must currently hold; empty means the key must not exist",
+					},
+					"expected_version": map[string]interface{}{
+						"type":        "integer",
+						"description": "Compare against this version (from store or retrieve) instead of the value",
+					},
+				},
+				"required": []string{"key", "value"},
+			},
+		},
 		{
 			Name:        "list_keys",
 			Description: "List all stored keys",
@@ -79,7 +109,7 @@ func (p *MemoryProfile) Tools() []Tool {
 			Name:        "clear",
 			Description: "Clear all stored data",
 			InputSchema: map[string]interface{}{
-				"type": "object",
+				"type":       "object",
 				"properties": map[string]interface{}{},
 			},
 		},
@@ -96,6 +126,12 @@ type memStore struct {
 	mu   sync.RWMutex
 	data map[string]string
 	path string // persistence path (empty = in-memory only)
+
+	// versions holds the store revision at each key's last write. Revisions
+	// only grow, so a deleted and recreated key never reuses a version. They
+	// are not persisted; keys loaded from disk start out at revision 1.
+	versions map[string]int64
+	rev      int64
 }
 
 func getMemStore(env map[string]string) *memStore {
@@ -113,7 +149,7 @@ func getMemStore(env map[string]string) *memStore {
 		return s
 	}
 
-	s := &memStore{data: make(map[string]string), path: path}
+	s := &memStore{data: make(map[string]string), path: path, versions: map[string]int64{}, rev: 1}
 
 	// Load from disk if persistence path exists
 	if path != "" {
@@ -121,11 +157,23 @@ func getMemStore(env map[string]string) *memStore {
 			json.Unmarshal(data, &s.data)
 		}
 	}
+	for k := range s.data {
+		s.versions[k] = s.rev
+	}
 
 	memStores[key] = s
 	return s
 }
 
+// set writes key and returns its new version; the caller holds s.mu
+func (s *memStore) set(key, value string) int64 {
+	s.rev++
+	s.data[key] = value
+	s.versions[key] = s.rev
+	s.persist()
+	return s.rev
+}
+
 func (s *memStore) persist() {
 	if s.path == "" {
 		return
@@ -137,6 +185,17 @@ func (s *memStore) persist() {
 	os.WriteFile(s.path, data, 0644)
 }
 
+func (p *MemoryProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "PERSIST_PATH", Required: false, Description: "File to persist the store to (in-memory if unset)"},
+		{Name: "MAX_ENTRIES", Required: false, Description: "Maximum number of stored keys (default 10000)"},
+	}
+}
+
+func (p *MemoryProfile) DryRunTools() []string {
+	return []string{"store", "compare_and_set", "delete", "clear"}
+}
+
 func (p *MemoryProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	store := getMemStore(env)
 	maxEntries := 10000
@@ -155,12 +214,18 @@ func (p *MemoryProfile) CallTool(name string, args map[string]interface{}, env m
 		}
 		store.mu.Lock()
 		defer store.mu.Unlock()
-		if _, exists := store.data[key]; !exists && len(store.data) >= maxEntries {
+		_, exists := store.data[key]
+		if !exists && len(store.data) >= maxEntries {
 			return "", fmt.Errorf("maximum entries (%d) reached", maxEntries)
 		}
-		store.data[key] = value
-		store.persist()
-		return fmt.Sprintf("Stored '%s' (%d bytes)", key, len(value)), nil
+		if IsDryRun(args) {
+			if exists {
+				return dryRunf("would overwrite '%s' with %d bytes", key, len(value)), nil
+			}
+			return dryRunf("would store '%s' (%d bytes)", key, len(value)), nil
+		}
+		version := store.set(key, value)
+		return fmt.Sprintf("Stored '%s' (%d bytes, version %d)", key, len(value), version), nil
 
 	case "retrieve":
 		key := getStr(args, "key")
@@ -173,8 +238,47 @@ func (p *MemoryProfile) CallTool(name string, args map[string]interface{}, env m
 		if !ok {
 			return fmt.Sprintf("Key '%s' not found", key), nil
 		}
+		if withVersion, _ := args["include_version"].(bool); withVersion {
+			return fmt.Sprintf("Version: %d\n\n%s", store.versions[key], value), nil
+		}
 		return value, nil
 
+	case "compare_and_set":
+		key := getStr(args, "key")
+		value := getStr(args, "value")
+		if key == "" || value == "" {
+			return "", fmt.Errorf("key and value are required")
+		}
+		expected := getStr(args, "expected")
+		_, byVersion := args["expected_version"]
+		expectedVersion := int64(getFloat(args, "expected_version"))
+
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		current, exists := store.data[key]
+		version := store.versions[key]
+		switch {
+		case byVersion && (!exists || version != expectedVersion):
+			if !exists {
+				return fmt.Sprintf("Conflict: '%s' does not exist (expected version %d); not set", key, expectedVersion), nil
+			}
+			return fmt.Sprintf("Conflict: '%s' is at version %d, not %d; not set", key, version, expectedVersion), nil
+		case !byVersion && expected == "" && exists:
+			return fmt.Sprintf("Conflict: '%s' already exists (version %d); not set", key, version), nil
+		case !byVersion && expected != "" && !exists:
+			return fmt.Sprintf("Conflict: '%s' does not exist; not set", key), nil
+		case !byVersion && expected != "" && current != expected:
+			return fmt.Sprintf("Conflict: '%s' holds a different value (version %d); not set", key, version), nil
+		}
+		if !exists && len(store.data) >= maxEntries {
+			return "", fmt.Errorf("maximum entries (%d) reached", maxEntries)
+		}
+		if IsDryRun(args) {
+			return dryRunf("would set '%s' (%d bytes); the expectation currently holds", key, len(value)), nil
+		}
+		version = store.set(key, value)
+		return fmt.Sprintf("OK: set '%s' (%d bytes, version %d)", key, len(value), version), nil
+
 	case "list_keys":
 		prefix := getStr(args, "prefix")
 		store.mu.RLock()
@@ -201,7 +305,11 @@ func (p *MemoryProfile) CallTool(name string, args map[string]interface{}, env m
 		if _, ok := store.data[key]; !ok {
 			return fmt.Sprintf("Key '%s' not found", key), nil
 		}
+		if IsDryRun(args) {
+			return dryRunf("would delete '%s'", key), nil
+		}
 		delete(store.data, key)
+		delete(store.versions, key)
 		store.persist()
 		return fmt.Sprintf("Deleted '%s'", key), nil
 
@@ -209,7 +317,11 @@ func (p *MemoryProfile) CallTool(name string, args map[string]interface{}, env m
 		store.mu.Lock()
 		defer store.mu.Unlock()
 		count := len(store.data)
+		if IsDryRun(args) {
+			return dryRunf("would clear %d entries", count), nil
+		}
 		store.data = make(map[string]string)
+		store.versions = map[string]int64{}
 		store.persist()
 		return fmt.Sprintf("Cleared %d entries", count), nil
 
@@ -0,0 +1,97 @@
+package profiles
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMemoryCompareAndSet(t *testing.T) {
+	p := &MemoryProfile{}
+	env := map[string]string{"PERSIST_PATH": filepath.Join(t.TempDir(), "memory.json")}
+	call := func(name string, args map[string]interface{}) string {
+		t.Helper()
+		out, err := p.CallTool(name, args, env)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return out
+	}
+
+	steps := []struct {
+		name string
+		tool string
+		args map[string]interface{}
+		want string
+	}{
+		{"create if absent", "compare_and_set", map[string]interface{}{"key": "lock", "value": "a"}, "OK: set 'lock' (1 bytes, version 2)"},
+		{"create again", "compare_and_set", map[string]interface{}{"key": "lock", "value": "b"}, "Conflict: 'lock' already exists (version 2)"},
+		{"wrong value", "compare_and_set", map[string]interface{}{"key": "lock", "value": "b", "expected": "x"}, "Conflict: 'lock' holds a different value (version 2)"},
+		{"matching value", "compare_and_set", map[string]interface{}{"key": "lock", "value": "b", "expected": "a"}, "OK: set 'lock' (1 bytes, version 3)"},
+		{"stale version", "compare_and_set", map[string]interface{}{"key": "lock", "value": "c", "expected_version": 2.0}, "Conflict: 'lock' is at version 3, not 2"},
+		{"current version", "compare_and_set", map[string]interface{}{"key": "lock", "value": "c", "expected_version": 3.0}, "OK: set 'lock' (1 bytes, version 4)"},
+		{"retrieve with version", "retrieve", map[string]interface{}{"key": "lock", "include_version": true}, "Version: 4\n\nc"},
+		{"store bumps the version", "store", map[string]interface{}{"key": "lock", "value": "d"}, "Stored 'lock' (1 bytes, version 5)"},
+		{"dry run", "compare_and_set", map[string]interface{}{"key": "lock", "value": "e", "expected": "d", "dry_run": true}, "would set 'lock'"},
+		{"dry run didn't write", "retrieve", map[string]interface{}{"key": "lock"}, "d"},
+		{"delete", "delete", map[string]interface{}{"key": "lock"}, "Deleted 'lock'"},
+		{"old version after delete", "compare_and_set", map[string]interface{}{"key": "lock", "value": "f", "expected_version": 5.0}, "Conflict: 'lock' does not exist (expected version 5)"},
+		{"absent by value", "compare_and_set", map[string]interface{}{"key": "lock", "value": "f", "expected": "d"}, "Conflict: 'lock' does not exist"},
+		{"recreated gets a new version", "compare_and_set", map[string]interface{}{"key": "lock", "value": "f"}, "OK: set 'lock' (1 bytes, version 6)"},
+	}
+	for _, st := range steps {
+		if got := call(st.tool, st.args); !strings.Contains(got, st.want) {
+			t.Errorf("%s: got %q, want %q", st.name, got, st.want)
+		}
+	}
+}
+
+func TestMemoryConcurrentCompareAndSet(t *testing.T) {
+	p := &MemoryProfile{}
+	env := map[string]string{"PERSIST_PATH": filepath.Join(t.TempDir(), "memory.json")}
+	if _, err := p.CallTool("store", map[string]interface{}{"key": "counter", "value": "0"}, env); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each worker increments the counter with a read, then a versioned
+	// compare_and_set, retrying on conflict; none may be lost
+	const workers, increments = 8, 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; {
+				out, err := p.CallTool("retrieve", map[string]interface{}{"key": "counter", "include_version": true}, env)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var version, n int
+				if _, err := fmt.Sscanf(out, "Version: %d\n\n%d", &version, &n); err != nil {
+					t.Errorf("parsing %q: %v", out, err)
+					return
+				}
+				out, err = p.CallTool("compare_and_set", map[string]interface{}{
+					"key": "counter", "value": strconv.Itoa(n + 1), "expected_version": float64(version),
+				}, env)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if strings.HasPrefix(out, "OK:") {
+					i++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	out, _ := p.CallTool("retrieve", map[string]interface{}{"key": "counter"}, env)
+	if out != strconv.Itoa(workers*increments) {
+		t.Errorf("counter = %s, want %d", out, workers*increments)
+	}
+}
@@ -0,0 +1,231 @@
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MOCK_MODE lets a client be wired up and tested against a connection
+// without live infrastructure: tools of profiles that talk to a backend or
+// other hosts (see UsesNetwork) return canned responses instead. Profiles
+// that work locally (time, math, memory, …) keep running for real.
+// MOCK_MODE=record runs every call for real and saves each complete result
+// to MOCK_RECORD_PATH, which MOCK_MODE=true then replays.
+
+// mockLabel starts every mocked result so it can't be mistaken for real output
+const mockLabel = "[mock] canned response, no backend was contacted\n\n"
+
+// mockFixtures are the built-in responses, keyed by tool name. {{arg}} is
+// replaced by the call's argument and {{arg|default}} falls back to default
+// when it's missing. MOCK_FIXTURES entries use the same syntax and take
+// precedence, then recorded responses, which are replayed as they are. Addresses are from the documentation ranges (RFC 5737/3849).
+var mockFixtures = map[string]string{
+	"fetch_url":  "Status: 200 OK\nContent-Type: application/json\n\n{\"url\": \"{{url}}\", \"message\": \"mock response\"}",
+	"fetch_html": "Title: Example Domain\nURL: {{url}}\n\nThis domain is for use in illustrative examples in documents.",
+
+	"send_webhook": "Webhook sent!\nURL: {{url}}\nMethod: {{method|POST}}\nStatus: 200 OK\nResponse: {\"ok\": true}",
+	"send_slack":   "Message sent to Slack",
+	"send_discord": "Message sent to Discord",
+
+	"query":           "id | name  | email\n---+-------+------------------\n1  | alice | alice@example.com\n2  | bob   | bob@example.com\n\n(2 rows)",
+	"list_tables":     "Tables:\n  public.orders\n  public.users",
+	"describe_table":  "Table {{table}}:\n  id          integer    NOT NULL\n  name        text\n  created_at  timestamp  NOT NULL",
+	"explain_query":   "Seq Scan on users  (cost=0.00..1.02 rows=2 width=68)",
+	"table_stats":     "Table {{table}}:\n  Rows: 2\n  Size: 16 kB",
+	"aggregate":       "Column: public.{{table}}.{{column}} (text)\nRows: 3\nNon-null: 3 (0 null)\nDistinct: 2\n\nTop 2 values:\n  shipped                        2 (66.7%)\n  pending                        1 (33.3%)",
+	"test_connection": "Connection OK\nServer: PostgreSQL 16.0 (mock)",
+	"db_stats":        "Database: mock\nSize: 8 MB\nConnections: 1",
+
+	"redis_get":      "mock-value",
+	"redis_set":      "OK",
+	"redis_del":      "Deleted 1 key(s)",
+	"redis_keys":     "mock:key:1\nmock:key:2",
+	"redis_info":     "redis_version:7.2.0\nconnected_clients:1\nused_memory_human:1.00M",
+	"redis_ttl":      "TTL of {{key}}: no expiry",
+	"redis_hscan":    "Hash '{{key}}' entries matching '{{pattern|*}}' (2):\nname: alice\nemail: alice@example.com",
+	"redis_sscan":    "Set '{{key}}' entries matching '{{pattern|*}}' (2):\nmock:member:1\nmock:member:2",
+	"redis_zscan":    "Sorted set '{{key}}' entries matching '{{pattern|*}}' (2):\nalice (score 10)\nbob (score 7)",
+	"redis_pipeline": "1) OK",
+
+	"docker_list":       "CONTAINER ID  NAME  IMAGE         STATUS\n0123456789ab  web   nginx:latest  Up 2 hours",
+	"docker_compose_ps": "NAME  SERVICE  STATUS\nweb   web      Up 2 hours",
+	"docker_logs":       "[{{container}}] mock log line 1\n[{{container}}] mock log line 2",
+	"docker_inspect":    "{\"Name\": \"/{{container}}\", \"State\": {\"Status\": \"running\"}}",
+	"docker_stats":      "{{container}}: CPU 0.50%, memory 12MiB / 512MiB",
+	"docker_restart":    "Container {{container}} restarted",
+	"docker_exec":       "(no output)",
+
+	"send_email":      "Email sent to {{to}}",
+	"send_html_email": "Email sent to {{to}}",
+	"validate_email":  "{{email}}: valid format, domain has MX records",
+
+	"dns_lookup":     "A records for {{domain}}:\n  192.0.2.10",
+	"reverse_lookup": "{{ip}} → host.example.com",
+	"check_port":     "Port {{port}} on {{host}}: OPEN (response time: 12ms)",
+	"traceroute":     "traceroute to {{host}} (192.0.2.10), 20 hops max\n\n 1  198.51.100.1     1.2ms\n 2  203.0.113.1      8.4ms\n 3  192.0.2.10       15.1ms",
+	"resolve_host":   "Host {{host}} resolves to:\n  192.0.2.10\n  2001:db8::10",
+	"whois":          "Domain: {{domain}}\nRegistrar: Example Registrar, Inc.\nCreated: 2000-01-01",
+	"dnssec_check":   "{{domain}}: not signed (no DS record in parent zone)",
+}
+
+var mockPlaceholderRe = regexp.MustCompile(`\{\{\s*(\w+)\s*(?:\|([^}]*))?\}\}`)
+
+// MockMode reports whether MOCK_MODE is enabled for the connection
+func MockMode(env map[string]string) bool {
+	switch strings.ToLower(env["MOCK_MODE"]) {
+	case "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+// mockedOwner returns the profile serving tool when its calls are mocked:
+// it talks to a backend or other hosts and knows the tool, so unknown tools
+// still get the profile's own error
+func mockedOwner(p Profile, tool string, env map[string]string) (Profile, bool) {
+	owner := p
+	if c, isComposite := p.(*CompositeProfile); isComposite {
+		var err error
+		if owner, err = c.member(tool, env); err != nil {
+			return nil, false
+		}
+	}
+	if !UsesNetwork(owner) {
+		return nil, false
+	}
+	tools, err := ToolsFor(owner, env)
+	if err != nil {
+		return nil, false
+	}
+	for _, t := range tools {
+		if t.Name == tool {
+			return owner, true
+		}
+	}
+	return nil, false
+}
+
+// MockRecording reports whether MOCK_MODE=record is set for the connection
+func MockRecording(env map[string]string) bool {
+	return strings.EqualFold(env["MOCK_MODE"], "record")
+}
+
+// MockCall returns the canned response for a call when MOCK_MODE is on and
+// the profile serving tool talks to a backend or other hosts. ok is false
+// when the call should run for real.
+func MockCall(p Profile, tool string, args map[string]interface{}, env map[string]string) (result string, ok bool, err error) {
+	if !MockMode(env) {
+		return "", false, nil
+	}
+	owner, mocked := mockedOwner(p, tool, env)
+	if !mocked {
+		return "", false, nil
+	}
+
+	fixture, found := mockFixtures[tool]
+	if raw := env["MOCK_FIXTURES"]; raw != "" {
+		var custom map[string]string
+		if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+			return "", true, notConfiguredf("MOCK_FIXTURES must be a JSON object of tool name to response: %s", err)
+		}
+		if f, ok := custom[tool]; ok {
+			return mockLabel + expandMockFixture(f, args), true, nil
+		}
+	}
+	if path := env["MOCK_RECORD_PATH"]; path != "" {
+		recorded, err := readMockRecording(path)
+		if err != nil {
+			return "", true, err
+		}
+		if r, ok := recorded[tool]; ok {
+			return mockLabel + r, true, nil
+		}
+	}
+	if !found {
+		argsJSON, _ := json.Marshal(args)
+		fixture = fmt.Sprintf("%s/%s called with %s", owner.ID(), tool, argsJSON)
+		return mockLabel + fixture, true, nil
+	}
+	return mockLabel + expandMockFixture(fixture, args), true, nil
+}
+
+func expandMockFixture(fixture string, args map[string]interface{}) string {
+	return mockPlaceholderRe.ReplaceAllStringFunc(fixture, func(ph string) string {
+		m := mockPlaceholderRe.FindStringSubmatch(ph)
+		if v, ok := args[m[1]]; ok && v != nil {
+			return formValue(v)
+		}
+		return m[2]
+	})
+}
+
+// mockRecordMu serializes updates to recording files, which connections
+// recording to the same path would otherwise overwrite
+var mockRecordMu sync.Mutex
+
+// RecordMock saves result as the response for tool in MOCK_RECORD_PATH when
+// MOCK_MODE=record and the profile serving tool is one MOCK_MODE stands in
+// for. The file holds a JSON object of tool name to response, like
+// MOCK_FIXTURES; a later result for the same tool replaces the earlier one.
+func RecordMock(p Profile, tool, result string, env map[string]string) error {
+	if !MockRecording(env) {
+		return nil
+	}
+	path := env["MOCK_RECORD_PATH"]
+	if path == "" {
+		return notConfiguredf("MOCK_MODE=record needs MOCK_RECORD_PATH")
+	}
+	if _, mocked := mockedOwner(p, tool, env); !mocked {
+		return nil
+	}
+
+	mockRecordMu.Lock()
+	defer mockRecordMu.Unlock()
+	recorded, err := readMockRecording(path)
+	if err != nil {
+		return err
+	}
+	if recorded == nil {
+		recorded = map[string]string{}
+	}
+	recorded[tool] = result
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a reader never sees half a recording
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mock-record-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readMockRecording loads a recording file; a missing file has no responses
+func readMockRecording(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, notConfiguredf("cannot read MOCK_RECORD_PATH: %s", err)
+	}
+	var recorded map[string]string
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, notConfiguredf("MOCK_RECORD_PATH must hold a JSON object of tool name to response: %s", err)
+	}
+	return recorded, nil
+}
@@ -0,0 +1,133 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMockCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   string
+		tool   string
+		args   map[string]interface{}
+		env    map[string]string
+		want   string // "" when the call runs for real
+		errMsg string
+	}{
+		{name: "off", spec: "dns", tool: "dns_lookup", args: map[string]interface{}{"domain": "example.com"}},
+		{name: "fixture", spec: "dns", tool: "dns_lookup", args: map[string]interface{}{"domain": "example.com"},
+			env: map[string]string{"MOCK_MODE": "true"}, want: "A records for example.com:\n  192.0.2.10"},
+		{name: "placeholder default", spec: "webhook", tool: "send_webhook", args: map[string]interface{}{"url": "https://example.com/hook"},
+			env: map[string]string{"MOCK_MODE": "true"}, want: "Method: POST"},
+		{name: "healthcheck", spec: "healthcheck", tool: "ping_url", args: map[string]interface{}{"url": "https://example.com"},
+			env: map[string]string{"MOCK_MODE": "true"}, want: `healthcheck/ping_url called with {"url":"https://example.com"}`},
+		{name: "s3", spec: "s3", tool: "list_objects", env: map[string]string{"MOCK_MODE": "true"}, want: "s3/list_objects called with"},
+		{name: "mongodb", spec: "mongodb", tool: "mongo_find", env: map[string]string{"MOCK_MODE": "true"}, want: "mongodb/mongo_find called with"},
+		{name: "kubernetes", spec: "kubernetes", tool: "k8s_list_pods", env: map[string]string{"MOCK_MODE": "true"}, want: "kubernetes/k8s_list_pods called with"},
+		{name: "graphql", spec: "graphql", tool: "graphql_query", env: map[string]string{"MOCK_MODE": "true"}, want: "graphql/graphql_query called with"},
+		{name: "clickhouse", spec: "clickhouse", tool: "query", env: map[string]string{"MOCK_MODE": "true"}, want: "id | name"},
+		{name: "composite member", spec: "math,redis", tool: "redis_get", env: map[string]string{"MOCK_MODE": "true"}, want: "mock-value"},
+		{name: "local member", spec: "math,redis", tool: "evaluate_expression", env: map[string]string{"MOCK_MODE": "true"}},
+		{name: "local profile", spec: "transform", tool: "base64_encode", env: map[string]string{"MOCK_MODE": "true"}},
+		{name: "unknown tool", spec: "dns", tool: "nope", env: map[string]string{"MOCK_MODE": "true"}},
+		{name: "custom fixture", spec: "redis", tool: "redis_get", args: map[string]interface{}{"key": "k"},
+			env: map[string]string{"MOCK_MODE": "true", "MOCK_FIXTURES": `{"redis_get": "value of {{key}}"}`}, want: "value of k"},
+		{name: "bad fixtures", spec: "redis", tool: "redis_get",
+			env: map[string]string{"MOCK_MODE": "true", "MOCK_FIXTURES": `[]`}, errMsg: "MOCK_FIXTURES must be a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Resolve(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, ok, err := MockCall(p, tt.tool, tt.args, tt.env)
+			if tt.errMsg != "" {
+				if !ok || err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("MockCall = %v, %v, want error containing %q", ok, err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("MockCall mocked %s/%s: %q", tt.spec, tt.tool, result)
+				}
+				return
+			}
+			if !ok || !strings.HasPrefix(result, mockLabel) || !strings.Contains(result, tt.want) {
+				t.Errorf("MockCall = %q, %v, want labeled result containing %q", result, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorded.json")
+	p, err := Resolve("math,redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := map[string]string{"MOCK_MODE": "record", "MOCK_RECORD_PATH": path}
+
+	if _, ok, _ := MockCall(p, "redis_get", nil, record); ok {
+		t.Fatal("MOCK_MODE=record mocked a call instead of running it")
+	}
+	for _, r := range []struct{ tool, result string }{
+		{"redis_get", "first"},
+		{"redis_keys", "user:1\nuser:{{id}}"},
+		{"redis_get", "second"},
+		{"evaluate_expression", "4"}, // local, never recorded
+	} {
+		if err := RecordMock(p, r.tool, r.result, record); err != nil {
+			t.Fatalf("RecordMock(%s): %v", r.tool, err)
+		}
+	}
+	if err := RecordMock(p, "redis_get", "x", map[string]string{"MOCK_MODE": "record"}); err == nil {
+		t.Error("RecordMock without MOCK_RECORD_PATH succeeded")
+	}
+	if err := RecordMock(p, "redis_get", "x", map[string]string{"MOCK_MODE": "true", "MOCK_RECORD_PATH": path}); err != nil {
+		t.Errorf("RecordMock with MOCK_MODE=true: %v", err)
+	}
+
+	replay := map[string]string{"MOCK_MODE": "true", "MOCK_RECORD_PATH": path}
+	tests := []struct {
+		tool string
+		env  map[string]string
+		want string
+	}{
+		{"redis_get", replay, "second"},
+		{"redis_keys", replay, "user:1\nuser:{{id}}"}, // recorded output isn't a template
+		{"redis_info", replay, mockFixtures["redis_info"]},
+		{"redis_get", map[string]string{"MOCK_MODE": "true", "MOCK_RECORD_PATH": path, "MOCK_FIXTURES": `{"redis_get": "custom"}`}, "custom"},
+		{"redis_get", map[string]string{"MOCK_MODE": "true", "MOCK_RECORD_PATH": filepath.Join(t.TempDir(), "missing.json")}, mockFixtures["redis_get"]},
+	}
+	for _, tt := range tests {
+		result, ok, err := MockCall(p, tt.tool, map[string]interface{}{"id": "7"}, tt.env)
+		if err != nil || !ok || result != mockLabel+tt.want {
+			t.Errorf("MockCall(%s) = %q, %v, %v, want %q", tt.tool, result, ok, err, mockLabel+tt.want)
+		}
+	}
+	if _, _, err := MockCall(p, "evaluate_expression", nil, replay); err != nil {
+		t.Errorf("MockCall(evaluate_expression): %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := MockCall(p, "redis_get", nil, replay); !ok || err == nil {
+		t.Errorf("MockCall with a corrupt recording = %v, %v, want an error", ok, err)
+	}
+	if err := RecordMock(p, "redis_get", "x", record); err == nil {
+		t.Error("RecordMock over a corrupt recording succeeded")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("recording directory has %d entries, want only the recording", len(entries))
+	}
+}
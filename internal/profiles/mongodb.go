@@ -0,0 +1,707 @@
+package profiles
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MongoDBProfile runs read-only queries over the MongoDB wire protocol (OP_MSG)
+type MongoDBProfile struct{}
+
+func (p *MongoDBProfile) ID() string       { return "mongodb" }
+func (p *MongoDBProfile) HasBackend() bool { return true }
+
+func (p *MongoDBProfile) Tools() []Tool {
+	return []Tool{
+		{
+			Name:        "mongo_find",
+			Description: "Find documents in a collection. filter/projection/sort are JSON objects (Extended JSON $oid/$date supported); pass sort as a JSON string to keep multi-key order.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{"type": "string", "description": "Collection name"},
+					"filter":     map[string]interface{}{"type": []string{"object", "string"}, "description": "Query filter, e.g. {\"status\": \"active\"}"},
+					"projection": map[string]interface{}{"type": []string{"object", "string"}, "description": "Fields to include/exclude"},
+					"sort":       map[string]interface{}{"type": []string{"object", "string"}, "description": "Sort spec, e.g. {\"createdAt\": -1}"},
+					"limit":      map[string]interface{}{"type": "number", "description": "Maximum documents (capped by MAX_ROWS)"},
+					"skip":       map[string]interface{}{"type": "number", "description": "Documents to skip"},
+				},
+				"required": []string{"collection"},
+			},
+		},
+		{
+			Name:        "mongo_count",
+			Description: "Count documents in a collection matching a filter",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{"type": "string", "description": "Collection name"},
+					"filter":     map[string]interface{}{"type": []string{"object", "string"}, "description": "Query filter"},
+				},
+				"required": []string{"collection"},
+			},
+		},
+		{
+			Name:        "list_collections",
+			Description: "List collections and views in the database",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "collection_stats",
+			Description: "Show document count, sizes and indexes of a collection",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{"type": "string", "description": "Collection name"},
+				},
+				"required": []string{"collection"},
+			},
+		},
+	}
+}
+
+func (p *MongoDBProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "MONGO_URI", Required: true, Description: "Connection string (mongodb:// or mongodb+srv://)"},
+		{Name: "MONGO_DB", Required: false, Description: "Database name (default: database in MONGO_URI)"},
+		{Name: "MAX_ROWS", Required: false, Description: "Maximum documents returned per query (default 100, max 1000)"},
+	}
+}
+
+func (p *MongoDBProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	switch name {
+	case "mongo_find":
+		return p.find(args, env)
+	case "mongo_count":
+		return p.count(args, env)
+	case "list_collections":
+		return p.listCollections(env)
+	case "collection_stats":
+		return p.collectionStats(args, env)
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+func (p *MongoDBProfile) find(args map[string]interface{}, env map[string]string) (string, error) {
+	coll, err := mongoCollection(args)
+	if err != nil {
+		return "", err
+	}
+	filter, err := mongoDocArg(args, "filter")
+	if err != nil {
+		return "", err
+	}
+	projection, err := mongoDocArg(args, "projection")
+	if err != nil {
+		return "", err
+	}
+	sortSpec, err := mongoDocArg(args, "sort")
+	if err != nil {
+		return "", err
+	}
+
+	maxRows := 100
+	if mr := env["MAX_ROWS"]; mr != "" {
+		if n, err := strconv.Atoi(mr); err == nil && n > 0 {
+			maxRows = n
+		}
+	}
+	if maxRows > 1000 {
+		maxRows = 1000
+	}
+	limit := int(getFloat(args, "limit"))
+	if limit <= 0 || limit > maxRows {
+		limit = maxRows
+	}
+
+	cmd := bsonDoc{{"find", coll}, {"filter", filter}, {"limit", int64(limit)}, {"batchSize", int64(limit)}, {"singleBatch", true}}
+	if len(projection) > 0 {
+		cmd = append(cmd, bsonElem{"projection", projection})
+	}
+	if len(sortSpec) > 0 {
+		cmd = append(cmd, bsonElem{"sort", sortSpec})
+	}
+	if skip := int64(getFloat(args, "skip")); skip > 0 {
+		cmd = append(cmd, bsonElem{"skip", skip})
+	}
+
+	conn, err := dialMongo(env)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	reply, err := conn.command(cmd)
+	if err != nil {
+		return "", err
+	}
+	docs := mongoFirstBatch(reply)
+	if len(docs) == 0 {
+		return fmt.Sprintf("No documents matched in %s", coll), nil
+	}
+
+	output, _ := json.MarshalIndent(docs, "", "  ")
+	return fmt.Sprintf("Documents: %d (limit %d)\n\n%s", len(docs), limit, string(output)), nil
+}
+
+func (p *MongoDBProfile) count(args map[string]interface{}, env map[string]string) (string, error) {
+	coll, err := mongoCollection(args)
+	if err != nil {
+		return "", err
+	}
+	filter, err := mongoDocArg(args, "filter")
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := dialMongo(env)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	reply, err := conn.command(bsonDoc{{"count", coll}, {"query", filter}})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s: %d documents", coll, mongoInt(reply.get("n"))), nil
+}
+
+func (p *MongoDBProfile) listCollections(env map[string]string) (string, error) {
+	conn, err := dialMongo(env)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	reply, err := conn.command(bsonDoc{{"listCollections", int32(1)}, {"authorizedCollections", true}, {"nameOnly", true}})
+	if err != nil {
+		return "", err
+	}
+	colls := mongoFirstBatch(reply)
+	if len(colls) == 0 {
+		return fmt.Sprintf("No collections found in '%s'", conn.db), nil
+	}
+
+	var lines []string
+	for _, c := range colls {
+		lines = append(lines, fmt.Sprintf("  %v (%v)", c.get("name"), c.get("type")))
+	}
+	return fmt.Sprintf("Collections in '%s' (%d):\n%s", conn.db, len(colls), strings.Join(lines, "\n")), nil
+}
+
+func (p *MongoDBProfile) collectionStats(args map[string]interface{}, env map[string]string) (string, error) {
+	coll, err := mongoCollection(args)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := dialMongo(env)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	stats, err := conn.command(bsonDoc{{"collStats", coll}})
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Collection: %s.%s\n", conn.db, coll))
+	lines = append(lines, fmt.Sprintf("%-20s %d", "Documents", mongoInt(stats.get("count"))))
+	lines = append(lines, fmt.Sprintf("%-20s %s", "Data size", humanBytes(float64(mongoInt(stats.get("size"))))))
+	lines = append(lines, fmt.Sprintf("%-20s %s", "Avg document", humanBytes(float64(mongoInt(stats.get("avgObjSize"))))))
+	lines = append(lines, fmt.Sprintf("%-20s %s", "Storage size", humanBytes(float64(mongoInt(stats.get("storageSize"))))))
+	lines = append(lines, fmt.Sprintf("%-20s %s", "Total index size", humanBytes(float64(mongoInt(stats.get("totalIndexSize"))))))
+	if capped, _ := stats.get("capped").(bool); capped {
+		lines = append(lines, fmt.Sprintf("%-20s yes", "Capped"))
+	}
+
+	idxReply, err := conn.command(bsonDoc{{"listIndexes", coll}})
+	if err == nil {
+		sizes, _ := stats.get("indexSizes").(bsonDoc)
+		lines = append(lines, "")
+		lines = append(lines, "Indexes:")
+		for _, idx := range mongoFirstBatch(idxReply) {
+			name := fmt.Sprintf("%v", idx.get("name"))
+			key, _ := json.Marshal(idx.get("key"))
+			extra := ""
+			if unique, _ := idx.get("unique").(bool); unique {
+				extra = " unique"
+			}
+			lines = append(lines, fmt.Sprintf("  %s: %s%s (%s)", name, key, extra, humanBytes(float64(mongoInt(sizes.get(name))))))
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func mongoCollection(args map[string]interface{}) (string, error) {
+	coll := getStr(args, "collection")
+	if coll == "" {
+		return "", invalidInputf("collection is required")
+	}
+	if strings.HasPrefix(coll, "system.") || strings.ContainsAny(coll, "$\x00") {
+		return "", invalidInputf("invalid collection name")
+	}
+	return coll, nil
+}
+
+// mongoDocArg reads an object argument given either as JSON object or JSON string,
+// and rejects operators that execute code or write
+func mongoDocArg(args map[string]interface{}, key string) (bsonDoc, error) {
+	var doc bsonDoc
+	switch v := args[key].(type) {
+	case nil:
+		return bsonDoc{}, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return bsonDoc{}, nil
+		}
+		parsed, err := parseOrderedJSON(v)
+		if err != nil {
+			return nil, invalidInputf("invalid %s JSON: %s", key, err)
+		}
+		doc = parsed
+	case map[string]interface{}:
+		converted, ok := toBSONValue(v).(bsonDoc)
+		if !ok {
+			return nil, invalidInputf("%s must be an object", key)
+		}
+		doc = converted
+	default:
+		return nil, invalidInputf("%s must be an object", key)
+	}
+	if op := findBlockedMongoOperator(doc); op != "" {
+		return nil, forbiddenf("%s is not allowed (read-only mode)", op)
+	}
+	return doc, nil
+}
+
+// Operators that run server-side JavaScript or write output
+var blockedMongoOperators = map[string]bool{
+	"$where":       true,
+	"$function":    true,
+	"$accumulator": true,
+	"$out":         true,
+	"$merge":       true,
+}
+
+func findBlockedMongoOperator(v interface{}) string {
+	switch val := v.(type) {
+	case bsonDoc:
+		for _, e := range val {
+			if blockedMongoOperators[e.Key] {
+				return e.Key
+			}
+			if op := findBlockedMongoOperator(e.Value); op != "" {
+				return op
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if op := findBlockedMongoOperator(item); op != "" {
+				return op
+			}
+		}
+	}
+	return ""
+}
+
+func mongoFirstBatch(reply bsonDoc) []bsonDoc {
+	cursor, _ := reply.get("cursor").(bsonDoc)
+	batch, _ := cursor.get("firstBatch").([]interface{})
+	docs := make([]bsonDoc, 0, len(batch))
+	for _, item := range batch {
+		if d, ok := item.(bsonDoc); ok {
+			docs = append(docs, d)
+		}
+	}
+	return docs
+}
+
+func mongoInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// --- wire protocol ---
+
+type mongoConn struct {
+	conn net.Conn
+	db   string
+}
+
+var mongoRequestID int32
+
+type mongoURI struct {
+	hosts      []string
+	user       string
+	password   string
+	db         string
+	authSource string
+	mechanism  string
+	tls        bool
+}
+
+// parseMongoURI handles mongodb:// (multiple hosts) and mongodb+srv:// URIs.
+// url.Parse rejects comma-separated host lists, so the authority is split by hand.
+func parseMongoURI(raw string) (*mongoURI, error) {
+	var srv bool
+	switch {
+	case strings.HasPrefix(raw, "mongodb://"):
+		raw = strings.TrimPrefix(raw, "mongodb://")
+	case strings.HasPrefix(raw, "mongodb+srv://"):
+		raw = strings.TrimPrefix(raw, "mongodb+srv://")
+		srv = true
+	default:
+		return nil, fmt.Errorf("must start with mongodb:// or mongodb+srv://")
+	}
+
+	rest := ""
+	if i := strings.IndexAny(raw, "/?"); i >= 0 {
+		raw, rest = raw[:i], raw[i:]
+	}
+	u := &mongoURI{tls: srv}
+	if at := strings.LastIndex(raw, "@"); at >= 0 {
+		userinfo := raw[:at]
+		raw = raw[at+1:]
+		user, pass, _ := strings.Cut(userinfo, ":")
+		var err error
+		if u.user, err = url.PathUnescape(user); err != nil {
+			return nil, fmt.Errorf("invalid username encoding")
+		}
+		if u.password, err = url.PathUnescape(pass); err != nil {
+			return nil, fmt.Errorf("invalid password encoding")
+		}
+	}
+	if raw == "" {
+		return nil, fmt.Errorf("no host")
+	}
+
+	path, query, _ := strings.Cut(rest, "?")
+	u.db = strings.TrimPrefix(path, "/")
+	opts, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %s", err)
+	}
+
+	if srv {
+		_, addrs, err := net.LookupSRV("mongodb", "tcp", raw)
+		if err != nil {
+			return nil, fmt.Errorf("SRV lookup failed: %s", err)
+		}
+		for _, a := range addrs {
+			u.hosts = append(u.hosts, net.JoinHostPort(strings.TrimSuffix(a.Target, "."), strconv.Itoa(int(a.Port))))
+		}
+		// TXT records may carry authSource/replicaSet defaults
+		if txts, err := net.LookupTXT(raw); err == nil {
+			for _, txt := range txts {
+				if txtOpts, err := url.ParseQuery(txt); err == nil {
+					for k, v := range txtOpts {
+						if opts.Get(k) == "" {
+							opts[k] = v
+						}
+					}
+				}
+			}
+		}
+	} else {
+		for _, h := range strings.Split(raw, ",") {
+			if _, _, err := net.SplitHostPort(h); err != nil {
+				h = net.JoinHostPort(strings.Trim(h, "[]"), "27017")
+			}
+			u.hosts = append(u.hosts, h)
+		}
+	}
+
+	u.authSource = opts.Get("authSource")
+	u.mechanism = opts.Get("authMechanism")
+	if v := opts.Get("tls"); v != "" {
+		u.tls = v == "true"
+	} else if v := opts.Get("ssl"); v != "" {
+		u.tls = v == "true"
+	}
+	return u, nil
+}
+
+func dialMongo(env map[string]string) (*mongoConn, error) {
+	raw := env["MONGO_URI"]
+	if raw == "" {
+		return nil, notConfiguredf("MONGO_URI is not configured")
+	}
+	uri, err := parseMongoURI(raw)
+	if err != nil {
+		return nil, notConfiguredf("invalid MONGO_URI: %s", err)
+	}
+
+	db := env["MONGO_DB"]
+	if db == "" {
+		db = uri.db
+	}
+	if db == "" {
+		return nil, notConfiguredf("no database: set MONGO_DB or include it in MONGO_URI")
+	}
+
+	var lastErr error
+	for _, host := range uri.hosts {
+		var conn net.Conn
+		if uri.tls {
+			conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", host, &tls.Config{MinVersion: tls.VersionTLS12})
+		} else {
+			conn, err = net.DialTimeout("tcp", host, 5*time.Second)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c := &mongoConn{conn: conn, db: db}
+		if uri.user != "" {
+			authDB := uri.authSource
+			if authDB == "" {
+				authDB = uri.db
+			}
+			if authDB == "" {
+				authDB = "admin"
+			}
+			if err := c.authenticate(uri.user, uri.password, authDB, uri.mechanism); err != nil {
+				conn.Close()
+				return nil, backendErrorf("auth failed: %s", err)
+			}
+		}
+		return c, nil
+	}
+	return nil, backendErrorf("connection failed: %s", lastErr)
+}
+
+func (c *mongoConn) Close() error { return c.conn.Close() }
+
+// command runs a command against the profile database
+func (c *mongoConn) command(cmd bsonDoc) (bsonDoc, error) {
+	return c.commandOn(c.db, cmd)
+}
+
+func (c *mongoConn) commandOn(db string, cmd bsonDoc) (bsonDoc, error) {
+	cmd = append(cmd, bsonElem{"$db", db})
+	// primaryPreferred lets reads succeed when the first reachable host is a secondary
+	cmd = append(cmd, bsonElem{"$readPreference", bsonDoc{{"mode", "primaryPreferred"}}})
+	body, err := encodeBSON(cmd)
+	if err != nil {
+		return nil, invalidInputf("%s", err)
+	}
+
+	// OP_MSG: header, flagBits, one kind-0 section
+	msg := make([]byte, 16+4+1+len(body))
+	reqID := atomic.AddInt32(&mongoRequestID, 1)
+	binary.LittleEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.LittleEndian.PutUint32(msg[4:], uint32(reqID))
+	binary.LittleEndian.PutUint32(msg[12:], 2013)
+	msg[20] = 0
+	copy(msg[21:], body)
+
+	c.conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if _, err := c.conn.Write(msg); err != nil {
+		return nil, backendErrorf("write failed: %s", err)
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, backendErrorf("read failed: %s", err)
+	}
+	size := int(binary.LittleEndian.Uint32(header))
+	if size < 21 || size > 48*1024*1024 {
+		return nil, backendErrorf("invalid reply size %d", size)
+	}
+	if op := binary.LittleEndian.Uint32(header[12:]); op != 2013 {
+		return nil, backendErrorf("unexpected reply opcode %d", op)
+	}
+	payload := make([]byte, size-16)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return nil, backendErrorf("read failed: %s", err)
+	}
+	if payload[4] != 0 {
+		return nil, backendErrorf("unexpected reply section kind %d", payload[4])
+	}
+	reply, err := decodeBSON(payload[5:])
+	if err != nil {
+		return nil, backendErrorf("invalid reply: %s", err)
+	}
+
+	if mongoInt(reply.get("ok")) != 1 {
+		msg, _ := reply.get("errmsg").(string)
+		code := mongoInt(reply.get("code"))
+		switch code {
+		case 13, 18: // Unauthorized, AuthenticationFailed
+			return nil, forbiddenf("%s", msg)
+		case 26: // NamespaceNotFound
+			return nil, invalidInputf("%s", msg)
+		case 2, 9, 14: // BadValue, FailedToParse, TypeMismatch
+			return nil, invalidInputf("%s", msg)
+		}
+		return nil, backendErrorf("%s (code %d)", msg, code)
+	}
+	return reply, nil
+}
+
+// authenticate performs SCRAM-SHA-256 (default) or SCRAM-SHA-1
+func (c *mongoConn) authenticate(user, password, authDB, mechanism string) error {
+	if mechanism == "" {
+		mechanism = "SCRAM-SHA-256"
+	}
+	var newHash func() hash.Hash
+	switch mechanism {
+	case "SCRAM-SHA-256":
+		newHash = sha256.New
+	case "SCRAM-SHA-1":
+		newHash = sha1.New
+		// SCRAM-SHA-1 in MongoDB uses the legacy MONGODB-CR password digest
+		sum := md5.Sum([]byte(user + ":mongo:" + password))
+		password = hex.EncodeToString(sum[:])
+	default:
+		return fmt.Errorf("unsupported authMechanism %s", mechanism)
+	}
+
+	nonceBytes := make([]byte, 24)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return err
+	}
+	nonce := base64.StdEncoding.EncodeToString(nonceBytes)
+	escapedUser := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(user)
+	clientFirstBare := "n=" + escapedUser + ",r=" + nonce
+
+	reply, err := c.commandOn(authDB, bsonDoc{
+		{"saslStart", int32(1)},
+		{"mechanism", mechanism},
+		{"payload", []byte("n,," + clientFirstBare)},
+		{"autoAuthorize", int32(1)},
+		{"options", bsonDoc{{"skipEmptyExchange", true}}},
+	})
+	if err != nil {
+		return err
+	}
+	conversationID := reply.get("conversationId")
+	serverFirst := string(mongoPayload(reply))
+	fields := scramFields(serverFirst)
+	serverNonce, salt64, iterStr := fields["r"], fields["s"], fields["i"]
+	if !strings.HasPrefix(serverNonce, nonce) {
+		return fmt.Errorf("server nonce mismatch")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return fmt.Errorf("invalid salt")
+	}
+	iterations, err := strconv.Atoi(iterStr)
+	if err != nil || iterations < 1 {
+		return fmt.Errorf("invalid iteration count")
+	}
+
+	salted := pbkdf2Key(newHash, []byte(password), salt, iterations)
+	clientKey := hmacSum(newHash, salted, "Client Key")
+	h := newHash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	clientFinalNoProof := "c=biws,r=" + serverNonce
+	authMessage := clientFirstBare + "," + serverFirst + "," + clientFinalNoProof
+	clientSig := hmacSum(newHash, storedKey, authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSig[i]
+	}
+	serverSig := hmacSum(newHash, hmacSum(newHash, salted, "Server Key"), authMessage)
+
+	reply, err = c.commandOn(authDB, bsonDoc{
+		{"saslContinue", int32(1)},
+		{"conversationId", conversationID},
+		{"payload", []byte(clientFinalNoProof + ",p=" + base64.StdEncoding.EncodeToString(proof))},
+	})
+	if err != nil {
+		return err
+	}
+	final := scramFields(string(mongoPayload(reply)))
+	if got, _ := base64.StdEncoding.DecodeString(final["v"]); !hmac.Equal(got, serverSig) {
+		return fmt.Errorf("server signature mismatch")
+	}
+
+	for done, _ := reply.get("done").(bool); !done; done, _ = reply.get("done").(bool) {
+		reply, err = c.commandOn(authDB, bsonDoc{
+			{"saslContinue", int32(1)},
+			{"conversationId", conversationID},
+			{"payload", []byte{}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mongoPayload(reply bsonDoc) []byte {
+	if b, ok := reply.get("payload").(bsonBinary); ok {
+		return b.Data
+	}
+	return nil
+}
+
+func scramFields(msg string) map[string]string {
+	out := map[string]string{}
+	for _, part := range strings.Split(msg, ",") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func hmacSum(newHash func() hash.Hash, key []byte, data string) []byte {
+	m := hmac.New(newHash, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// pbkdf2Key derives a single-block key, which is all SCRAM needs (dkLen == hash size)
+func pbkdf2Key(newHash func() hash.Hash, password, salt []byte, iterations int) []byte {
+	prf := hmac.New(newHash, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+	out := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range out {
+			out[j] ^= u[j]
+		}
+	}
+	return out
+}
@@ -0,0 +1,511 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIProfile exposes each operation of an OpenAPI 3 document as a tool.
+// Tools depend on the connection's OPENAPI_SPEC_URL, so they are served via ToolsFor.
+type OpenAPIProfile struct {
+	mu    sync.RWMutex
+	cache map[string]*openapiSpec
+}
+
+type openapiSpec struct {
+	URL        string
+	FetchedAt  time.Time
+	BaseURL    string
+	Operations []openapiOperation
+	byName     map[string]*openapiOperation
+}
+
+type openapiOperation struct {
+	Name        string
+	Method      string
+	Path        string
+	Description string
+	Params      []openapiParam
+	HasBody     bool
+	BodySchema  map[string]interface{}
+}
+
+type openapiParam struct {
+	Name        string
+	In          string // path, query, header
+	Required    bool
+	Description string
+	Schema      map[string]interface{}
+}
+
+var openapiNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func (p *OpenAPIProfile) ID() string       { return "openapi" }
+func (p *OpenAPIProfile) HasBackend() bool { return true }
+
+// Tools is empty: the tool list comes from the spec, see ToolsFor
+func (p *OpenAPIProfile) Tools() []Tool {
+	return nil
+}
+
+func (p *OpenAPIProfile) ToolsFor(env map[string]string) ([]Tool, error) {
+	spec, err := p.ensureSpec(env)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]Tool, 0, len(spec.Operations))
+	for _, op := range spec.Operations {
+		tools = append(tools, op.tool())
+	}
+	return tools, nil
+}
+
+func (p *OpenAPIProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "OPENAPI_SPEC_URL", Required: true, Description: "URL of the OpenAPI 3 document (JSON or YAML)"},
+		{Name: "OPENAPI_BASE_URL", Required: false, Description: "Override the API base URL (default: first entry in servers)"},
+		{Name: "AUTH_HEADER_NAME", Required: false, Description: "Header used for API auth (default Authorization)"},
+		{Name: "AUTH_HEADER_VALUE", Required: false, Description: "Value sent in the auth header, e.g. 'Bearer xyz'"},
+		{Name: "MAX_RESPONSE_SIZE", Required: false, Description: "Maximum response bytes returned (default 1MB)"},
+		{Name: "REFRESH_INTERVAL_SECONDS", Required: false, Description: "Spec cache lifetime (default 300)"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 30, max 300)"},
+	}
+}
+
+func (p *OpenAPIProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	spec, err := p.ensureSpec(env)
+	if err != nil {
+		return "", err
+	}
+	op, ok := spec.byName[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+	return p.invoke(spec, op, args, env)
+}
+
+func (p *OpenAPIProfile) invoke(spec *openapiSpec, op *openapiOperation, args map[string]interface{}, env map[string]string) (string, error) {
+	path := op.Path
+	query := url.Values{}
+	headers := map[string]string{}
+	for _, param := range op.Params {
+		v, ok := args[param.Name]
+		if !ok || v == nil {
+			if param.Required {
+				return "", invalidInputf("%s is required", param.Name)
+			}
+			continue
+		}
+		s := getStr(args, param.Name)
+		switch param.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", url.PathEscape(s))
+		case "query":
+			if arr, ok := v.([]interface{}); ok {
+				for _, item := range arr {
+					query.Add(param.Name, fmt.Sprintf("%v", item))
+				}
+			} else {
+				query.Set(param.Name, s)
+			}
+		case "header":
+			headers[param.Name] = s
+		}
+	}
+
+	rawURL := strings.TrimRight(spec.BaseURL, "/") + path
+	if len(query) > 0 {
+		rawURL += "?" + query.Encode()
+	}
+	if err := validateURL(rawURL, env); err != nil {
+		return "", err
+	}
+
+	var bodyReader io.Reader
+	if op.HasBody {
+		if body, ok := args["body"]; ok && body != nil {
+			data, err := json.Marshal(body)
+			if err != nil {
+				return "", invalidInputf("invalid body: %s", err)
+			}
+			bodyReader = bytes.NewReader(data)
+		}
+	}
+
+	req, err := http.NewRequest(op.Method, rawURL, bodyReader)
+	if err != nil {
+		return "", fmt.Errorf("invalid request: %s", err)
+	}
+	req.Header.Set("User-Agent", "Dublyo-MCP-OpenAPI/1.0")
+	req.Header.Set("Accept", "application/json, */*;q=0.5")
+	if bodyReader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if authValue := env["AUTH_HEADER_VALUE"]; authValue != "" {
+		authName := env["AUTH_HEADER_NAME"]
+		if authName == "" {
+			authName = "Authorization"
+		}
+		req.Header.Set(authName, authValue)
+	}
+
+	resp, err := safeHTTPClient(httpTimeout(env, 30*time.Second)).Do(req)
+	if err != nil {
+		return "", backendErrorf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	maxSize := envInt(env["MAX_RESPONSE_SIZE"], 1024*1024)
+	if maxSize < 1024 {
+		maxSize = 1024
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return "", backendErrorf("read failed: %s", err)
+	}
+	truncated := ""
+	if len(data) > maxSize {
+		data = data[:maxSize]
+		truncated = fmt.Sprintf("\n... (truncated at %d bytes)", maxSize)
+	}
+
+	// Pretty-print JSON responses
+	text := string(data)
+	if truncated == "" && strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		var v interface{}
+		if json.Unmarshal(data, &v) == nil {
+			if pretty, err := json.MarshalIndent(v, "", "  "); err == nil {
+				text = string(pretty)
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s %s\nStatus: %d %s\nContent-Type: %s\n\n%s%s",
+		op.Method, path, resp.StatusCode, http.StatusText(resp.StatusCode),
+		resp.Header.Get("Content-Type"), text, truncated), nil
+}
+
+func (op openapiOperation) tool() Tool {
+	props := map[string]interface{}{}
+	var required []string
+	for _, param := range op.Params {
+		schema := map[string]interface{}{}
+		for k, v := range param.Schema {
+			schema[k] = v
+		}
+		if _, ok := schema["type"]; !ok {
+			schema["type"] = "string"
+		}
+		desc := param.Description
+		if desc == "" {
+			desc = fmt.Sprintf("%s parameter", param.In)
+		}
+		schema["description"] = desc
+		props[param.Name] = schema
+		if param.Required {
+			required = append(required, param.Name)
+		}
+	}
+	if op.HasBody {
+		body := op.BodySchema
+		if body == nil {
+			body = map[string]interface{}{"type": "object"}
+		}
+		props["body"] = body
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	desc := op.Description
+	if desc == "" {
+		desc = fmt.Sprintf("%s %s", op.Method, op.Path)
+	}
+	return Tool{Name: op.Name, Description: desc, InputSchema: schema}
+}
+
+func (p *OpenAPIProfile) ensureSpec(env map[string]string) (*openapiSpec, error) {
+	rawURL := strings.TrimSpace(env["OPENAPI_SPEC_URL"])
+	if rawURL == "" {
+		return nil, notConfiguredf("OPENAPI_SPEC_URL is not configured")
+	}
+	if err := validateURL(rawURL, nil); err != nil {
+		return nil, notConfiguredf("invalid OPENAPI_SPEC_URL: %s", err)
+	}
+
+	refreshSeconds := envInt(env["REFRESH_INTERVAL_SECONDS"], 300)
+	if refreshSeconds < 10 {
+		refreshSeconds = 10
+	}
+	cacheKey := rawURL + "|" + env["OPENAPI_BASE_URL"]
+
+	p.mu.RLock()
+	current := p.cache[cacheKey]
+	p.mu.RUnlock()
+	if current != nil && time.Since(current.FetchedAt) < time.Duration(refreshSeconds)*time.Second {
+		return current, nil
+	}
+
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %s", err)
+	}
+	req.Header.Set("User-Agent", "Dublyo-MCP-OpenAPI/1.0")
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, */*;q=0.1")
+
+	resp, err := safeHTTPClient(httpTimeout(env, 30*time.Second)).Do(req)
+	if err != nil {
+		if current != nil {
+			return current, nil
+		}
+		return nil, backendErrorf("failed to fetch OpenAPI spec: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if current != nil {
+			return current, nil
+		}
+		return nil, backendErrorf("OpenAPI spec endpoint returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+	if err != nil {
+		return nil, backendErrorf("failed to read OpenAPI spec: %s", err)
+	}
+
+	spec, err := parseOpenAPISpec(body, env["OPENAPI_BASE_URL"], rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if p.cache == nil {
+		p.cache = map[string]*openapiSpec{}
+	}
+	p.cache[cacheKey] = spec
+	p.mu.Unlock()
+
+	return spec, nil
+}
+
+// parseOpenAPISpec builds operations from an OpenAPI 3 document.
+// YAML is a superset of JSON, so one decoder handles both.
+func parseOpenAPISpec(data []byte, baseOverride, specURL string) (*openapiSpec, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %s", err)
+	}
+	if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		return nil, fmt.Errorf("only OpenAPI 3.x documents are supported")
+	}
+
+	baseURL := baseOverride
+	if baseURL == "" {
+		if servers, ok := doc["servers"].([]interface{}); ok && len(servers) > 0 {
+			if s, ok := servers[0].(map[string]interface{}); ok {
+				baseURL, _ = s["url"].(string)
+			}
+		}
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("spec has no servers; set OPENAPI_BASE_URL")
+	}
+	// Relative server URLs are resolved against the spec location
+	if base, err := url.Parse(baseURL); err == nil && !base.IsAbs() {
+		if specU, err := url.Parse(specURL); err == nil {
+			baseURL = specU.ResolveReference(base).String()
+		}
+	}
+
+	spec := &openapiSpec{
+		URL:       specURL,
+		FetchedAt: time.Now(),
+		BaseURL:   baseURL,
+		byName:    map[string]*openapiOperation{},
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	pathKeys := make([]string, 0, len(paths))
+	for k := range paths {
+		pathKeys = append(pathKeys, k)
+	}
+	sort.Strings(pathKeys)
+
+	methods := []string{"get", "post", "put", "patch", "delete", "head", "options"}
+	for _, path := range pathKeys {
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		shared := openapiParams(doc, item["parameters"])
+		for _, method := range methods {
+			raw, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op := openapiOperation{
+				Method: strings.ToUpper(method),
+				Path:   path,
+			}
+			op.Name = openapiToolName(raw, method, path)
+			if _, dup := spec.byName[op.Name]; dup {
+				op.Name = openapiToolName(nil, method, path)
+			}
+			summary, _ := raw["summary"].(string)
+			description, _ := raw["description"].(string)
+			op.Description = strings.TrimSpace(strings.Join(nonEmpty(summary, description), " — "))
+
+			op.Params = mergeOpenAPIParams(shared, openapiParams(doc, raw["parameters"]))
+
+			if rb, ok := resolveOpenAPIRef(doc, raw["requestBody"], 0).(map[string]interface{}); ok {
+				op.HasBody = true
+				if content, ok := rb["content"].(map[string]interface{}); ok {
+					if media, ok := content["application/json"].(map[string]interface{}); ok {
+						if schema, ok := resolveOpenAPIRef(doc, media["schema"], 0).(map[string]interface{}); ok {
+							op.BodySchema = schema
+						}
+					}
+				}
+			}
+
+			spec.Operations = append(spec.Operations, op)
+			spec.byName[op.Name] = &spec.Operations[len(spec.Operations)-1]
+		}
+	}
+
+	// byName pointers must reference the final slice backing array
+	for i := range spec.Operations {
+		spec.byName[spec.Operations[i].Name] = &spec.Operations[i]
+	}
+
+	if len(spec.Operations) == 0 {
+		return nil, fmt.Errorf("OpenAPI document has no operations")
+	}
+	return spec, nil
+}
+
+func openapiToolName(op map[string]interface{}, method, path string) string {
+	name, _ := op["operationId"].(string)
+	if name == "" {
+		name = method + "_" + path
+	}
+	name = strings.Trim(openapiNameSanitizer.ReplaceAllString(name, "_"), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func openapiParams(doc map[string]interface{}, raw interface{}) []openapiParam {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil
+	}
+	var params []openapiParam
+	for _, item := range list {
+		m, ok := resolveOpenAPIRef(doc, item, 0).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := m["name"].(string)
+		in, _ := m["in"].(string)
+		if name == "" || (in != "path" && in != "query" && in != "header") {
+			continue
+		}
+		required, _ := m["required"].(bool)
+		desc, _ := m["description"].(string)
+		schema, _ := resolveOpenAPIRef(doc, m["schema"], 0).(map[string]interface{})
+		params = append(params, openapiParam{
+			Name:        name,
+			In:          in,
+			Required:    required || in == "path",
+			Description: desc,
+			Schema:      schema,
+		})
+	}
+	return params
+}
+
+// mergeOpenAPIParams applies operation-level parameters over path-level ones
+func mergeOpenAPIParams(shared, own []openapiParam) []openapiParam {
+	out := make([]openapiParam, 0, len(shared)+len(own))
+	seen := map[string]bool{}
+	for _, p := range own {
+		seen[p.In+":"+p.Name] = true
+		out = append(out, p)
+	}
+	for _, p := range shared {
+		if !seen[p.In+":"+p.Name] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// resolveOpenAPIRef inlines local "#/..." references, recursing into nested
+// schemas up to a fixed depth so cyclic schemas terminate
+func resolveOpenAPIRef(doc map[string]interface{}, v interface{}, depth int) interface{} {
+	if depth > 8 {
+		return map[string]interface{}{"type": "object"}
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := val["$ref"].(string); ok {
+			if !strings.HasPrefix(ref, "#/") {
+				return map[string]interface{}{"type": "object"}
+			}
+			var cur interface{} = doc
+			for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+				part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+				m, ok := cur.(map[string]interface{})
+				if !ok {
+					return map[string]interface{}{"type": "object"}
+				}
+				cur = m[part]
+			}
+			return resolveOpenAPIRef(doc, cur, depth+1)
+		}
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			out[k] = resolveOpenAPIRef(doc, child, depth+1)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = resolveOpenAPIRef(doc, child, depth+1)
+		}
+		return out
+	}
+	return v
+}
+
+func nonEmpty(items ...string) []string {
+	var out []string
+	for _, s := range items {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
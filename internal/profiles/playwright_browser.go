@@ -15,7 +15,8 @@ type PlaywrightBrowserProfile struct {
 	requestID atomic.Int64
 }
 
-func (p *PlaywrightBrowserProfile) ID() string { return "playwright-browser" }
+func (p *PlaywrightBrowserProfile) ID() string       { return "playwright-browser" }
+func (p *PlaywrightBrowserProfile) HasBackend() bool { return true }
 
 func (p *PlaywrightBrowserProfile) Tools() []Tool {
 	return []Tool{
@@ -43,6 +44,13 @@ func (p *PlaywrightBrowserProfile) Tools() []Tool {
 	}
 }
 
+func (p *PlaywrightBrowserProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "MCP_UPSTREAM_URL", Required: true, Description: "URL of the Playwright MCP sidecar"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 120, max 300)"},
+	}
+}
+
 func (p *PlaywrightBrowserProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	return proxyToolCall(p, name, args, env)
 }
@@ -74,7 +82,7 @@ func proxyToolCall(p interface{ nextID() int64 }, toolName string, args map[stri
 		return "", fmt.Errorf("marshal request: %w", err)
 	}
 
-	client := &http.Client{Timeout: 120 * time.Second}
+	client := &http.Client{Timeout: httpTimeout(env, 120*time.Second)}
 	resp, err := client.Post(upstream, "application/json", bytes.NewReader(body))
 	if err != nil {
 		return "", fmt.Errorf("upstream request failed: %w", err)
@@ -1,5 +1,12 @@
 package profiles
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // Tool describes an MCP tool
 type Tool struct {
 	Name        string
@@ -14,6 +21,78 @@ type Profile interface {
 	CallTool(name string, args map[string]interface{}, env map[string]string) (string, error)
 }
 
+// EnvSpec documents an environment variable a profile reads from the connection config
+type EnvSpec struct {
+	Name        string
+	Required    bool
+	Description string
+}
+
+// EnvSpecProvider is optionally implemented by profiles to declare the
+// environment variables they use, so misconfigured connections can be
+// flagged when config is applied rather than on the first tool call.
+type EnvSpecProvider interface {
+	RequiredEnv() []EnvSpec
+}
+
+// DynamicToolsProvider is optionally implemented by profiles whose tool list
+// depends on the connection's env (e.g. tools generated from a remote spec).
+// When implemented, its result is served for tools/list instead of Tools().
+type DynamicToolsProvider interface {
+	ToolsFor(env map[string]string) ([]Tool, error)
+}
+
+// ToolsFor returns the tools p exposes for a connection with the given env
+func ToolsFor(p Profile, env map[string]string) ([]Tool, error) {
+	if dp, ok := p.(DynamicToolsProvider); ok {
+		return dp.ToolsFor(env)
+	}
+	return p.Tools(), nil
+}
+
+// StreamingProfile is optionally implemented by profiles that can produce a
+// tool result incrementally instead of building one large string. emit is
+// called for each chunk; once it returns an error (e.g. ErrResultLimit) the
+// profile should stop producing output and return that error. ctx may carry
+// a logger, as for ContextProfile.
+type StreamingProfile interface {
+	CallToolStream(ctx context.Context, name string, args map[string]interface{}, env map[string]string, emit func(chunk string) error) error
+}
+
+// ErrResultLimit is returned by emit when the caller won't accept more output
+var ErrResultLimit = errors.New("result size limit reached")
+
+// EnvSpecs returns the env specs declared by p, or nil if it declares none
+func EnvSpecs(p Profile) []EnvSpec {
+	if ep, ok := p.(EnvSpecProvider); ok {
+		return ep.RequiredEnv()
+	}
+	return nil
+}
+
+// ValidateEnv checks env against the profile's declared specs and returns
+// a description of each problem found
+func ValidateEnv(p Profile, env map[string]string) []string {
+	var problems []string
+	for _, spec := range EnvSpecs(p) {
+		if spec.Required && strings.TrimSpace(env[spec.Name]) == "" {
+			problems = append(problems, fmt.Sprintf("%s is required", spec.Name))
+		}
+	}
+	return problems
+}
+
+// ReadOnlyMode reports whether the READ_ONLY env keeps a profile's mutating
+// tools disabled. It defaults to true; only an explicit false, 0, no or off
+// (in any case) enables writes, so a typo never opens write access.
+func ReadOnlyMode(env map[string]string) bool {
+	switch strings.ToLower(strings.TrimSpace(env["READ_ONLY"])) {
+	case "false", "0", "no", "off":
+		return false
+	}
+	return true
+}
+
 // Registry holds all available profiles
 var Registry = map[string]Profile{}
 
@@ -42,6 +121,13 @@ func init() {
 		&GitProfile{},
 		&DockerProfile{},
 		&PlaywrightBrowserProfile{},
+		&OpenAPIProfile{},
+		&GraphQLProfile{},
+		&S3Profile{},
+		&KubernetesProfile{},
+		&MongoDBProfile{},
+		&ClickHouseProfile{},
+		&DiagnosticsProfile{},
 	}
 	for _, p := range reg {
 		Registry[p.ID()] = p
@@ -0,0 +1,73 @@
+package profiles
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	tests := []struct {
+		value string
+		set   bool
+		want  bool
+	}{
+		{"", false, true},
+		{"", true, true},
+		{"true", true, true},
+		{"TRUE", true, true},
+		{"1", true, true},
+		{"yes", true, true},
+		{"Yes", true, true},
+		{"on", true, true},
+		{"ture", true, true},
+		{"false", true, false},
+		{"FALSE", true, false},
+		{" false ", true, false},
+		{"0", true, false},
+		{"no", true, false},
+		{"Off", true, false},
+	}
+	for _, tt := range tests {
+		env := map[string]string{}
+		if tt.set {
+			env["READ_ONLY"] = tt.value
+		}
+		if got := ReadOnlyMode(env); got != tt.want {
+			t.Errorf("READ_ONLY=%q (set %v): ReadOnlyMode = %v, want %v", tt.value, tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestFilesystemReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		readOnly string
+		wantErr  bool
+	}{
+		{"", true},
+		{"TRUE", true},
+		{"1", true},
+		{"false", false},
+		{"No", false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "note-"+tt.readOnly+".txt")
+		env := map[string]string{"ALLOWED_PATHS": dir, "READ_ONLY": tt.readOnly}
+		_, err := (&FilesystemProfile{}).CallTool("write_file", map[string]interface{}{"path": path, "content": "hi"}, env)
+		if tt.wantErr {
+			var te *ToolError
+			if !errors.As(err, &te) || te.Code != ErrForbidden {
+				t.Errorf("READ_ONLY=%q: err = %v, want forbidden", tt.readOnly, err)
+			}
+			if _, statErr := os.Stat(path); statErr == nil {
+				t.Errorf("READ_ONLY=%q: file written", tt.readOnly)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("READ_ONLY=%q: %v", tt.readOnly, err)
+		}
+	}
+}
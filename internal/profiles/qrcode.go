@@ -1,6 +1,7 @@
 package profiles
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"image"
@@ -18,7 +19,7 @@ func (p *QRCodeProfile) Tools() []Tool {
 	return []Tool{
 		{
 			Name:        "generate_qr",
-			Description: "Generate a QR code PNG image from text or URL. Returns base64-encoded PNG.",
+			Description: "Generate a QR code PNG image from text or URL. Returns the PNG as an image.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -71,9 +72,41 @@ func (p *QRCodeProfile) CallTool(name string, args map[string]interface{}, env m
 }
 
 func (p *QRCodeProfile) generateQR(args map[string]interface{}) (string, error) {
+	png, size, err := encodeQR(args)
+	if err != nil {
+		return "", err
+	}
+
+	b64 := base64.StdEncoding.EncodeToString(png)
+	return fmt.Sprintf("QR code generated (%dx%d pixels, %d bytes)\nContent: %s\nBase64 PNG:\n%s", size, size, len(png), getStr(args, "content"), b64), nil
+}
+
+// ContentTools lists the tools returning an image block to MCP clients
+func (p *QRCodeProfile) ContentTools() []string {
+	return []string{"generate_qr"}
+}
+
+// CallToolContent returns generate_qr's PNG as an image block, which clients
+// can display, rather than base64 inside text
+func (p *QRCodeProfile) CallToolContent(ctx context.Context, name string, args map[string]interface{}, env map[string]string) ([]Content, error) {
+	if name != "generate_qr" {
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+	png, size, err := encodeQR(args)
+	if err != nil {
+		return nil, err
+	}
+	return []Content{
+		TextContent(fmt.Sprintf("QR code generated (%dx%d pixels, %d bytes)\nContent: %s", size, size, len(png), getStr(args, "content"))),
+		{Type: "image", Data: base64.StdEncoding.EncodeToString(png), MimeType: "image/png"},
+	}, nil
+}
+
+// encodeQR renders the content arg as a PNG QR code, returning it and its size
+func encodeQR(args map[string]interface{}) ([]byte, int, error) {
 	content := getStr(args, "content")
 	if content == "" {
-		return "", fmt.Errorf("content is required")
+		return nil, 0, fmt.Errorf("content is required")
 	}
 
 	size := int(getFloat(args, "size"))
@@ -86,11 +119,9 @@ func (p *QRCodeProfile) generateQR(args map[string]interface{}) (string, error)
 
 	png, err := qrcode.Encode(content, qrcode.Medium, size)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate QR code: %s", err)
+		return nil, 0, fmt.Errorf("failed to generate QR code: %s", err)
 	}
-
-	b64 := base64.StdEncoding.EncodeToString(png)
-	return fmt.Sprintf("QR code generated (%dx%d pixels, %d bytes)\nContent: %s\nBase64 PNG:\n%s", size, size, len(png), content, b64), nil
+	return png, size, nil
 }
 
 func (p *QRCodeProfile) generateBarcode(args map[string]interface{}) (string, error) {
@@ -2,6 +2,7 @@ package profiles
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -11,9 +12,12 @@ import (
 	"time"
 )
 
-type RedisProfile struct{}
+type RedisProfile struct {
+	pool redisPool
+}
 
-func (p *RedisProfile) ID() string { return "redis" }
+func (p *RedisProfile) ID() string       { return "redis" }
+func (p *RedisProfile) HasBackend() bool { return true }
 
 func (p *RedisProfile) Tools() []Tool {
 	return []Tool{
@@ -23,14 +27,15 @@ func (p *RedisProfile) Tools() []Tool {
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"key": map[string]interface{}{"type": "string", "description": "Key to get"},
+					"key":    map[string]interface{}{"type": "string", "description": "Key to get"},
+					"format": redisFormatProperty(),
 				},
 				"required": []string{"key"},
 			},
 		},
 		{
 			Name:        "redis_set",
-			Description: "Set a key-value pair with optional TTL",
+			Description: "Set a key-value pair with optional TTL (requires READ_ONLY=false)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -43,7 +48,7 @@ func (p *RedisProfile) Tools() []Tool {
 		},
 		{
 			Name:        "redis_del",
-			Description: "Delete one or more keys",
+			Description: "Delete one or more keys (requires READ_ONLY=false)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -62,6 +67,9 @@ func (p *RedisProfile) Tools() []Tool {
 				},
 			},
 		},
+		redisScanTool("redis_hscan", "List a hash's fields and values matching a pattern, iterating with HSCAN (up to MAX_KEYS) so large hashes don't block the server"),
+		redisScanTool("redis_sscan", "List a set's members matching a pattern, iterating with SSCAN (up to MAX_KEYS) so large sets don't block the server"),
+		redisScanTool("redis_zscan", "List a sorted set's members and scores matching a pattern, iterating with ZSCAN (up to MAX_KEYS) so large sorted sets don't block the server"),
 		{
 			Name:        "redis_info",
 			Description: "Get Redis server information",
@@ -69,6 +77,11 @@ func (p *RedisProfile) Tools() []Tool {
 				"type": "object",
 				"properties": map[string]interface{}{
 					"section": map[string]interface{}{"type": "string", "description": "Info section: server, memory, stats, keyspace, all (default keyspace)"},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"text", "json"},
+						"description": "text: INFO's output as is (default); json: an object of sections, each an object of fields",
+					},
 				},
 			},
 		},
@@ -83,23 +96,74 @@ func (p *RedisProfile) Tools() []Tool {
 				"required": []string{"key"},
 			},
 		},
+		{
+			Name:        "redis_pipeline",
+			Description: "Run several commands over one connection and return each reply, optionally as an atomic MULTI/EXEC transaction. Commands other than reads require READ_ONLY=false.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"commands": map[string]interface{}{
+						"type":        "array",
+						"description": "Commands to run in order, each an array of arguments (e.g. [\"SET\", \"k\", \"v\"]) or a space-separated string (e.g. \"GET k\")",
+						"items":       map[string]interface{}{"type": []string{"array", "string"}},
+					},
+					"transaction": map[string]interface{}{"type": "boolean", "description": "Wrap the commands in MULTI/EXEC so they apply atomically"},
+					"format":      redisFormatProperty(),
+				},
+				"required": []string{"commands"},
+			},
+		},
 	}
 }
 
+func (p *RedisProfile) RequiredEnv() []EnvSpec {
+	return append([]EnvSpec{
+		{Name: "REDIS_URL", Required: true, Description: "Redis connection URL (redis://[:password@]host[:port][/db])"},
+		{Name: "MAX_KEYS", Required: false, Description: "Maximum keys returned by redis_keys, and entries by redis_hscan/redis_sscan/redis_zscan (default 100)"},
+		{Name: "READ_ONLY", Required: false, Description: "Set to false to allow redis_set/redis_del and writes in redis_pipeline (default true)"},
+		{Name: "REDIS_PROTOCOL", Required: false, Description: "Set to 3 to negotiate RESP3 (HELLO 3, Redis 6+) for maps, sets, booleans and doubles in format=json replies (default 2)"},
+	}, sshTunnelEnv...)
+}
+
+func (p *RedisProfile) DryRunTools() []string {
+	return []string{"redis_set", "redis_del", "redis_pipeline"}
+}
+
 func (p *RedisProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "redis_get":
+		asJSON, err := redisJSONFormat(args)
+		if err != nil {
+			return "", err
+		}
+		if asJSON {
+			reply, err := p.redisCmdReply(env, "GET", getStr(args, "key"))
+			if err != nil {
+				return "", err
+			}
+			return jsonReply(reply)
+		}
 		return p.redisCmd(env, "GET", getStr(args, "key"))
 	case "redis_set":
+		if ReadOnlyMode(env) {
+			return "", forbiddenf("redis_set requires READ_ONLY=false")
+		}
 		return p.redisSet(args, env)
 	case "redis_del":
+		if ReadOnlyMode(env) {
+			return "", forbiddenf("redis_del requires READ_ONLY=false")
+		}
 		return p.redisDel(args, env)
 	case "redis_keys":
 		return p.redisKeys(args, env)
+	case "redis_hscan", "redis_sscan", "redis_zscan":
+		return p.redisScan(name, args, env)
 	case "redis_info":
 		return p.redisInfo(args, env)
 	case "redis_ttl":
 		return p.redisCmd(env, "TTL", getStr(args, "key"))
+	case "redis_pipeline":
+		return p.redisPipeline(args, env)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
@@ -109,9 +173,23 @@ func (p *RedisProfile) redisSet(args map[string]interface{}, env map[string]stri
 	key := getStr(args, "key")
 	value := getStr(args, "value")
 	if key == "" || value == "" {
-		return "", fmt.Errorf("key and value are required")
+		return "", invalidInputf("key and value are required")
 	}
 	ttl := int(getFloat(args, "ttl"))
+	if IsDryRun(args) {
+		exists, err := p.redisCmd(env, "EXISTS", key)
+		if err != nil {
+			return "", err
+		}
+		action := "create"
+		if exists == "1" {
+			action = "overwrite"
+		}
+		if ttl > 0 {
+			return dryRunf("would %s key '%s' (%d bytes, expires in %ds)", action, key, len(value), ttl), nil
+		}
+		return dryRunf("would %s key '%s' (%d bytes)", action, key, len(value)), nil
+	}
 	if ttl > 0 {
 		return p.redisCmd(env, "SET", key, value, "EX", strconv.Itoa(ttl))
 	}
@@ -125,7 +203,7 @@ func (p *RedisProfile) redisDel(args map[string]interface{}, env map[string]stri
 	switch v := args["keys"].(type) {
 	case string:
 		if v == "" {
-			return "", fmt.Errorf("keys is required")
+			return "", invalidInputf("keys is required")
 		}
 		for _, k := range strings.Split(v, ",") {
 			k = strings.TrimSpace(k)
@@ -140,11 +218,19 @@ func (p *RedisProfile) redisDel(args map[string]interface{}, env map[string]stri
 			}
 		}
 	default:
-		return "", fmt.Errorf("keys is required")
+		return "", invalidInputf("keys is required")
 	}
 
 	if len(keys) == 0 {
-		return "", fmt.Errorf("keys is required")
+		return "", invalidInputf("keys is required")
+	}
+
+	if IsDryRun(args) {
+		existing, err := p.redisCmd(env, "EXISTS", keys...)
+		if err != nil {
+			return "", err
+		}
+		return dryRunf("would delete %s of %d keys: %s", existing, len(keys), strings.Join(keys, ", ")), nil
 	}
 
 	return p.redisCmd(env, "DEL", keys...)
@@ -155,45 +241,39 @@ func (p *RedisProfile) redisKeys(args map[string]interface{}, env map[string]str
 	if pattern == "" {
 		pattern = "*"
 	}
-	maxKeys := 100
-	if mk := env["MAX_KEYS"]; mk != "" {
-		if n, err := strconv.Atoi(mk); err == nil && n > 0 {
-			maxKeys = n
-		}
-	}
+	maxKeys := redisMaxKeys(env)
 
 	// Use SCAN instead of KEYS for safety
-	conn, err := p.connect(env)
-	if err != nil {
-		return "", err
-	}
-	defer conn.Close()
-
 	var allKeys []string
-	cursor := "0"
-	for {
-		resp, err := sendCommand(conn, "SCAN", cursor, "MATCH", pattern, "COUNT", "100")
-		if err != nil {
-			return "", err
-		}
-		// SCAN returns [cursor, [keys...]]
-		parts := strings.SplitN(resp, "\n", 2)
-		if len(parts) < 2 {
-			break
-		}
-		cursor = strings.TrimSpace(parts[0])
-		keyList := strings.TrimSpace(parts[1])
-		if keyList != "(empty)" && keyList != "" {
-			for _, k := range strings.Split(keyList, "\n") {
-				k = strings.TrimSpace(k)
-				if k != "" {
-					allKeys = append(allKeys, k)
+	err := p.withConn(env, func(conn net.Conn) error {
+		cursor := "0"
+		for {
+			resp, err := sendCommand(conn, "SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+			if err != nil {
+				return err
+			}
+			// SCAN returns [cursor, [keys...]]
+			parts := strings.SplitN(resp, "\n", 2)
+			if len(parts) < 2 {
+				return nil
+			}
+			cursor = strings.TrimSpace(parts[0])
+			keyList := strings.TrimSpace(parts[1])
+			if keyList != "(empty)" && keyList != "" {
+				for _, k := range strings.Split(keyList, "\n") {
+					k = strings.TrimSpace(k)
+					if k != "" {
+						allKeys = append(allKeys, k)
+					}
 				}
 			}
+			if cursor == "0" || len(allKeys) >= maxKeys {
+				return nil
+			}
 		}
-		if cursor == "0" || len(allKeys) >= maxKeys {
-			break
-		}
+	})
+	if err != nil {
+		return "", err
 	}
 
 	if len(allKeys) == 0 {
@@ -210,22 +290,203 @@ func (p *RedisProfile) redisInfo(args map[string]interface{}, env map[string]str
 	if section == "" {
 		section = "keyspace"
 	}
+	asJSON, err := redisJSONFormat(args)
+	if err != nil {
+		return "", err
+	}
+	var info string
 	if section == "all" {
-		return p.redisCmd(env, "INFO")
+		info, err = p.redisCmd(env, "INFO")
+	} else {
+		info, err = p.redisCmd(env, "INFO", section)
+	}
+	if err != nil || !asJSON {
+		return info, err
+	}
+	return jsonReply(parseRedisInfo(info))
+}
+
+// maxPipelineCommands caps the commands accepted by one redis_pipeline call
+const maxPipelineCommands = 100
+
+// redisReadCommands are the commands redis_pipeline runs in read-only mode
+var redisReadCommands = map[string]bool{
+	"GET": true, "MGET": true, "GETRANGE": true, "STRLEN": true, "EXISTS": true,
+	"TTL": true, "PTTL": true, "TYPE": true, "DBSIZE": true, "PING": true, "ECHO": true,
+	"HGET": true, "HMGET": true, "HGETALL": true, "HKEYS": true, "HVALS": true, "HLEN": true, "HEXISTS": true,
+	"LRANGE": true, "LLEN": true, "LINDEX": true,
+	"SMEMBERS": true, "SISMEMBER": true, "SCARD": true,
+	"ZRANGE": true, "ZREVRANGE": true, "ZRANGEBYSCORE": true, "ZSCORE": true, "ZRANK": true, "ZCARD": true, "ZCOUNT": true,
+	"SCAN": true, "HSCAN": true, "SSCAN": true, "ZSCAN": true,
+}
+
+// redisPipelineDenied are never run by redis_pipeline: they change
+// connection state the pipeline manages itself, block, or administer the
+// server rather than its data
+var redisPipelineDenied = map[string]bool{
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+	"AUTH": true, "SELECT": true, "HELLO": true, "RESET": true, "QUIT": true,
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true, "MONITOR": true, "SYNC": true, "PSYNC": true,
+	"BLPOP": true, "BRPOP": true, "BLMOVE": true, "BRPOPLPUSH": true, "BZPOPMIN": true, "BZPOPMAX": true, "BLMPOP": true, "BZMPOP": true,
+	"FLUSHALL": true, "FLUSHDB": true, "CONFIG": true, "SHUTDOWN": true, "DEBUG": true, "ACL": true,
+	"CLIENT": true, "MODULE": true, "REPLICAOF": true, "SLAVEOF": true, "SCRIPT": true, "FUNCTION": true,
+}
+
+func (p *RedisProfile) redisPipeline(args map[string]interface{}, env map[string]string) (string, error) {
+	list, ok := args["commands"].([]interface{})
+	if !ok || len(list) == 0 {
+		return "", invalidInputf("commands must be a non-empty array")
+	}
+	if len(list) > maxPipelineCommands {
+		return "", invalidInputf("at most %d commands per pipeline", maxPipelineCommands)
+	}
+
+	var commands [][]string
+	for i, item := range list {
+		var parts []string
+		switch v := item.(type) {
+		case string:
+			parts = strings.Fields(v)
+		case []interface{}:
+			for _, a := range v {
+				parts = append(parts, fmt.Sprintf("%v", a))
+			}
+		}
+		if len(parts) == 0 {
+			return "", invalidInputf("command %d is empty", i+1)
+		}
+		parts[0] = strings.ToUpper(parts[0])
+		if redisPipelineDenied[parts[0]] {
+			return "", forbiddenf("command %d: %s is not allowed in redis_pipeline", i+1, parts[0])
+		}
+		if !redisReadCommands[parts[0]] && ReadOnlyMode(env) {
+			return "", forbiddenf("command %d: %s requires READ_ONLY=false", i+1, parts[0])
+		}
+		commands = append(commands, parts)
 	}
-	return p.redisCmd(env, "INFO", section)
+	transaction, _ := args["transaction"].(bool)
+	asJSON, err := redisJSONFormat(args)
+	if err != nil {
+		return "", err
+	}
+
+	if IsDryRun(args) {
+		mode := "pipeline"
+		if transaction {
+			mode = "MULTI/EXEC transaction"
+		}
+		lines := make([]string, len(commands))
+		for i, c := range commands {
+			lines[i] = fmt.Sprintf("  %d. %s", i+1, strings.Join(c, " "))
+		}
+		return dryRunf("would run %d commands as a %s:\n%s", len(commands), mode, strings.Join(lines, "\n")), nil
+	}
+
+	replies := make([]interface{}, len(commands))
+	err = p.withConn(env, func(conn net.Conn) error {
+		return runPipeline(conn, commands, transaction, asJSON, replies)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if asJSON {
+		out := make([]map[string]interface{}, len(commands))
+		for i, c := range commands {
+			out[i] = map[string]interface{}{"command": strings.Join(c, " "), "reply": replies[i]}
+		}
+		return jsonReply(out)
+	}
+	var sb strings.Builder
+	for i, c := range commands {
+		reply := strings.ReplaceAll(replies[i].(string), "\n", "\n   ")
+		fmt.Fprintf(&sb, "%d. %s\n   %s\n", i+1, strings.Join(c, " "), reply)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// runPipeline sends commands in one write, then reads their replies into
+// replies, as text or, if structured, as readReply returns them. Error
+// replies to individual commands are recorded, not returned.
+func runPipeline(conn net.Conn, commands [][]string, transaction, structured bool, replies []interface{}) error {
+	// Send everything in one write, then read the replies in order
+	var buf strings.Builder
+	if transaction {
+		writeCommand(&buf, []string{"MULTI"})
+	}
+	for _, c := range commands {
+		writeCommand(&buf, c)
+	}
+	if transaction {
+		writeCommand(&buf, []string{"EXEC"})
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write([]byte(buf.String())); err != nil {
+		return backendErrorf("write failed: %s", err)
+	}
+	reader := bufio.NewReader(conn)
+
+	if transaction {
+		// MULTI answers OK and each command QUEUED (or an error, which
+		// makes EXEC abort); EXEC then answers with an array of replies
+		if _, err := readResp(reader); err != nil {
+			return backendErrorf("MULTI failed: %s", err)
+		}
+		var queueErr error
+		for range commands {
+			if _, err := readResp(reader); err != nil && queueErr == nil {
+				queueErr = err
+			}
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return backendErrorf("read failed: %s", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "-") {
+			if queueErr != nil {
+				return backendErrorf("transaction aborted: %s", queueErr)
+			}
+			return backendErrorf("transaction aborted: %s", line[1:])
+		}
+		if line == "*-1" {
+			return backendErrorf("transaction aborted")
+		}
+	}
+	for i := range commands {
+		var reply interface{}
+		var err error
+		if structured {
+			reply, err = readReply(reader)
+		} else {
+			reply, err = readResp(reader)
+		}
+		if err != nil {
+			var replyErr redisError
+			if !errors.As(err, &replyErr) {
+				return backendErrorf("%s", err)
+			}
+			if structured {
+				reply = map[string]interface{}{"error": string(replyErr)}
+			} else {
+				reply = "(error) " + string(replyErr)
+			}
+		}
+		replies[i] = reply
+	}
+	return nil
 }
 
 // Minimal RESP protocol client
 func (p *RedisProfile) connect(env map[string]string) (net.Conn, error) {
 	redisURL := env["REDIS_URL"]
 	if redisURL == "" {
-		return nil, fmt.Errorf("REDIS_URL is not configured")
+		return nil, notConfiguredf("REDIS_URL is not configured")
 	}
 
 	u, err := url.Parse(redisURL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid REDIS_URL: %s", err)
+		return nil, notConfiguredf("invalid REDIS_URL: %s", err)
 	}
 
 	host := u.Host
@@ -233,9 +494,13 @@ func (p *RedisProfile) connect(env map[string]string) (net.Conn, error) {
 		host += ":6379"
 	}
 
-	conn, err := net.DialTimeout("tcp", host, 5*time.Second)
+	conn, err := dialBackend(env, host, 5*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("connection failed: %s", err)
+		var toolErr *ToolError
+		if errors.As(err, &toolErr) {
+			return nil, err
+		}
+		return nil, backendErrorf("connection failed: %s", err)
 	}
 
 	// AUTH if password present
@@ -245,11 +510,23 @@ func (p *RedisProfile) connect(env map[string]string) (net.Conn, error) {
 			_, err := sendCommand(conn, "AUTH", pass)
 			if err != nil {
 				conn.Close()
-				return nil, fmt.Errorf("auth failed: %s", err)
+				return nil, backendErrorf("auth failed: %s", err)
 			}
 		}
 	}
 
+	// RESP3 applies to the whole connection, so the pool keeps such
+	// connections apart (see redisPoolKey)
+	if protocol, err := redisProtocol(env); err != nil {
+		conn.Close()
+		return nil, err
+	} else if protocol == 3 {
+		if _, err := sendCommandReply(conn, "HELLO", "3"); err != nil {
+			conn.Close()
+			return nil, backendErrorf("HELLO 3 failed (REDIS_PROTOCOL=3 needs Redis 6 or later): %s", err)
+		}
+	}
+
 	// SELECT database if path present
 	if u.Path != "" && u.Path != "/" {
 		db := strings.TrimPrefix(u.Path, "/")
@@ -257,7 +534,7 @@ func (p *RedisProfile) connect(env map[string]string) (net.Conn, error) {
 			_, err := sendCommand(conn, "SELECT", db)
 			if err != nil {
 				conn.Close()
-				return nil, fmt.Errorf("select db failed: %s", err)
+				return nil, backendErrorf("select db failed: %s", err)
 			}
 		}
 	}
@@ -266,27 +543,30 @@ func (p *RedisProfile) connect(env map[string]string) (net.Conn, error) {
 }
 
 func (p *RedisProfile) redisCmd(env map[string]string, cmd string, args ...string) (string, error) {
-	conn, err := p.connect(env)
-	if err != nil {
-		return "", err
-	}
-	defer conn.Close()
-
-	resp, err := sendCommand(conn, cmd, args...)
+	var resp string
+	err := p.withConn(env, func(conn net.Conn) error {
+		var err error
+		resp, err = sendCommand(conn, cmd, args...)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
 	return resp, nil
 }
 
-func sendCommand(conn net.Conn, cmd string, args ...string) (string, error) {
-	// Build RESP array
-	parts := append([]string{cmd}, args...)
-	var buf strings.Builder
+// writeCommand appends parts to buf as a RESP array
+func writeCommand(buf *strings.Builder, parts []string) {
 	buf.WriteString(fmt.Sprintf("*%d\r\n", len(parts)))
 	for _, p := range parts {
 		buf.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(p), p))
 	}
+}
+
+func sendCommand(conn net.Conn, cmd string, args ...string) (string, error) {
+	// Build RESP array
+	var buf strings.Builder
+	writeCommand(&buf, append([]string{cmd}, args...))
 
 	conn.SetDeadline(time.Now().Add(10 * time.Second))
 	_, err := conn.Write([]byte(buf.String()))
@@ -313,7 +593,7 @@ func readResp(reader *bufio.Reader) (string, error) {
 	case '+': // Simple string
 		return line[1:], nil
 	case '-': // Error
-		return "", fmt.Errorf("redis error: %s", line[1:])
+		return "", redisError(line[1:])
 	case ':': // Integer
 		return line[1:], nil
 	case '$': // Bulk string
@@ -327,11 +607,41 @@ func readResp(reader *bufio.Reader) (string, error) {
 			return "", fmt.Errorf("read bulk failed: %s", err)
 		}
 		return string(data[:length]), nil
-	case '*': // Array
+	case '_': // RESP3 null
+		return "(nil)", nil
+	case '#': // RESP3 boolean
+		return strconv.FormatBool(line[1:] == "t"), nil
+	case ',', '(': // RESP3 double and big number
+		return line[1:], nil
+	case '=', '!': // RESP3 verbatim string and blob error
+		length, _ := strconv.Atoi(line[1:])
+		data, err := readBulk(reader, length)
+		if err != nil {
+			return "", err
+		}
+		if line[0] == '!' {
+			return "", redisError(data)
+		}
+		if len(data) >= 4 && data[3] == ':' {
+			data = data[4:]
+		}
+		return data, nil
+	case '|': // RESP3 attributes, skipped
+		count, _ := strconv.Atoi(line[1:])
+		for i := 0; i < 2*count; i++ {
+			if _, err := readResp(reader); err != nil {
+				return "", err
+			}
+		}
+		return readResp(reader)
+	case '*', '~', '>', '%': // Array, and RESP3 set, push and map (keys and values in turn)
 		count, _ := strconv.Atoi(line[1:])
 		if count == -1 {
 			return "(empty)", nil
 		}
+		if line[0] == '%' {
+			count *= 2
+		}
 		var items []string
 		for i := 0; i < count; i++ {
 			item, err := readResp(reader)
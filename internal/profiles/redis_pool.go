@@ -0,0 +1,131 @@
+package profiles
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Idle connections kept per REDIS_URL, and how long one may sit unused
+const (
+	redisMaxIdle     = 4
+	redisIdleTimeout = time.Minute
+)
+
+// redisError is an error reply from the server. The connection it came on
+// is still in a clean state and can be reused.
+type redisError string
+
+func (e redisError) Error() string { return "redis error: " + string(e) }
+
+type redisIdleConn struct {
+	conn  net.Conn
+	since time.Time
+}
+
+// redisPool keeps connections that are already authenticated and on the
+// right database, keyed by the full REDIS_URL so AUTH and SELECT state always
+// matches the URL a call is made with
+type redisPool struct {
+	mu   sync.Mutex
+	idle map[string][]redisIdleConn
+}
+
+// get returns an idle connection for redisURL that still answers PING, or nil
+func (pool *redisPool) get(redisURL string) net.Conn {
+	for {
+		pool.mu.Lock()
+		conns := pool.idle[redisURL]
+		if len(conns) == 0 {
+			pool.mu.Unlock()
+			return nil
+		}
+		c := conns[len(conns)-1]
+		pool.idle[redisURL] = conns[:len(conns)-1]
+		pool.mu.Unlock()
+
+		if time.Since(c.since) < redisIdleTimeout {
+			if pong, err := sendCommand(c.conn, "PING"); err == nil && pong == "PONG" {
+				return c.conn
+			}
+		}
+		c.conn.Close()
+	}
+}
+
+// put returns conn to the pool, closing it if the pool is full. Connections
+// of other URLs that have idled too long are closed along the way.
+func (pool *redisPool) put(redisURL string, conn net.Conn) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.idle == nil {
+		pool.idle = map[string][]redisIdleConn{}
+	}
+	now := time.Now()
+	for u, conns := range pool.idle {
+		fresh := conns[:0]
+		for _, c := range conns {
+			if now.Sub(c.since) < redisIdleTimeout {
+				fresh = append(fresh, c)
+			} else {
+				c.conn.Close()
+			}
+		}
+		if len(fresh) == 0 {
+			delete(pool.idle, u)
+		} else {
+			pool.idle[u] = fresh
+		}
+	}
+	if len(pool.idle[redisURL]) >= redisMaxIdle {
+		conn.Close()
+		return
+	}
+	pool.idle[redisURL] = append(pool.idle[redisURL], redisIdleConn{conn: conn, since: now})
+}
+
+// redisPoolKey is the pool key of a connection's Redis connections: its
+// REDIS_URL, apart for RESP3 ones and ones through an SSH bastion. Tunnelled
+// connections are only shared between identical SSH settings (key, host key
+// pins and all), so none is reused by a connection that couldn't open it.
+func redisPoolKey(env map[string]string) (string, error) {
+	key := env["REDIS_URL"]
+	if env["REDIS_PROTOCOL"] == "3" {
+		key += "#resp3"
+	}
+	tunnel, err := sshTunnelFor(env)
+	if err != nil {
+		return "", err
+	}
+	if tunnel != nil {
+		key += "#ssh:" + tunnel.key
+	}
+	return key, nil
+}
+
+// withConn runs fn on a pooled connection, or a new one if none is idle. The
+// connection goes back to the pool unless fn failed with anything other than
+// an error reply, since the stream may then be out of step.
+func (p *RedisProfile) withConn(env map[string]string, fn func(conn net.Conn) error) error {
+	redisURL, err := redisPoolKey(env)
+	if err != nil {
+		return err
+	}
+	conn := p.pool.get(redisURL)
+	if conn == nil {
+		conn, err = p.connect(env)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = fn(conn)
+	var replyErr redisError
+	if err == nil || errors.As(err, &replyErr) {
+		p.pool.put(redisURL, conn)
+	} else {
+		conn.Close()
+	}
+	return err
+}
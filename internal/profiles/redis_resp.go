@@ -0,0 +1,217 @@
+package profiles
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Replies are rendered as text by default, flattened the way redis-cli
+// prints them. With format=json they keep their shape instead: arrays,
+// integers, nulls and, over RESP3 (REDIS_PROTOCOL=3), maps, sets, booleans
+// and doubles, with an error inside an array as {"error": "..."}.
+
+// redisFormatProperty is the schema of the format argument
+func redisFormatProperty() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"enum":        []string{"text", "json"},
+		"description": "text: the reply as redis-cli prints it (default); json: the reply's structure as JSON (arrays, integers, null, and maps over RESP3)",
+	}
+}
+
+// redisJSONFormat reports whether the call asked for format=json
+func redisJSONFormat(args map[string]interface{}) (bool, error) {
+	switch f := getStr(args, "format"); f {
+	case "", "text":
+		return false, nil
+	case "json":
+		return true, nil
+	default:
+		return false, invalidInputf("format must be text or json, got %q", f)
+	}
+}
+
+// redisProtocol returns the REDIS_PROTOCOL the connection asks for
+func redisProtocol(env map[string]string) (int, error) {
+	switch v := env["REDIS_PROTOCOL"]; v {
+	case "", "2":
+		return 2, nil
+	case "3":
+		return 3, nil
+	default:
+		return 0, notConfiguredf("REDIS_PROTOCOL must be 2 or 3, got %q", v)
+	}
+}
+
+// sendCommandReply is sendCommand returning the reply's structure
+func sendCommandReply(conn net.Conn, cmd string, args ...string) (interface{}, error) {
+	var buf strings.Builder
+	writeCommand(&buf, append([]string{cmd}, args...))
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write([]byte(buf.String())); err != nil {
+		return nil, fmt.Errorf("write failed: %s", err)
+	}
+	return readReply(bufio.NewReader(conn))
+}
+
+// redisCmdReply is redisCmd returning the reply's structure
+func (p *RedisProfile) redisCmdReply(env map[string]string, cmd string, args ...string) (interface{}, error) {
+	var reply interface{}
+	err := p.withConn(env, func(conn net.Conn) error {
+		var err error
+		reply, err = sendCommandReply(conn, cmd, args...)
+		return err
+	})
+	return reply, err
+}
+
+// readReply reads one RESP2 or RESP3 reply as JSON-encodable values. An
+// error reply is returned as a redisError; one nested in an aggregate is kept
+// in place as {"error": message}.
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %s", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		if n, err := strconv.ParseInt(line[1:], 10, 64); err == nil {
+			return n, nil
+		}
+		return line[1:], nil
+	case '_': // RESP3 null
+		return nil, nil
+	case '#': // RESP3 boolean
+		return line[1:] == "t", nil
+	case ',': // RESP3 double; infinities and NaN have no JSON number
+		if f, err := strconv.ParseFloat(line[1:], 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f, nil
+		}
+		return line[1:], nil
+	case '(': // RESP3 big number
+		return json.Number(line[1:]), nil
+	case '$', '=', '!':
+		length, _ := strconv.Atoi(line[1:])
+		if length < 0 {
+			return nil, nil
+		}
+		data, err := readBulk(reader, length)
+		if err != nil {
+			return nil, err
+		}
+		switch line[0] {
+		case '=': // verbatim string, after its three-letter format ("txt:")
+			if len(data) >= 4 && data[3] == ':' {
+				data = data[4:]
+			}
+		case '!': // blob error
+			return nil, redisError(data)
+		}
+		return data, nil
+	case '*', '~', '>': // array, RESP3 set and push
+		count, _ := strconv.Atoi(line[1:])
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			item, err := readReplyItem(reader)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case '%', '|': // RESP3 map, and attributes, which precede the reply they describe
+		count, _ := strconv.Atoi(line[1:])
+		m := make(map[string]interface{}, count)
+		for i := 0; i < count; i++ {
+			key, err := readReplyItem(reader)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readReplyItem(reader)
+			if err != nil {
+				return nil, err
+			}
+			m[formValue(key)] = value
+		}
+		if line[0] == '|' {
+			return readReply(reader)
+		}
+		return m, nil
+	}
+	return line, nil
+}
+
+// readReplyItem reads an element of an aggregate reply, keeping an error
+// reply in place
+func readReplyItem(reader *bufio.Reader) (interface{}, error) {
+	item, err := readReply(reader)
+	var replyErr redisError
+	if errors.As(err, &replyErr) {
+		return map[string]interface{}{"error": string(replyErr)}, nil
+	}
+	return item, err
+}
+
+// readBulk reads a bulk payload of length bytes and its trailing CRLF
+func readBulk(reader *bufio.Reader, length int) (string, error) {
+	data := make([]byte, length+2)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", fmt.Errorf("read bulk failed: %s", err)
+	}
+	return string(data[:length]), nil
+}
+
+// jsonReply renders a structured reply for format=json
+func jsonReply(reply interface{}) (string, error) {
+	data, err := json.MarshalIndent(reply, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseRedisInfo turns INFO's "# Section" headers and key:value lines into
+// an object of sections
+func parseRedisInfo(info string) map[string]interface{} {
+	sections := map[string]interface{}{}
+	current := map[string]interface{}{}
+	sections["default"] = current
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			current = map[string]interface{}{}
+			sections[strings.ToLower(strings.TrimSpace(line[1:]))] = current
+		default:
+			if k, v, ok := strings.Cut(line, ":"); ok {
+				current[k] = v
+			}
+		}
+	}
+	if len(sections["default"].(map[string]interface{})) == 0 {
+		delete(sections, "default")
+	}
+	return sections
+}
@@ -0,0 +1,150 @@
+package profiles
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// redisScanTools iterate over one hash, set or sorted set with HSCAN, SSCAN
+// or ZSCAN, like redis_keys does over the keyspace with SCAN, so a large
+// collection is read in small batches without blocking the server.
+var redisScanTools = map[string]struct {
+	command string
+	kind    string // what the key holds, for messages
+	pairs   bool   // whether the reply alternates entries and their values
+}{
+	"redis_hscan": {"HSCAN", "Hash", true},
+	"redis_sscan": {"SSCAN", "Set", false},
+	"redis_zscan": {"ZSCAN", "Sorted set", true},
+}
+
+func redisScanTool(name, description string) Tool {
+	return Tool{
+		Name:        name,
+		Description: description,
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"key":     map[string]interface{}{"type": "string", "description": "Key of the collection"},
+				"pattern": map[string]interface{}{"type": "string", "description": "Pattern entries must match (e.g. 'user:*'). Default '*'"},
+				"format":  redisFormatProperty(),
+			},
+			"required": []string{"key"},
+		},
+	}
+}
+
+// redisMaxKeys returns the connection's MAX_KEYS, the most keys or entries a
+// call returns
+func redisMaxKeys(env map[string]string) int {
+	if n, err := strconv.Atoi(env["MAX_KEYS"]); err == nil && n > 0 {
+		return n
+	}
+	return 100
+}
+
+func (p *RedisProfile) redisScan(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	tool := redisScanTools[name]
+	key := getStr(args, "key")
+	if key == "" {
+		return "", invalidInputf("key is required")
+	}
+	pattern := getStr(args, "pattern")
+	if pattern == "" {
+		pattern = "*"
+	}
+	asJSON, err := redisJSONFormat(args)
+	if err != nil {
+		return "", err
+	}
+	maxEntries := redisMaxKeys(env)
+
+	// Entries, and for hashes and sorted sets each followed by its value
+	var items []string
+	more := false
+	err = p.withConn(env, func(conn net.Conn) error {
+		cursor := "0"
+		for {
+			reply, err := sendCommandReply(conn, tool.command, key, cursor, "MATCH", pattern, "COUNT", "100")
+			if err != nil {
+				return err
+			}
+			// xSCAN returns [cursor, [entries...]]
+			parts, ok := reply.([]interface{})
+			if !ok || len(parts) < 2 {
+				return backendErrorf("unexpected %s reply", tool.command)
+			}
+			cursor = formValue(parts[0])
+			batch, _ := parts[1].([]interface{})
+			for _, item := range batch {
+				items = append(items, formValue(item))
+			}
+			if cursor == "0" {
+				return nil
+			}
+			if entries := len(items); (tool.pairs && entries/2 >= maxEntries) || (!tool.pairs && entries >= maxEntries) {
+				more = true
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	step := 1
+	if tool.pairs {
+		step = 2
+	}
+	if len(items)/step > maxEntries {
+		items = items[:maxEntries*step]
+		more = true
+	}
+
+	if asJSON {
+		var entries interface{}
+		switch tool.command {
+		case "HSCAN":
+			fields := make(map[string]string, len(items)/2)
+			for i := 0; i+1 < len(items); i += 2 {
+				fields[items[i]] = items[i+1]
+			}
+			entries = fields
+		case "ZSCAN":
+			members := make([]map[string]interface{}, 0, len(items)/2)
+			for i := 0; i+1 < len(items); i += 2 {
+				var score interface{} = items[i+1]
+				if f, err := strconv.ParseFloat(items[i+1], 64); err == nil {
+					score = f
+				}
+				members = append(members, map[string]interface{}{"member": items[i], "score": score})
+			}
+			entries = members
+		default:
+			entries = items
+		}
+		return jsonReply(map[string]interface{}{"key": key, "entries": entries, "truncated": more})
+	}
+
+	if len(items) == 0 {
+		return fmt.Sprintf("%s '%s' has no entries matching '%s' (or does not exist)", tool.kind, key, pattern), nil
+	}
+	lines := make([]string, 0, len(items)/step)
+	for i := 0; i+step-1 < len(items); i += step {
+		switch tool.command {
+		case "HSCAN":
+			lines = append(lines, items[i]+": "+items[i+1])
+		case "ZSCAN":
+			lines = append(lines, items[i]+" (score "+items[i+1]+")")
+		default:
+			lines = append(lines, items[i])
+		}
+	}
+	out := fmt.Sprintf("%s '%s' entries matching '%s' (%d):\n%s", tool.kind, key, pattern, len(lines), strings.Join(lines, "\n"))
+	if more {
+		out += fmt.Sprintf("\n\n(stopped at MAX_KEYS=%d; use a narrower pattern to see others)", maxEntries)
+	}
+	return out, nil
+}
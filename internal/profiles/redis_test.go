@@ -0,0 +1,252 @@
+package profiles
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeRedis is a RESP server for a few string commands, with MULTI/EXEC
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+	log  []string // commands run, in order
+}
+
+// serve listens on loopback and returns a REDIS_URL for it
+func (f *fakeRedis) serve(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return "redis://" + ln.Addr().String()
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var queued [][]string
+	inMulti := false
+	for {
+		cmd, err := readFakeCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(cmd[0])
+		if name == "MULTI" || name == "EXEC" {
+			f.mu.Lock()
+			f.log = append(f.log, name)
+			f.mu.Unlock()
+		}
+		switch {
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			fmt.Fprint(conn, "+OK\r\n")
+		case name == "EXEC":
+			fmt.Fprintf(conn, "*%d\r\n", len(queued))
+			for _, c := range queued {
+				fmt.Fprint(conn, f.run(c))
+			}
+			inMulti = false
+		case inMulti:
+			queued = append(queued, cmd)
+			fmt.Fprint(conn, "+QUEUED\r\n")
+		default:
+			fmt.Fprint(conn, f.run(cmd))
+		}
+	}
+}
+
+// run executes cmd and returns its RESP reply
+func (f *fakeRedis) run(cmd []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := strings.ToUpper(cmd[0])
+	if name == "PING" {
+		// The pool checks idle connections before reusing them
+		return "+PONG\r\n"
+	}
+	f.log = append(f.log, strings.Join(cmd, " "))
+	switch {
+	case name == "GET" && len(cmd) == 2:
+		v, ok := f.data[cmd[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case name == "SET" && len(cmd) == 3:
+		f.data[cmd[1]] = cmd[2]
+		return "+OK\r\n"
+	case name == "INCR" && len(cmd) == 2:
+		n, err := strconv.Atoi(f.data[cmd[1]])
+		if err != nil && f.data[cmd[1]] != "" {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		f.data[cmd[1]] = strconv.Itoa(n + 1)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd[0])
+}
+
+func readFakeCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	cmd := make([]string, n)
+	for i := range cmd {
+		if _, err := r.ReadString('\n'); err != nil { // $<len>
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		cmd[i] = strings.TrimRight(arg, "\r\n")
+	}
+	return cmd, nil
+}
+
+func TestRedisPipeline(t *testing.T) {
+	fake := &fakeRedis{data: map[string]string{}}
+	redisURL := fake.serve(t)
+	p := &RedisProfile{}
+
+	tests := []struct {
+		name     string
+		args     map[string]interface{}
+		readOnly string
+		want     []string
+		wantLog  []string
+		wantErr  string
+	}{
+		{
+			name:     "GET/SET/GET",
+			args:     map[string]interface{}{"commands": []interface{}{"GET greeting", []interface{}{"SET", "greeting", "hello world"}, "GET greeting"}},
+			readOnly: "false",
+			want:     []string{"1. GET greeting\n   (nil)", "2. SET greeting hello world\n   OK", "3. GET greeting\n   hello world"},
+			wantLog:  []string{"GET greeting", "SET greeting hello world", "GET greeting"},
+		},
+		{
+			name:     "transaction",
+			args:     map[string]interface{}{"commands": []interface{}{"INCR hits", "INCR hits", "GET hits"}, "transaction": true},
+			readOnly: "false",
+			want:     []string{"1. INCR hits\n   1", "2. INCR hits\n   2", "3. GET hits\n   2"},
+			wantLog:  []string{"MULTI", "EXEC", "INCR hits", "INCR hits", "GET hits"},
+		},
+		{
+			name:     "error reply of one command",
+			args:     map[string]interface{}{"commands": []interface{}{"INCR greeting", "GET hits"}},
+			readOnly: "false",
+			want:     []string{"1. INCR greeting\n   (error) ERR value is not an integer", "2. GET hits\n   2"},
+			wantLog:  []string{"INCR greeting", "GET hits"},
+		},
+		{
+			name:    "write in read-only mode",
+			args:    map[string]interface{}{"commands": []interface{}{"GET a", "SET a b"}},
+			wantErr: "command 2: SET requires READ_ONLY=false",
+		},
+		{
+			name:     "denied command",
+			args:     map[string]interface{}{"commands": []interface{}{"FLUSHALL"}},
+			readOnly: "false",
+			wantErr:  "FLUSHALL is not allowed",
+		},
+		{
+			name:    "empty",
+			args:    map[string]interface{}{"commands": []interface{}{}},
+			wantErr: "non-empty array",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.mu.Lock()
+			fake.log = nil
+			fake.mu.Unlock()
+			env := map[string]string{"REDIS_URL": redisURL, "READ_ONLY": tt.readOnly}
+			out, err := p.CallTool("redis_pipeline", tt.args, env)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output lacks %q:\n%s", want, out)
+				}
+			}
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if strings.Join(fake.log, "|") != strings.Join(tt.wantLog, "|") {
+				t.Errorf("server ran %q, want %q", fake.log, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestRedisPoolSSHSettings(t *testing.T) {
+	keyPEM, clientPub := testClientKey(t)
+	bastion := startTestBastion(t, clientPub)
+	fake := &fakeRedis{data: map[string]string{"greeting": "hello"}}
+	redisURL := fake.serve(t)
+	otherKeyPEM, _ := testClientKey(t)
+	_, otherHost, _ := ed25519.GenerateKey(rand.Reader)
+	otherHostKey, _ := ssh.NewPublicKey(otherHost.Public())
+	pinned := string(ssh.MarshalAuthorizedKey(bastion.hostKey))
+	p := &RedisProfile{}
+
+	// Each step shares REDIS_URL, SSH_HOST and SSH_USER with the first, whose
+	// connection is back in the pool when the others run
+	steps := []struct {
+		name    string
+		key     string
+		known   string
+		wantErr string
+	}{
+		{"authenticated", keyPEM, pinned, ""},
+		{"wrong client key", otherKeyPEM, pinned, "unable to authenticate"},
+		{"other host key pinned", keyPEM, string(ssh.MarshalAuthorizedKey(otherHostKey)), "not in SSH_KNOWN_HOSTS"},
+		{"invalid key", "not a key", pinned, "invalid SSH_KEY"},
+		{"authenticated again", keyPEM, pinned, ""},
+	}
+	for _, st := range steps {
+		env := map[string]string{"REDIS_URL": redisURL, "SSH_HOST": bastion.addr, "SSH_USER": "tunnel", "SSH_KEY": st.key, "SSH_KNOWN_HOSTS": st.known}
+		out, err := p.CallTool("redis_pipeline", map[string]interface{}{"commands": []interface{}{"GET greeting"}}, env)
+		if st.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), st.wantErr) {
+				t.Errorf("%s: err = %v, output %q; want %q", st.name, err, out, st.wantErr)
+			}
+			continue
+		}
+		if err != nil || !strings.Contains(out, "hello") {
+			t.Errorf("%s: err = %v, output %q", st.name, err, out)
+		}
+	}
+}
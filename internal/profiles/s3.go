@@ -0,0 +1,536 @@
+package profiles
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// S3Profile talks to S3-compatible object storage (AWS, MinIO, R2, ...) using
+// path-style requests signed with AWS Signature V4.
+type S3Profile struct{}
+
+func (p *S3Profile) ID() string       { return "s3" }
+func (p *S3Profile) HasBackend() bool { return true }
+
+func (p *S3Profile) Tools() []Tool {
+	return []Tool{
+		{
+			Name:        "list_objects",
+			Description: "List objects in the bucket",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"prefix": map[string]interface{}{
+						"type":        "string",
+						"description": "Only list keys starting with this prefix",
+					},
+					"delimiter": map[string]interface{}{
+						"type":        "string",
+						"description": "Group keys by this delimiter (e.g. '/') to browse like folders",
+					},
+					"max_keys": map[string]interface{}{
+						"type":        "number",
+						"description": "Maximum keys to return (default 100, max 1000)",
+					},
+					"continuation_token": map[string]interface{}{
+						"type":        "string",
+						"description": "Token from a previous truncated listing",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get_object",
+			Description: "Read an object. Text is returned as-is, binary content as base64.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"key": map[string]interface{}{
+						"type":        "string",
+						"description": "Object key",
+					},
+				},
+				"required": []string{"key"},
+			},
+		},
+		{
+			Name:        "put_object",
+			Description: "Write an object (requires READ_ONLY=false)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"key": map[string]interface{}{
+						"type":        "string",
+						"description": "Object key",
+					},
+					"content": map[string]interface{}{
+						"type":        "string",
+						"description": "Object content",
+					},
+					"encoding": map[string]interface{}{
+						"type":        "string",
+						"description": "Content encoding: text (default) or base64",
+						"enum":        []string{"text", "base64"},
+					},
+					"content_type": map[string]interface{}{
+						"type":        "string",
+						"description": "Content-Type (default text/plain or application/octet-stream)",
+					},
+				},
+				"required": []string{"key", "content"},
+			},
+		},
+		{
+			Name:        "presign_url",
+			Description: "Create a time-limited URL for downloading (GET) or uploading (PUT, requires READ_ONLY=false) an object",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"key": map[string]interface{}{
+						"type":        "string",
+						"description": "Object key",
+					},
+					"method": map[string]interface{}{
+						"type":        "string",
+						"description": "GET (default) or PUT",
+						"enum":        []string{"GET", "PUT"},
+					},
+					"expires_seconds": map[string]interface{}{
+						"type":        "number",
+						"description": "URL lifetime in seconds (default 3600, max 604800)",
+					},
+				},
+				"required": []string{"key"},
+			},
+		},
+	}
+}
+
+func (p *S3Profile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "S3_BUCKET", Required: true, Description: "Bucket name"},
+		{Name: "S3_ACCESS_KEY", Required: true, Description: "Access key ID"},
+		{Name: "S3_SECRET_KEY", Required: true, Description: "Secret access key"},
+		{Name: "S3_ENDPOINT", Required: false, Description: "Endpoint URL for MinIO/R2/etc. (default AWS regional endpoint)"},
+		{Name: "S3_REGION", Required: false, Description: "Signing region (default us-east-1)"},
+		{Name: "READ_ONLY", Required: false, Description: "Set to false to allow put_object and PUT presigning (default true)"},
+		{Name: "MAX_OBJECT_SIZE", Required: false, Description: "Maximum bytes returned by get_object (default 1MB)"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 60, max 300)"},
+	}
+}
+
+func (p *S3Profile) DryRunTools() []string {
+	return []string{"put_object"}
+}
+
+func (p *S3Profile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	cfg, err := s3ConfigFromEnv(env)
+	if err != nil {
+		return "", err
+	}
+
+	readOnly := ReadOnlyMode(env)
+
+	switch name {
+	case "list_objects":
+		return p.listObjects(cfg, args)
+	case "get_object":
+		return p.getObject(cfg, args, env)
+	case "put_object":
+		if readOnly {
+			return "", forbiddenf("put_object requires READ_ONLY=false")
+		}
+		return p.putObject(cfg, args)
+	case "presign_url":
+		return p.presignURL(cfg, args, readOnly)
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+type s3Config struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	timeout   time.Duration
+}
+
+func s3ConfigFromEnv(env map[string]string) (*s3Config, error) {
+	cfg := &s3Config{
+		timeout:   httpTimeout(env, 60*time.Second),
+		bucket:    env["S3_BUCKET"],
+		region:    env["S3_REGION"],
+		accessKey: env["S3_ACCESS_KEY"],
+		secretKey: env["S3_SECRET_KEY"],
+	}
+	if cfg.bucket == "" || cfg.accessKey == "" || cfg.secretKey == "" {
+		return nil, notConfiguredf("S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required")
+	}
+	if cfg.region == "" {
+		cfg.region = "us-east-1"
+	}
+
+	endpoint := env["S3_ENDPOINT"]
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, notConfiguredf("invalid S3_ENDPOINT: %s", endpoint)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	cfg.endpoint = u
+	return cfg, nil
+}
+
+// objectURL builds a path-style URL, which both AWS and MinIO accept
+func (c *s3Config) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path = c.endpoint.Path + "/" + c.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = c.endpoint.Path + "/" + awsURIEncode(c.bucket, true)
+	if key != "" {
+		u.RawPath += "/" + awsURIEncode(key, false)
+	}
+	u.RawQuery = awsCanonicalQuery(query)
+	return &u
+}
+
+func (c *s3Config) do(method, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	u := c.objectURL(key, query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %s", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	payloadHash := sha256Hex(body)
+	now := time.Now().UTC()
+	req.Header.Set("x-amz-date", now.Format("20060102T150405Z"))
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if _, ok := headers["Content-Type"]; ok {
+		signed = append([]string{"content-type"}, signed...)
+	}
+	var canonHeaders strings.Builder
+	for _, h := range signed {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = u.Host
+		}
+		canonHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonical := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		canonHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope, signature := c.sign(now, canonical)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+
+	resp, err := privateHTTPClient(c.timeout).Do(req)
+	if err != nil {
+		return nil, backendErrorf("S3 request failed: %s", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var s3err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.Unmarshal(data, &s3err) == nil && s3err.Code != "" {
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, invalidInputf("%s: %s", s3err.Code, s3err.Message)
+			}
+			return nil, backendErrorf("S3 %d %s: %s", resp.StatusCode, s3err.Code, s3err.Message)
+		}
+		return nil, backendErrorf("S3 returned HTTP %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// sign returns the credential scope and the hex signature of a canonical request
+func (c *s3Config) sign(t time.Time, canonical string) (string, string) {
+	date := t.Format("20060102")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format("20060102T150405Z"),
+		scope,
+		sha256Hex([]byte(canonical)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func (p *S3Profile) listObjects(cfg *s3Config, args map[string]interface{}) (string, error) {
+	maxKeys := int(getFloat(args, "max_keys"))
+	if maxKeys <= 0 {
+		maxKeys = 100
+	}
+	if maxKeys > 1000 {
+		maxKeys = 1000
+	}
+
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("max-keys", strconv.Itoa(maxKeys))
+	if prefix := getStr(args, "prefix"); prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if delim := getStr(args, "delimiter"); delim != "" {
+		query.Set("delimiter", delim)
+	}
+	if token := getStr(args, "continuation_token"); token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	resp, err := cfg.do("GET", "", query, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+		Contents              []struct {
+			Key          string `xml:"Key"`
+			LastModified string `xml:"LastModified"`
+			Size         int64  `xml:"Size"`
+		} `xml:"Contents"`
+		CommonPrefixes []struct {
+			Prefix string `xml:"Prefix"`
+		} `xml:"CommonPrefixes"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 10*1024*1024)).Decode(&result); err != nil {
+		return "", backendErrorf("failed to parse listing: %s", err)
+	}
+
+	if len(result.Contents) == 0 && len(result.CommonPrefixes) == 0 {
+		return "No objects found", nil
+	}
+
+	var lines []string
+	for _, cp := range result.CommonPrefixes {
+		lines = append(lines, fmt.Sprintf("%-10s %-20s %s", "DIR", "", cp.Prefix))
+	}
+	for _, obj := range result.Contents {
+		modified := obj.LastModified
+		if t, err := time.Parse(time.RFC3339, obj.LastModified); err == nil {
+			modified = t.UTC().Format("2006-01-02 15:04:05")
+		}
+		lines = append(lines, fmt.Sprintf("%-10s %-20s %s", humanBytes(float64(obj.Size)), modified, obj.Key))
+	}
+
+	out := fmt.Sprintf("Bucket %s (%d objects, %d prefixes):\n\n%s",
+		cfg.bucket, len(result.Contents), len(result.CommonPrefixes), strings.Join(lines, "\n"))
+	if result.IsTruncated {
+		out += fmt.Sprintf("\n\n... more results, continuation_token: %s", result.NextContinuationToken)
+	}
+	return out, nil
+}
+
+func (p *S3Profile) getObject(cfg *s3Config, args map[string]interface{}, env map[string]string) (string, error) {
+	key := getStr(args, "key")
+	if key == "" {
+		return "", invalidInputf("key is required")
+	}
+
+	maxSize := envInt(env["MAX_OBJECT_SIZE"], 1024*1024)
+	resp, err := cfg.do("GET", key, nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > int64(maxSize) {
+		return "", invalidInputf("object is %s, larger than MAX_OBJECT_SIZE (%s)", humanBytes(float64(resp.ContentLength)), humanBytes(float64(maxSize)))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return "", backendErrorf("read failed: %s", err)
+	}
+	if len(data) > maxSize {
+		return "", invalidInputf("object is larger than MAX_OBJECT_SIZE (%s)", humanBytes(float64(maxSize)))
+	}
+
+	header := fmt.Sprintf("Key: %s\nSize: %d\nContent-Type: %s\nLast-Modified: %s\n",
+		key, len(data), resp.Header.Get("Content-Type"), resp.Header.Get("Last-Modified"))
+	if utf8.Valid(data) && !bytes.ContainsRune(data, 0) {
+		return header + "\n" + string(data), nil
+	}
+	return header + "Encoding: base64\n\n" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (p *S3Profile) putObject(cfg *s3Config, args map[string]interface{}) (string, error) {
+	key := getStr(args, "key")
+	if key == "" {
+		return "", invalidInputf("key is required")
+	}
+	content := getStr(args, "content")
+
+	var body []byte
+	contentType := getStr(args, "content_type")
+	switch getStr(args, "encoding") {
+	case "", "text":
+		body = []byte(content)
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return "", invalidInputf("invalid base64 content: %s", err)
+		}
+		body = decoded
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	default:
+		return "", invalidInputf("encoding must be text or base64")
+	}
+
+	if IsDryRun(args) {
+		return dryRunf("would upload %s to s3://%s/%s (%s)", humanBytes(float64(len(body))), cfg.bucket, key, contentType), nil
+	}
+
+	resp, err := cfg.do("PUT", key, nil, body, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	return fmt.Sprintf("Uploaded %s (%s, %s)\nETag: %s", key, humanBytes(float64(len(body))), contentType, resp.Header.Get("ETag")), nil
+}
+
+func (p *S3Profile) presignURL(cfg *s3Config, args map[string]interface{}, readOnly bool) (string, error) {
+	key := getStr(args, "key")
+	if key == "" {
+		return "", invalidInputf("key is required")
+	}
+	method := strings.ToUpper(getStr(args, "method"))
+	if method == "" {
+		method = "GET"
+	}
+	if method != "GET" && method != "PUT" {
+		return "", invalidInputf("method must be GET or PUT")
+	}
+	if method == "PUT" && readOnly {
+		return "", forbiddenf("presigning uploads requires READ_ONLY=false")
+	}
+
+	expires := int(getFloat(args, "expires_seconds"))
+	if expires <= 0 {
+		expires = 3600
+	}
+	if expires > 604800 {
+		expires = 604800
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", cfg.accessKey+"/"+date+"/"+cfg.region+"/s3/aws4_request")
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(expires))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	u := cfg.objectURL(key, query)
+	canonical := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	_, signature := cfg.sign(now, canonical)
+	u.RawQuery += "&X-Amz-Signature=" + signature
+
+	return fmt.Sprintf("%s %s\nExpires: %s (%ds)", method, u.String(),
+		now.Add(time.Duration(expires)*time.Second).Format(time.RFC3339), expires), nil
+}
+
+// awsURIEncode percent-encodes s as SigV4 requires: everything except
+// unreserved characters, with '/' kept when encoding object key paths
+func awsURIEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			sb.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return sb.String()
+}
+
+func awsCanonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,128 @@
+package profiles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// sequenceMaxSteps bounds the tool calls one run_sequence makes
+const sequenceMaxSteps = 20
+
+// ToolCaller calls another tool of the same connection, with the checks a
+// client's call gets. isError reports a failed tool call, whose output is
+// the error text; err is for calls that couldn't be made at all.
+type ToolCaller func(ctx context.Context, name string, args map[string]interface{}) (output string, isError bool, err error)
+
+type toolCallerKey struct{}
+
+// WithToolCaller returns a context that lets the tool being called call
+// other tools through call
+func WithToolCaller(ctx context.Context, call ToolCaller) context.Context {
+	return context.WithValue(ctx, toolCallerKey{}, call)
+}
+
+type sequenceStep struct {
+	tool string
+	args map[string]interface{}
+}
+
+type sequenceResult struct {
+	Step   int    `json:"step"`
+	Tool   string `json:"tool"`
+	Status string `json:"status"` // ok, error or skipped
+	Output string `json:"output,omitempty"`
+}
+
+// runSequence calls the steps' tools one after another, sharing nothing
+// between them but their order, and reports every step's outcome
+func (p *DiagnosticsProfile) runSequence(ctx context.Context, args map[string]interface{}) (string, error) {
+	call, _ := ctx.Value(toolCallerKey{}).(ToolCaller)
+	if call == nil {
+		return "", notConfiguredf("run_sequence is not available on this transport")
+	}
+
+	rawSteps, ok := args["steps"].([]interface{})
+	if !ok || len(rawSteps) == 0 {
+		return "", invalidInputf("steps must be a non-empty list of {tool, args}")
+	}
+	if len(rawSteps) > sequenceMaxSteps {
+		return "", invalidInputf("at most %d steps are allowed, got %d", sequenceMaxSteps, len(rawSteps))
+	}
+	steps := make([]sequenceStep, len(rawSteps))
+	for i, raw := range rawSteps {
+		step, ok := raw.(map[string]interface{})
+		if !ok {
+			return "", invalidInputf("step %d must be an object with tool and args", i+1)
+		}
+		tool, _ := step["tool"].(string)
+		if tool == "" {
+			return "", invalidInputf("step %d: tool is required", i+1)
+		}
+		if tool == "run_sequence" {
+			return "", invalidInputf("step %d: run_sequence can't be nested", i+1)
+		}
+		stepArgs, ok := step["args"].(map[string]interface{})
+		if !ok && step["args"] != nil {
+			return "", invalidInputf("step %d: args must be an object", i+1)
+		}
+		steps[i] = sequenceStep{tool: tool, args: stepArgs}
+	}
+
+	stopOnError := true
+	if v, ok := args["stop_on_error"].(bool); ok {
+		stopOnError = v
+	}
+	format := getStr(args, "format")
+	if format != "" && format != "text" && format != "json" {
+		return "", invalidInputf("format must be text or json")
+	}
+
+	results := make([]sequenceResult, len(steps))
+	stopped := ""
+	for i, step := range steps {
+		results[i] = sequenceResult{Step: i + 1, Tool: step.tool, Status: "skipped"}
+		if stopped != "" {
+			continue
+		}
+		if ctx.Err() != nil {
+			stopped = "out of time"
+			continue
+		}
+		logf(ctx, "debug", "run_sequence step %d/%d: %s", i+1, len(steps), step.tool)
+		out, isError, err := call(ctx, step.tool, step.args)
+		if err != nil {
+			out, isError = "Error: "+err.Error(), true
+		}
+		results[i].Output = out
+		results[i].Status = "ok"
+		if isError {
+			results[i].Status = "error"
+			if stopOnError {
+				stopped = "an earlier step failed"
+			}
+		}
+	}
+
+	if format == "json" {
+		out, _ := json.MarshalIndent(results, "", "  ")
+		return string(out), nil
+	}
+
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Sequence of %d steps: %d ok, %d failed, %d skipped", len(steps), counts["ok"], counts["error"], counts["skipped"])
+	for _, r := range results {
+		fmt.Fprintf(&sb, "\n\n[%d/%d] %s: %s", r.Step, len(steps), r.Tool, r.Status)
+		if r.Status == "skipped" {
+			fmt.Fprintf(&sb, " (%s)", stopped)
+		} else {
+			sb.WriteString("\n" + r.Output)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,297 @@
+package profiles
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Database and Redis connections can reach a backend that isn't exposed
+// publicly through an SSH bastion: with SSH_HOST set, the gateway logs in to
+// the bastion and opens a direct-tcpip channel to the backend for each
+// connection, over one SSH session shared by all connections using the same
+// bastion settings. Keys are only ever held in memory. Without SSH_HOST the
+// backend is dialed directly.
+
+// sshTunnelEnv are the bastion settings of the profiles that dial a backend
+var sshTunnelEnv = []EnvSpec{
+	{Name: "SSH_HOST", Required: false, Description: "SSH bastion (host or host:port) to reach the backend through; the backend address is then resolved from the bastion"},
+	{Name: "SSH_USER", Required: false, Description: "User to log in to SSH_HOST as"},
+	{Name: "SSH_KEY", Required: false, Description: "Private key (PEM/OpenSSH format) for SSH_USER"},
+	{Name: "SSH_KEY_PASSPHRASE", Required: false, Description: "Passphrase of SSH_KEY, if it is encrypted"},
+	{Name: "SSH_KNOWN_HOSTS", Required: false, Description: "known_hosts line(s) or public key(s) pinning SSH_HOST's host key; required unless SSH_TRUST_ON_FIRST_USE=true"},
+	{Name: "SSH_TRUST_ON_FIRST_USE", Required: false, Description: "Set to true to trust the host key SSH_HOST first presents, for the life of the gateway, when SSH_KNOWN_HOSTS is unset (default false)"},
+}
+
+// sshTunnel dials backends through an SSH bastion
+type sshTunnel struct {
+	addr   string // the bastion's host:port
+	config *ssh.ClientConfig
+	key    string // identifies the settings, to share clients
+}
+
+// sshTunnelFor returns the tunnel the connection configures, or nil when
+// SSH_HOST is unset
+func sshTunnelFor(env map[string]string) (*sshTunnel, error) {
+	host := strings.TrimSpace(env["SSH_HOST"])
+	if host == "" {
+		return nil, nil
+	}
+	user, key := strings.TrimSpace(env["SSH_USER"]), env["SSH_KEY"]
+	if user == "" || strings.TrimSpace(key) == "" {
+		return nil, notConfiguredf("SSH_HOST is set, so SSH_USER and SSH_KEY are required")
+	}
+	port := "22"
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return nil, notConfiguredf("invalid SSH_HOST port: %s", port)
+	}
+	addr := net.JoinHostPort(host, port)
+
+	var signer ssh.Signer
+	var err error
+	if passphrase := env["SSH_KEY_PASSPHRASE"]; passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(key))
+	}
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, notConfiguredf("SSH_KEY is encrypted; set SSH_KEY_PASSPHRASE")
+		}
+		return nil, notConfiguredf("invalid SSH_KEY: %s", err)
+	}
+
+	var hostKey ssh.HostKeyCallback
+	if known := strings.TrimSpace(env["SSH_KNOWN_HOSTS"]); known != "" {
+		keys, err := parseKnownHostKeys(known)
+		if err != nil {
+			return nil, notConfiguredf("invalid SSH_KNOWN_HOSTS: %s", err)
+		}
+		hostKey = pinnedHostKeys(keys)
+	} else if strings.EqualFold(strings.TrimSpace(env["SSH_TRUST_ON_FIRST_USE"]), "true") {
+		hostKey = trustOnFirstUse(addr)
+	} else {
+		return nil, notConfiguredf("SSH_HOST is set, so SSH_KNOWN_HOSTS is required to verify its host key (or set SSH_TRUST_ON_FIRST_USE=true)")
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{addr, user, key, env["SSH_KEY_PASSPHRASE"], env["SSH_KNOWN_HOSTS"], env["SSH_TRUST_ON_FIRST_USE"]}, "\x00")))
+	return &sshTunnel{
+		addr: addr,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKey,
+		},
+		key: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// parseKnownHostKeys returns the keys of known_hosts lines, or of bare
+// public keys as in authorized_keys
+func parseKnownHostKeys(known string) ([]ssh.PublicKey, error) {
+	var keys []ssh.PublicKey
+	for _, line := range strings.Split(known, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			return nil, fmt.Errorf("markers such as %s are not supported", strings.Fields(line)[0])
+		}
+		if key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line)); err == nil {
+			keys = append(keys, key)
+			continue
+		}
+		_, _, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no host keys")
+	}
+	return keys, nil
+}
+
+// pinnedHostKeys accepts only the given host keys
+func pinnedHostKeys(keys []ssh.PublicKey) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, k := range keys {
+			if bytes.Equal(k.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("host key %s %s is not in SSH_KNOWN_HOSTS", key.Type(), ssh.FingerprintSHA256(key))
+	}
+}
+
+var (
+	sshTOFUMu   sync.Mutex
+	sshTOFUKeys = map[string][]byte{} // bastion address → first host key seen
+)
+
+// trustOnFirstUse accepts the first host key addr presents and, for the
+// life of the gateway, only that key afterwards
+func trustOnFirstUse(addr string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		sshTOFUMu.Lock()
+		defer sshTOFUMu.Unlock()
+		seen, ok := sshTOFUKeys[addr]
+		if !ok {
+			sshTOFUKeys[addr] = key.Marshal()
+			return nil
+		}
+		if !bytes.Equal(seen, key.Marshal()) {
+			return fmt.Errorf("host key of %s changed to %s %s since first use", addr, key.Type(), ssh.FingerprintSHA256(key))
+		}
+		return nil
+	}
+}
+
+var (
+	sshClientsMu sync.Mutex
+	sshClients   = map[string]*ssh.Client{} // by sshTunnel.key
+)
+
+// sshKeepAlive is how often an idle bastion session is checked, so a dead
+// one is replaced before the next dial waits on it
+const sshKeepAlive = 30 * time.Second
+
+// client returns the session to the bastion, logging in if there is none
+func (t *sshTunnel) client(timeout time.Duration) (*ssh.Client, error) {
+	sshClientsMu.Lock()
+	defer sshClientsMu.Unlock()
+	if c, ok := sshClients[t.key]; ok {
+		return c, nil
+	}
+
+	conn, err := net.DialTimeout("tcp", t.addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	cc, chans, reqs, err := ssh.NewClientConn(conn, t.addr, t.config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	c := ssh.NewClient(cc, chans, reqs)
+	sshClients[t.key] = c
+
+	go func() {
+		c.Wait()
+		sshClientsMu.Lock()
+		if sshClients[t.key] == c {
+			delete(sshClients, t.key)
+		}
+		sshClientsMu.Unlock()
+	}()
+	go func() {
+		for {
+			time.Sleep(sshKeepAlive)
+			if _, _, err := c.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				c.Close()
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+// drop closes c and forgets it, if it is still t's session
+func (t *sshTunnel) drop(c *ssh.Client) {
+	sshClientsMu.Lock()
+	if sshClients[t.key] == c {
+		delete(sshClients, t.key)
+	}
+	sshClientsMu.Unlock()
+	c.Close()
+}
+
+// Dial implements pq.Dialer
+func (t *sshTunnel) Dial(network, address string) (net.Conn, error) {
+	return t.DialTimeout(network, address, 10*time.Second)
+}
+
+// DialTimeout implements pq.Dialer. timeout bounds connecting to the bastion.
+func (t *sshTunnel) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	if !strings.HasPrefix(network, "tcp") {
+		return nil, fmt.Errorf("ssh tunnel: cannot forward %s addresses", network)
+	}
+	// A shared session may have died since its last use: retry once on a
+	// new one
+	for attempt := 0; ; attempt++ {
+		c, err := t.client(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("ssh tunnel: %s", err)
+		}
+		ch, err := c.Dial("tcp", address)
+		if err == nil {
+			return newSSHConn(ch, address), nil
+		}
+		var openErr *ssh.OpenChannelError
+		if errors.As(err, &openErr) || attempt > 0 {
+			// The bastion is fine but refused or couldn't reach the backend
+			return nil, fmt.Errorf("ssh tunnel: %s", err)
+		}
+		t.drop(c)
+	}
+}
+
+// dialBackend connects to address (host:port) over TCP, through the
+// connection's SSH bastion if it has one
+func dialBackend(env map[string]string, address string, timeout time.Duration) (net.Conn, error) {
+	tunnel, err := sshTunnelFor(env)
+	if err != nil {
+		return nil, err
+	}
+	if tunnel != nil {
+		return tunnel.DialTimeout("tcp", address, timeout)
+	}
+	return net.DialTimeout("tcp", address, timeout)
+}
+
+// sshConn is a connection forwarded over an SSH channel. SSH channels have
+// no deadlines, which the backends' clients rely on, so the channel is
+// relayed through an in-memory pipe that has them.
+type sshConn struct {
+	net.Conn // our end of the pipe
+	target   string
+}
+
+func newSSHConn(ch net.Conn, target string) *sshConn {
+	local, remote := net.Pipe()
+	go func() {
+		io.Copy(ch, remote)
+		ch.Close()
+	}()
+	go func() {
+		io.Copy(remote, ch)
+		remote.Close()
+	}()
+	return &sshConn{Conn: local, target: target}
+}
+
+func (c *sshConn) LocalAddr() net.Addr  { return sshAddr("ssh") }
+func (c *sshConn) RemoteAddr() net.Addr { return sshAddr(c.target) }
+
+type sshAddr string
+
+func (a sshAddr) Network() string { return "ssh" }
+func (a sshAddr) String() string  { return string(a) }
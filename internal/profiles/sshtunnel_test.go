@@ -0,0 +1,181 @@
+package profiles
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testBastion is an SSH server forwarding direct-tcpip channels, accepting
+// clientKey only
+type testBastion struct {
+	addr    string
+	hostKey ssh.PublicKey
+}
+
+func startTestBastion(t *testing.T, clientKey ssh.PublicKey) *testBastion {
+	t.Helper()
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == "tunnel" && string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					var target struct {
+						Host       string
+						Port       uint32
+						OriginHost string
+						OriginPort uint32
+					}
+					if nc.ChannelType() != "direct-tcpip" || ssh.Unmarshal(nc.ExtraData(), &target) != nil {
+						nc.Reject(ssh.UnknownChannelType, "unsupported")
+						continue
+					}
+					backend, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+					if err != nil {
+						nc.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					ch, chReqs, err := nc.Accept()
+					if err != nil {
+						backend.Close()
+						continue
+					}
+					go ssh.DiscardRequests(chReqs)
+					go func() { io.Copy(ch, backend); ch.Close() }()
+					go func() { io.Copy(backend, ch); backend.Close() }()
+				}
+			}()
+		}
+	}()
+	return &testBastion{addr: ln.Addr().String(), hostKey: hostSigner.PublicKey()}
+}
+
+// startEchoServer echoes each connection's input back
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(conn, conn); conn.Close() }()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func testClientKey(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, _ := ssh.NewSignerFromKey(priv)
+	return string(pem.EncodeToMemory(block)), signer.PublicKey()
+}
+
+func TestSSHTunnel(t *testing.T) {
+	keyPEM, clientPub := testClientKey(t)
+	bastion := startTestBastion(t, clientPub)
+	echo := startEchoServer(t)
+	otherKeyPEM, _ := testClientKey(t)
+	_, otherHost, _ := ed25519.GenerateKey(rand.Reader)
+	otherHostKey, _ := ssh.NewPublicKey(otherHost.Public())
+	pinned := "bastion " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(bastion.hostKey)))
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"pinned known_hosts line", map[string]string{"SSH_KEY": keyPEM, "SSH_KNOWN_HOSTS": pinned}, ""},
+		{"pinned bare key", map[string]string{"SSH_KEY": keyPEM, "SSH_KNOWN_HOSTS": string(ssh.MarshalAuthorizedKey(bastion.hostKey))}, ""},
+		{"trust on first use", map[string]string{"SSH_KEY": keyPEM, "SSH_TRUST_ON_FIRST_USE": "true"}, ""},
+		{"known hosts required", map[string]string{"SSH_KEY": keyPEM}, "SSH_KNOWN_HOSTS is required"},
+		{"wrong host key", map[string]string{"SSH_KEY": keyPEM, "SSH_KNOWN_HOSTS": string(ssh.MarshalAuthorizedKey(otherHostKey))}, "not in SSH_KNOWN_HOSTS"},
+		{"wrong client key", map[string]string{"SSH_KEY": otherKeyPEM, "SSH_KNOWN_HOSTS": pinned}, "unable to authenticate"},
+		{"invalid key", map[string]string{"SSH_KEY": "not a key", "SSH_KNOWN_HOSTS": pinned}, "invalid SSH_KEY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"SSH_HOST": bastion.addr, "SSH_USER": "tunnel"}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			conn, err := dialBackend(env, echo, 5*time.Second)
+			if tt.wantErr != "" {
+				if err == nil {
+					conn.Close()
+					t.Fatalf("dial succeeded, want error %q", tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := conn.Write([]byte("ping\n")); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 5)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				t.Fatal(err)
+			}
+			if string(buf) != "ping\n" {
+				t.Errorf("echo = %q", buf)
+			}
+			// Deadlines work through the tunnel
+			conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+			if _, err := conn.Read(buf); err == nil || !strings.Contains(err.Error(), "timeout") {
+				t.Errorf("read past deadline: err = %v", err)
+			}
+		})
+	}
+}
@@ -2,8 +2,12 @@ package profiles
 
 import (
 	"fmt"
+	"io/fs"
 	"math"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -56,7 +60,11 @@ func (p *TimeProfile) Tools() []Tool {
 				"properties": map[string]interface{}{
 					"datetime": map[string]interface{}{
 						"type":        "string",
-						"description": "Datetime string to parse (RFC3339, RFC822, or common formats)",
+						"description": "Datetime to parse: RFC3339, RFC822 or another common format, a unix timestamp (seconds or milliseconds), or a relative expression like \"tomorrow\", \"next friday at 9am\" or \"3 days ago\"",
+					},
+					"timezone": map[string]interface{}{
+						"type":        "string",
+						"description": "IANA timezone for datetimes without an offset and for relative expressions. Defaults to DEFAULT_TIMEZONE or UTC.",
 					},
 				},
 				"required": []string{"datetime"},
@@ -80,7 +88,90 @@ func (p *TimeProfile) Tools() []Tool {
 				"required": []string{"start", "end"},
 			},
 		},
+		{
+			Name:        "solar_times",
+			Description: "Calculate sunrise, sunset, solar noon and civil twilight for a date and location",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"latitude": map[string]interface{}{
+						"type":        "number",
+						"description": "Latitude in degrees, -90 to 90 (north positive)",
+					},
+					"longitude": map[string]interface{}{
+						"type":        "number",
+						"description": "Longitude in degrees, -180 to 180 (east positive)",
+					},
+					"date": map[string]interface{}{
+						"type":        "string",
+						"description": "Date as YYYY-MM-DD. Defaults to today in the timezone.",
+					},
+					"timezone": map[string]interface{}{
+						"type":        "string",
+						"description": "IANA timezone for the output times. Defaults to DEFAULT_TIMEZONE or UTC.",
+					},
+				},
+				"required": []string{"latitude", "longitude"},
+			},
+		},
+	}
+}
+
+func (p *TimeProfile) RequiredEnv() []EnvSpec {
+	return []EnvSpec{
+		{Name: "DEFAULT_TIMEZONE", Required: false, Description: "IANA timezone used when none is given (default UTC)"},
+	}
+}
+
+func (p *TimeProfile) Complete(tool, arg, partial string, env map[string]string) ([]string, error) {
+	switch arg {
+	case "timezone", "from_timezone", "to_timezone":
+		return timezoneNames(), nil
 	}
+	return nil, nil
+}
+
+var (
+	tzNamesOnce sync.Once
+	tzNames     []string
+)
+
+// timezoneNames lists the IANA zones in the system zoneinfo database, or a
+// few common ones if it isn't installed
+func timezoneNames() []string {
+	tzNamesOnce.Do(func() {
+		root := os.Getenv("ZONEINFO")
+		if root == "" {
+			root = "/usr/share/zoneinfo"
+		}
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			name, _ := filepath.Rel(root, path)
+			// Skip top-level legacy aliases (EST, GMT0, ...), data files and the
+			// posix/ and right/ copies of the tree
+			if !strings.Contains(name, "/") && name != "UTC" {
+				return nil
+			}
+			if first := name[0]; first < 'A' || first > 'Z' || strings.HasPrefix(name, "posix/") || strings.HasPrefix(name, "right/") {
+				return nil
+			}
+			if _, err := time.LoadLocation(name); err == nil {
+				tzNames = append(tzNames, name)
+			}
+			return nil
+		})
+		if len(tzNames) == 0 {
+			tzNames = []string{
+				"UTC", "America/New_York", "America/Chicago", "America/Denver", "America/Los_Angeles",
+				"America/Sao_Paulo", "Europe/London", "Europe/Paris", "Europe/Berlin", "Europe/Moscow",
+				"Africa/Cairo", "Africa/Johannesburg", "Asia/Dubai", "Asia/Kolkata", "Asia/Shanghai",
+				"Asia/Tokyo", "Asia/Singapore", "Australia/Sydney", "Pacific/Auckland",
+			}
+		}
+	})
+	return tzNames
 }
 
 func (p *TimeProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
@@ -130,24 +221,23 @@ func (p *TimeProfile) CallTool(name string, args map[string]interface{}, env map
 		if dtStr == "" {
 			return "", fmt.Errorf("datetime is required")
 		}
-		formats := []string{
-			time.RFC3339, time.RFC1123, time.RFC822, time.RFC850,
-			"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02",
-			"01/02/2006", "Jan 2, 2006",
-		}
-		var parsed time.Time
-		var parseErr error
-		for _, f := range formats {
-			parsed, parseErr = time.Parse(f, dtStr)
-			if parseErr == nil {
-				break
-			}
+		tz := getStr(args, "timezone")
+		if tz == "" {
+			tz = env["DEFAULT_TIMEZONE"]
 		}
-		if parseErr != nil {
-			return "", fmt.Errorf("could not parse datetime: %s", dtStr)
+		if tz == "" {
+			tz = "UTC"
 		}
-		return fmt.Sprintf("Parsed: %s\nRFC3339: %s\nUnix: %d\nWeekday: %s\nDay of year: %d",
-			dtStr, parsed.Format(time.RFC3339), parsed.Unix(),
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return "", fmt.Errorf("invalid timezone: %s", tz)
+		}
+		parsed, format, err := parseDatetime(dtStr, loc, time.Now())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Parsed: %s\nFormat: %s\nRFC3339: %s\nUnix: %d\nWeekday: %s\nDay of year: %d",
+			dtStr, format, parsed.Format(time.RFC3339), parsed.Unix(),
 			parsed.Weekday().String(), parsed.YearDay()), nil
 
 	case "time_difference":
@@ -185,6 +275,9 @@ func (p *TimeProfile) CallTool(name string, args map[string]interface{}, env map
 		}
 		return fmt.Sprintf("Difference: %s\nTotal seconds: %.0f", strings.Join(parts, ", "), math.Abs(diff.Seconds())), nil
 
+	case "solar_times":
+		return p.solarTimes(args, env)
+
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
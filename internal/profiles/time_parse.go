@@ -0,0 +1,247 @@
+package profiles
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// datetimeLayouts are tried in order by parseDatetime after the unix
+// timestamp check. The RFC layouts come first as the common fast path.
+var datetimeLayouts = []struct {
+	name, layout string
+}{
+	{"RFC3339", time.RFC3339Nano},
+	{"RFC1123", time.RFC1123},
+	{"RFC1123Z", time.RFC1123Z},
+	{"RFC822", time.RFC822},
+	{"RFC822Z", time.RFC822Z},
+	{"RFC850", time.RFC850},
+	{"ANSIC", time.ANSIC},
+	{"Unix date", time.UnixDate},
+	{"Ruby date", time.RubyDate},
+	{"ISO 8601 local", "2006-01-02T15:04:05.999999999"},
+	{"ISO 8601 local", "2006-01-02T15:04"},
+	{"ISO 8601 basic", "20060102T150405Z0700"},
+	{"ISO 8601 basic", "20060102T150405"},
+	{"date time with zone", "2006-01-02 15:04:05Z07:00"},
+	{"date time with zone", "2006-01-02 15:04:05 -0700"},
+	{"date time with zone", "2006-01-02 15:04:05 MST"},
+	{"date time", "2006-01-02 15:04:05.999999999"},
+	{"date time", "2006-01-02 15:04"},
+	{"date time", "2006-01-02 3:04 PM"},
+	{"date time", "2006/01/02 15:04:05"},
+	{"date time", "2006/01/02 15:04"},
+	{"ISO date", "2006-01-02"},
+	{"date", "2006/01/02"},
+	{"compact date", "20060102"},
+	{"US date time", "01/02/2006 15:04:05"},
+	{"US date time", "01/02/2006 15:04"},
+	{"US date time", "1/2/2006 3:04 PM"},
+	{"US date", "01/02/2006"},
+	{"US date", "1/2/2006"},
+	{"European date", "02.01.2006 15:04"},
+	{"European date", "02.01.2006"},
+	{"month day year", "Jan 2, 2006 15:04"},
+	{"month day year", "Jan 2, 2006 3:04 PM"},
+	{"month day year", "Jan 2, 2006"},
+	{"month day year", "January 2, 2006 15:04"},
+	{"month day year", "January 2, 2006 3:04 PM"},
+	{"month day year", "January 2, 2006"},
+	{"month day year", "Jan 2 2006"},
+	{"month day year", "January 2 2006"},
+	{"month day year", "Mon, Jan 2, 2006"},
+	{"month day year", "Monday, January 2, 2006"},
+	{"day month year", "2 Jan 2006 15:04"},
+	{"day month year", "2 Jan 2006"},
+	{"day month year", "2 January 2006 15:04"},
+	{"day month year", "2 January 2006"},
+	{"day month year", "Mon, 2 Jan 2006"},
+	{"day month year", "Monday, 2 January 2006"},
+}
+
+var (
+	relativeOffsetRe = regexp.MustCompile(`^(?:in\s+(\d+)\s+([a-z]+?)s?|(\d+)\s+([a-z]+?)s?\s+ago)$`)
+	weekdayRe        = regexp.MustCompile(`^(?:(next|last|this)\s+)?(sunday|monday|tuesday|wednesday|thursday|friday|saturday)$`)
+	clockRe          = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+)
+
+// parseDatetime parses s as a unix timestamp, one of datetimeLayouts, or a
+// relative expression such as "tomorrow", "next friday at 9am" or
+// "3 days ago" (relative to now). Times without a zone are taken to be in
+// loc. It returns the time and a description of the format it recognized.
+func parseDatetime(s string, loc *time.Location, now time.Time) (time.Time, string, error) {
+	s = strings.TrimSpace(s)
+
+	if t, format, ok := parseUnixTimestamp(s); ok {
+		return t.In(loc), format, nil
+	}
+
+	for _, l := range datetimeLayouts {
+		if t, err := time.ParseInLocation(l.layout, s, loc); err == nil {
+			return t, fmt.Sprintf("%s (%s)", l.name, l.layout), nil
+		}
+	}
+
+	if t, ok := parseRelative(strings.ToLower(s), now.In(loc)); ok {
+		return t, "relative to now", nil
+	}
+	return time.Time{}, "", invalidInputf("could not parse datetime: %s (try RFC3339, a unix timestamp, or e.g. \"next friday at 9:00\")", s)
+}
+
+// parseUnixTimestamp recognizes an integer or decimal epoch timestamp,
+// guessing its unit from the number of integer digits
+func parseUnixTimestamp(s string) (time.Time, string, bool) {
+	digits := strings.TrimPrefix(s, "-")
+	intPart, frac, hasFrac := strings.Cut(digits, ".")
+	if intPart == "" || strings.Trim(intPart, "0123456789") != "" || (hasFrac && strings.Trim(frac, "0123456789") != "") {
+		return time.Time{}, "", false
+	}
+	// Bare years and compact dates (20240115) are not timestamps
+	if len(intPart) < 9 {
+		return time.Time{}, "", false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	switch n := len(intPart); {
+	case n <= 11:
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), "unix seconds", true
+	case n <= 14:
+		return time.UnixMilli(int64(f)), "unix milliseconds", true
+	case n <= 17:
+		return time.UnixMicro(int64(f)), "unix microseconds", true
+	default:
+		n, err := strconv.ParseInt(strings.Split(s, ".")[0], 10, 64)
+		if err != nil {
+			return time.Time{}, "", false
+		}
+		return time.Unix(0, n), "unix nanoseconds", true
+	}
+}
+
+// parseRelative handles now/today/tomorrow/yesterday, "[next|last|this]
+// <weekday>" and "in N <unit>" / "N <unit> ago", optionally followed by
+// "at <clock time>". Day expressions without a clock time mean midnight.
+func parseRelative(s string, now time.Time) (time.Time, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	day, clock, hasClock := strings.Cut(s, " at ")
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var t time.Time
+	switch day {
+	case "now":
+		if hasClock {
+			return time.Time{}, false
+		}
+		return now, true
+	case "today":
+		t = midnight
+	case "tomorrow":
+		t = midnight.AddDate(0, 0, 1)
+	case "yesterday":
+		t = midnight.AddDate(0, 0, -1)
+	default:
+		if m := weekdayRe.FindStringSubmatch(day); m != nil {
+			t = relativeWeekday(midnight, m[1], m[2])
+			break
+		}
+		m := relativeOffsetRe.FindStringSubmatch(day)
+		if m == nil || hasClock {
+			return time.Time{}, false
+		}
+		count, unit, sign := m[1], m[2], 1
+		if count == "" {
+			count, unit, sign = m[3], m[4], -1
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return addUnits(now, unit, n*sign)
+	}
+
+	if hasClock {
+		hour, min, ok := parseClock(clock)
+		if !ok {
+			return time.Time{}, false
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), hour, min, 0, 0, t.Location())
+	}
+	return t, true
+}
+
+// relativeWeekday resolves "next friday" (the one after the coming one if
+// today is friday), "last friday" (strictly before today) and "friday" /
+// "this friday" (today or the coming one)
+func relativeWeekday(midnight time.Time, which, name string) time.Time {
+	var target time.Weekday
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.ToLower(d.String()) == name {
+			target = d
+		}
+	}
+	ahead := (int(target) - int(midnight.Weekday()) + 7) % 7
+	switch which {
+	case "next":
+		if ahead == 0 {
+			ahead = 7
+		}
+	case "last":
+		ahead -= 7
+		if ahead == 0 {
+			ahead = -7
+		}
+	}
+	return midnight.AddDate(0, 0, ahead)
+}
+
+func addUnits(t time.Time, unit string, n int) (time.Time, bool) {
+	switch unit {
+	case "second", "sec":
+		return t.Add(time.Duration(n) * time.Second), true
+	case "minute", "min":
+		return t.Add(time.Duration(n) * time.Minute), true
+	case "hour":
+		return t.Add(time.Duration(n) * time.Hour), true
+	case "day":
+		return t.AddDate(0, 0, n), true
+	case "week":
+		return t.AddDate(0, 0, 7*n), true
+	case "month":
+		return t.AddDate(0, n, 0), true
+	case "year":
+		return t.AddDate(n, 0, 0), true
+	}
+	return time.Time{}, false
+}
+
+// parseClock parses "15:04", "9am" or "9:30 pm"
+func parseClock(s string) (hour, min int, ok bool) {
+	m := clockRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, 0, false
+	}
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		min, _ = strconv.Atoi(m[2])
+	}
+	switch m[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || min > 59 {
+		return 0, 0, false
+	}
+	return hour, min, true
+}
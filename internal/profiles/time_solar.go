@@ -0,0 +1,175 @@
+package profiles
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sun altitudes, in degrees, at the events solar_times reports. Sunrise and
+// sunset allow for refraction and the sun's apparent radius.
+const (
+	sunriseAltitude  = -0.833
+	civilTwilightAlt = -6.0
+)
+
+// solarDay holds the Julian dates of one day's solar events; a zero value
+// means the sun never crosses that altitude on the day
+type solarDay struct {
+	noon                   float64
+	sunrise, sunset        float64
+	civilDawn, civilDusk   float64
+	polarDay, polarNight   bool // sun never sets / never rises
+	civilAllDay, civilNone bool // sun never drops below / rises above -6°
+}
+
+// computeSolarDay applies the sunrise equation (as used by NOAA, accurate to
+// about a minute) for the date at the given latitude and longitude, east
+// positive. date is taken as a calendar day; only its year, month and day are used.
+func computeSolarDay(date time.Time, lat, lon float64) solarDay {
+	rad := math.Pi / 180
+	noonUTC := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(julianDate(noonUTC) - 2451545.0 + 0.0008)
+
+	meanNoon := n - lon/360
+	m := math.Mod(357.5291+0.98560028*meanNoon, 360)
+	c := 1.9148*math.Sin(m*rad) + 0.0200*math.Sin(2*m*rad) + 0.0003*math.Sin(3*m*rad)
+	eclipticLon := math.Mod(m+c+180+102.9372, 360)
+	transit := 2451545.0 + meanNoon + 0.0053*math.Sin(m*rad) - 0.0069*math.Sin(2*eclipticLon*rad)
+	declination := math.Asin(math.Sin(eclipticLon*rad) * math.Sin(23.4397*rad))
+
+	// hourAngle returns the half-day arc, in days, for which the sun is above
+	// altitude, or -1 if it's below all day or 1 if above all day
+	hourAngle := func(altitude float64) (float64, int) {
+		cosH := (math.Sin(altitude*rad) - math.Sin(lat*rad)*math.Sin(declination)) /
+			(math.Cos(lat*rad) * math.Cos(declination))
+		switch {
+		case cosH > 1:
+			return 0, -1
+		case cosH < -1:
+			return 0, 1
+		}
+		return math.Acos(cosH) / rad / 360, 0
+	}
+
+	day := solarDay{noon: transit}
+	if h, state := hourAngle(sunriseAltitude); state == 0 {
+		day.sunrise, day.sunset = transit-h, transit+h
+	} else {
+		day.polarDay, day.polarNight = state > 0, state < 0
+	}
+	if h, state := hourAngle(civilTwilightAlt); state == 0 {
+		day.civilDawn, day.civilDusk = transit-h, transit+h
+	} else {
+		day.civilAllDay, day.civilNone = state > 0, state < 0
+	}
+	return day
+}
+
+func julianDate(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+func fromJulianDate(jd float64) time.Time {
+	return time.Unix(0, int64((jd-2440587.5)*86400*1e9)).Round(time.Second)
+}
+
+// coordArg reads a required latitude or longitude and checks it is within ±limit
+func coordArg(args map[string]interface{}, key string, limit float64) (float64, error) {
+	var v float64
+	switch raw := args[key].(type) {
+	case float64:
+		v = raw
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return 0, invalidInputf("%s must be a number", key)
+		}
+		v = f
+	case nil:
+		return 0, invalidInputf("%s is required", key)
+	default:
+		return 0, invalidInputf("%s must be a number", key)
+	}
+	if math.IsNaN(v) || v < -limit || v > limit {
+		return 0, invalidInputf("%s must be between -%g and %g", key, limit, limit)
+	}
+	return v, nil
+}
+
+func (p *TimeProfile) solarTimes(args map[string]interface{}, env map[string]string) (string, error) {
+	lat, err := coordArg(args, "latitude", 90)
+	if err != nil {
+		return "", err
+	}
+	lon, err := coordArg(args, "longitude", 180)
+	if err != nil {
+		return "", err
+	}
+
+	tz := getStr(args, "timezone")
+	if tz == "" {
+		tz = env["DEFAULT_TIMEZONE"]
+	}
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return "", fmt.Errorf("invalid timezone: %s", tz)
+	}
+
+	date := time.Now().In(loc)
+	if ds := getStr(args, "date"); ds != "" {
+		date, err = time.ParseInLocation("2006-01-02", ds, loc)
+		if err != nil {
+			return "", invalidInputf("invalid date (use YYYY-MM-DD): %s", ds)
+		}
+	}
+
+	day := computeSolarDay(date, lat, lon)
+	clock := func(jd float64) string {
+		t := fromJulianDate(jd).In(loc)
+		s := t.Format("15:04:05")
+		// Far from the timezone's meridian an event can fall on another calendar day
+		if y, m, d := t.Date(); y != date.Year() || m != date.Month() || d != date.Day() {
+			s += t.Format(" (Jan 2)")
+		}
+		return s
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Solar times for %s at %.4f, %.4f (%s)\n\n", date.Format("2006-01-02"), lat, lon, tz)
+	switch {
+	case day.polarDay:
+		sb.WriteString("Midnight sun: the sun stays above the horizon all day\n")
+	case day.polarNight:
+		sb.WriteString("Polar night: the sun stays below the horizon all day\n")
+	default:
+		fmt.Fprintf(&sb, "%-13s %s\n", "Sunrise:", clock(day.sunrise))
+		fmt.Fprintf(&sb, "%-13s %s\n", "Sunset:", clock(day.sunset))
+	}
+	fmt.Fprintf(&sb, "%-13s %s\n", "Solar noon:", clock(day.noon))
+	switch {
+	case day.civilAllDay:
+		sb.WriteString("Civil twilight: none, it stays light all day\n")
+	case day.civilNone:
+		sb.WriteString("Civil twilight: none, the sun stays more than 6° below the horizon\n")
+	default:
+		fmt.Fprintf(&sb, "%-13s %s\n", "Civil dawn:", clock(day.civilDawn))
+		fmt.Fprintf(&sb, "%-13s %s\n", "Civil dusk:", clock(day.civilDusk))
+	}
+
+	switch {
+	case day.polarDay:
+		sb.WriteString("Day length:   24h 0m")
+	case day.polarNight:
+		sb.WriteString("Day length:   0h 0m")
+	default:
+		length := time.Duration((day.sunset - day.sunrise) * 24 * float64(time.Hour))
+		fmt.Fprintf(&sb, "Day length:   %dh %dm", int(length.Hours()), int(length.Minutes())%60)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,252 @@
+package profiles
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// traceroute sends UDP probes with increasing TTLs and reads the ICMP
+// replies routers send back, like the classic Unix tool. Reading ICMP needs
+// a raw socket (root or CAP_NET_RAW); without one it falls back to timing
+// each step of a TCP/TLS connection so the caller still learns where it fails.
+
+const (
+	tracerouteDefaultHops = 20
+	tracerouteMaxHops     = 30
+	tracerouteHopTimeout  = 2 * time.Second
+	tracerouteDeadline    = 30 * time.Second
+	tracerouteBasePort    = 33434 // first UDP port probed, as in Unix traceroute
+)
+
+// ICMP message types a probe can draw
+const (
+	icmpDestUnreachable = 3
+	icmpTimeExceeded    = 11
+)
+
+var icmpUnreachableCodes = map[byte]string{
+	0:  "network unreachable",
+	1:  "host unreachable",
+	2:  "protocol unreachable",
+	4:  "fragmentation needed",
+	9:  "network administratively prohibited",
+	10: "host administratively prohibited",
+	13: "communication administratively prohibited",
+}
+
+func (p *DnsProfile) traceroute(args map[string]interface{}) (string, error) {
+	host := getStr(args, "host")
+	if host == "" {
+		return "", invalidInputf("host is required")
+	}
+	maxHops := int(getFloat(args, "max_hops"))
+	if maxHops <= 0 {
+		maxHops = tracerouteDefaultHops
+	}
+	if maxHops > tracerouteMaxHops {
+		maxHops = tracerouteMaxHops
+	}
+	port := int(getFloat(args, "port"))
+	if port == 0 {
+		port = 443
+	}
+	if port < 1 || port > 65535 {
+		return "", invalidInputf("port must be between 1 and 65535")
+	}
+
+	start := time.Now()
+	ips, err := net.LookupIP(host)
+	dnsTime := time.Since(start)
+	if err != nil {
+		return "", fmt.Errorf("resolve failed: %s", err)
+	}
+	var dst net.IP
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			dst = ip4
+			break
+		}
+	}
+
+	var sb strings.Builder
+	if dst != nil {
+		icmp, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+		if err == nil {
+			defer icmp.Close()
+			fmt.Fprintf(&sb, "traceroute to %s (%s), %d hops max\n\n", host, dst, maxHops)
+			if err := traceICMP(&sb, icmp.(*net.IPConn), dst, maxHops); err == nil {
+				return sb.String(), nil
+			} else if !errors.Is(err, os.ErrPermission) {
+				return "", backendErrorf("traceroute failed: %s", err)
+			}
+			sb.Reset()
+		}
+		sb.WriteString("Note: hop-by-hop tracing needs raw socket access (root or CAP_NET_RAW), which this gateway doesn't have. Showing where a direct connection gets to instead.\n\n")
+	} else {
+		sb.WriteString("Note: hop-by-hop tracing supports IPv4 only and the host has no IPv4 address. Showing where a direct connection gets to instead.\n\n")
+		dst = ips[0]
+	}
+
+	traceConnect(&sb, host, dst, port, dnsTime)
+	return sb.String(), nil
+}
+
+// traceICMP probes one hop at a time until the destination answers, maxHops
+// is reached or tracerouteDeadline runs out, writing a line per hop
+func traceICMP(sb *strings.Builder, icmp *net.IPConn, dst net.IP, maxHops int) error {
+	deadline := time.Now().Add(tracerouteDeadline)
+	buf := make([]byte, 1500)
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		if time.Now().After(deadline) {
+			fmt.Fprintf(sb, "Stopped after %s\n", tracerouteDeadline)
+			return nil
+		}
+		probePort := tracerouteBasePort + ttl
+		sent, err := sendProbe(dst, probePort, ttl)
+		if err != nil {
+			return err
+		}
+
+		wait := sent.Add(tracerouteHopTimeout)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		icmp.SetReadDeadline(wait)
+
+		var from net.Addr
+		var msgType, code byte
+		for {
+			n, addr, err := icmp.ReadFrom(buf)
+			if err != nil {
+				from = nil
+				break
+			}
+			if t, c, port, ok := parseICMPReply(buf[:n], dst); ok && port == probePort {
+				from, msgType, code = addr, t, c
+				break
+			}
+		}
+
+		if from == nil {
+			fmt.Fprintf(sb, "%2d  *\n", ttl)
+			continue
+		}
+		rtt := time.Since(sent)
+		fmt.Fprintf(sb, "%2d  %-15s  %s", ttl, from.String(), rtt.Round(10*time.Microsecond))
+		if name := reverseName(from.String()); name != "" {
+			fmt.Fprintf(sb, "  %s", name)
+		}
+		sb.WriteString("\n")
+
+		if msgType == icmpDestUnreachable {
+			if code != 3 { // 3 = port unreachable: the destination itself answered
+				reason := icmpUnreachableCodes[code]
+				if reason == "" {
+					reason = "code " + strconv.Itoa(int(code))
+				}
+				fmt.Fprintf(sb, "\nStopped: %s\n", reason)
+			}
+			return nil
+		}
+	}
+	fmt.Fprintf(sb, "\nDestination not reached within %d hops\n", maxHops)
+	return nil
+}
+
+// sendProbe sends one UDP datagram to dst:port with the given TTL
+func sendProbe(dst net.IP, port, ttl int) (time.Time, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: dst, Port: port})
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+	}); err != nil {
+		return time.Time{}, err
+	}
+	if sockErr != nil {
+		return time.Time{}, sockErr
+	}
+
+	sent := time.Now()
+	if _, err := conn.Write([]byte("mcp-gateway traceroute")); err != nil {
+		return time.Time{}, err
+	}
+	return sent, nil
+}
+
+// parseICMPReply extracts the type and code of an ICMP time exceeded or
+// destination unreachable message, and the destination port of the UDP
+// probe it quotes, if that probe was sent to dst
+func parseICMPReply(msg []byte, dst net.IP) (msgType, code byte, port int, ok bool) {
+	if len(msg) < 8+20+8 {
+		return 0, 0, 0, false
+	}
+	msgType, code = msg[0], msg[1]
+	if msgType != icmpTimeExceeded && msgType != icmpDestUnreachable {
+		return 0, 0, 0, false
+	}
+	quoted := msg[8:]
+	ihl := int(quoted[0]&0x0f) * 4
+	if ihl < 20 || len(quoted) < ihl+8 || quoted[9] != syscall.IPPROTO_UDP || !net.IP(quoted[16:20]).Equal(dst) {
+		return 0, 0, 0, false
+	}
+	port = int(quoted[ihl+2])<<8 | int(quoted[ihl+3])
+	return msgType, code, port, true
+}
+
+// reverseName returns "(name)" for addr, giving up quickly so slow PTR
+// lookups don't eat into the traceroute's time budget
+func reverseName(addr string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return "(" + strings.TrimSuffix(names[0], ".") + ")"
+}
+
+// traceConnect times DNS, TCP connect and, on port 443, the TLS handshake,
+// reporting the step at which the connection fails
+func traceConnect(sb *strings.Builder, host string, dst net.IP, port int, dnsTime time.Duration) {
+	fmt.Fprintf(sb, "%-14s %s → %s\n", "DNS:", dnsTime.Round(time.Millisecond), dst)
+
+	addr := net.JoinHostPort(dst.String(), strconv.Itoa(port))
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		fmt.Fprintf(sb, "%-14s FAILED after %s: %s\n", "TCP connect:", time.Since(start).Round(time.Millisecond), err)
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(sb, "%-14s %s (port %d open)\n", "TCP connect:", time.Since(start).Round(time.Millisecond), port)
+	if port != 443 {
+		return
+	}
+
+	start = time.Now()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+	if err := tlsConn.Handshake(); err != nil {
+		fmt.Fprintf(sb, "%-14s FAILED after %s: %s\n", "TLS handshake:", time.Since(start).Round(time.Millisecond), err)
+		return
+	}
+	state := tlsConn.ConnectionState()
+	fmt.Fprintf(sb, "%-14s %s (%s)\n", "TLS handshake:", time.Since(start).Round(time.Millisecond), tls.VersionName(state.Version))
+}
@@ -1,11 +1,22 @@
 package profiles
 
 import (
+	"encoding/base32"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"html"
+	"io"
 	"net/url"
+	"strconv"
 	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type TransformProfile struct{}
@@ -44,7 +55,7 @@ func (p *TransformProfile) Tools() []Tool {
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"text":    map[string]interface{}{"type": "string", "description": "Text to encode"},
+					"text":     map[string]interface{}{"type": "string", "description": "Text to encode"},
 					"url_safe": map[string]interface{}{"type": "boolean", "description": "Use URL-safe encoding (default false)"},
 				},
 				"required": []string{"text"},
@@ -61,9 +72,56 @@ func (p *TransformProfile) Tools() []Tool {
 				"required": []string{"encoded"},
 			},
 		},
+		{
+			Name:        "base32_encode",
+			Description: "Encode text or bytes to base32 (RFC 4648), e.g. for TOTP secrets",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text":       map[string]interface{}{"type": "string", "description": "Text to encode"},
+					"from_hex":   map[string]interface{}{"type": "boolean", "description": "Treat text as hex-encoded bytes (default false)"},
+					"no_padding": map[string]interface{}{"type": "boolean", "description": "Omit trailing '=' padding (default false)"},
+				},
+				"required": []string{"text"},
+			},
+		},
+		{
+			Name:        "base32_decode",
+			Description: "Decode base32 (RFC 4648, padding optional) to text",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"encoded": map[string]interface{}{"type": "string", "description": "Base32 string to decode"},
+				},
+				"required": []string{"encoded"},
+			},
+		},
+		{
+			Name:        "base58_encode",
+			Description: "Encode text or bytes to base58 (Bitcoin alphabet)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text":     map[string]interface{}{"type": "string", "description": "Text to encode"},
+					"from_hex": map[string]interface{}{"type": "boolean", "description": "Treat text as hex-encoded bytes, e.g. a key or hash (default false)"},
+				},
+				"required": []string{"text"},
+			},
+		},
+		{
+			Name:        "base58_decode",
+			Description: "Decode base58 (Bitcoin alphabet) to text, or hex for binary data",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"encoded": map[string]interface{}{"type": "string", "description": "Base58 string to decode"},
+				},
+				"required": []string{"encoded"},
+			},
+		},
 		{
 			Name:        "url_encode",
-			Description: "URL-encode a string",
+			Description: "URL-encode a string for use in a query component (spaces become '+')",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -83,6 +141,63 @@ func (p *TransformProfile) Tools() []Tool {
 				"required": []string{"text"},
 			},
 		},
+		{
+			Name:        "url_path_encode",
+			Description: "Encode a string for use as a URL path segment (spaces become '%20', '/' is escaped)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text":   map[string]interface{}{"type": "string", "description": "Text to encode"},
+					"decode": map[string]interface{}{"type": "boolean", "description": "Decode a path segment instead (default false)"},
+				},
+				"required": []string{"text"},
+			},
+		},
+		{
+			Name:        "hex_encode",
+			Description: "Encode text to hexadecimal",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text":      map[string]interface{}{"type": "string", "description": "Text to encode"},
+					"uppercase": map[string]interface{}{"type": "boolean", "description": "Use uppercase hex digits (default false)"},
+				},
+				"required": []string{"text"},
+			},
+		},
+		{
+			Name:        "hex_decode",
+			Description: "Decode hexadecimal to text (whitespace, ':' and a 0x prefix are ignored)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"encoded": map[string]interface{}{"type": "string", "description": "Hex string to decode"},
+				},
+				"required": []string{"encoded"},
+			},
+		},
+		{
+			Name:        "html_encode",
+			Description: "Escape HTML special characters (<, >, &, ', \") as entities",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]interface{}{"type": "string", "description": "Text to escape"},
+				},
+				"required": []string{"text"},
+			},
+		},
+		{
+			Name:        "html_decode",
+			Description: "Decode HTML entities (named and numeric) to text",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]interface{}{"type": "string", "description": "Text with HTML entities"},
+				},
+				"required": []string{"text"},
+			},
+		},
 		{
 			Name:        "json_diff",
 			Description: "Compare two JSON objects and show the differences",
@@ -95,6 +210,60 @@ func (p *TransformProfile) Tools() []Tool {
 				"required": []string{"json_a", "json_b"},
 			},
 		},
+		{
+			Name:        "text_diff",
+			Description: "Compare two texts line by line and show a unified or side-by-side diff",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text_a":  map[string]interface{}{"type": "string", "description": "Original text"},
+					"text_b":  map[string]interface{}{"type": "string", "description": "Changed text"},
+					"format":  map[string]interface{}{"type": "string", "description": "unified (default) or side-by-side", "enum": []string{"unified", "side-by-side"}},
+					"context": map[string]interface{}{"type": "number", "description": "Context lines around changes (default 3)"},
+				},
+				"required": []string{"text_a", "text_b"},
+			},
+		},
+		{
+			Name:        "render_template",
+			Description: "Render a Go text/template (e.g. 'Hello {{.name}}') with a JSON data object. Functions: upper, lower, title, trim, replace, split, join, contains, hasPrefix, hasSuffix, default, json, add, sub",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"template": map[string]interface{}{"type": "string", "description": "Template source"},
+					"data":     map[string]interface{}{"type": []string{"object", "string"}, "description": "Data object (or JSON string) available as '.'"},
+				},
+				"required": []string{"template"},
+			},
+		},
+		{
+			Name:        "xml_to_json",
+			Description: "Convert XML to JSON. Attributes become prefixed keys (default '@attr'), text next to attributes or children goes under '#text', repeated elements become arrays",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"xml":         map[string]interface{}{"type": "string", "description": "XML document"},
+					"attr_prefix": map[string]interface{}{"type": "string", "description": "Prefix for attribute keys (default '@')"},
+					"text_key":    map[string]interface{}{"type": "string", "description": "Key for element text content (default '#text')"},
+				},
+				"required": []string{"xml"},
+			},
+		},
+		{
+			Name:        "json_to_xml",
+			Description: "Convert JSON to XML using the same attribute/text conventions as xml_to_json",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"json":        map[string]interface{}{"type": "string", "description": "JSON document"},
+					"root":        map[string]interface{}{"type": "string", "description": "Root element name when the JSON object doesn't have exactly one key (default 'root')"},
+					"attr_prefix": map[string]interface{}{"type": "string", "description": "Prefix marking attribute keys (default '@')"},
+					"text_key":    map[string]interface{}{"type": "string", "description": "Key holding element text content (default '#text')"},
+					"indent":      map[string]interface{}{"type": "boolean", "description": "Pretty-print (default true)"},
+				},
+				"required": []string{"json"},
+			},
+		},
 		{
 			Name:        "url_parse",
 			Description: "Parse a URL into its components (scheme, host, path, query params, etc.)",
@@ -109,29 +278,36 @@ func (p *TransformProfile) Tools() []Tool {
 	}
 }
 
-func (p *TransformProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
-	switch name {
-	case "json_format":
-		return p.jsonFormat(args)
-	case "json_query":
-		return p.jsonQuery(args)
-	case "base64_encode":
-		return p.base64Encode(args)
-	case "base64_decode":
-		return p.base64Decode(args)
-	case "url_encode":
-		return p.urlEncode(args)
-	case "url_decode":
-		return p.urlDecode(args)
-	case "json_diff":
-		return p.jsonDiff(args)
-	case "url_parse":
-		return p.urlParse(args)
-	default:
-		return "", fmt.Errorf("unknown tool: %s", name)
+func (p *TransformProfile) Handlers() Dispatcher {
+	return Dispatcher{
+		"json_format":     argsOnly(p.jsonFormat),
+		"json_query":      argsOnly(p.jsonQuery),
+		"base64_encode":   argsOnly(p.base64Encode),
+		"base64_decode":   argsOnly(p.base64Decode),
+		"base32_encode":   argsOnly(p.base32Encode),
+		"base32_decode":   argsOnly(p.base32Decode),
+		"base58_encode":   argsOnly(p.base58Encode),
+		"base58_decode":   argsOnly(p.base58Decode),
+		"url_encode":      argsOnly(p.urlEncode),
+		"url_decode":      argsOnly(p.urlDecode),
+		"url_path_encode": argsOnly(p.urlPathEncode),
+		"hex_encode":      argsOnly(p.hexEncode),
+		"hex_decode":      argsOnly(p.hexDecode),
+		"html_encode":     argsOnly(p.htmlEncode),
+		"html_decode":     argsOnly(p.htmlDecode),
+		"json_diff":       argsOnly(p.jsonDiff),
+		"url_parse":       argsOnly(p.urlParse),
+		"text_diff":       argsOnly(p.textDiff),
+		"render_template": argsOnly(p.renderTemplate),
+		"xml_to_json":     argsOnly(p.xmlToJSON),
+		"json_to_xml":     argsOnly(p.jsonToXML),
 	}
 }
 
+func (p *TransformProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	return p.Handlers().Call(name, args, env)
+}
+
 func (p *TransformProfile) jsonFormat(args map[string]interface{}) (string, error) {
 	jsonStr := getStr(args, "json")
 	if jsonStr == "" {
@@ -245,6 +421,148 @@ func (p *TransformProfile) base64Decode(args map[string]interface{}) (string, er
 	return string(data), nil
 }
 
+// encodeInput returns the bytes an encode tool should work on: text itself,
+// or the bytes it spells out when from_hex is set
+func encodeInput(args map[string]interface{}) ([]byte, error) {
+	text := getStr(args, "text")
+	if text == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+	if fromHex, _ := args["from_hex"].(bool); fromHex {
+		data, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(text), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex: %s", err)
+		}
+		return data, nil
+	}
+	return []byte(text), nil
+}
+
+// decodedText returns decoded bytes as text, or as hex if they aren't valid UTF-8
+func decodedText(data []byte) string {
+	if !utf8.Valid(data) {
+		return fmt.Sprintf("(binary data, %d bytes, hex): %s", len(data), hex.EncodeToString(data))
+	}
+	return string(data)
+}
+
+func (p *TransformProfile) base32Encode(args map[string]interface{}) (string, error) {
+	data, err := encodeInput(args)
+	if err != nil {
+		return "", err
+	}
+	if noPad, _ := args["no_padding"].(bool); noPad {
+		return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(data), nil
+	}
+	return base32.StdEncoding.EncodeToString(data), nil
+}
+
+func (p *TransformProfile) base32Decode(args map[string]interface{}) (string, error) {
+	encoded := getStr(args, "encoded")
+	if encoded == "" {
+		return "", fmt.Errorf("encoded is required")
+	}
+	// Secrets are often shown lowercase and grouped with spaces or dashes
+	encoded = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '\n' || r == '\t' || r == '\r' {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, encoded)
+	unpadded := strings.TrimRight(encoded, "=")
+
+	data, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(unpadded)
+	if err != nil {
+		if pos, ok := err.(base32.CorruptInputError); ok && int(pos) < len(unpadded) {
+			return "", fmt.Errorf("invalid base32 character %q at position %d (alphabet is A-Z and 2-7)", unpadded[pos], pos)
+		}
+		return "", fmt.Errorf("invalid base32: %s", err)
+	}
+	return decodedText(data), nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func (p *TransformProfile) base58Encode(args map[string]interface{}) (string, error) {
+	data, err := encodeInput(args)
+	if err != nil {
+		return "", err
+	}
+	return base58Encode(data), nil
+}
+
+func (p *TransformProfile) base58Decode(args map[string]interface{}) (string, error) {
+	encoded := strings.TrimSpace(getStr(args, "encoded"))
+	if encoded == "" {
+		return "", fmt.Errorf("encoded is required")
+	}
+	data, err := base58Decode(encoded)
+	if err != nil {
+		return "", err
+	}
+	return decodedText(data), nil
+}
+
+// base58Encode converts data to base58, keeping each leading zero byte as '1'
+func base58Encode(data []byte) string {
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+	// Repeated division by 58 over a big-endian byte buffer
+	digits := make([]byte, 0, len(data)*138/100+1)
+	for _, b := range data[zeros:] {
+		carry := int(b)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = '1'
+	}
+	for i, d := range digits {
+		out[len(out)-1-i] = base58Alphabet[d]
+	}
+	return string(out)
+}
+
+func base58Decode(s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	buf := make([]byte, 0, len(s)*733/1000+1)
+	for i := zeros; i < len(s); i++ {
+		carry := strings.IndexByte(base58Alphabet, s[i])
+		if carry < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q at position %d (the alphabet excludes 0, O, I and l)", s[i], i)
+		}
+		for j := range buf {
+			carry += int(buf[j]) * 58
+			buf[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			buf = append(buf, byte(carry))
+			carry >>= 8
+		}
+	}
+
+	out := make([]byte, zeros+len(buf))
+	for i, b := range buf {
+		out[len(out)-1-i] = b
+	}
+	return out, nil
+}
+
 func (p *TransformProfile) urlEncode(args map[string]interface{}) (string, error) {
 	text := getStr(args, "text")
 	if text == "" {
@@ -265,6 +583,72 @@ func (p *TransformProfile) urlDecode(args map[string]interface{}) (string, error
 	return decoded, nil
 }
 
+func (p *TransformProfile) urlPathEncode(args map[string]interface{}) (string, error) {
+	text := getStr(args, "text")
+	if text == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	if decode, _ := args["decode"].(bool); decode {
+		decoded, err := url.PathUnescape(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid path encoding: %s", err)
+		}
+		return decoded, nil
+	}
+	return url.PathEscape(text), nil
+}
+
+func (p *TransformProfile) hexEncode(args map[string]interface{}) (string, error) {
+	text := getStr(args, "text")
+	if text == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	encoded := hex.EncodeToString([]byte(text))
+	if upper, _ := args["uppercase"].(bool); upper {
+		encoded = strings.ToUpper(encoded)
+	}
+	return encoded, nil
+}
+
+func (p *TransformProfile) hexDecode(args map[string]interface{}) (string, error) {
+	encoded := getStr(args, "encoded")
+	if encoded == "" {
+		return "", fmt.Errorf("encoded is required")
+	}
+	encoded = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(encoded), "0x"), "0X")
+	encoded = strings.Map(func(r rune) rune {
+		if r == ':' || r == ' ' || r == '\n' || r == '\t' || r == '\r' {
+			return -1
+		}
+		return r
+	}, encoded)
+
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex: %s", err)
+	}
+	if !utf8.Valid(decoded) {
+		return fmt.Sprintf("(binary data, %d bytes, base64): %s", len(decoded), base64.StdEncoding.EncodeToString(decoded)), nil
+	}
+	return string(decoded), nil
+}
+
+func (p *TransformProfile) htmlEncode(args map[string]interface{}) (string, error) {
+	text := getStr(args, "text")
+	if text == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	return html.EscapeString(text), nil
+}
+
+func (p *TransformProfile) htmlDecode(args map[string]interface{}) (string, error) {
+	text := getStr(args, "text")
+	if text == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	return html.UnescapeString(text), nil
+}
+
 func (p *TransformProfile) jsonDiff(args map[string]interface{}) (string, error) {
 	jsonA := getStr(args, "json_a")
 	jsonB := getStr(args, "json_b")
@@ -384,3 +768,817 @@ func (p *TransformProfile) urlParse(args map[string]interface{}) (string, error)
 	}
 	return strings.Join(lines, "\n"), nil
 }
+
+const (
+	maxDiffLines = 10000
+	maxDiffEdits = 2000
+)
+
+type diffOp struct {
+	kind byte // ' ', '-', '+'
+	text string
+	a, b int // 0-based line index in text_a / text_b (-1 when absent)
+}
+
+func (p *TransformProfile) textDiff(args map[string]interface{}) (string, error) {
+	textA := getStr(args, "text_a")
+	textB := getStr(args, "text_b")
+	if textA == "" && textB == "" {
+		return "", fmt.Errorf("text_a and text_b are required")
+	}
+
+	context := 3
+	if _, ok := args["context"]; ok {
+		context = int(getFloat(args, "context"))
+		if context < 0 {
+			context = 0
+		}
+	}
+
+	linesA := splitDiffLines(textA)
+	linesB := splitDiffLines(textB)
+	if len(linesA) > maxDiffLines || len(linesB) > maxDiffLines {
+		return "", fmt.Errorf("texts are limited to %d lines each", maxDiffLines)
+	}
+
+	ops, err := myersDiff(linesA, linesB)
+	if err != nil {
+		return "", err
+	}
+	added, removed, changed := diffStats(ops)
+	if added == 0 && removed == 0 && changed == 0 {
+		return "No differences found — texts are identical", nil
+	}
+	summary := fmt.Sprintf("%d added, %d removed, %d changed", added, removed, changed)
+
+	switch getStr(args, "format") {
+	case "", "unified":
+		return summary + "\n\n" + unifiedDiff(ops, context), nil
+	case "side-by-side":
+		return summary + "\n\n" + sideBySideDiff(ops, context), nil
+	default:
+		return "", fmt.Errorf("format must be unified or side-by-side")
+	}
+}
+
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+}
+
+// myersDiff returns the shortest edit script turning a into b (Myers' O(ND)
+// algorithm). Only the live diagonal window is kept per step, and the edit
+// distance is capped so the trace stays small.
+func myersDiff(a, b []string) ([]diffOp, error) {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEdits {
+			return nil, fmt.Errorf("texts differ in more than %d lines", maxDiffEdits)
+		}
+		// Step d reads diagonals -d-1..d+1
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func backtrackDiff(a, b []string, trace [][]int) []diffOp {
+	x, y := len(a), len(b)
+	var ops []diffOp
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x], x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, diffOp{'+', b[y], -1, y})
+			} else {
+				x--
+				ops = append(ops, diffOp{'-', a[x], x, -1})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// diffStats pairs removals with additions inside each change block as "changed"
+func diffStats(ops []diffOp) (added, removed, changed int) {
+	del, ins := 0, 0
+	flush := func() {
+		c := del
+		if ins < c {
+			c = ins
+		}
+		changed += c
+		removed += del - c
+		added += ins - c
+		del, ins = 0, 0
+	}
+	for _, op := range ops {
+		switch op.kind {
+		case '-':
+			del++
+		case '+':
+			ins++
+		default:
+			flush()
+		}
+	}
+	flush()
+	return
+}
+
+// diffHunks groups op indexes into ranges of changes plus surrounding context
+func diffHunks(ops []diffOp, context int) [][2]int {
+	var hunks [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		end += context
+		if end >= len(ops) {
+			end = len(ops) - 1
+		}
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1][1]+1 {
+			hunks[len(hunks)-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+		i = end
+	}
+	return hunks
+}
+
+func unifiedDiff(ops []diffOp, context int) string {
+	var sb strings.Builder
+	sb.WriteString("--- text_a\n+++ text_b\n")
+	for _, h := range diffHunks(ops, context) {
+		aStart, bStart, aCount, bCount := -1, -1, 0, 0
+		for _, op := range ops[h[0] : h[1]+1] {
+			if op.a >= 0 {
+				if aStart < 0 {
+					aStart = op.a
+				}
+				aCount++
+			}
+			if op.b >= 0 {
+				if bStart < 0 {
+					bStart = op.b
+				}
+				bCount++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(aStart, aCount, ops, h[0], true), hunkRange(bStart, bCount, ops, h[0], false)))
+		for _, op := range ops[h[0] : h[1]+1] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// hunkRange formats "start,count" with 1-based lines; an empty side points at
+// the line before the hunk, as diff -u does
+func hunkRange(start, count int, ops []diffOp, from int, sideA bool) string {
+	if count == 0 {
+		line := 0
+		for i := from - 1; i >= 0; i-- {
+			idx := ops[i].b
+			if sideA {
+				idx = ops[i].a
+			}
+			if idx >= 0 {
+				line = idx + 1
+				break
+			}
+		}
+		return fmt.Sprintf("%d,0", line)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func sideBySideDiff(ops []diffOp, context int) string {
+	const width = 60
+	cell := func(s string) string {
+		s = strings.ReplaceAll(s, "\t", "    ")
+		if r := []rune(s); len(r) > width {
+			s = string(r[:width-1]) + "…"
+		}
+		return s + strings.Repeat(" ", width-len([]rune(s)))
+	}
+
+	var lines []string
+	for hi, h := range diffHunks(ops, context) {
+		if hi > 0 {
+			lines = append(lines, strings.Repeat("·", width*2+3))
+		}
+		seg := ops[h[0] : h[1]+1]
+		for i := 0; i < len(seg); {
+			if seg[i].kind == ' ' {
+				lines = append(lines, cell(seg[i].text)+"   "+seg[i].text)
+				i++
+				continue
+			}
+			// Pair a run of removals with the following run of additions
+			var dels, ins []string
+			for i < len(seg) && seg[i].kind == '-' {
+				dels = append(dels, seg[i].text)
+				i++
+			}
+			for i < len(seg) && seg[i].kind == '+' {
+				ins = append(ins, seg[i].text)
+				i++
+			}
+			for j := 0; j < len(dels) || j < len(ins); j++ {
+				switch {
+				case j < len(dels) && j < len(ins):
+					lines = append(lines, cell(dels[j])+" | "+ins[j])
+				case j < len(dels):
+					lines = append(lines, cell(dels[j])+" <")
+				default:
+					lines = append(lines, cell("")+" > "+ins[j])
+				}
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+const (
+	maxTemplateSize   = 64 * 1024
+	maxTemplateOutput = 1024 * 1024
+	templateTimeout   = 5 * time.Second
+	// maxTemplateWidth bounds printf widths and precisions
+	maxTemplateWidth = 1024
+)
+
+// templateFuncs is the whole function set available to render_template;
+// text/template itself has no filesystem or process access
+var templateFuncs = template.FuncMap{
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"title":     titleCase,
+	"trim":      strings.TrimSpace,
+	"replace":   templateReplace,
+	"split":     strings.Split,
+	"contains":  strings.Contains,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"join": func(items interface{}, sep string) (string, error) {
+		var parts []string
+		switch v := items.(type) {
+		case []string:
+			parts = v
+		case []interface{}:
+			parts = make([]string, len(v))
+			for i, item := range v {
+				parts[i] = fmt.Sprint(item)
+			}
+		default:
+			return templateResult(fmt.Sprint(items))
+		}
+		size := len(sep) * max(len(parts)-1, 0)
+		for _, part := range parts {
+			size += len(part)
+		}
+		if size > maxTemplateOutput {
+			return "", fmt.Errorf("join: result exceeds %d bytes", maxTemplateOutput)
+		}
+		return strings.Join(parts, sep), nil
+	},
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"add": func(a, b float64) float64 { return a + b },
+	"sub": func(a, b float64) float64 { return a - b },
+
+	// Builtins that can grow their input, replaced by bounded versions
+	"printf": templatePrintf,
+	"print": func(args ...interface{}) (string, error) {
+		return templateResult(fmt.Sprint(args...))
+	},
+	"println": func(args ...interface{}) (string, error) {
+		return templateResult(fmt.Sprintln(args...))
+	},
+	"html": func(args ...interface{}) (string, error) {
+		return templateResult(template.HTMLEscaper(args...))
+	},
+	"js": func(args ...interface{}) (string, error) {
+		return templateResult(template.JSEscaper(args...))
+	},
+	"urlquery": func(args ...interface{}) (string, error) {
+		return templateResult(template.URLQueryEscaper(args...))
+	},
+}
+
+// templateResult fails a function result longer than the whole output may
+// be. limitedBuffer only sees what is written, so without this a value
+// built up in a variable could exhaust memory before anything is.
+func templateResult(s string) (string, error) {
+	if len(s) > maxTemplateOutput {
+		return "", fmt.Errorf("result exceeds %d bytes", maxTemplateOutput)
+	}
+	return s, nil
+}
+
+// templateReplace is strings.ReplaceAll, checking the size of the result
+// before building it. An empty old, which would insert new between every
+// rune, is refused.
+func templateReplace(s, old, new string) (string, error) {
+	if old == "" {
+		return "", fmt.Errorf("replace: the string to replace must not be empty")
+	}
+	if n := strings.Count(s, old); len(s)+n*(len(new)-len(old)) > maxTemplateOutput {
+		return "", fmt.Errorf("replace: result exceeds %d bytes", maxTemplateOutput)
+	}
+	return strings.ReplaceAll(s, old, new), nil
+}
+
+// templatePrintf is fmt.Sprintf with widths and precisions of at most
+// maxTemplateWidth, and no * taking them from the arguments, so a short
+// format can't ask for a huge string
+func templatePrintf(format string, args ...interface{}) (string, error) {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		// Flags, width, precision and argument indexes up to the verb
+		for i++; i < len(format) && strings.IndexByte("+-# 0123456789.[]*", format[i]) >= 0; i++ {
+			if format[i] == '*' {
+				return "", fmt.Errorf("printf: * widths are not supported")
+			}
+			if format[i] < '1' || format[i] > '9' {
+				continue
+			}
+			j := i
+			for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+				j++
+			}
+			if n, err := strconv.Atoi(format[i:j]); err != nil || n > maxTemplateWidth {
+				return "", fmt.Errorf("printf: widths and precisions are limited to %d", maxTemplateWidth)
+			}
+			i = j - 1
+		}
+	}
+	return templateResult(fmt.Sprintf(format, args...))
+}
+
+// titleCase upper-cases the first letter of each word
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
+
+// limitedBuffer fails writes past max so runaway templates stop early
+type limitedBuffer struct {
+	strings.Builder
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, fmt.Errorf("output exceeds %d bytes", b.max)
+	}
+	return b.Builder.Write(p)
+}
+
+func (p *TransformProfile) renderTemplate(args map[string]interface{}) (string, error) {
+	src := getStr(args, "template")
+	if src == "" {
+		return "", fmt.Errorf("template is required")
+	}
+	if len(src) > maxTemplateSize {
+		return "", fmt.Errorf("template exceeds %d bytes", maxTemplateSize)
+	}
+
+	var data interface{}
+	switch v := args["data"].(type) {
+	case string:
+		if strings.TrimSpace(v) != "" {
+			if err := json.Unmarshal([]byte(v), &data); err != nil {
+				return "", fmt.Errorf("invalid data JSON: %s", err)
+			}
+		}
+	default:
+		data = v
+	}
+
+	// Execution can't be interrupted from outside, so every loop iteration
+	// and template call first runs templateStep, which ends it once the time
+	// is up; a range over a huge number or a recursive define then stops
+	deadline := time.Now().Add(templateTimeout)
+	timedOut := false
+	step := func() (string, error) {
+		if time.Now().After(deadline) {
+			timedOut = true
+			return "", fmt.Errorf("time is up")
+		}
+		return "", nil
+	}
+	tmpl, err := template.New("template").Funcs(templateFuncs).Funcs(template.FuncMap{templateStep: step}).Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("template parse error: %s", strings.TrimPrefix(err.Error(), "template: "))
+	}
+	if err := instrumentTemplate(tmpl); err != nil {
+		return "", err
+	}
+
+	out := &limitedBuffer{max: maxTemplateOutput}
+	if err := tmpl.Execute(out, data); err != nil {
+		if timedOut {
+			return "", fmt.Errorf("template execution exceeded %s", templateTimeout)
+		}
+		return "", fmt.Errorf("template execution error: %s", strings.TrimPrefix(err.Error(), "template: "))
+	}
+	return out.String(), nil
+}
+
+// templateStep is the function instrumentTemplate calls before each unit of
+// repeated work
+const templateStep = "_step"
+
+// instrumentTemplate inserts a call to templateStep at the start of every
+// range body and every template of tmpl
+func instrumentTemplate(tmpl *template.Template) error {
+	stepTree, err := parse.Parse("step", "{{"+templateStep+"}}", "", "", map[string]interface{}{templateStep: true})
+	if err != nil {
+		return err
+	}
+	stepNode := stepTree["step"].Root.Nodes[0]
+
+	var walk func(list *parse.ListNode)
+	walk = func(list *parse.ListNode) {
+		if list == nil {
+			return
+		}
+		for _, n := range list.Nodes {
+			switch n := n.(type) {
+			case *parse.RangeNode:
+				walk(n.List)
+				walk(n.ElseList)
+				if n.List != nil {
+					n.List.Nodes = append([]parse.Node{stepNode}, n.List.Nodes...)
+				}
+			case *parse.IfNode:
+				walk(n.List)
+				walk(n.ElseList)
+			case *parse.WithNode:
+				walk(n.List)
+				walk(n.ElseList)
+			case *parse.ListNode:
+				walk(n)
+			}
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		walk(t.Tree.Root)
+		t.Tree.Root.Nodes = append([]parse.Node{stepNode}, t.Tree.Root.Nodes...)
+	}
+	return nil
+}
+
+func xmlConventions(args map[string]interface{}) (attrPrefix, textKey string) {
+	attrPrefix, textKey = "@", "#text"
+	if v, ok := args["attr_prefix"].(string); ok {
+		attrPrefix = v
+	}
+	if v := getStr(args, "text_key"); v != "" {
+		textKey = v
+	}
+	return
+}
+
+func (p *TransformProfile) xmlToJSON(args map[string]interface{}) (string, error) {
+	src := getStr(args, "xml")
+	if src == "" {
+		return "", fmt.Errorf("xml is required")
+	}
+	attrPrefix, textKey := xmlConventions(args)
+
+	dec := xml.NewDecoder(strings.NewReader(src))
+	dec.Strict = true
+	posErr := func(err error) error {
+		line, col := dec.InputPos()
+		return fmt.Errorf("invalid XML at line %d, column %d: %s", line, col, err)
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("no root element found")
+		}
+		if err != nil {
+			return "", posErr(err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		value, err := xmlElementToJSON(dec, start, attrPrefix, textKey)
+		if err != nil {
+			return "", posErr(err)
+		}
+		var out strings.Builder
+		enc := json.NewEncoder(&out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		enc.Encode(bsonDoc{{start.Name.Local, value}})
+		return strings.TrimRight(out.String(), "\n"), nil
+	}
+}
+
+// xmlElementToJSON converts the element opened by start. Elements with only
+// text become strings; anything else becomes an ordered object.
+func xmlElementToJSON(dec *xml.Decoder, start xml.StartElement, attrPrefix, textKey string) (interface{}, error) {
+	obj := bsonDoc{}
+	for _, a := range start.Attr {
+		name := a.Name.Local
+		if a.Name.Space == "xmlns" {
+			name = "xmlns:" + name
+		}
+		obj = append(obj, bsonElem{attrPrefix + name, a.Value})
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := xmlElementToJSON(dec, t, attrPrefix, textKey)
+			if err != nil {
+				return nil, err
+			}
+			obj = addXMLChild(obj, t.Name.Local, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(obj) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				obj = append(obj, bsonElem{textKey, content})
+			}
+			return obj, nil
+		}
+	}
+}
+
+// addXMLChild appends a child, turning repeated element names into arrays
+func addXMLChild(obj bsonDoc, name string, child interface{}) bsonDoc {
+	for i, e := range obj {
+		if e.Key != name {
+			continue
+		}
+		if arr, ok := e.Value.([]interface{}); ok {
+			obj[i].Value = append(arr, child)
+		} else {
+			obj[i].Value = []interface{}{e.Value, child}
+		}
+		return obj
+	}
+	return append(obj, bsonElem{name, child})
+}
+
+func (p *TransformProfile) jsonToXML(args map[string]interface{}) (string, error) {
+	src := getStr(args, "json")
+	if src == "" {
+		return "", fmt.Errorf("json is required")
+	}
+	attrPrefix, textKey := xmlConventions(args)
+	indent := true
+	if v, ok := args["indent"].(bool); ok {
+		indent = v
+	}
+
+	dec := json.NewDecoder(strings.NewReader(src))
+	dec.UseNumber()
+	value, err := decodeOrderedJSON(dec, false)
+	if err != nil {
+		return "", fmt.Errorf("invalid JSON at offset %d: %s", dec.InputOffset(), err)
+	}
+
+	rootName := getStr(args, "root")
+	if obj, ok := value.(bsonDoc); ok && len(obj) == 1 && rootName == "" && !strings.HasPrefix(obj[0].Key, attrPrefix) {
+		rootName, value = obj[0].Key, obj[0].Value
+	}
+	if rootName == "" {
+		rootName = "root"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	if !indent {
+		sb.Reset()
+		sb.WriteString(strings.TrimSuffix(xml.Header, "\n"))
+	}
+	w := &xmlWriter{sb: &sb, attrPrefix: attrPrefix, textKey: textKey, indent: indent}
+	if err := w.element(rootName, value, 0); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+type xmlWriter struct {
+	sb         *strings.Builder
+	attrPrefix string
+	textKey    string
+	indent     bool
+}
+
+func (w *xmlWriter) pad(depth int) {
+	if w.indent {
+		w.sb.WriteString(strings.Repeat("  ", depth))
+	}
+}
+
+func (w *xmlWriter) newline() {
+	if w.indent {
+		w.sb.WriteByte('\n')
+	}
+}
+
+func (w *xmlWriter) element(name string, value interface{}, depth int) error {
+	if !isXMLName(name) {
+		return fmt.Errorf("%q is not a valid XML element name", name)
+	}
+
+	// Arrays repeat the element
+	if arr, ok := value.([]interface{}); ok {
+		for _, item := range arr {
+			if err := w.element(name, item, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w.pad(depth)
+	w.sb.WriteString("<" + name)
+
+	obj, isObj := value.(bsonDoc)
+	if !isObj {
+		if xmlScalar(value) == "" {
+			w.sb.WriteString("/>")
+		} else {
+			w.sb.WriteByte('>')
+			xml.EscapeText(w.sb, []byte(xmlScalar(value)))
+			w.sb.WriteString("</" + name + ">")
+		}
+		w.newline()
+		return nil
+	}
+
+	var text string
+	var children bsonDoc
+	for _, e := range obj {
+		switch {
+		case e.Key == w.textKey:
+			text = xmlScalar(e.Value)
+		case w.attrPrefix != "" && strings.HasPrefix(e.Key, w.attrPrefix):
+			attr := strings.TrimPrefix(e.Key, w.attrPrefix)
+			if !isXMLName(attr) {
+				return fmt.Errorf("%q is not a valid XML attribute name", attr)
+			}
+			w.sb.WriteString(" " + attr + "=\"")
+			xml.EscapeText(w.sb, []byte(xmlScalar(e.Value)))
+			w.sb.WriteByte('"')
+		default:
+			children = append(children, e)
+		}
+	}
+
+	if len(children) == 0 {
+		if text == "" {
+			w.sb.WriteString("/>")
+		} else {
+			w.sb.WriteByte('>')
+			xml.EscapeText(w.sb, []byte(text))
+			w.sb.WriteString("</" + name + ">")
+		}
+		w.newline()
+		return nil
+	}
+
+	w.sb.WriteByte('>')
+	w.newline()
+	if text != "" {
+		w.pad(depth + 1)
+		xml.EscapeText(w.sb, []byte(text))
+		w.newline()
+	}
+	for _, c := range children {
+		if err := w.element(c.Key, c.Value, depth+1); err != nil {
+			return err
+		}
+	}
+	w.pad(depth)
+	w.sb.WriteString("</" + name + ">")
+	w.newline()
+	return nil
+}
+
+func xmlScalar(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case bsonDoc, []interface{}:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// isXMLName reports whether s is usable as an element or attribute name
+func isXMLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.' || r == ':') {
+			continue
+		}
+		return false
+	}
+	return true
+}
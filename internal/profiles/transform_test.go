@@ -0,0 +1,237 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     interface{}
+		want     string
+		wantErr  string
+	}{
+		{"field", "Hello {{.name}}", map[string]interface{}{"name": "Ada"}, "Hello Ada", ""},
+		{"range", "{{range .items}}[{{.}}]{{end}}", map[string]interface{}{"items": []interface{}{"a", "b"}}, "[a][b]", ""},
+		{"range else", "{{range .items}}x{{else}}none{{end}}", map[string]interface{}{}, "none", ""},
+		{"define", `{{define "row"}}<{{.}}>{{end}}{{range .items}}{{template "row" .}}{{end}}`, map[string]interface{}{"items": []interface{}{1.0, 2.0}}, "<1><2>", ""},
+		{"nested", "{{range .a}}{{range .}}{{.}}{{end}};{{end}}", map[string]interface{}{"a": []interface{}{[]interface{}{"x", "y"}, []interface{}{"z"}}}, "xy;z;", ""},
+		{"parse error", "{{.name", nil, "", "template parse error"},
+		{"huge range", "{{range 99999999999}}{{end}}", nil, "", "template execution exceeded"},
+		{"recursion", `{{define "a"}}{{template "a" .}}{{template "a" .}}{{end}}{{template "a" .}}`, nil, "", "template execution"},
+		{"output limit", `{{range 99999999999}}xxxxxxxxxxxxxxxx{{end}}`, nil, "", "output exceeds"},
+		{"replace", `{{replace .s "b" "xy"}}`, map[string]interface{}{"s": "abcb"}, "axycxy", ""},
+		{"replace empty old", `{{$s := printf "%0200d" 0}}{{$x := replace $s "" $s}}{{len $x}}`, nil, "", "must not be empty"},
+		{"replace growth", `{{$s := printf "%01000d" 0}}{{$s = replace $s "0" $s}}{{len $s}}`, nil, "1000000", ""},
+		{"replace too large", `{{$s := printf "%01000d" 0}}{{$s = replace $s "0" $s}}{{$s = replace $s "0" "00"}}`, nil, "", "replace: result exceeds"},
+		{"printf width", `{{$s := printf "%020000d" 0}}{{$x := replace $s "" $s}}{{len $x}}`, nil, "", "printf: widths"},
+		{"printf precision", `{{printf "%.2000f" 1.0}}`, nil, "", "printf: widths"},
+		{"printf star width", `{{printf "%*d" 100000000 1}}`, nil, "", "printf: * widths"},
+		{"printf", `{{printf "%05.1f|%-4s|%[1]v%%" 3.14159 "ab"}}`, nil, "003.1|ab  |3.14159%", ""},
+		{"doubling a variable", `{{$s := "xxxxxxxx"}}{{range 100}}{{$s = print $s $s}}{{end}}`, nil, "", "error calling print: result exceeds"},
+		{"escaping a variable", `{{$s := printf "%01000d" 0}}{{$s = replace $s "0" $s}}{{$s = replace $s "0" "&"}}{{html $s}}`, nil, "", "error calling html: result exceeds"},
+		{"join", `{{join (split "a,b" ",") "-"}}`, nil, "a-b", ""},
+		{"join too large", `{{$s := printf "%01000d" 0}}{{$t := replace $s "0" $s}}{{join (split $s "") $t}}`, nil, "", "join: result exceeds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			got, err := (&TransformProfile{}).renderTemplate(map[string]interface{}{"template": tt.template, "data": tt.data})
+			if elapsed := time.Since(start); elapsed > templateTimeout+time.Second {
+				t.Fatalf("took %s", elapsed)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXMLToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{
+			"nested elements and attributes",
+			map[string]interface{}{"xml": `<order id="7"><customer><name>Ada</name></customer><item sku="a">Pen</item><item sku="b">Ink</item><empty/></order>`},
+			`{"order":{"@id":"7","customer":{"name":"Ada"},"item":[{"@sku":"a","#text":"Pen"},{"@sku":"b","#text":"Ink"}],"empty":""}}`,
+			"",
+		},
+		{
+			"namespace declaration",
+			map[string]interface{}{"xml": `<a xmlns:x="urn:x"><x:b>1</x:b></a>`},
+			`{"a":{"@xmlns:x":"urn:x","b":"1"}}`,
+			"",
+		},
+		{
+			"custom conventions",
+			map[string]interface{}{"xml": `<r id="1">text<c/></r>`, "attr_prefix": "-", "text_key": "value"},
+			`{"r":{"-id":"1","c":"","value":"text"}}`,
+			"",
+		},
+		{"mismatched tag", map[string]interface{}{"xml": "<a>\n<b></a>"}, "", "line 2, column 8"},
+		{"no root", map[string]interface{}{"xml": "<!-- nothing -->"}, "", "no root element"},
+		{"missing", map[string]interface{}{}, "", "xml is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&TransformProfile{}).xmlToJSON(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, []byte(got)); err != nil {
+				t.Fatalf("invalid JSON %q: %s", got, err)
+			}
+			if compact.String() != tt.want {
+				t.Errorf("got  %s\nwant %s", compact.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONToXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		root    string
+		want    string
+		wantErr string
+	}{
+		{
+			"attributes, text and arrays",
+			`{"order":{"@id":"7","item":[{"@sku":"a","#text":"Pen"},"Ink"],"note":"a < b"}}`, "",
+			`<order id="7"><item sku="a">Pen</item><item>Ink</item><note>a &lt; b</note></order>`, "",
+		},
+		{"default root", `{"a":1,"b":[true,null]}`, "", `<root><a>1</a><b>true</b><b/></root>`, ""},
+		{"named root", `{"a":1}`, "doc", `<doc><a>1</a></doc>`, ""},
+		{"invalid name", `{"1st":"x"}`, "", "", "not a valid XML element name"},
+		{"invalid JSON", `{"a":}`, "", "", "invalid JSON at offset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string]interface{}{"json": tt.json, "indent": false}
+			if tt.root != "" {
+				args["root"] = tt.root
+			}
+			got, err := (&TransformProfile{}).jsonToXML(args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got = strings.TrimPrefix(got, strings.TrimSuffix(xml.Header, "\n")); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXMLJSONRoundTrip(t *testing.T) {
+	const doc = `<feed lang="en"><entry id="1"><title>One</title><tag>a</tag><tag>b</tag></entry><entry id="2"><title>Two &amp; more</title></entry></feed>`
+	p := &TransformProfile{}
+	js, err := p.xmlToJSON(map[string]interface{}{"xml": doc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := p.jsonToXML(map[string]interface{}{"json": js, "indent": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back = strings.TrimPrefix(back, strings.TrimSuffix(xml.Header, "\n")); back != doc {
+		t.Errorf("round trip:\n got  %s\n want %s", back, doc)
+	}
+}
+
+func TestBaseNEncoding(t *testing.T) {
+	p := &TransformProfile{}
+	tests := []struct {
+		name    string
+		call    func(map[string]interface{}) (string, error)
+		args    map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{"base32 padded", p.base32Encode, map[string]interface{}{"text": "foobar"}, "MZXW6YTBOI======", ""},
+		{"base32 unpadded", p.base32Encode, map[string]interface{}{"text": "foo", "no_padding": true}, "MZXW6", ""},
+		{"base32 from hex", p.base32Encode, map[string]interface{}{"text": "0xff00", "from_hex": true}, "74AA====", ""},
+		{"base32 decode", p.base32Decode, map[string]interface{}{"encoded": "MZXW6YTBOI======"}, "foobar", ""},
+		{"base32 decode grouped lowercase", p.base32Decode, map[string]interface{}{"encoded": "mzxw 6ytb-oi"}, "foobar", ""},
+		{"base32 invalid character", p.base32Decode, map[string]interface{}{"encoded": "MZXW1"}, "", `invalid base32 character '1' at position 4`},
+		{"base58", p.base58Encode, map[string]interface{}{"text": "Hello World!"}, "2NEpo7TZRRrLZSi2U", ""},
+		{"base58 address", p.base58Encode, map[string]interface{}{"text": "00eb15231dfceb60925886b67d065299925915aeb172c06647", "from_hex": true}, "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L", ""},
+		{"base58 zeros", p.base58Encode, map[string]interface{}{"text": "00000000000000000000", "from_hex": true}, "1111111111", ""},
+		{"base58 decode", p.base58Decode, map[string]interface{}{"encoded": "2NEpo7TZRRrLZSi2U"}, "Hello World!", ""},
+		{"base58 decode binary", p.base58Decode, map[string]interface{}{"encoded": "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"}, "(binary data, 25 bytes, hex): 00eb15231dfceb60925886b67d065299925915aeb172c06647", ""},
+		{"base58 invalid character", p.base58Decode, map[string]interface{}{"encoded": "2NEpo0"}, "", `invalid base58 character '0' at position 5`},
+		{"missing text", p.base58Encode, map[string]interface{}{}, "", "text is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.call(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseNRoundTrip(t *testing.T) {
+	inputs := [][]byte{{0}, {0, 0, 1}, {255}, []byte("The quick brown fox"), {0, 1, 2, 3, 4, 5, 250, 251, 252, 253, 254, 255}}
+	for _, data := range inputs {
+		decoded, err := base58Decode(base58Encode(data))
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("base58 round trip of %x = %x, %v", data, decoded, err)
+		}
+		for _, noPad := range []bool{false, true} {
+			p := &TransformProfile{}
+			enc, err := p.base32Encode(map[string]interface{}{"text": hex.EncodeToString(data), "from_hex": true, "no_padding": noPad})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(enc, "="))
+			if err != nil || !bytes.Equal(dec, data) {
+				t.Errorf("base32 round trip of %x (no_padding %v) = %x, %v", data, noPad, dec, err)
+			}
+		}
+	}
+}
@@ -6,26 +6,45 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 )
 
-type WebhookProfile struct{}
+type WebhookProfile struct {
+	limits webhookLimits
+}
 
 func (p *WebhookProfile) ID() string { return "webhook" }
 
+func (p *WebhookProfile) UsesNetwork() bool { return true }
+
 func (p *WebhookProfile) Tools() []Tool {
 	return []Tool{
 		{
 			Name:        "send_webhook",
-			Description: "Send an HTTP webhook (POST JSON to a URL)",
+			Description: "Send an HTTP webhook (POST JSON to a URL, or GET with the payload as query parameters)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"url":     map[string]interface{}{"type": "string", "description": "Webhook URL to send to"},
-					"payload": map[string]interface{}{"type": "object", "description": "JSON payload to send"},
-					"method":  map[string]interface{}{"type": "string", "description": "HTTP method (default POST)"},
+					"payload": map[string]interface{}{"type": "object", "description": "JSON payload to send; for GET, an object of query parameters (arrays become repeated parameters)"},
+					"method":  map[string]interface{}{"type": "string", "enum": []string{"POST", "PUT", "PATCH", "DELETE", "GET"}, "description": "HTTP method (default POST)"},
 					"headers": map[string]interface{}{"type": "object", "description": "Custom headers"},
+					"data":    map[string]interface{}{"type": "object", "description": "Values for {{placeholders}} in the payload's strings, e.g. {{host}} or {{check.status}}; {{name|fallback}} sets a fallback"},
+					"idempotency_key": map[string]interface{}{
+						"type":        "string",
+						"description": "Sent as the Idempotency-Key header so the receiver can safely discard a retried delivery; reuse the same key when retrying",
+					},
+					"expect_status": map[string]interface{}{
+						"type":        []string{"integer", "array"},
+						"items":       map[string]interface{}{"type": "integer"},
+						"description": "Expected response status code or list of codes; any other status is reported as an error",
+					},
+					"expect_json_path": map[string]interface{}{"type": "string", "description": "Dot path into the JSON response that must exist, e.g. data.items[0].id"},
+					"expect_value":     map[string]interface{}{"description": "Value expected at expect_json_path (requires expect_json_path)"},
 				},
 				"required": []string{"url", "payload"},
 			},
@@ -38,6 +57,7 @@ func (p *WebhookProfile) Tools() []Tool {
 				"properties": map[string]interface{}{
 					"text":    map[string]interface{}{"type": "string", "description": "Message text (supports Slack markdown)"},
 					"channel": map[string]interface{}{"type": "string", "description": "Override channel (optional)"},
+					"data":    map[string]interface{}{"type": "object", "description": "Values for {{placeholders}} in the text, e.g. {{host}} or {{check.status}}; {{name|fallback}} sets a fallback"},
 				},
 				"required": []string{"text"},
 			},
@@ -50,6 +70,7 @@ func (p *WebhookProfile) Tools() []Tool {
 				"properties": map[string]interface{}{
 					"content":  map[string]interface{}{"type": "string", "description": "Message content (supports Discord markdown)"},
 					"username": map[string]interface{}{"type": "string", "description": "Override bot username (optional)"},
+					"data":     map[string]interface{}{"type": "object", "description": "Values for {{placeholders}} in the content, e.g. {{host}} or {{check.status}}; {{name|fallback}} sets a fallback"},
 				},
 				"required": []string{"content"},
 			},
@@ -57,6 +78,21 @@ func (p *WebhookProfile) Tools() []Tool {
 	}
 }
 
+func (p *WebhookProfile) RequiredEnv() []EnvSpec {
+	return append([]EnvSpec{
+		{Name: "SLACK_WEBHOOK_URL", Required: false, Description: "Slack incoming webhook URL for send_slack"},
+		{Name: "DISCORD_WEBHOOK_URL", Required: false, Description: "Discord webhook URL for send_discord"},
+		{Name: "ALLOWED_URLS", Required: false, Description: "Comma-separated allowlist for send_webhook"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 15, max 300)"},
+		{Name: "WEBHOOK_RATE_LIMIT", Required: false, Description: "Maximum sends per minute across all tools (default unlimited)"},
+		{Name: "WEBHOOK_DEDUP_SECONDS", Required: false, Description: "Suppress identical sends to the same destination within this many seconds (default 0 = off, max 3600)"},
+	}, outboundHTTPEnv...)
+}
+
+func (p *WebhookProfile) DryRunTools() []string {
+	return []string{"send_webhook", "send_slack", "send_discord"}
+}
+
 func (p *WebhookProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "send_webhook":
@@ -92,41 +128,263 @@ func (p *WebhookProfile) sendWebhook(args map[string]interface{}, env map[string
 		}
 	}
 
+	if err := validateURL(rawURL, env); err != nil {
+		return "", err
+	}
+
+	method := strings.ToUpper(getStr(args, "method"))
+	switch method {
+	case "":
+		method = "POST"
+	case "POST", "PUT", "PATCH", "DELETE", "GET":
+	default:
+		return "", invalidInputf("unsupported method %s (use POST, PUT, PATCH, DELETE or GET)", method)
+	}
+
+	// GET requests carry the payload in the query string and have no body
+	var data []byte
+	var err error
 	payload, _ := args["payload"]
-	data, err := json.Marshal(payload)
+	tmplData, err := webhookTemplateData(args)
 	if err != nil {
+		return "", err
+	}
+	if tmplData != nil {
+		missing := map[string]bool{}
+		payload = fillPayload(payload, tmplData, missing)
+		if err := unresolvedError(missing); err != nil {
+			return "", err
+		}
+	}
+	if method == "GET" {
+		if rawURL, err = webhookQueryURL(rawURL, payload); err != nil {
+			return "", err
+		}
+	} else if data, err = json.Marshal(payload); err != nil {
 		return "", fmt.Errorf("invalid payload: %s", err)
 	}
 
-	method := getStr(args, "method")
-	if method == "" {
-		method = "POST"
+	var expectStatus []int
+	if v, ok := args["expect_status"]; ok && v != nil {
+		if expectStatus, err = parseExpectStatus(v); err != nil {
+			return "", err
+		}
+	}
+	jsonPath := getStr(args, "expect_json_path")
+	expectValue, hasExpectValue := args["expect_value"]
+	if hasExpectValue && jsonPath == "" {
+		return "", invalidInputf("expect_value requires expect_json_path")
 	}
 
-	req, err := http.NewRequest(method, rawURL, bytes.NewReader(data))
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, rawURL, reqBody)
 	if err != nil {
 		return "", fmt.Errorf("invalid request: %s", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "Dublyo-MCP-Webhook/1.0")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", userAgent(env, "Dublyo-MCP-Webhook/1.0"))
 
 	if h, ok := args["headers"].(map[string]interface{}); ok {
 		for k, v := range h {
 			req.Header.Set(k, fmt.Sprintf("%v", v))
 		}
 	}
+	if method == "GET" {
+		req.Header.Del("Content-Type")
+	}
+	idempotencyKey := getStr(args, "idempotency_key")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	if IsDryRun(args) {
+		desc := fmt.Sprintf("%s %s", method, rawURL)
+		if data != nil {
+			desc += fmt.Sprintf(" with %d byte JSON payload", len(data))
+		}
+		if idempotencyKey != "" {
+			desc += " (Idempotency-Key " + idempotencyKey + ")"
+		}
+		return dryRunf("would send %s", desc), nil
+	}
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	// Deliveries with different idempotency keys are distinct sends
+	dest := method + " " + rawURL
+	if idempotencyKey != "" {
+		dest += " " + idempotencyKey
+	}
+	if msg, err := p.limits.reserve(env, dest, data); msg != "" || err != nil {
+		return msg, err
+	}
+	client, err := withDefaultHeaders(safeHTTPClient(httpTimeout(env, 15*time.Second)), env)
+	if err != nil {
+		return "", err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("webhook failed: %s", err)
 	}
 	defer resp.Body.Close()
+	p.limits.delivered(env, dest, data)
+
+	// Read more than is shown when the body has to be parsed for expect_json_path
+	limit := int64(4096)
+	if jsonPath != "" {
+		limit = 1024 * 1024
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, limit))
+	shown := body
+	if len(shown) > 4096 {
+		shown = shown[:4096]
+	}
+	status := fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+
+	if len(expectStatus) > 0 && !containsInt(expectStatus, resp.StatusCode) {
+		return "", backendErrorf("webhook returned status %s, expected %s\nResponse: %s",
+			status, joinInts(expectStatus), string(shown))
+	}
+	if jsonPath != "" {
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return "", backendErrorf("webhook response is not valid JSON, cannot check %s\nResponse: %s", jsonPath, string(shown))
+		}
+		got := navigateJSON(data, jsonPath)
+		if got == nil {
+			return "", backendErrorf("webhook response has no value at %s\nResponse: %s", jsonPath, string(shown))
+		}
+		if hasExpectValue && !jsonValueEqual(got, expectValue) {
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(expectValue)
+			return "", backendErrorf("webhook response has %s = %s, expected %s", jsonPath, gotJSON, wantJSON)
+		}
+	}
+
+	result := fmt.Sprintf("Webhook sent!\nURL: %s\nMethod: %s\nStatus: %s\nResponse: %s",
+		rawURL, method, status, string(shown))
+	if idempotencyKey != "" {
+		result += "\nIdempotency-Key: " + idempotencyKey
+	}
+	if len(expectStatus) > 0 || jsonPath != "" {
+		result += "\nExpectations: all passed"
+	}
+	return result, nil
+}
 
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+// webhookQueryURL adds a GET payload's fields to rawURL's query string.
+// Arrays become repeated parameters; nested objects can't be expressed in a
+// query string and are rejected.
+func webhookQueryURL(rawURL string, payload interface{}) (string, error) {
+	var fields map[string]interface{}
+	switch p := payload.(type) {
+	case nil:
+		return rawURL, nil
+	case map[string]interface{}:
+		fields = p
+	default:
+		return "", invalidInputf("payload must be an object of query parameters for GET")
+	}
+	if len(fields) == 0 {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", invalidInputf("invalid URL: %s", err)
+	}
+	query := u.Query()
+	for k, v := range fields {
+		items, isList := v.([]interface{})
+		if !isList {
+			items = []interface{}{v}
+		}
+		for _, item := range items {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return "", invalidInputf("payload field %s must be a scalar or a list of scalars for GET", k)
+			}
+			query.Add(k, formValue(item))
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// parseExpectStatus accepts a status code, a list of codes, or a
+// comma-separated string of codes
+func parseExpectStatus(v interface{}) ([]int, error) {
+	var raw []interface{}
+	switch t := v.(type) {
+	case []interface{}:
+		raw = t
+	case string:
+		for _, s := range strings.Split(t, ",") {
+			raw = append(raw, strings.TrimSpace(s))
+		}
+	default:
+		raw = []interface{}{t}
+	}
+
+	codes := make([]int, 0, len(raw))
+	for _, r := range raw {
+		var code int
+		switch c := r.(type) {
+		case float64:
+			code = int(c)
+			if float64(code) != c {
+				code = 0
+			}
+		case string:
+			code, _ = strconv.Atoi(c)
+		}
+		if code < 100 || code > 599 {
+			return nil, invalidInputf("expect_status must be HTTP status codes (100-599), got %v", r)
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, invalidInputf("expect_status must not be empty")
+	}
+	return codes, nil
+}
 
-	return fmt.Sprintf("Webhook sent!\nURL: %s\nMethod: %s\nStatus: %d %s\nResponse: %s",
-		rawURL, method, resp.StatusCode, http.StatusText(resp.StatusCode), string(body)), nil
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func joinInts(list []int) string {
+	parts := make([]string, len(list))
+	for i, v := range list {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, " or ")
+}
+
+// jsonValueEqual compares decoded JSON values. A string expectation also
+// matches a scalar whose text form is the same, so "42" matches 42.
+func jsonValueEqual(got, want interface{}) bool {
+	if reflect.DeepEqual(got, want) {
+		return true
+	}
+	s, ok := want.(string)
+	if !ok {
+		return false
+	}
+	switch got.(type) {
+	case float64, bool:
+		b, _ := json.Marshal(got)
+		return string(b) == s
+	}
+	return false
 }
 
 func (p *WebhookProfile) sendSlack(args map[string]interface{}, env map[string]string) (string, error) {
@@ -139,6 +397,10 @@ func (p *WebhookProfile) sendSlack(args map[string]interface{}, env map[string]s
 	if text == "" {
 		return "", fmt.Errorf("text is required")
 	}
+	text, err := fillMessage(text, args)
+	if err != nil {
+		return "", err
+	}
 
 	payload := map[string]interface{}{"text": text}
 	if ch := getStr(args, "channel"); ch != "" {
@@ -146,11 +408,22 @@ func (p *WebhookProfile) sendSlack(args map[string]interface{}, env map[string]s
 	}
 
 	data, _ := json.Marshal(payload)
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewReader(data))
+	if IsDryRun(args) {
+		return dryRunf("would post to Slack: %s", data), nil
+	}
+	if msg, err := p.limits.reserve(env, "Slack", data); msg != "" || err != nil {
+		return msg, err
+	}
+	client, err := withDefaultHeaders(safeHTTPClient(httpTimeout(env, 15*time.Second)), env)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(data))
 	if err != nil {
 		return "", fmt.Errorf("slack webhook failed: %s", err)
 	}
 	defer resp.Body.Close()
+	p.limits.delivered(env, "Slack", data)
 	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
 
 	if resp.StatusCode == 200 {
@@ -169,6 +442,10 @@ func (p *WebhookProfile) sendDiscord(args map[string]interface{}, env map[string
 	if content == "" {
 		return "", fmt.Errorf("content is required")
 	}
+	content, err := fillMessage(content, args)
+	if err != nil {
+		return "", err
+	}
 
 	payload := map[string]interface{}{"content": content}
 	if username := getStr(args, "username"); username != "" {
@@ -176,11 +453,22 @@ func (p *WebhookProfile) sendDiscord(args map[string]interface{}, env map[string
 	}
 
 	data, _ := json.Marshal(payload)
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewReader(data))
+	if IsDryRun(args) {
+		return dryRunf("would post to Discord: %s", data), nil
+	}
+	if msg, err := p.limits.reserve(env, "Discord", data); msg != "" || err != nil {
+		return msg, err
+	}
+	client, err := withDefaultHeaders(safeHTTPClient(httpTimeout(env, 15*time.Second)), env)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(data))
 	if err != nil {
 		return "", fmt.Errorf("discord webhook failed: %s", err)
 	}
 	defer resp.Body.Close()
+	p.limits.delivered(env, "Discord", data)
 	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
 
 	if resp.StatusCode == 204 || resp.StatusCode == 200 {
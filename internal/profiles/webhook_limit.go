@@ -0,0 +1,122 @@
+package profiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// webhookOutbound tracks recent sends for one connection, so a looping agent
+// can't flood a channel with alerts
+type webhookOutbound struct {
+	sent     []time.Time          // send times within the last minute
+	recent   map[string]time.Time // payload fingerprint → last successful send
+	lastUsed time.Time
+}
+
+// webhookLimits holds per-connection outbound state, keyed by the connection
+// ID the gateway puts in env, so it survives config changes. State unused
+// for longer than any window it enforces is dropped.
+type webhookLimits struct {
+	mu        sync.Mutex
+	conns     map[string]*webhookOutbound
+	lastPrune time.Time
+}
+
+// webhookIdleTTL is how long a connection's state outlives its last send:
+// the longest dedup window
+const webhookIdleTTL = time.Hour
+
+// get returns the state of the connection env belongs to. Caller must hold mu.
+func (l *webhookLimits) get(env map[string]string, now time.Time) *webhookOutbound {
+	if l.conns == nil {
+		l.conns = map[string]*webhookOutbound{}
+	}
+	if now.Sub(l.lastPrune) >= time.Minute {
+		for id, o := range l.conns {
+			if now.Sub(o.lastUsed) >= webhookIdleTTL {
+				delete(l.conns, id)
+			}
+		}
+		l.lastPrune = now
+	}
+	id := env[EnvConnectionID]
+	o, ok := l.conns[id]
+	if !ok {
+		o = &webhookOutbound{recent: map[string]time.Time{}}
+		l.conns[id] = o
+	}
+	o.lastUsed = now
+	return o
+}
+
+// reserve checks the dedup window and rate limit for a send of body to dest.
+// It returns a message if the send should be suppressed as a duplicate, or an
+// error if the rate limit is exhausted; otherwise the send is counted.
+func (l *webhookLimits) reserve(env map[string]string, dest string, body []byte) (string, error) {
+	perMinute := envInt(env["WEBHOOK_RATE_LIMIT"], 0)
+	dedup := envInt(env["WEBHOOK_DEDUP_SECONDS"], 0)
+	if dedup > 3600 {
+		dedup = 3600
+	}
+	if perMinute <= 0 && dedup <= 0 {
+		return "", nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	o := l.get(env, now)
+
+	if dedup > 0 {
+		window := time.Duration(dedup) * time.Second
+		for k, t := range o.recent {
+			if now.Sub(t) >= window {
+				delete(o.recent, k)
+			}
+		}
+		if last, ok := o.recent[payloadFingerprint(dest, body)]; ok {
+			return fmt.Sprintf("Not sent: an identical payload went to %s %s ago (WEBHOOK_DEDUP_SECONDS=%d)",
+				dest, now.Sub(last).Round(time.Second), dedup), nil
+		}
+	}
+
+	if perMinute > 0 {
+		windowStart := now.Add(-time.Minute)
+		valid := o.sent[:0]
+		for _, t := range o.sent {
+			if t.After(windowStart) {
+				valid = append(valid, t)
+			}
+		}
+		o.sent = valid
+		if len(o.sent) >= perMinute {
+			retry := o.sent[0].Add(time.Minute).Sub(now).Round(time.Second)
+			return "", forbiddenf("webhook rate limit reached (%d per minute, WEBHOOK_RATE_LIMIT); not sent, retry in %s", perMinute, retry)
+		}
+		o.sent = append(o.sent, now)
+	}
+	return "", nil
+}
+
+// delivered starts the dedup window for body. It is called only once the
+// request went out, so a send that failed in transit can be retried at once.
+func (l *webhookLimits) delivered(env map[string]string, dest string, body []byte) {
+	if envInt(env["WEBHOOK_DEDUP_SECONDS"], 0) <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.get(env, now).recent[payloadFingerprint(dest, body)] = now
+}
+
+func payloadFingerprint(dest string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(dest))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
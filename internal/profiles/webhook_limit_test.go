@@ -0,0 +1,55 @@
+package profiles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookLimits(t *testing.T) {
+	connA := map[string]string{EnvConnectionID: "a", "WEBHOOK_RATE_LIMIT": "2", "WEBHOOK_DEDUP_SECONDS": "60"}
+	// Same connection after a config change
+	connAEdited := map[string]string{EnvConnectionID: "a", "WEBHOOK_RATE_LIMIT": "2", "WEBHOOK_DEDUP_SECONDS": "60", "SLACK_WEBHOOK_URL": "https://hooks.example.com/x"}
+	// Another connection configured identically
+	connB := map[string]string{EnvConnectionID: "b", "WEBHOOK_RATE_LIMIT": "2", "WEBHOOK_DEDUP_SECONDS": "60"}
+
+	var l webhookLimits
+	steps := []struct {
+		name      string
+		env       map[string]string
+		body      string
+		deliver   bool
+		wantDup   bool
+		wantLimit bool
+	}{
+		{"first send", connA, "one", true, false, false},
+		{"duplicate", connA, "one", false, true, false},
+		{"duplicate after config change", connAEdited, "one", false, true, false},
+		{"second send", connAEdited, "two", true, false, false},
+		{"rate limited", connA, "three", false, false, true},
+		{"other connection has its own budget", connB, "one", true, false, false},
+	}
+	for _, st := range steps {
+		msg, err := l.reserve(st.env, "https://example.com/hook", []byte(st.body))
+		if (msg != "") != st.wantDup {
+			t.Errorf("%s: duplicate message = %q, want duplicate %v", st.name, msg, st.wantDup)
+		}
+		if (err != nil) != st.wantLimit {
+			t.Errorf("%s: err = %v, want rate limited %v", st.name, err, st.wantLimit)
+		}
+		if st.deliver && msg == "" && err == nil {
+			l.delivered(st.env, "https://example.com/hook", []byte(st.body))
+		}
+	}
+
+	// Idle connections are forgotten
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.conns) != 2 {
+		t.Fatalf("tracking %d connections, want 2", len(l.conns))
+	}
+	later := time.Now().Add(webhookIdleTTL + time.Minute)
+	l.get(connB, later)
+	if _, ok := l.conns["a"]; ok || len(l.conns) != 1 {
+		t.Errorf("idle connection not pruned: %d tracked", len(l.conns))
+	}
+}
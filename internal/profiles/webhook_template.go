@@ -0,0 +1,92 @@
+package profiles
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Messages sent by the webhook profile can be templates: with a data object
+// in the call, {{path}} is replaced by the value at that dot path in data
+// (e.g. {{host}}, {{check.status}}, {{items[0].name}}) and {{path|fallback}}
+// uses fallback when it's missing. Without data, text is sent as is.
+
+var webhookPlaceholderRe = regexp.MustCompile(`\{\{\s*([\w.\[\]-]+)\s*(?:\|([^}]*))?\}\}`)
+
+// webhookTemplateData returns the call's data object, or nil if it has none
+func webhookTemplateData(args map[string]interface{}) (map[string]interface{}, error) {
+	raw, ok := args["data"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, invalidInputf("data must be an object")
+	}
+	return data, nil
+}
+
+// fillMessage fills a message's placeholders from the call's data, if any
+func fillMessage(text string, args map[string]interface{}) (string, error) {
+	data, err := webhookTemplateData(args)
+	if err != nil || data == nil {
+		return text, err
+	}
+	missing := map[string]bool{}
+	text = fillTemplate(text, data, missing)
+	return text, unresolvedError(missing)
+}
+
+// fillTemplate replaces the placeholders in s from data, adding the paths it
+// couldn't resolve to missing
+func fillTemplate(s string, data map[string]interface{}, missing map[string]bool) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return webhookPlaceholderRe.ReplaceAllStringFunc(s, func(ph string) string {
+		m := webhookPlaceholderRe.FindStringSubmatch(ph)
+		if v := navigateJSON(data, m[1]); v != nil {
+			return formValue(v)
+		}
+		if strings.Contains(ph, "|") {
+			return m[2]
+		}
+		missing[m[1]] = true
+		return ph
+	})
+}
+
+// fillPayload fills the placeholders in every string of a JSON payload;
+// keys and non-string values are left alone
+func fillPayload(v interface{}, data map[string]interface{}, missing map[string]bool) interface{} {
+	switch val := v.(type) {
+	case string:
+		return fillTemplate(val, data, missing)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = fillPayload(item, data, missing)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = fillPayload(item, data, missing)
+		}
+		return out
+	}
+	return v
+}
+
+// unresolvedError reports the placeholders data had no value for
+func unresolvedError(missing map[string]bool) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(missing))
+	for p := range missing {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return invalidInputf("data has no value for placeholder(s): %s (add them to data or give a fallback with {{name|fallback}})", strings.Join(paths, ", "))
+}
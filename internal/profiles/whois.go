@@ -0,0 +1,216 @@
+package profiles
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	ianaWhoisServer  = "whois.iana.org"
+	whoisTimeout     = 10 * time.Second
+	maxWhoisResponse = 256 * 1024
+	maxWhoisHops     = 3
+)
+
+// whoisFields maps the labels registries use to the summary line they belong to.
+// Registries disagree on naming, so several labels feed the same field.
+var whoisFields = []struct {
+	label string
+	keys  []string
+}{
+	{"Registrar", []string{"registrar", "registrar name", "sponsoring registrar"}},
+	{"Created", []string{"creation date", "created", "created on", "registered on", "registration time", "domain registration date"}},
+	{"Updated", []string{"updated date", "last updated", "last-update", "changed", "last modified"}},
+	{"Expires", []string{"registry expiry date", "registrar registration expiration date", "expiration date", "expiry date", "expires", "expires on", "paid-till", "expiration time"}},
+	{"Status", []string{"domain status", "status", "state"}},
+	{"Name servers", []string{"name server", "nameserver", "nameservers", "nserver", "name servers"}},
+	{"DNSSEC", []string{"dnssec"}},
+}
+
+// whoisLookup queries IANA for the registry's WHOIS server, then follows
+// referrals (registry → registrar) and summarizes the most specific answer
+func (p *DnsProfile) whoisLookup(args map[string]interface{}) (string, error) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(getStr(args, "domain"))), ".")
+	if domain == "" {
+		return "", invalidInputf("domain is required")
+	}
+	if !strings.Contains(domain, ".") || strings.ContainsAny(domain, " /:@\r\n") {
+		return "", invalidInputf("invalid domain: %s", domain)
+	}
+
+	server := ianaWhoisServer
+	var chain []string
+	var answer string
+	for hop := 0; hop < maxWhoisHops && server != ""; hop++ {
+		resp, err := whoisQuery(server, domain)
+		if err != nil {
+			if answer != "" {
+				// The registrar's server failing still leaves the registry's answer
+				chain = append(chain, fmt.Sprintf("%s (failed: %s)", server, err))
+				break
+			}
+			return "", err
+		}
+		if whoisRateLimited(resp) {
+			if answer != "" {
+				chain = append(chain, server+" (rate limited)")
+				break
+			}
+			return "", backendErrorf("%s is rate limiting queries, try again later", server)
+		}
+		chain = append(chain, server)
+		answer = resp
+
+		next := whoisReferral(resp)
+		if next == "" || strings.EqualFold(next, server) {
+			break
+		}
+		server = next
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "WHOIS for %s\nServers: %s\n\n", domain, strings.Join(chain, " → "))
+
+	if raw, _ := args["raw"].(bool); raw {
+		out.WriteString(strings.TrimSpace(answer))
+		return out.String(), nil
+	}
+
+	summary := parseWhois(answer)
+	if len(summary) == 0 {
+		// Unfamiliar format, so hand back the text rather than nothing
+		out.WriteString("(could not parse this registry's format, raw response follows)\n\n")
+		out.WriteString(strings.TrimSpace(answer))
+		return out.String(), nil
+	}
+	for _, f := range whoisFields {
+		values := summary[f.label]
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) == 1 {
+			fmt.Fprintf(&out, "%-14s %s\n", f.label+":", values[0])
+			continue
+		}
+		fmt.Fprintf(&out, "%s:\n", f.label)
+		for _, v := range values {
+			fmt.Fprintf(&out, "  %s\n", v)
+		}
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// whoisQuery sends a single WHOIS (RFC 3912) query on port 43
+func whoisQuery(server, query string) (string, error) {
+	dialer := safeDialer()
+	dialer.Timeout = whoisTimeout
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(server, "43"))
+	if err != nil {
+		return "", backendErrorf("cannot reach %s: %s", server, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(whoisTimeout))
+
+	// Verisign's servers otherwise match name servers and hosts as well
+	if strings.HasSuffix(server, "verisign-grs.com") {
+		query = "domain " + query
+	}
+	if _, err := conn.Write([]byte(query + "\r\n")); err != nil {
+		return "", backendErrorf("query to %s failed: %s", server, err)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(conn, maxWhoisResponse))
+	if err != nil && len(data) == 0 {
+		return "", backendErrorf("no response from %s: %s", server, err)
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+// whoisReferral extracts the next server to ask from a response
+func whoisReferral(resp string) string {
+	scanner := bufio.NewScanner(strings.NewReader(resp))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "refer", "whois", "registrar whois server", "referralserver":
+			value = strings.TrimSpace(value)
+			value = strings.TrimPrefix(value, "whois://")
+			value = strings.TrimSuffix(value, "/")
+			if value != "" && !strings.ContainsAny(value, " /") {
+				return strings.ToLower(value)
+			}
+		}
+	}
+	return ""
+}
+
+func whoisRateLimited(resp string) bool {
+	lower := strings.ToLower(resp)
+	for _, phrase := range []string{"limit exceeded", "quota exceeded", "too many requests", "too many queries", "rate limit"} {
+		if strings.Contains(lower, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWhois collects the known fields from "Key: value" lines, keeping the
+// first label that matches each field and de-duplicating repeated values
+func parseWhois(resp string) map[string][]string {
+	lookup := map[string]string{}
+	for _, f := range whoisFields {
+		for _, k := range f.keys {
+			lookup[k] = f.label
+		}
+	}
+
+	result := map[string][]string{}
+	source := map[string]string{} // field label -> key it was taken from
+	scanner := bufio.NewScanner(strings.NewReader(resp))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ">>>") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		label, known := lookup[key]
+		if !known || value == "" {
+			continue
+		}
+		if prev, seen := source[label]; seen && prev != key {
+			continue
+		}
+		source[label] = key
+
+		if label == "Name servers" {
+			value = strings.ToLower(strings.TrimSuffix(strings.Fields(value)[0], "."))
+		}
+		if label == "Status" {
+			// Drop the ICANN explanation URL that follows EPP status codes
+			value = strings.Fields(value)[0]
+		}
+		dup := false
+		for _, v := range result[label] {
+			if strings.EqualFold(v, value) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			result[label] = append(result[label], value)
+		}
+	}
+	return result
+}
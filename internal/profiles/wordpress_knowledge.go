@@ -1,10 +1,11 @@
 package profiles
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"sort"
@@ -17,9 +18,9 @@ import (
 )
 
 type WordPressKnowledgeProfile struct {
-	mu     sync.RWMutex
-	cache  map[string]*wpKnowledgeSource
-	client *http.Client
+	mu      sync.RWMutex
+	cache   map[string]*wpKnowledgeSource
+	partial map[string]*wpPartialDownload // by cache key
 }
 
 type wpKnowledgeSource struct {
@@ -29,6 +30,15 @@ type wpKnowledgeSource struct {
 	LastModified string
 	ContentChars int
 	Chunks       []wpKnowledgeChunk
+	index        *knowledgeIndex
+}
+
+// wpPartialDownload is the start of a download that was cut off, which the
+// next refresh resumes from if the source is still the version validator
+// identifies
+type wpPartialDownload struct {
+	body      []byte
+	validator string
 }
 
 type wpKnowledgeChunk struct {
@@ -42,7 +52,8 @@ type wpKnowledgeMatch struct {
 	Score float64
 }
 
-func (p *WordPressKnowledgeProfile) ID() string { return "wordpress-knowledge" }
+func (p *WordPressKnowledgeProfile) ID() string       { return "wordpress-knowledge" }
+func (p *WordPressKnowledgeProfile) HasBackend() bool { return true }
 
 func (p *WordPressKnowledgeProfile) Tools() []Tool {
 	return []Tool{
@@ -64,6 +75,7 @@ func (p *WordPressKnowledgeProfile) Tools() []Tool {
 						"type":        "integer",
 						"description": "Maximum characters per returned snippet (default 900)",
 					},
+					"timeout_ms": knowledgeTimeoutProperty(),
 				},
 				"required": []string{"query"},
 			},
@@ -91,7 +103,7 @@ func (p *WordPressKnowledgeProfile) Tools() []Tool {
 		},
 		{
 			Name:        "refresh_source",
-			Description: "Force-refresh the llms.txt source immediately and rebuild the search index",
+			Description: "Force-refresh the llms.txt source immediately (downloading it whole) and rebuild the search index",
 			InputSchema: map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -100,10 +112,30 @@ func (p *WordPressKnowledgeProfile) Tools() []Tool {
 	}
 }
 
+func (p *WordPressKnowledgeProfile) RequiredEnv() []EnvSpec {
+	return append([]EnvSpec{
+		{Name: "LLMS_TXT_URL", Required: true, Description: "URL of the WordPress llms.txt source"},
+		{Name: "LLMS_TXT_AUTH_TOKEN", Required: false, Description: "Bearer token sent when fetching the source"},
+		{Name: "REFRESH_INTERVAL_SECONDS", Required: false, Description: "Source cache lifetime (default 300)"},
+		{Name: "MAX_DOWNLOAD_BYTES", Required: false, Description: "Maximum source size in bytes"},
+		{Name: "MAX_RESULTS", Required: false, Description: "Default number of search matches"},
+		{Name: "USER_AGENT", Required: false, Description: "User-Agent header for source requests"},
+		{Name: "HTTP_TIMEOUT_SECONDS", Required: false, Description: "HTTP request timeout in seconds (default 30, max 300)"},
+	}, outboundHTTPEnv...)
+}
+
+func (p *WordPressKnowledgeProfile) CacheableTools() []string {
+	return []string{"search_knowledge", "list_sections"}
+}
+
 func (p *WordPressKnowledgeProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	return p.CallToolContext(context.Background(), name, args, env)
+}
+
+func (p *WordPressKnowledgeProfile) CallToolContext(ctx context.Context, name string, args map[string]interface{}, env map[string]string) (string, error) {
 	switch name {
 	case "search_knowledge":
-		return p.searchKnowledge(args, env)
+		return p.searchKnowledge(ctx, args, env)
 	case "source_status":
 		return p.sourceStatus(env, false)
 	case "list_sections":
@@ -115,7 +147,7 @@ func (p *WordPressKnowledgeProfile) CallTool(name string, args map[string]interf
 	}
 }
 
-func (p *WordPressKnowledgeProfile) searchKnowledge(args map[string]interface{}, env map[string]string) (string, error) {
+func (p *WordPressKnowledgeProfile) searchKnowledge(ctx context.Context, args map[string]interface{}, env map[string]string) (string, error) {
 	query := strings.TrimSpace(getStr(args, "query"))
 	if query == "" {
 		return "", fmt.Errorf("query is required")
@@ -154,24 +186,15 @@ func (p *WordPressKnowledgeProfile) searchKnowledge(args map[string]interface{},
 		return "", fmt.Errorf("query must contain letters or numbers")
 	}
 
-	matches := make([]wpKnowledgeMatch, 0, len(source.Chunks))
-	for _, chunk := range source.Chunks {
-		score := scoreKnowledgeChunk(chunk, queryLower, terms)
-		if score <= 0 {
-			continue
-		}
-		matches = append(matches, wpKnowledgeMatch{Chunk: chunk, Score: score})
-	}
-
-	sort.Slice(matches, func(i, j int) bool {
-		if matches[i].Score == matches[j].Score {
-			return len(matches[i].Chunk.Content) > len(matches[j].Chunk.Content)
-		}
-		return matches[i].Score > matches[j].Score
-	})
+	ctx, cancel := knowledgeSearchContext(ctx, args)
+	defer cancel()
+	chunks := source.Chunks
+	scores, scored, candidates := source.index.search(ctx, queryLower, terms, limit,
+		func(i int, phrase bool, occ []int) float64 { return scoreKnowledgeChunk(chunks[i], phrase, terms, occ) })
 
-	if len(matches) > limit {
-		matches = matches[:limit]
+	matches := make([]wpKnowledgeMatch, len(scores))
+	for i, s := range scores {
+		matches[i] = wpKnowledgeMatch{Chunk: chunks[s.index], Score: s.score}
 	}
 
 	var out strings.Builder
@@ -181,6 +204,10 @@ func (p *WordPressKnowledgeProfile) searchKnowledge(args map[string]interface{},
 	if warning != "" {
 		out.WriteString(fmt.Sprintf("Note: %s\n", warning))
 	}
+	if scored < candidates {
+		markPartial(ctx)
+		out.WriteString(fmt.Sprintf("Note: search timed out after scoring %d of %d matching chunks; results are the best among those\n", scored, candidates))
+	}
 
 	if len(matches) == 0 {
 		out.WriteString("\nNo relevant matches found for this query.")
@@ -298,7 +325,7 @@ func (p *WordPressKnowledgeProfile) ensureSource(env map[string]string, force bo
 	}
 
 	p.mu.RLock()
-	current := p.cache[cacheKey]
+	current, resume := p.cache[cacheKey], p.partial[cacheKey]
 	p.mu.RUnlock()
 
 	if !force && current != nil && time.Since(current.FetchedAt) < time.Duration(refreshSeconds)*time.Second {
@@ -313,40 +340,31 @@ func (p *WordPressKnowledgeProfile) ensureSource(env map[string]string, force bo
 		maxBytes = 100 * 1024 * 1024
 	}
 
-	client := p.httpClient()
-	req, err := http.NewRequest("GET", rawURL, nil)
+	client, err := p.httpClient(env)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to build request: %s", err)
-	}
-
-	userAgent := strings.TrimSpace(env["USER_AGENT"])
-	if userAgent == "" {
-		userAgent = "Dublyo-WP-Knowledge/1.0"
+		return nil, "", err
 	}
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Accept", "text/plain,text/markdown;q=0.9,*/*;q=0.1")
-
-	if token := strings.TrimSpace(env["LLMS_TXT_AUTH_TOKEN"]); token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
+	// A forced refresh always downloads the whole source
+	if force {
+		resume = nil
 	}
-
-	if current != nil {
-		if current.ETag != "" {
-			req.Header.Set("If-None-Match", current.ETag)
-		}
-		if current.LastModified != "" {
-			req.Header.Set("If-Modified-Since", current.LastModified)
+	resp, body, interrupted, err := p.download(client, rawURL, env, current, resume, maxBytes)
+	if resp != nil {
+		p.mu.Lock()
+		p.ensureCacheLocked()
+		if interrupted != nil {
+			p.partial[cacheKey] = interrupted
+		} else {
+			delete(p.partial, cacheKey)
 		}
+		p.mu.Unlock()
 	}
-
-	resp, err := client.Do(req)
-	if err != nil {
+	if resp == nil {
 		if current != nil {
 			return current, fmt.Sprintf("using cached source because refresh failed: %s", err), nil
 		}
 		return nil, "", fmt.Errorf("failed to fetch llms.txt source: %s", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotModified && current != nil {
 		refreshed := *current
@@ -365,7 +383,6 @@ func (p *WordPressKnowledgeProfile) ensureSource(env map[string]string, force bo
 		return nil, "", fmt.Errorf("llms.txt endpoint returned HTTP %d", resp.StatusCode)
 	}
 
-	body, err := readWithLimit(resp.Body, maxBytes)
 	if err != nil {
 		if current != nil {
 			return current, fmt.Sprintf("using cached source because response read failed: %s", err), nil
@@ -394,6 +411,7 @@ func (p *WordPressKnowledgeProfile) ensureSource(env map[string]string, force bo
 		LastModified: strings.TrimSpace(resp.Header.Get("Last-Modified")),
 		ContentChars: len([]rune(content)),
 		Chunks:       chunks,
+		index:        indexWPKnowledge(chunks),
 	}
 
 	p.mu.Lock()
@@ -404,19 +422,136 @@ func (p *WordPressKnowledgeProfile) ensureSource(env map[string]string, force bo
 	return source, "", nil
 }
 
+// download fetches the source, conditionally on current's validators. When
+// resume is set, only the bytes after it are asked for, with If-Range so the
+// server sends the whole source instead if it changed since; a 206 is only
+// used when its validator and range match resume, and the source is
+// downloaded whole otherwise. The response's body is read and closed; on a
+// transport failure resp is nil, while err with a resp means its body
+// couldn't be read, in which case interrupted holds what was if the
+// download can be resumed.
+func (p *WordPressKnowledgeProfile) download(client *http.Client, rawURL string, env map[string]string, current *wpKnowledgeSource, resume *wpPartialDownload, maxBytes int) (resp *http.Response, body []byte, interrupted *wpPartialDownload, err error) {
+	for {
+		req, err := http.NewRequest("GET", rawURL, nil)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to build request: %s", err)
+		}
+
+		ua := strings.TrimSpace(env["USER_AGENT"])
+		if ua == "" {
+			ua = userAgent(env, "Dublyo-WP-Knowledge/1.0")
+		}
+		req.Header.Set("User-Agent", ua)
+		req.Header.Set("Accept", "text/plain,text/markdown;q=0.9,*/*;q=0.1")
+
+		if token := strings.TrimSpace(env["LLMS_TXT_AUTH_TOKEN"]); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		if current != nil {
+			if current.ETag != "" {
+				req.Header.Set("If-None-Match", current.ETag)
+			}
+			if current.LastModified != "" {
+				req.Header.Set("If-Modified-Since", current.LastModified)
+			}
+		}
+		if resume != nil {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(resume.body)))
+			req.Header.Set("If-Range", resume.validator)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		var prefix []byte
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && resume != nil && resumes(resp, resume):
+			prefix = resume.body
+		case resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+			resp.Body.Close()
+			if resume == nil {
+				return resp, nil, nil, fmt.Errorf("unexpected HTTP %d to a request without Range", resp.StatusCode)
+			}
+			// Not the rest of the version we have the start of
+			resume = nil
+			continue
+		case resp.StatusCode < 200 || resp.StatusCode >= 300:
+			resp.Body.Close()
+			return resp, nil, nil, nil
+		}
+		body, interrupted, err = readResumable(resp, prefix, maxBytes)
+		resp.Body.Close()
+		return resp, body, interrupted, err
+	}
+}
+
+// resumes tells whether a 206 response is the rest of resume's version of
+// the source
+func resumes(resp *http.Response, resume *wpPartialDownload) bool {
+	var first int
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &first); err != nil || first != len(resume.body) {
+		return false
+	}
+	return strongValidator(resp) == resume.validator
+}
+
+// strongValidator returns resp's ETag, or else its Last-Modified, if it is
+// a strong validator, which If-Range requires: not a weak ETag, and not a
+// Last-Modified within a second of the response's Date, as the source could
+// have changed again within that second. It is "" when there is none.
+func strongValidator(resp *http.Response) string {
+	if etag := strings.TrimSpace(resp.Header.Get("ETag")); etag != "" {
+		if strings.HasPrefix(etag, "W/") {
+			return ""
+		}
+		return etag
+	}
+	lastModified := strings.TrimSpace(resp.Header.Get("Last-Modified"))
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return ""
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil || date.Sub(modified) < time.Second {
+		return ""
+	}
+	return lastModified
+}
+
+// readResumable reads resp's body, which follows prefix in the source. When
+// the read fails partway through a response the server can resume, the
+// bytes read so far are returned as interrupted.
+func readResumable(resp *http.Response, prefix []byte, maxBytes int) (body []byte, interrupted *wpPartialDownload, err error) {
+	var buf bytes.Buffer
+	buf.Write(prefix)
+	_, err = io.Copy(&buf, io.LimitReader(resp.Body, int64(max(0, maxBytes-len(prefix)))+1))
+	if buf.Len() > maxBytes {
+		return nil, nil, fmt.Errorf("llms.txt source exceeds MAX_DOWNLOAD_BYTES (%d)", maxBytes)
+	}
+	if err != nil {
+		validator := strongValidator(resp)
+		rangeable := resp.StatusCode == http.StatusPartialContent || strings.Contains(resp.Header.Get("Accept-Ranges"), "bytes")
+		if validator != "" && rangeable && buf.Len() > 0 {
+			interrupted = &wpPartialDownload{body: buf.Bytes(), validator: validator}
+		}
+		return nil, interrupted, fmt.Errorf("failed reading llms.txt source: %s", err)
+	}
+	return buf.Bytes(), nil, nil
+}
+
 func (p *WordPressKnowledgeProfile) ensureCacheLocked() {
 	if p.cache == nil {
 		p.cache = map[string]*wpKnowledgeSource{}
 	}
+	if p.partial == nil {
+		p.partial = map[string]*wpPartialDownload{}
+	}
 }
 
-func (p *WordPressKnowledgeProfile) httpClient() *http.Client {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.client == nil {
-		p.client = &http.Client{Timeout: 30 * time.Second}
-	}
-	return p.client
+func (p *WordPressKnowledgeProfile) httpClient(env map[string]string) (*http.Client, error) {
+	return withDefaultHeaders(safeHTTPClient(httpTimeout(env, 30*time.Second)), env)
 }
 
 func (p *WordPressKnowledgeProfile) cacheKey(urlVal, token string) string {
@@ -440,10 +575,8 @@ func validateKnowledgeURL(rawURL string) (*url.URL, error) {
 		return nil, fmt.Errorf("localhost is not allowed for LLMS_TXT_URL")
 	}
 
-	if ip := net.ParseIP(host); ip != nil {
-		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-			return nil, fmt.Errorf("private or local IPs are not allowed for LLMS_TXT_URL")
-		}
+	if err := checkResolvedHost(host); err != nil {
+		return nil, fmt.Errorf("LLMS_TXT_URL is not allowed: %s", err)
 	}
 
 	return u, nil
@@ -569,25 +702,35 @@ func normalizeHeading(line string) string {
 	return line
 }
 
-func scoreKnowledgeChunk(chunk wpKnowledgeChunk, queryLower string, terms []string) float64 {
+func indexWPKnowledge(chunks []wpKnowledgeChunk) *knowledgeIndex {
+	lower := make([]string, len(chunks))
+	length := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		lower[i], length[i] = chunk.lower, len(chunk.Content)
+	}
+	return buildKnowledgeIndex(lower, length)
+}
+
+// scoreKnowledgeChunk scores a chunk for a query: phrase is whether it
+// contains the whole query, occ[i] how often it contains terms[i]
+func scoreKnowledgeChunk(chunk wpKnowledgeChunk, phrase bool, terms []string, occ []int) float64 {
 	score := 0.0
-	if strings.Contains(chunk.lower, queryLower) {
+	if phrase {
 		score += 8
 	}
 	headingLower := strings.ToLower(chunk.Heading)
-	for _, term := range terms {
+	for i, term := range terms {
 		if term == "" {
 			continue
 		}
-		occ := strings.Count(chunk.lower, term)
-		if occ > 0 {
-			score += float64(occ)
+		if occ[i] > 0 {
+			score += float64(occ[i])
 		}
 		if strings.Contains(headingLower, term) {
 			score += 2.5
 		}
 	}
-	if len(chunk.Content) < 1200 {
+	if len(chunk.Content) < knowledgeShortChunk {
 		score += 0.3
 	}
 	return score
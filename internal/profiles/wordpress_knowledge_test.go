@@ -0,0 +1,107 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingReader serves content but fails after cut bytes
+type failingReader struct {
+	*strings.Reader
+	cut int64
+}
+
+func (r *failingReader) Read(b []byte) (int, error) {
+	pos, _ := r.Seek(0, io.SeekCurrent)
+	if pos >= r.cut {
+		return 0, errors.New("connection lost")
+	}
+	if left := r.cut - pos; int64(len(b)) > left {
+		b = b[:left]
+	}
+	return r.Reader.Read(b)
+}
+
+func TestWPKnowledgeResumeDownload(t *testing.T) {
+	const v1 = "# Install\nRun the installer, then activate the plugin.\n"
+	const v2 = "# Install\nDownload the plugin and upload it from the dashboard.\n"
+	modified := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		etag          string
+		lastModified  time.Time
+		ignoreIfRange bool // the server answers a Range even when If-Range doesn't match
+		changeTo      string
+		wantResumable bool
+		wantRequests  int // to complete the interrupted download
+		want          string
+	}{
+		{name: "strong ETag", etag: `"v1"`, wantResumable: true, wantRequests: 1, want: v1},
+		{name: "Last-Modified", lastModified: modified, wantResumable: true, wantRequests: 1, want: v1},
+		{name: "changed since", etag: `"v1"`, changeTo: v2, wantResumable: true, wantRequests: 1, want: v2},
+		{name: "changed, If-Range ignored", etag: `"v1"`, ignoreIfRange: true, changeTo: v2, wantResumable: true, wantRequests: 2, want: v2},
+		{name: "weak ETag", etag: `W/"v1"`, want: v1},
+		{name: "Last-Modified too recent", lastModified: time.Now().Add(time.Minute), want: v1},
+		{name: "no validator", want: v1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, etag, cut := v1, tt.etag, int64(len(v1)/2)
+			var ranges []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if etag != "" {
+					w.Header().Set("ETag", etag)
+				}
+				if tt.ignoreIfRange {
+					r.Header.Del("If-Range")
+				}
+				body := &failingReader{Reader: strings.NewReader(content), cut: cut}
+				http.ServeContent(w, r, "llms.txt", tt.lastModified, body)
+			}))
+			defer srv.Close()
+
+			p := &WordPressKnowledgeProfile{}
+			resp, body, interrupted, err := p.download(srv.Client(), srv.URL, nil, nil, nil, 1<<20)
+			if resp == nil || err == nil || body != nil {
+				t.Fatalf("first download: resp %v, err %v; want an interrupted body", resp, err)
+			}
+			if (interrupted != nil) != tt.wantResumable {
+				t.Fatalf("interrupted = %v, want resumable %v", interrupted, tt.wantResumable)
+			}
+			if interrupted == nil {
+				return
+			}
+			if string(interrupted.body) != v1[:cut] {
+				t.Errorf("kept %q, want %q", interrupted.body, v1[:cut])
+			}
+
+			resumeAt := cut
+			cut = 1 << 20
+			if tt.changeTo != "" {
+				content, etag = tt.changeTo, `"v2"`
+			}
+			ranges = nil
+			resp, body, interrupted, err = p.download(srv.Client(), srv.URL, nil, nil, interrupted, 1<<20)
+			if err != nil || resp == nil {
+				t.Fatalf("resumed download: %v", err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("body = %q, want %q", body, tt.want)
+			}
+			if interrupted != nil {
+				t.Errorf("complete download left a partial one")
+			}
+			if len(ranges) != tt.wantRequests || ranges[0] != fmt.Sprintf("bytes=%d-", resumeAt) {
+				t.Errorf("requests' Range headers: %q", ranges)
+			}
+		})
+	}
+}
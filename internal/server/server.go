@@ -1,34 +1,72 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dublyo/mcp-gateway/internal/gateway"
+	"github.com/dublyo/mcp-gateway/internal/logging"
 	"github.com/dublyo/mcp-gateway/internal/mcp"
+	"github.com/dublyo/mcp-gateway/internal/profiles"
 )
 
 // Session tracks an active SSE or Streamable HTTP session
 type Session struct {
-	ID       string
-	ConnID   string
-	Messages chan []byte // SSE events sent to client
-	done     chan struct{}
+	ID        string
+	ConnID    string
+	Messages  chan []byte // SSE events sent to client
+	done      chan struct{}
 	closeOnce sync.Once
+
+	// Streamable HTTP sessions outlive any one request; they are reaped once
+	// idle for streamableSessionTTL with no GET stream attached
+	lastActive atomic.Int64        // unix nanoseconds, zero for SSE sessions
+	streams    atomic.Int32        // open GET streams
+	conn       *gateway.Connection // counted against its MaxConcurrency until ended
 }
 
+// streamableSessionTTL is how long a Streamable HTTP session survives
+// without requests or an open stream
+const streamableSessionTTL = 30 * time.Minute
+
 // Server is the HTTP server that handles MCP requests
 type Server struct {
-	gw       *gateway.Gateway
-	sessions sync.Map // sessionID -> *Session
+	gw           *gateway.Gateway
+	sessions     sync.Map      // sessionID -> *Session
+	keepAlive    time.Duration // SSE comment ping interval, zero to disable
+	writeTimeout time.Duration // deadline for writing non-streaming responses
+
+	// requestIDHeader carries each request's correlation ID, honored when the
+	// client sends one and generated otherwise
+	requestIDHeader string
+
+	// trustedProxies are the peers whose X-Forwarded-For is believed when
+	// working out a client's address
+	trustedProxies []*net.IPNet
+
+	log *slog.Logger
 }
 
+// defaultSSEKeepAlive is the SSE ping interval unless SSE_KEEPALIVE_SECONDS is set
+const defaultSSEKeepAlive = 30 * time.Second
+
 // Close safely closes the session's done channel exactly once
 func (sess *Session) Close() {
 	sess.closeOnce.Do(func() {
@@ -36,8 +74,96 @@ func (sess *Session) Close() {
 	})
 }
 
+// keepAliveTicks returns a channel that fires every keep-alive interval, or
+// never if keep-alives are disabled, and a function to stop it
+func (s *Server) keepAliveTicks() (<-chan time.Time, func()) {
+	if s.keepAlive <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(s.keepAlive)
+	return ticker.C, ticker.Stop
+}
+
+// touch marks a Streamable HTTP session as in use
+func (sess *Session) touch() {
+	sess.lastActive.Store(time.Now().UnixNano())
+}
+
+// reapIdleSessions closes Streamable HTTP sessions clients abandoned without
+// a DELETE, so their message buffers don't accumulate
+func (s *Server) reapIdleSessions() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		cutoff := time.Now().Add(-streamableSessionTTL).UnixNano()
+		s.sessions.Range(func(k, v interface{}) bool {
+			session := v.(*Session)
+			last := session.lastActive.Load()
+			if last != 0 && last < cutoff && session.streams.Load() == 0 {
+				s.endStreamableSession(session)
+			}
+			return true
+		})
+	}
+}
+
+// endStreamableSession closes a Streamable HTTP session and releases its slot
+// in the connection's session limit, once however many callers end it
+func (s *Server) endStreamableSession(session *Session) {
+	if s.sessions.CompareAndDelete(session.ID, session) {
+		session.Close()
+		s.gw.DecrementSessions(session.conn)
+	}
+}
+
 func New(gw *gateway.Gateway) *Server {
-	return &Server{gw: gw}
+	s := &Server{
+		gw:              gw,
+		keepAlive:       defaultSSEKeepAlive,
+		requestIDHeader: "X-Request-ID",
+		log:             logging.Component("server"),
+	}
+	s.writeTimeout = s.durationEnv("HTTP_WRITE_TIMEOUT", 30*time.Second)
+	if v := os.Getenv("REQUEST_ID_HEADER"); v != "" {
+		s.requestIDHeader = http.CanonicalHeaderKey(v)
+	}
+	if v := os.Getenv("TRUSTED_PROXY_CIDRS"); v != "" {
+		nets, err := profiles.ParseCIDRList(v)
+		if err != nil {
+			s.log.Warn("invalid TRUSTED_PROXY_CIDRS, ignoring X-Forwarded-For", "err", err)
+		}
+		s.trustedProxies = nets
+	}
+	if v := os.Getenv("SSE_KEEPALIVE_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			s.keepAlive = time.Duration(n) * time.Second
+		} else {
+			s.log.Warn("invalid SSE_KEEPALIVE_SECONDS, using default", "value", v, "default", defaultSSEKeepAlive)
+		}
+	}
+	gw.OnToolsChanged(s.notifyToolsChanged)
+	return s
+}
+
+// notifyToolsChanged sends notifications/tools/list_changed to every open
+// session of the given connections
+func (s *Server) notifyToolsChanged(connIDs []string) {
+	affected := make(map[string]bool, len(connIDs))
+	for _, id := range connIDs {
+		affected[id] = true
+	}
+	msg, _ := json.Marshal(mcp.ToolsListChanged)
+	s.sessions.Range(func(_, v interface{}) bool {
+		session := v.(*Session)
+		if affected[session.ConnID] {
+			select {
+			case session.Messages <- msg:
+			default:
+				s.log.Warn("session message buffer full, dropping tools/list_changed", "session", session.ID, "connection", session.ConnID)
+			}
+		}
+		return true
+	})
 }
 
 func (s *Server) Start() error {
@@ -48,33 +174,175 @@ func (s *Server) Start() error {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/ready", s.handleReady)
+	mux.HandleFunc("/debug/traefik", s.handleTraefikConfig)
 	mux.HandleFunc("/", s.handleRequest)
 
+	maxHeaderBytes := http.DefaultMaxHeaderBytes
+	if v := os.Getenv("HTTP_MAX_HEADER_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxHeaderBytes = n
+		} else {
+			s.log.Warn("invalid HTTP_MAX_HEADER_BYTES, using default", "value", v, "default", maxHeaderBytes)
+		}
+	}
+
 	server := &http.Server{
-		Addr:         ":" + port,
-		Handler:      mux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 0, // SSE needs no write timeout
-		IdleTimeout:  120 * time.Second,
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadTimeout:       s.durationEnv("HTTP_READ_TIMEOUT", 5*time.Second),
+		ReadHeaderTimeout: s.durationEnv("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		IdleTimeout:       s.durationEnv("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		MaxHeaderBytes:    maxHeaderBytes,
+		// No server-wide WriteTimeout: SSE streams stay open indefinitely, so
+		// other responses get a per-request deadline from setWriteDeadline
 	}
 
-	log.Printf("[server] listening on :%s", port)
+	go s.reapIdleSessions()
+
+	s.log.Info("listening", "port", port)
 	return server.ListenAndServe()
 }
 
+// durationEnv reads a positive duration such as "30s" from the environment
+func (s *Server) durationEnv(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		s.log.Warn("invalid "+key+", using default", "value", v, "default", fallback)
+	}
+	return fallback
+}
+
+// setWriteDeadline gives the response writeTimeout from now to be written.
+// It is called just before writing, not when the request arrives, so a slow
+// tool call doesn't eat into the time to send its result.
+func (s *Server) setWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(s.writeTimeout))
+}
+
+// clearWriteDeadline lifts the deadline when a response turns into an SSE stream
+func clearWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.setWriteDeadline(w)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
+// handleReady reports 503 until the first config sync has been applied,
+// so orchestrators don't route traffic to a gateway with no connections
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	s.setWriteDeadline(w)
+	ready, version, conns := s.gw.Readiness()
+	status := "ready"
+	code := http.StatusOK
+	if !ready {
+		status = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      status,
+		"version":     version,
+		"connections": conns,
+	})
+}
+
+// handleTraefikConfig shows the Traefik dynamic config the current
+// connections produce, as a config sync would write it, without writing
+// anything, to check routing. It requires the gateway token.
+func (s *Server) handleTraefikConfig(w http.ResponseWriter, r *http.Request) {
+	s.setWriteDeadline(w)
+	if r.Method != "GET" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") || !s.gw.VerifyGatewayToken(strings.TrimPrefix(auth, "Bearer ")) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	data, err := s.gw.TraefikConfig()
+	if err != nil {
+		s.log.Error("traefik config render failed", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
+
 // setCORS sets CORS headers for all MCP endpoints
-func setCORS(w http.ResponseWriter) {
+func (s *Server) setCORS(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, mcp-session-id")
-	w.Header().Set("Access-Control-Expose-Headers", "mcp-session-id")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, mcp-session-id, "+s.requestIDHeader)
+	w.Header().Set("Access-Control-Expose-Headers", "mcp-session-id, "+s.requestIDHeader)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 }
 
+// maxRequestIDLen bounds client-supplied request IDs, which end up in logs
+const maxRequestIDLen = 128
+
+// requestID returns the client's correlation ID if it is safe to log, or a
+// new one
+func requestID(supplied string) string {
+	valid := supplied != "" && len(supplied) <= maxRequestIDLen
+	for i := 0; valid && i < len(supplied); i++ {
+		c := supplied[i]
+		valid = c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.:/+=", c) >= 0
+	}
+	if valid {
+		return supplied
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// clientIP returns the address of the client behind a request. The peer's
+// address is used unless it is a trusted proxy, in which case
+// X-Forwarded-For is followed back from the right past any other trusted
+// proxies; entries further left are client-supplied and can't be believed.
+func (s *Server) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !s.trusted(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !s.trusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// trusted reports whether ip is one of TRUSTED_PROXY_CIDRS
+func (s *Server) trusted(ip net.IP) bool {
+	for _, n := range s.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	// Extract domain from Host header
 	host := r.Host
@@ -83,7 +351,17 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set CORS on all responses
-	setCORS(w)
+	s.setCORS(w)
+
+	// Tag the request with a correlation ID for errors and logs
+	reqID := requestID(r.Header.Get(s.requestIDHeader))
+	w.Header().Set(s.requestIDHeader, reqID)
+	r = r.WithContext(mcp.WithRequestID(r.Context(), reqID))
+
+	// GETs open SSE streams; everything else is a plain response
+	if r.Method != "GET" {
+		s.setWriteDeadline(w)
+	}
 
 	// Handle preflight
 	if r.Method == "OPTIONS" {
@@ -96,6 +374,10 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
+	if !s.gw.ClientAllowed(conn, s.clientIP(r)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
 
 	path := r.URL.Path
 
@@ -193,14 +475,16 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request, conn *gateway
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
-	// Send endpoint event
+	// Send endpoint event; the leading comment gets buffering proxies to
+	// pass the headers on at once
 	messageURL := fmt.Sprintf("/message?sessionId=%s", sessionID)
+	fmt.Fprintf(w, ": connected\n\n")
 	fmt.Fprintf(w, "event: endpoint\ndata: %s\n\n", messageURL)
 	flusher.Flush()
 
 	// Keep connection alive, send messages
-	keepAlive := time.NewTicker(30 * time.Second)
-	defer keepAlive.Stop()
+	keepAlive, stopKeepAlive := s.keepAliveTicks()
+	defer stopKeepAlive()
 
 	for {
 		select {
@@ -211,7 +495,7 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request, conn *gateway
 		case msg := <-session.Messages:
 			fmt.Fprintf(w, "event: message\ndata: %s\n\n", string(msg))
 			flusher.Flush()
-		case <-keepAlive.C:
+		case <-keepAlive:
 			fmt.Fprintf(w, ": ping\n\n")
 			flusher.Flush()
 		}
@@ -237,26 +521,39 @@ func (s *Server) handleSSEMessage(w http.ResponseWriter, r *http.Request, conn *
 	session := sessionVal.(*Session)
 
 	// Read request body
-	body := make([]byte, 0, 1024)
-	buf := make([]byte, 4096)
-	for {
-		n, err := r.Body.Read(buf)
-		if n > 0 {
-			body = append(body, buf[:n]...)
-		}
-		if err != nil {
-			break
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	// Tool log notifications share the session's stream
+	notify := func(n mcp.JSONRPCNotification) {
+		msg, _ := json.Marshal(n)
+		select {
+		case session.Messages <- msg:
+		default:
+			s.log.Warn("session message buffer full, dropping log notification", "session", sessionID, "connection", conn.Config.ID)
 		}
-		if len(body) > 1024*1024 { // 1MB limit
-			http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
-			return
+	}
+
+	if isBatch(body) {
+		if responses := s.handleBatch(messageContext(r), conn, body, notify); len(responses) > 0 {
+			respBytes, _ := json.Marshal(responses)
+			select {
+			case session.Messages <- respBytes:
+			default:
+				s.log.Warn("session message buffer full, dropping response", "session", sessionID, "connection", conn.Config.ID)
+			}
 		}
+		s.setWriteDeadline(w)
+		w.WriteHeader(http.StatusAccepted)
+		return
 	}
 
 	start := time.Now()
 
 	// Process the message
-	response := conn.Handler.HandleMessage(body)
+	response := conn.Handler.HandleMessageContext(messageContext(r), body, notify)
 	latency := float64(time.Since(start).Milliseconds())
 
 	isError := response != nil && response.Error != nil
@@ -267,13 +564,34 @@ func (s *Server) handleSSEMessage(w http.ResponseWriter, r *http.Request, conn *
 		select {
 		case session.Messages <- respBytes:
 		default:
-			log.Printf("[server] session %s message buffer full, dropping", sessionID)
+			s.log.Warn("session message buffer full, dropping response", "session", sessionID, "connection", conn.Config.ID)
 		}
 	}
 
+	s.setWriteDeadline(w)
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// maxRequestBody bounds the size of a POSTed JSON-RPC message or batch
+const maxRequestBody = 1 << 20
+
+// readBody reads the whole request body, answering 413 if it is larger than
+// maxRequestBody and 400 if it can't be read. ok is false when a response has
+// been written.
+func readBody(w http.ResponseWriter, r *http.Request) (body []byte, ok bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Bad request body", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return body, true
+}
+
 // ========== Streamable HTTP Transport ==========
 
 func (s *Server) handleStreamableHTTP(w http.ResponseWriter, r *http.Request, conn *gateway.Connection) {
@@ -282,54 +600,156 @@ func (s *Server) handleStreamableHTTP(w http.ResponseWriter, r *http.Request, co
 	}
 
 	// Read body
-	body := make([]byte, 0, 1024)
-	buf := make([]byte, 4096)
-	for {
-		n, err := r.Body.Read(buf)
-		if n > 0 {
-			body = append(body, buf[:n]...)
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	var rawMsg map[string]interface{}
+	json.Unmarshal(body, &rawMsg)
+
+	// An initialize request starts a new session; later requests name it in
+	// the mcp-session-id header. Requests without one are served statelessly.
+	sessionID := r.Header.Get("mcp-session-id")
+	var pending *Session // the session an initialize starts, if it succeeds
+	if method, _ := rawMsg["method"].(string); method == "initialize" {
+		// Initializing again within a session replaces it
+		if old, ok := s.sessions.Load(sessionID); sessionID != "" && ok && old.(*Session).ConnID == conn.Config.ID {
+			s.endStreamableSession(old.(*Session))
 		}
-		if err != nil {
-			break
+		// A session counts against MaxConcurrency like an SSE one, until
+		// DELETEd or reaped. The slot is taken while initialize runs and
+		// given back if it fails.
+		if !s.gw.CheckConcurrency(conn) {
+			http.Error(w, "Too many concurrent sessions", http.StatusServiceUnavailable)
+			return
 		}
-		if len(body) > 1024*1024 {
-			http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+		s.gw.IncrementSessions(conn)
+		pending = &Session{
+			ID:       generateSessionID(),
+			ConnID:   conn.Config.ID,
+			Messages: make(chan []byte, 64),
+			done:     make(chan struct{}),
+			conn:     conn,
+		}
+		sessionID = pending.ID
+	} else if sessionID != "" {
+		sessionVal, ok := s.sessions.Load(sessionID)
+		if !ok || sessionVal.(*Session).ConnID != conn.Config.ID {
+			http.Error(w, "Session not found", http.StatusNotFound)
 			return
 		}
+		sessionVal.(*Session).touch()
 	}
 
 	// Check if this is a notification (no id field)
-	var rawMsg map[string]interface{}
-	if err := json.Unmarshal(body, &rawMsg); err == nil {
+	if rawMsg != nil {
 		if _, hasID := rawMsg["id"]; !hasID {
 			// Notification — no response needed
-			conn.Handler.HandleMessage(body)
+			conn.Handler.HandleMessageContext(messageContext(r), body, nil)
+			if pending != nil {
+				s.gw.DecrementSessions(conn)
+			}
 			w.WriteHeader(http.StatusAccepted)
 			return
 		}
 	}
 
+	// Clients that accept an SSE response get tool log notifications as they
+	// happen; the reply switches to SSE on the first one, with the response
+	// as the final event
+	var notify func(mcp.JSONRPCNotification)
+	var streamMu sync.Mutex
+	streaming, finished := false, false
+	writeEvent := func(v interface{}) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+		w.(http.Flusher).Flush()
+	}
+	if _, ok := w.(http.Flusher); ok && strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		notify = func(n mcp.JSONRPCNotification) {
+			streamMu.Lock()
+			defer streamMu.Unlock()
+			if finished {
+				return
+			}
+			if !streaming {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+				if sessionID != "" {
+					w.Header().Set("mcp-session-id", sessionID)
+				}
+				clearWriteDeadline(w)
+				w.WriteHeader(http.StatusOK)
+				streaming = true
+			}
+			writeEvent(n)
+		}
+	}
+
+	if isBatch(body) {
+		responses := s.handleBatch(messageContext(r), conn, body, notify)
+
+		streamMu.Lock()
+		defer streamMu.Unlock()
+		finished = true
+
+		if streaming {
+			for _, response := range responses {
+				writeEvent(response)
+			}
+			return
+		}
+		s.setWriteDeadline(w)
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if sessionID != "" {
+			w.Header().Set("mcp-session-id", sessionID)
+		}
+		writeJSON(w, r, responses)
+		return
+	}
+
 	start := time.Now()
-	response := conn.Handler.HandleMessage(body)
+	response := conn.Handler.HandleMessageContext(messageContext(r), body, notify)
 	latency := float64(time.Since(start).Milliseconds())
 
 	isError := response != nil && response.Error != nil
 	s.gw.RecordRequest(conn.Config.ID, latency, isError)
 
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	finished = true
+
+	if pending != nil {
+		if response != nil && response.Error == nil {
+			pending.touch()
+			s.sessions.Store(pending.ID, pending)
+		} else {
+			s.gw.DecrementSessions(conn)
+			sessionID = ""
+		}
+	}
+
+	if streaming {
+		if response != nil {
+			writeEvent(response)
+		}
+		return
+	}
+
+	s.setWriteDeadline(w)
 	if response == nil {
 		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
-	// Get or create session ID
-	sessionID := r.Header.Get("mcp-session-id")
-	if sessionID == "" {
-		sessionID = generateSessionID()
+	if sessionID != "" {
+		w.Header().Set("mcp-session-id", sessionID)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("mcp-session-id", sessionID)
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, r, response)
 }
 
 func (s *Server) handleStreamableSSE(w http.ResponseWriter, r *http.Request, conn *gateway.Connection) {
@@ -343,19 +763,50 @@ func (s *Server) handleStreamableSSE(w http.ResponseWriter, r *http.Request, con
 		return
 	}
 
+	// Attach to the client's session so server-initiated messages queued for
+	// it (log entries, tools/list_changed) are delivered on this stream.
+	// Without a session ID the stream only carries keep-alives.
+	session := &Session{
+		ConnID:   conn.Config.ID,
+		Messages: make(chan []byte, 64),
+		done:     make(chan struct{}),
+	}
+	if sessionID := r.Header.Get("mcp-session-id"); sessionID != "" {
+		sessionVal, ok := s.sessions.Load(sessionID)
+		if !ok || sessionVal.(*Session).ConnID != conn.Config.ID {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		session = sessionVal.(*Session)
+		session.streams.Add(1)
+		defer func() {
+			session.touch()
+			session.streams.Add(-1)
+		}()
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
+	// Flush the headers through any buffering proxy right away
+	fmt.Fprintf(w, ": connected\n\n")
+	flusher.Flush()
+
 	// Keep alive until client disconnects
-	keepAlive := time.NewTicker(30 * time.Second)
-	defer keepAlive.Stop()
+	keepAlive, stopKeepAlive := s.keepAliveTicks()
+	defer stopKeepAlive()
 
 	for {
 		select {
 		case <-r.Context().Done():
 			return
-		case <-keepAlive.C:
+		case <-session.done:
+			return
+		case msg := <-session.Messages:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", string(msg))
+			flusher.Flush()
+		case <-keepAlive:
 			fmt.Fprintf(w, ": ping\n\n")
 			flusher.Flush()
 		}
@@ -363,25 +814,139 @@ func (s *Server) handleStreamableSSE(w http.ResponseWriter, r *http.Request, con
 }
 
 func (s *Server) handleStreamableDelete(w http.ResponseWriter, r *http.Request, conn *gateway.Connection) {
+	if !s.authenticateRequest(w, r, conn) {
+		return
+	}
+
 	// Session termination
 	sessionID := r.Header.Get("mcp-session-id")
 	if sessionID != "" {
 		if sessionVal, ok := s.sessions.Load(sessionID); ok {
 			session := sessionVal.(*Session)
-			session.Close()
-			s.sessions.Delete(sessionID)
+			if session.ConnID != conn.Config.ID || session.lastActive.Load() == 0 {
+				http.Error(w, "Session not found", http.StatusNotFound)
+				return
+			}
+			s.endStreamableSession(session)
 		}
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// generateSessionID creates a short unique session ID
+// messageContext is the context MCP messages of r are handled in. It keeps
+// r's request ID but not its cancellation: results on the SSE transport are
+// delivered on the stream, after the POST carrying the message has finished.
+func messageContext(r *http.Request) context.Context {
+	return context.WithoutCancel(r.Context())
+}
+
+// generateSessionID creates an unguessable session ID. Knowing a session ID
+// is enough to post to it, so it must not be derivable from the time.
 func generateSessionID() string {
-	return fmt.Sprintf("s_%d_%d", time.Now().UnixNano(), time.Now().UnixMicro()%10000)
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// gzipMinBytes is the smallest JSON response worth compressing
+const gzipMinBytes = 1024
+
+// writeJSON writes v as the JSON response, gzip-compressed if it is large
+// and the client accepts gzip. SSE responses are never compressed, as a
+// compressor would hold back events that need to be flushed.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	data = append(data, '\n')
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Encoding")
+	if len(data) < gzipMinBytes || !acceptsGzip(r) {
+		w.Write(data)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	gz.Write(data)
+	gz.Close()
 }
 
-// JSONRPCResponse for direct responses
-type jsonrpcBatchItem struct {
-	ID     interface{}      `json:"id"`
-	Result *mcp.JSONRPCResponse `json:"result,omitempty"`
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding = strings.TrimSpace(coding); coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// isBatch reports whether body is a JSON-RPC batch (an array of messages)
+func isBatch(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+}
+
+// handleBatch runs the messages of a JSON-RPC batch concurrently, at most as
+// many at once as the connection may run tool calls, and returns their
+// responses in request order. Notifications in the batch get no response.
+func (s *Server) handleBatch(ctx context.Context, conn *gateway.Connection, body []byte, notify func(mcp.JSONRPCNotification)) []*mcp.JSONRPCResponse {
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return []*mcp.JSONRPCResponse{{
+			JSONRPC: "2.0",
+			Error:   &mcp.JSONRPCError{Code: mcp.ParseError, Message: "Parse error"},
+		}}
+	}
+	if len(items) == 0 {
+		return []*mcp.JSONRPCResponse{{
+			JSONRPC: "2.0",
+			Error:   &mcp.JSONRPCError{Code: mcp.InvalidRequest, Message: "Empty batch"},
+		}}
+	}
+
+	results := make([]*mcp.JSONRPCResponse, len(items))
+	slots := make(chan struct{}, conn.Limiter.Limit())
+	var wg sync.WaitGroup
+	for i, item := range items {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			var msg struct {
+				ID json.RawMessage `json:"id"`
+			}
+			notification := json.Unmarshal(item, &msg) == nil && msg.ID == nil
+
+			start := time.Now()
+			response := conn.Handler.HandleMessageContext(ctx, item, notify)
+			latency := float64(time.Since(start).Milliseconds())
+			if notification {
+				return
+			}
+			if response != nil {
+				s.gw.RecordRequest(conn.Config.ID, latency, response.Error != nil)
+			}
+			results[i] = response
+		}()
+	}
+	wg.Wait()
+
+	responses := results[:0]
+	for _, response := range results {
+		if response != nil {
+			responses = append(responses, response)
+		}
+	}
+	return responses
 }
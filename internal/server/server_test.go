@@ -0,0 +1,558 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dublyo/mcp-gateway/internal/gateway"
+	"github.com/dublyo/mcp-gateway/internal/mcp"
+	"github.com/dublyo/mcp-gateway/internal/profiles"
+)
+
+const (
+	testDomain = "mcp.example.com"
+	testPepper = "pepper"
+	testAPIKey = "secret-key"
+)
+
+// newTestServer serves one connection, configured by cc on top of defaults,
+// that accepts testAPIKey
+func newTestServer(t *testing.T, cc gateway.ConnectionConfig) (*Server, *gateway.Gateway) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(testPepper + testAPIKey))
+	cc.ID, cc.Slug, cc.Domain, cc.Enabled = "conn-1", "test", testDomain, true
+	cc.APIKeyHash = hex.EncodeToString(sum[:])
+	if cc.Profile == "" {
+		cc.Profile = "time"
+	}
+	gw := gateway.New()
+	gw.ApplyConfig(gateway.GatewayConfig{Pepper: testPepper, Connections: []gateway.ConnectionConfig{cc}})
+	return New(gw), gw
+}
+
+// do sends a request to the test connection, authenticated unless key is empty
+func do(s *Server, method, path, key string, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Host = testDomain
+	if key != "" {
+		r.Header.Set("Authorization", "Bearer "+key)
+	}
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.handleRequest(w, r)
+	return w
+}
+
+const initializeBody = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`
+
+func TestStreamableSessions(t *testing.T) {
+	s, gw := newTestServer(t, gateway.ConnectionConfig{MaxConcurrency: 1})
+	conn := gw.GetConnection(testDomain)
+
+	w := do(s, "POST", "/mcp", testAPIKey, initializeBody, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("initialize: %d %s", w.Code, w.Body)
+	}
+	sessionID := w.Header().Get("mcp-session-id")
+	if sessionID == "" {
+		t.Fatal("initialize returned no session ID")
+	}
+	if gw.CheckConcurrency(conn) {
+		t.Error("session not counted against MaxConcurrency")
+	}
+
+	// The limit applies to Streamable sessions too
+	if w := do(s, "POST", "/mcp", testAPIKey, initializeBody, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("initialize over the limit: %d, want 503", w.Code)
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		sessionID string
+		want      int
+		wantOpen  bool
+	}{
+		{"unauthenticated", "", sessionID, http.StatusUnauthorized, true},
+		{"wrong key", "nope", sessionID, http.StatusUnauthorized, true},
+		{"unknown session", testAPIKey, "unknown", http.StatusNoContent, true},
+		{"authenticated", testAPIKey, sessionID, http.StatusNoContent, false},
+		{"already ended", testAPIKey, sessionID, http.StatusNoContent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(s, "DELETE", "/mcp", tt.key, "", map[string]string{"mcp-session-id": tt.sessionID})
+			if w.Code != tt.want {
+				t.Errorf("DELETE: %d, want %d", w.Code, tt.want)
+			}
+			if _, open := s.sessions.Load(sessionID); open != tt.wantOpen {
+				t.Errorf("session open = %v, want %v", open, tt.wantOpen)
+			}
+			if gw.CheckConcurrency(conn) == tt.wantOpen {
+				t.Errorf("session slot held = %v, want %v", !gw.CheckConcurrency(conn), tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestStreamableGETDeliversMessages(t *testing.T) {
+	s, _ := newTestServer(t, gateway.ConnectionConfig{})
+	w := do(s, "POST", "/mcp", testAPIKey, initializeBody, nil)
+	sessionID := w.Header().Get("mcp-session-id")
+	if sessionID == "" {
+		t.Fatalf("initialize: %d %s", w.Code, w.Body)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRequest))
+	defer srv.Close()
+	get := func(sessionID string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+"/mcp", nil)
+		req.Host = testDomain
+		req.Header.Set("Authorization", "Bearer "+testAPIKey)
+		req.Header.Set("Accept", "text/event-stream")
+		if sessionID != "" {
+			req.Header.Set("mcp-session-id", sessionID)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name      string
+		sessionID string
+		want      int
+	}{
+		{"unknown session", "unknown", http.StatusNotFound},
+		{"no session", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := get(tt.sessionID)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+
+	resp := get(sessionID)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET: %d", resp.StatusCode)
+	}
+	s.notifyToolsChanged([]string{"conn-1"})
+
+	got := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				got <- data
+				return
+			}
+		}
+		close(got)
+	}()
+	select {
+	case data := <-got:
+		if !strings.Contains(data, `"method":"notifications/tools/list_changed"`) {
+			t.Errorf("delivered %q", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued message not delivered")
+	}
+}
+
+// barrierProfile's "wait" tool blocks until want calls run at once, or a
+// second passes, and records the most seen running together
+type barrierProfile struct {
+	want    int32
+	running atomic.Int32
+	maxSeen atomic.Int32
+}
+
+func (p *barrierProfile) ID() string                      { return "barrier" }
+func (p *barrierProfile) RequiredEnv() []profiles.EnvSpec { return nil }
+func (p *barrierProfile) Tools() []profiles.Tool {
+	return []profiles.Tool{{Name: "wait", InputSchema: map[string]interface{}{"type": "object"}}}
+}
+
+func (p *barrierProfile) CallTool(name string, args map[string]interface{}, env map[string]string) (string, error) {
+	n := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		seen := p.maxSeen.Load()
+		if n <= seen || p.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for p.maxSeen.Load() < p.want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return fmt.Sprintf("%v", args["n"]), nil
+}
+
+func TestBatchRunsConcurrently(t *testing.T) {
+	s, gw := newTestServer(t, gateway.ConnectionConfig{MaxToolConcurrency: 3})
+	conn := gw.GetConnection(testDomain)
+	profile := &barrierProfile{want: 3}
+	conn.Handler = mcp.NewHandler(profile, nil)
+	conn.Handler.SetToolLimiter(conn.Limiter)
+
+	var batch []string
+	for i := 1; i <= 6; i++ {
+		batch = append(batch, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"wait","arguments":{"n":%d}}}`, i, i))
+	}
+	batch = append(batch, `{"jsonrpc":"2.0","method":"notifications/initialized"}`, `{"jsonrpc":"2.0","id":"last","method":"ping"}`)
+
+	start := time.Now()
+	w := do(s, "POST", "/mcp", testAPIKey, "["+strings.Join(batch, ",")+"]", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch: %d %s", w.Code, w.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("batch took %s; calls didn't run together", elapsed)
+	}
+	if got := profile.maxSeen.Load(); got != 3 {
+		t.Errorf("%d calls ran at once, want MaxToolConcurrency 3", got)
+	}
+
+	var responses []struct {
+		ID     interface{}        `json:"id"`
+		Result mcp.ToolCallResult `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("%s: %s", err, w.Body)
+	}
+	if len(responses) != 7 {
+		t.Fatalf("%d responses, want 7 (none for the notification)", len(responses))
+	}
+	for i, r := range responses[:6] {
+		if r.ID != float64(i+1) || len(r.Result.Content) != 1 || r.Result.Content[0].Text != fmt.Sprint(i+1) {
+			t.Errorf("response %d: %+v", i, r)
+		}
+	}
+	if responses[6].ID != "last" {
+		t.Errorf("last response id %v", responses[6].ID)
+	}
+
+	// Each request is recorded on its own
+	var requests int64
+	for _, r := range gw.CollectMetrics() {
+		if r.ConnectionID == "conn-1" {
+			requests = r.RequestCount
+		}
+	}
+	if requests != 7 {
+		t.Errorf("recorded %d requests, want 7", requests)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip", true},
+		{"br;q=1.0, gzip;q=0.5", true},
+		{"*", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.000", false},
+		{"identity", false},
+		{"x-gzip", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/mcp", nil)
+		r.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsGzip(r); got != tt.want {
+			t.Errorf("Accept-Encoding %q: %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipResponses(t *testing.T) {
+	s, _ := newTestServer(t, gateway.ConnectionConfig{Profile: "transform"})
+	w := do(s, "POST", "/mcp", testAPIKey, initializeBody, nil)
+	session := map[string]string{"mcp-session-id": w.Header().Get("mcp-session-id")}
+	const listTools = `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+	const ping = `{"jsonrpc":"2.0","id":3,"method":"ping"}`
+
+	plain := do(s, "POST", "/mcp", testAPIKey, listTools, session)
+	if plain.Code != http.StatusOK || plain.Header().Get("Content-Encoding") != "" || plain.Body.Len() < gzipMinBytes {
+		t.Fatalf("uncompressed tools/list: %d %q, %d bytes", plain.Code, plain.Header().Get("Content-Encoding"), plain.Body.Len())
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		encoding string
+		wantGzip bool
+	}{
+		{"large response", listTools, "gzip", true},
+		{"gzip refused", listTools, "gzip;q=0", false},
+		{"small response", ping, "gzip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{"Accept-Encoding": tt.encoding}
+			for k, v := range session {
+				header[k] = v
+			}
+			w := do(s, "POST", "/mcp", testAPIKey, tt.body, header)
+			if w.Code != http.StatusOK {
+				t.Fatalf("%d %s", w.Code, w.Body)
+			}
+			if (w.Header().Get("Content-Encoding") == "gzip") != tt.wantGzip {
+				t.Fatalf("Content-Encoding %q, want gzip %v", w.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+			body := w.Body.Bytes()
+			if tt.wantGzip {
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(body, plain.Body.Bytes()) {
+					t.Errorf("decompressed response differs from the uncompressed one")
+				}
+			}
+			if !json.Valid(body) {
+				t.Errorf("invalid JSON: %.200s", body)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXY_CIDRS", "10.0.0.0/8, 2001:db8::1")
+	s, _ := newTestServer(t, gateway.ConnectionConfig{})
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct", "203.0.113.9:4000", nil, "203.0.113.9"},
+		{"untrusted peer's header ignored", "203.0.113.9:4000", []string{"198.51.100.7"}, "203.0.113.9"},
+		{"trusted proxy", "10.0.0.1:4000", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.1:4000", []string{"198.51.100.7, 10.0.0.2, 10.0.0.3"}, "198.51.100.7"},
+		{"spoofed entries left of the client", "10.0.0.1:4000", []string{"192.0.2.66, 198.51.100.7"}, "198.51.100.7"},
+		{"repeated headers", "10.0.0.1:4000", []string{"192.0.2.66", "198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"garbage stops the walk", "10.0.0.1:4000", []string{"198.51.100.7, junk, 10.0.0.2"}, "10.0.0.2"},
+		{"only proxies", "10.0.0.1:4000", []string{"10.0.0.2"}, "10.0.0.2"},
+		{"no header from proxy", "10.0.0.1:4000", nil, "10.0.0.1"},
+		{"IPv6 proxy", "[2001:db8::1]:4000", []string{"2001:db8::42"}, "2001:db8::42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/mcp", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := s.clientIP(r); got.String() != tt.want {
+				t.Errorf("clientIP = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedClients(t *testing.T) {
+	// httptest requests come from 192.0.2.1
+	tests := []struct {
+		name    string
+		cidrs   string
+		proxies string
+		xff     string
+		key     string
+		want    int
+	}{
+		{"allowed", "192.0.2.0/24", "", "", testAPIKey, http.StatusOK},
+		{"allowed, then authenticated", "192.0.2.0/24", "", "", "", http.StatusUnauthorized},
+		{"denied before auth", "10.0.0.0/8", "", "", "", http.StatusForbidden},
+		{"denied with a valid key", "10.0.0.0/8", "", "", testAPIKey, http.StatusForbidden},
+		{"forwarded header needs a trusted proxy", "10.0.0.0/8", "", "10.1.2.3", testAPIKey, http.StatusForbidden},
+		{"client behind a trusted proxy", "10.0.0.0/8", "192.0.2.1", "10.1.2.3", testAPIKey, http.StatusOK},
+		{"proxy itself isn't the client", "192.0.2.0/24", "192.0.2.1", "10.1.2.3", testAPIKey, http.StatusForbidden},
+		{"invalid list denies all", "192.0.2.0/24, bogus", "", "", testAPIKey, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXY_CIDRS", tt.proxies)
+			s, _ := newTestServer(t, gateway.ConnectionConfig{EnvVars: map[string]string{"ALLOWED_CLIENT_CIDRS": tt.cidrs}})
+			header := map[string]string{}
+			if tt.xff != "" {
+				header["X-Forwarded-For"] = tt.xff
+			}
+			w := do(s, "POST", "/mcp", tt.key, initializeBody, header)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestRequestBodySegments(t *testing.T) {
+	s, _ := newTestServer(t, gateway.ConnectionConfig{})
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRequest))
+	defer srv.Close()
+
+	// Whitespace around the message keeps it valid JSON at any size
+	atLimit := initializeBody + strings.Repeat(" ", maxRequestBody-len(initializeBody))
+	split := func(body string, n int) []string {
+		var segments []string
+		for size := (len(body) + n - 1) / n; len(body) > size; body = body[size:] {
+			segments = append(segments, body[:size])
+		}
+		return append(segments, body)
+	}
+
+	tests := []struct {
+		name     string
+		segments []string
+		chunked  bool
+		want     int
+	}{
+		{"one segment", []string{initializeBody}, false, http.StatusOK},
+		{"split mid-token", split(initializeBody, 7), false, http.StatusOK},
+		{"byte at a time", split(initializeBody, len(initializeBody)), false, http.StatusOK},
+		{"chunked", split(initializeBody, 5), true, http.StatusOK},
+		{"at the limit", split(atLimit, 4), false, http.StatusOK},
+		{"over the limit", split(atLimit+" ", 4), false, http.StatusRequestEntityTooLarge},
+		{"over the limit, chunked", split(atLimit+" ", 4), true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := net.Dial("tcp", srv.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			length := 0
+			for _, seg := range tt.segments {
+				length += len(seg)
+			}
+			framing := fmt.Sprintf("Content-Length: %d", length)
+			if tt.chunked {
+				framing = "Transfer-Encoding: chunked"
+			}
+			go func() {
+				fmt.Fprintf(c, "POST /mcp HTTP/1.1\r\nHost: %s\r\nAuthorization: Bearer %s\r\nContent-Type: application/json\r\n%s\r\n\r\n",
+					testDomain, testAPIKey, framing)
+				for _, seg := range tt.segments {
+					time.Sleep(time.Millisecond)
+					if tt.chunked {
+						seg = fmt.Sprintf("%x\r\n%s\r\n", len(seg), seg)
+					}
+					if _, err := io.WriteString(c, seg); err != nil {
+						return // the server answered without reading everything
+					}
+				}
+				if tt.chunked {
+					io.WriteString(c, "0\r\n\r\n")
+				}
+			}()
+
+			resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			out, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.want, out)
+			}
+			if tt.want == http.StatusOK && !bytes.Contains(out, []byte(`"result"`)) {
+				t.Errorf("response: %s", out)
+			}
+		})
+	}
+}
+
+func TestInitializeSessionSlots(t *testing.T) {
+	s, gw := newTestServer(t, gateway.ConnectionConfig{MaxConcurrency: 2})
+	conn := gw.GetConnection(testDomain)
+	sessions := func() int {
+		n := 0
+		s.sessions.Range(func(_, v interface{}) bool {
+			if v.(*Session).ConnID == conn.Config.ID {
+				n++
+			}
+			return true
+		})
+		return n
+	}
+	badVersion := strings.Replace(initializeBody, "2025-03-26", "1999-01-01", 1)
+	notification := strings.Replace(initializeBody, `"id":1,`, "", 1)
+
+	var current string // the session the client re-initializes in
+	steps := []struct {
+		name        string
+		body        string
+		reinit      bool // send current's ID along
+		times       int
+		want        int
+		wantSession bool
+		wantOpen    int // sessions open afterwards
+	}{
+		{"unsupported version", badVersion, false, 5, http.StatusOK, false, 0},
+		{"initialize as a notification", notification, false, 3, http.StatusAccepted, false, 0},
+		{"initialized", initializeBody, false, 1, http.StatusOK, true, 1},
+		{"initialized again in the session", initializeBody, true, 10, http.StatusOK, true, 1},
+		{"failed again in the session", badVersion, true, 1, http.StatusOK, false, 0},
+		{"second client", initializeBody, false, 2, http.StatusOK, true, 2},
+		{"over the limit", initializeBody, false, 1, http.StatusServiceUnavailable, false, 2},
+	}
+	for _, st := range steps {
+		for i := 0; i < st.times; i++ {
+			header := map[string]string{}
+			if st.reinit {
+				header["mcp-session-id"] = current
+			}
+			w := do(s, "POST", "/mcp", testAPIKey, st.body, header)
+			if w.Code != st.want {
+				t.Fatalf("%s #%d: %d %s, want %d", st.name, i+1, w.Code, w.Body, st.want)
+			}
+			id := w.Header().Get("mcp-session-id")
+			if (id != "") != st.wantSession {
+				t.Fatalf("%s #%d: session ID %q", st.name, i+1, id)
+			}
+			if _, open := s.sessions.Load(current); st.reinit && open {
+				t.Errorf("%s #%d: replaced session still open", st.name, i+1)
+			}
+			if id != "" {
+				current = id
+			}
+		}
+		if got := sessions(); got != st.wantOpen {
+			t.Errorf("%s: %d sessions open, want %d", st.name, got, st.wantOpen)
+		}
+		if free := gw.CheckConcurrency(conn); free != (st.wantOpen < 2) {
+			t.Errorf("%s: slot free = %v with %d sessions open", st.name, free, st.wantOpen)
+		}
+	}
+}